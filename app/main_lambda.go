@@ -0,0 +1,49 @@
+//go:build lambda
+
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
+
+	"datadog-golang-example/internal/app"
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/logging"
+)
+
+// main serves API Gateway proxy events with the same router as the HTTP
+// server. Spans and metrics go to the Datadog Lambda Extension, which
+// listens on the default agent ports inside the execution environment.
+// Build the provided.al2023 bootstrap with:
+//
+//	GOOS=linux go build -tags lambda -o bootstrap ./app
+func main() {
+	cfg := config.Load()
+	logging.Setup(cfg.Log)
+
+	a, err := app.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to build application: %v", err)
+	}
+
+	// Components start once per execution environment, on cold start
+	if err := a.Start(context.Background()); err != nil {
+		log.Fatalf("Application error: %v", err)
+	}
+
+	adapter := httpadapter.New(a.Handler())
+	handler := func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		defer a.Flush()
+		return adapter.ProxyWithContext(ctx, req)
+	}
+
+	lambda.StartWithOptions(handler, lambda.WithEnableSIGTERM(func() {
+		if err := a.Stop(); err != nil {
+			log.Printf("Shutdown error: %v", err)
+		}
+	}))
+}
@@ -0,0 +1,172 @@
+// Package client is a Go client for the users API. Requests are traced
+// and carry the trace propagation headers, so calls from other services
+// join their traces. Idempotent requests are retried on transient
+// failures, and error responses are returned as *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	httptrace "github.com/DataDog/dd-trace-go/contrib/net/http/v2"
+)
+
+// Options configures a Client
+type Options struct {
+	// BaseURL is the root of the API, such as http://localhost:8080
+	BaseURL string
+	// APIKey is sent as X-API-Key when set
+	APIKey string
+	// HTTPClient sends the requests; it is wrapped for tracing. The
+	// default has a 10s timeout.
+	HTTPClient *http.Client
+	// Attempts is how many times an idempotent request is tried, 3 by
+	// default, waiting Backoff, 100ms by default, doubled each time
+	Attempts int
+	Backoff  time.Duration
+}
+
+// Client calls the users API
+type Client struct {
+	base     *url.URL
+	apiKey   string
+	http     *http.Client
+	attempts int
+	backoff  time.Duration
+}
+
+// New creates a Client for opts
+func New(opts Options) (*Client, error) {
+	base, err := url.Parse(strings.TrimSuffix(opts.BaseURL, "/"))
+	if err != nil || base.Scheme == "" || base.Host == "" {
+		return nil, fmt.Errorf("client: invalid base URL %q", opts.BaseURL)
+	}
+	hc := opts.HTTPClient
+	if hc == nil {
+		hc = &http.Client{Timeout: 10 * time.Second}
+	}
+	c := &Client{
+		base:     base,
+		apiKey:   opts.APIKey,
+		http:     httptrace.WrapClient(hc),
+		attempts: opts.Attempts,
+		backoff:  opts.Backoff,
+	}
+	if c.attempts <= 0 {
+		c.attempts = 3
+	}
+	if c.backoff <= 0 {
+		c.backoff = 100 * time.Millisecond
+	}
+	return c, nil
+}
+
+// do sends a request for path and query with in as JSON body, and decodes
+// a successful response into out. Both may be nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("client: encode request: %w", err)
+		}
+	}
+	u := c.base.JoinPath(path)
+	u.RawQuery = query.Encode()
+
+	attempts := 1
+	if idempotent(method) {
+		attempts = c.attempts
+	}
+	wait := c.backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, u.String(), body)
+		if err == nil && (attempt == attempts || !retryable(resp.StatusCode)) {
+			return decode(resp, out)
+		}
+		if err != nil && (attempt == attempts || ctx.Err() != nil) {
+			return fmt.Errorf("client: %s %s: %w", method, path, err)
+		}
+		if err == nil {
+			wait = max(wait, retryAfter(resp))
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("client: %s %s: %w", method, path, ctx.Err())
+		}
+		wait *= 2
+	}
+}
+
+// send sends one attempt of a request
+func (c *Client) send(ctx context.Context, method, u string, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	return c.http.Do(req)
+}
+
+// decode reads a response into out, or into an *Error for a failure
+func decode(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return newError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("client: decode response: %w", err)
+	}
+	return nil
+}
+
+// idempotent reports whether a request can be sent again safely
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a status is worth another attempt
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the wait asked for by a Retry-After in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// User is a user of the API
+type User struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	Age       int       `json:"age"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int64     `json:"version"`
+}
+
+// CreateUserRequest is the body of CreateUser
+type CreateUserRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Age   int    `json:"age"`
+}
+
+// ListOptions narrows and orders ListUsers
+type ListOptions struct {
+	// Filters maps fields, such as "tag", to the value they must equal
+	Filters map[string]string
+	// Sort is the field to sort by, prefixed with "-" for descending order
+	Sort string
+	// PageSize is the number of users fetched per request, 50 by default
+	// and at most 100
+	PageSize int
+}
+
+// usersPage is a page of GET /users
+type usersPage struct {
+	Users      []User `json:"users"`
+	NextOffset *int   `json:"next_offset"`
+}
+
+// CreateUser creates a user. It is not retried, since a retry could
+// create the user twice.
+func (c *Client) CreateUser(ctx context.Context, req CreateUserRequest) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodPost, "/api/v1/users", nil, req, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetUser returns the user with the given ID
+func (c *Client) GetUser(ctx context.Context, id string) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodGet, "/api/v1/users/"+url.PathEscape(id), nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteUser deletes the user with the given ID
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/v1/users/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// ListUsers iterates over the users matching opts, fetching them a page
+// at a time as the loop advances. Iteration stops at the first error,
+// which is yielded last.
+func (c *Client) ListUsers(ctx context.Context, opts ListOptions) iter.Seq2[User, error] {
+	return func(yield func(User, error) bool) {
+		query := url.Values{}
+		for field, v := range opts.Filters {
+			query.Set(field, v)
+		}
+		if opts.Sort != "" {
+			query.Set("sort", opts.Sort)
+		}
+		size := min(opts.PageSize, 100)
+		if size <= 0 {
+			size = 50
+		}
+		query.Set("limit", strconv.Itoa(size))
+
+		for offset := 0; ; {
+			query.Set("offset", strconv.Itoa(offset))
+			var page usersPage
+			if err := c.do(ctx, http.MethodGet, "/api/v1/users", query, nil, &page); err != nil {
+				yield(User{}, err)
+				return
+			}
+			for _, u := range page.Users {
+				if !yield(u, nil) {
+					return
+				}
+			}
+			if page.NextOffset == nil {
+				return
+			}
+			offset = *page.NextOffset
+		}
+	}
+}
@@ -0,0 +1,850 @@
+// Package app wires the application together: config, telemetry,
+// repositories, services, handlers and the HTTP server.
+package app
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	sqltrace "github.com/DataDog/dd-trace-go/contrib/database/sql/v2"
+	mongotrace "github.com/DataDog/dd-trace-go/contrib/go.mongodb.org/mongo-driver/v2/mongo"
+	redistrace "github.com/DataDog/dd-trace-go/contrib/redis/go-redis.v9/v2"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+
+	"datadog-golang-example/internal/auth"
+	"datadog-golang-example/internal/baggage"
+	"datadog-golang-example/internal/brownout"
+	"datadog-golang-example/internal/chaos"
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/dbops"
+	"datadog-golang-example/internal/dedup"
+	"datadog-golang-example/internal/emailcheck"
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/export"
+	"datadog-golang-example/internal/features"
+	"datadog-golang-example/internal/fieldcrypt"
+	"datadog-golang-example/internal/geoip"
+	"datadog-golang-example/internal/grpcserver"
+	httpapi "datadog-golang-example/internal/http"
+	"datadog-golang-example/internal/httpclient"
+	"datadog-golang-example/internal/ids"
+	"datadog-golang-example/internal/logging"
+	"datadog-golang-example/internal/mail"
+	"datadog-golang-example/internal/migrate"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/preflight"
+	"datadog-golang-example/internal/profile"
+	"datadog-golang-example/internal/quota"
+	"datadog-golang-example/internal/ratelimit"
+	"datadog-golang-example/internal/readonly"
+	"datadog-golang-example/internal/reload"
+	"datadog-golang-example/internal/repo"
+	"datadog-golang-example/internal/service"
+	"datadog-golang-example/internal/stats"
+	"datadog-golang-example/internal/storage"
+	"datadog-golang-example/internal/telemetry"
+	"datadog-golang-example/internal/worker"
+)
+
+// App is a fully wired application ready to run
+type App struct {
+	cfg       config.Config
+	lifecycle Lifecycle
+	server    *http.Server
+	health    *httpapi.HealthHandler
+	grpc      *grpcserver.Server
+	metrics   *statsd.Client
+	// The components the shutdown report accounts for
+	requests inFlight
+	tracer   *telemetry.Tracer
+	pool     *worker.Pool
+	bus      events.Bus
+}
+
+// New builds the application from its configuration. Nothing is started
+// until Run is called.
+func New(cfg config.Config) (*App, error) {
+	a := &App{cfg: cfg}
+
+	// Telemetry
+	metrics, agent, tr, err := newTelemetry(cfg.Datadog, &a.lifecycle)
+	if err != nil {
+		return nil, err
+	}
+	a.metrics, a.tracer = metrics, tr
+
+	// Authentication
+	keys, err := auth.ParseKeys(cfg.Auth.APIKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// Repositories
+	dataKeys, err := newKeyring(cfg.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	// Pool and runtime saturation
+	reporter := stats.NewReporter(cfg.Datadog.StatsInterval, metrics)
+	reporter.Add(&stats.Runtime{})
+	mongoPool := stats.NewMongoPool("mongodb")
+	reporter.Add(mongoPool)
+
+	client, err := newMongoClient(cfg.Mongo, metrics, mongoPool)
+	if err != nil {
+		return nil, err
+	}
+	a.lifecycle.Append(mongoHook("mongodb", client, cfg.Mongo))
+	store, err := newUserStore(cfg, client, dataKeys, &a.lifecycle)
+	if err != nil {
+		return nil, err
+	}
+
+	// Background work, such as shadow writes
+	pool := worker.NewPool(cfg.Worker, metrics)
+
+	var users repo.UserRepository = store
+	if cfg.Shadow.Enabled {
+		shadowPool := stats.NewMongoPool("shadow")
+		reporter.Add(shadowPool)
+		shadowClient, err := newMongoClient(cfg.Shadow.Mongo, metrics, shadowPool)
+		if err != nil {
+			return nil, err
+		}
+		a.lifecycle.Append(mongoHook("shadow mongodb", shadowClient, cfg.Shadow.Mongo))
+		shadow := repo.NewMongoUserRepository(shadowClient.Database(cfg.Shadow.Mongo.Database).Collection("users"), repo.MongoOptions{})
+		a.lifecycle.Append(Hook{Name: "shadow indexes", OnStart: shadow.EnsureIndexes})
+		users = repo.NewShadowUserRepository(store, shadow, pool, metrics)
+	}
+	indexes := repo.NewIndexManager(client.Database(cfg.Mongo.Database), MongoIndexes(cfg), metrics)
+	a.lifecycle.Append(Hook{Name: "indexes", OnStart: func(ctx context.Context) error {
+		_, err := indexes.Sync(ctx)
+		return err
+	}})
+
+	var rdb redis.UniversalClient
+	var quotas *quota.Tracker
+	var dedupes *dedup.Store
+	if cfg.Redis.Addr != "" {
+		rdb = newRedisClient(cfg.Redis)
+		a.lifecycle.Append(redisHook(rdb))
+		reporter.Add(stats.NewRedisPool(rdb))
+		quotas = quota.NewTracker(rdb, cfg.Quota)
+		if cfg.Dedup.Window > 0 {
+			dedupes = dedup.NewStore(rdb, cfg.Dedup)
+		}
+	}
+
+	// Fault injection for demos, off unless enabled
+	chaosSettings, err := chaos.ParseSettings(cfg.Chaos.Enabled, cfg.Chaos.Rules)
+	if err != nil {
+		return nil, err
+	}
+	injector := chaos.NewInjector(chaosSettings)
+
+	// Settings that can change while serving
+	limits := ratelimit.NewPolicy(cfg.RateLimit)
+	flags := features.New()
+	readOnly := readonly.New(cfg.HTTP.ReadOnly)
+	if cfg.HTTP.ReadOnly {
+		log.Printf("WARNING: Serving the API read-only: the routes that write are refused")
+	}
+	if cfg.Reload.File != "" {
+		reloader := reload.New(cfg.Reload, reload.Targets{Tracer: tr, RateLimits: limits, Features: flags, ReadOnly: readOnly})
+		a.lifecycle.Append(Hook{Name: "settings reloader", OnStart: reloader.Start, OnStop: reloader.Stop})
+	}
+
+	mailer, err := newMailer(cfg.Mail)
+	if err != nil {
+		return nil, err
+	}
+
+	var geo httpapi.CountryLookup
+	if cfg.HTTP.GeoIPDatabase != "" {
+		db, err := geoip.Open(cfg.HTTP.GeoIPDatabase)
+		if err != nil {
+			return nil, err
+		}
+		a.lifecycle.Append(Hook{Name: "geoip", OnStop: func(context.Context) error { return db.Close() }})
+		geo = db
+	}
+
+	// Started after the stores so it stops before them
+	a.lifecycle.Append(Hook{Name: "worker pool", OnStart: pool.Start, OnStop: pool.Stop})
+	a.pool = pool
+	if cfg.Datadog.StatsInterval > 0 {
+		a.lifecycle.Append(Hook{Name: "stats reporter", Run: reporter.Run})
+	}
+
+	// User change events, off unless a bus is selected
+	bus, err := events.New(cfg.Events)
+	if err != nil {
+		return nil, err
+	}
+	if bus != nil {
+		a.bus = bus
+		a.lifecycle.Append(Hook{Name: "event bus", OnStop: bus.Close})
+	}
+
+	if cfg.Preflight.Enabled {
+		suite := newPreflight(cfg, client, store)
+		a.lifecycle.Append(Hook{Name: "preflight", OnStart: func(ctx context.Context) error {
+			_, err := suite.Run(ctx)
+			return err
+		}})
+	}
+
+	// Services
+	var emails service.EmailVerifier
+	if cfg.EmailCheck.URL != "" {
+		emails = emailcheck.NewVerifier(cfg.EmailCheck, httpclient.New(cfg.Client.Timeout), rdb)
+	}
+	erasures := repo.NewMongoErasureLog(client.Database(cfg.Mongo.Database).Collection("erasures"))
+	marks := repo.NewMongoWatermarks(client.Database(cfg.Mongo.Database).Collection("watermarks"))
+	generator, err := ids.New(cfg.IDs)
+	if err != nil {
+		return nil, err
+	}
+	userService := service.NewUserService(users, generator, cfg.Suggest, pool, mailer, emails, erasures, marks, bus)
+	activityLog := repo.NewMongoActivityLog(client.Database(cfg.Mongo.Database).Collection("activity"))
+	responses := httpapi.NewResponseCache(cfg.Cache, metrics)
+	var activityHandler *httpapi.ActivityHandler
+	var history httpapi.UserHistory
+	if bus != nil {
+		// Writes served by other instances also invalidate local caches
+		for _, h := range []events.Handler{userService.HandleEvent, responses.HandleEvent} {
+			if err := bus.Subscribe("user.>", h); err != nil {
+				return nil, err
+			}
+		}
+		// The activity feed is the read model of every event
+		activity := service.NewActivityService(activityLog)
+		if err := bus.Subscribe(">", activity.HandleEvent); err != nil {
+			return nil, err
+		}
+		activityHandler = httpapi.NewActivityHandler(activity)
+		// User lists are marked with the changes of the feed
+		history = service.NewHistoryService(userService, activityLog, cfg.Events.HistoryWindow)
+	}
+
+	// Exports run in the background and stop before the stores they read
+	sink, err := export.New(context.Background(), cfg.Export)
+	if err != nil {
+		return nil, err
+	}
+	exports := service.NewExportService(userService, sink, cfg.Export.Retain)
+	a.lifecycle.Append(Hook{Name: "exports", OnStop: exports.Stop})
+
+	// Imports upsert into the primary only, without shadow writes
+	imports := service.NewImportService(userService, store, httpclient.New(cfg.Import.DownloadTimeout), cfg.Import)
+
+	// Signed audit trail, off without a signing key. The audit entries stop
+	// being appended before MongoDB disconnects.
+	var auditHandler *httpapi.AuditHandler
+	if cfg.Audit.SigningKey != "" {
+		key, err := base64.StdEncoding.DecodeString(cfg.Audit.SigningKey)
+		if err != nil || len(key) < 32 {
+			return nil, errors.New("AUDIT_SIGNING_KEY must be a base64 key of at least 32 bytes")
+		}
+		trail := service.NewAuditTrail(repo.NewMongoAuditLog(client.Database(cfg.Mongo.Database).Collection("audit")), key)
+		logging.SetAuditSink(trail)
+		a.lifecycle.Append(Hook{Name: "audit trail", OnStop: func(context.Context) error {
+			logging.SetAuditSink(nil)
+			return nil
+		}})
+		auditHandler = httpapi.NewAuditHandler(trail)
+	}
+
+	// Attachments, off unless a bucket is set
+	var attachmentHandler *httpapi.AttachmentHandler
+	attachments := repo.NewMongoAttachmentRepository(client.Database(cfg.Mongo.Database).Collection("attachments"))
+	if cfg.Storage.Bucket != "" {
+		blobs, err := storage.NewS3Store(context.Background(), cfg.Storage)
+		if err != nil {
+			return nil, err
+		}
+		attachmentHandler = httpapi.NewAttachmentHandler(service.NewAttachmentService(attachments, blobs, userService, store.counters))
+		if cfg.Counters.ReconcileInterval > 0 {
+			reconciler := service.NewCounterReconciler(store.counters, marks, metrics, cfg.Counters.ReconcileInterval)
+			a.lifecycle.Append(Hook{Name: "counter reconciler", Run: reconciler.Run})
+		}
+	}
+
+	// Weekly digest of the new users, off without recipients
+	if len(cfg.Digest.Recipients) > 0 && cfg.Digest.Period > 0 {
+		digest := service.NewDigestService(store.signups, mailer, cfg.Digest)
+		a.lifecycle.Append(Hook{Name: "digest", Run: digest.Run})
+	}
+
+	// User statistics, served from a snapshot refreshed in the background
+	userStats := service.NewStatsService(store.stats, cfg.UserStats)
+	if cfg.UserStats.RefreshInterval > 0 {
+		a.lifecycle.Append(Hook{Name: "user stats refresher", Run: userStats.Run})
+	}
+
+	// Merges move the records of the merged user along with it, in a
+	// transaction of MongoDB only
+	var mergeHandler *httpapi.MergeHandler
+	if store.tx != nil {
+		mergeHandler = httpapi.NewMergeHandler(service.NewMergeService(userService, store.tx, map[string]service.Reassigner{
+			"activity":    activityLog,
+			"attachments": attachments,
+		}))
+	}
+
+	orgs := repo.NewMongoOrgRepository(client.Database(cfg.Mongo.Database).Collection("organizations"))
+	orgService := service.NewOrgService(orgs, userService, generator)
+
+	// Handlers
+	userHandler := httpapi.NewUserHandler(userService, history)
+	var debugHandler *httpapi.DebugHandler
+	if cfg.Debug.Enabled {
+		debugHandler = httpapi.NewDebugHandler(store, httpclient.New(cfg.Client.Timeout), cfg.Debug.DownstreamURL)
+	}
+	var profileHandler *httpapi.ProfileHandler
+	if cfg.Profiles.Enabled {
+		profileHandler = httpapi.NewProfileHandler(profile.NewFetcher(cfg.Profiles, httpclient.New(cfg.Profiles.Timeout)), cfg.Profiles)
+	}
+
+	readiness := []httpapi.ReadinessCheck{
+		{Name: "mongodb", Critical: true, Check: func(ctx context.Context) error {
+			return client.Ping(ctx, nil)
+		}},
+		{Name: "datadog_agent", Check: func(context.Context) error {
+			return agent.Err()
+		}},
+	}
+	if store.mysql != nil {
+		readiness = append(readiness, httpapi.ReadinessCheck{Name: "mysql", Critical: true, Check: store.mysql.PingContext})
+	}
+	a.health = httpapi.NewHealthHandler(readiness...)
+
+	// Load shedding while MongoDB is degraded, off without a check interval
+	var degradation httpapi.DegradationSignal
+	if cfg.Brownout.CheckInterval > 0 {
+		watchdog := brownout.NewWatchdog(func(ctx context.Context) error {
+			return client.Ping(ctx, nil)
+		}, cfg.Brownout, metrics)
+		a.lifecycle.Append(Hook{Name: "brownout watchdog", Run: watchdog.Run})
+		degradation = watchdog
+	}
+
+	// Self-diagnostics, also logged as a banner once every component started
+	inject, extract := telemetry.PropagationStyles()
+	diagnostics := service.NewDiagnosticsService(model.Diagnostics{
+		Service: cfg.Datadog.Service,
+		Env:     cfg.Datadog.Env,
+		Version: cfg.Datadog.Version,
+		Features: map[string]bool{
+			"redis":            rdb != nil,
+			"quotas":           quotas != nil,
+			"dedup":            dedupes != nil,
+			"shadow_writes":    cfg.Shadow.Enabled,
+			"field_encryption": dataKeys != nil,
+			"events":           bus != nil,
+			"audit_trail":      auditHandler != nil,
+			"attachments":      attachmentHandler != nil,
+			"merges":           mergeHandler != nil,
+			"digest":           len(cfg.Digest.Recipients) > 0 && cfg.Digest.Period > 0,
+			"email_check":      emails != nil,
+			"smtp":             cfg.Mail.SMTPAddr != "",
+			"geoip":            geo != nil,
+			"brownout":         degradation != nil,
+			"chaos":            cfg.Chaos.Enabled,
+			"settings_reload":  cfg.Reload.File != "",
+			"remote_config":    cfg.Datadog.RemoteConfig,
+			"preflight":        cfg.Preflight.Enabled,
+			"grpc":             cfg.GRPC.Addr != "",
+			"profiles":         cfg.Profiles.Enabled,
+			"debug_routes":     cfg.Debug.Enabled,
+			"pprof":            cfg.Debug.Pprof,
+		},
+		Tracer: model.TracerStatus{
+			AgentURL:  cfg.Datadog.TraceAgentURL,
+			DevExport: cfg.Datadog.DevExport,
+			Inject:    inject,
+			Extract:   extract,
+		},
+		Profiler: model.ProfilerStatus{Reason: "the service does not start the Datadog profiler"},
+		MongoDB:  model.MongoStatus{Database: cfg.Mongo.Database, ReadPreference: cmp.Or(cfg.Mongo.ReadPreference, "primary")},
+	}, flags, tr, agent, func(ctx context.Context) (repo.Topology, error) {
+		return repo.DescribeTopology(ctx, client)
+	}, indexes, migrate.New(client.Database(cfg.Mongo.Database), migrate.Migrations))
+
+	// Servers
+	if cfg.GRPC.Addr != "" {
+		a.grpc = grpcserver.New(cfg.GRPC, a.health)
+		a.lifecycle.Append(Hook{Name: "grpc server", OnStart: a.grpc.Start, Run: a.grpc.Serve, OnStop: a.grpc.Stop})
+	}
+	// Gin reads its mode when the router is built
+	switch cfg.HTTP.GinMode {
+	case gin.ReleaseMode, gin.DebugMode, gin.TestMode:
+		gin.SetMode(cfg.HTTP.GinMode)
+	default:
+		return nil, fmt.Errorf("unknown GIN_MODE %q, want release, debug or test", cfg.HTTP.GinMode)
+	}
+	a.server = &http.Server{
+		Addr:           cfg.HTTP.Addr,
+		ReadTimeout:    cfg.HTTP.ReadTimeout,
+		WriteTimeout:   cfg.HTTP.WriteTimeout,
+		IdleTimeout:    cfg.HTTP.IdleTimeout,
+		MaxHeaderBytes: cfg.HTTP.MaxHeaderBytes,
+		Handler: a.requests.wrap(httpapi.NewRouter(httpapi.RouterConfig{
+			Service:        cfg.Datadog.Service,
+			Version:        cfg.Datadog.Version,
+			Users:          userHandler,
+			Orgs:           httpapi.NewOrgHandler(orgService),
+			Merges:         mergeHandler,
+			Attachments:    attachmentHandler,
+			Activity:       activityHandler,
+			Sessions:       repo.NewCausalSessions(client),
+			CausalRoutes:   cfg.Mongo.CausalRoutes,
+			Views:          httpapi.NewViewHandler(userService),
+			UI:             httpapi.NewUIHandler(cfg.RUM, cfg.Datadog),
+			Quota:          httpapi.NewQuotaHandler(quotas),
+			Chaos:          httpapi.NewChaosHandler(injector),
+			Exports:        httpapi.NewExportHandler(exports),
+			Imports:        httpapi.NewImportHandler(imports),
+			Keys:           keys,
+			RateLimit:      limits,
+			SLO:            cfg.SLO,
+			Quotas:         quotas,
+			Dedup:          dedupes,
+			Baggage:        baggage.New(cfg.Baggage),
+			DBBudget:       cfg.DBBudget,
+			Brownout:       cfg.Brownout,
+			Degradation:    degradation,
+			Injector:       injector,
+			Metrics:        metrics,
+			Debug:          debugHandler,
+			Profiles:       profileHandler,
+			Audit:          auditHandler,
+			Diagnostics:    httpapi.NewDiagnosticsHandler(diagnostics),
+			Stats:          httpapi.NewStatsHandler(userStats),
+			ReadOnly:       readOnly,
+			ReadOnlySwitch: httpapi.NewReadOnlyHandler(readOnly),
+			Pprof:          cfg.Debug.Pprof,
+			TrustedProxies: cfg.HTTP.TrustedProxies,
+			Middleware:     cfg.HTTP.Middleware,
+			APIMiddleware:  cfg.HTTP.APIMiddleware,
+			CORSOrigins:    cfg.HTTP.CORSOrigins,
+			GeoIP:          geo,
+			Cache:          responses,
+			Health:         a.health,
+		})),
+	}
+	a.lifecycle.Append(Hook{Name: "banner", OnStart: func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, bannerTimeout)
+		defer cancel()
+		logBanner(diagnostics.Diagnose(ctx))
+		return nil
+	}})
+	// Registered last so it stops first, before the components it uses
+	a.lifecycle.Append(Hook{Name: "http server", Run: a.serveHTTP, OnStop: a.server.Shutdown})
+	return a, nil
+}
+
+// Run starts every component and serves HTTP and gRPC until ctx is
+// cancelled or a server fails, then stops the components in reverse
+// order. The shutdown is traced as an app.shutdown span giving its reason.
+func (a *App) Run(ctx context.Context) error {
+	if err := a.Start(ctx); err != nil {
+		return err
+	}
+
+	runErr := a.lifecycle.Run(ctx)
+	reason := "signal"
+	if runErr != nil {
+		reason = "failure"
+		log.Printf("Shutting down: %v", runErr)
+	}
+	span, spanCtx := tracer.StartSpanFromContext(context.Background(), "app.shutdown",
+		tracer.ResourceName(reason), tracer.Tag("shutdown.reason", reason))
+	if runErr != nil {
+		span.SetTag(ext.Error, runErr)
+	} else {
+		a.drain()
+	}
+
+	if err := a.stop(spanCtx); err != nil && runErr == nil {
+		runErr = err
+	}
+	return runErr
+}
+
+// serveHTTP serves HTTP until the server is shut down
+func (a *App) serveHTTP(context.Context) error {
+	log.Printf("Server running on %s", a.cfg.HTTP.Addr)
+	if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Start starts every component without serving HTTP, for runtimes such
+// as AWS Lambda that deliver requests to Handler themselves. On failure
+// the components already started are stopped again.
+func (a *App) Start(ctx context.Context) error {
+	if err := a.lifecycle.Start(ctx); err != nil {
+		a.stop(context.Background())
+		return err
+	}
+	return nil
+}
+
+// Stop stops the components started by Start
+func (a *App) Stop() error {
+	return a.stop(context.Background())
+}
+
+// Handler returns the HTTP handler serving every route
+func (a *App) Handler() http.Handler {
+	return a.server.Handler
+}
+
+// Flush sends buffered spans and metrics right away. A Lambda execution
+// environment is frozen between invocations, so it is called after each.
+func (a *App) Flush() {
+	tracer.Flush()
+	if err := a.metrics.Flush(); err != nil {
+		log.Printf("Failed to flush metrics: %v", err)
+	}
+}
+
+// drain fails readiness and keeps serving for the drain delay, so load
+// balancers stop sending new requests before the server shuts down
+func (a *App) drain() {
+	if a.cfg.HTTP.DrainDelay <= 0 {
+		return
+	}
+	log.Printf("Draining: failing readiness for %s", a.cfg.HTTP.DrainDelay)
+	a.health.Drain()
+	if a.grpc != nil {
+		a.grpc.Drain()
+	}
+	time.Sleep(a.cfg.HTTP.DrainDelay)
+}
+
+// stop runs the stop hooks, from the servers to the tracer and the
+// database connections, within the shutdown timeout, then logs the
+// shutdown report. ctx carries the shutdown span, if any.
+func (a *App) stop(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	before, start := a.shutdownCounts(), time.Now()
+	err := a.lifecycle.Stop(ctx)
+	logShutdownReport(before, a.shutdownCounts(), time.Since(start), err)
+	return err
+}
+
+// newTelemetry registers the DogStatsD client, the agent monitor and the
+// tracer on lc, in that order, the tracer being watched for its remote
+// settings
+func newTelemetry(cfg config.DatadogConfig, lc *Lifecycle) (*statsd.Client, *telemetry.AgentMonitor, *telemetry.Tracer, error) {
+	if err := telemetry.CheckTransport(cfg); err != nil {
+		return nil, nil, nil, err
+	}
+	metrics, err := telemetry.NewStatsd(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	lc.Append(Hook{Name: "dogstatsd", OnStop: func(context.Context) error { return metrics.Close() }})
+	agent := telemetry.NewAgentMonitor(cfg, metrics)
+	lc.Append(Hook{Name: "agent monitor", OnStart: agent.Start, OnStop: agent.Stop})
+	tr := telemetry.NewTracer(cfg, agent)
+	if cfg.RemoteConfig {
+		if _, err := telemetry.NewRemoteConfigWatcher(cfg, tr); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	lc.Append(Hook{Name: "tracer", OnStart: tr.Start, OnStop: tr.Stop, StopsTracer: true})
+	return metrics, agent, tr, nil
+}
+
+// newMongoClient creates a traced MongoDB client whose slow commands are
+// explained and primary changes counted in metrics, and whose pool events
+// feed pool unless it is nil. The driver connects lazily, so the connection is only
+// verified by the lifecycle hook.
+func newMongoClient(cfg config.MongoConfig, metrics statsd.ClientInterface, pool *stats.MongoPool) (*mongo.Client, error) {
+	monitor := dbops.Monitor(mongotrace.NewMonitor())
+	var slow *repo.SlowQueryMonitor
+	if cfg.SlowQueryThreshold > 0 {
+		slow = repo.NewSlowQueryMonitor(cfg.SlowQueryThreshold, metrics, monitor)
+		monitor = slow.Monitor()
+	}
+	opts := options.Client().
+		ApplyURI(cfg.URI).
+		SetMonitor(monitor).
+		SetServerMonitor(repo.NewFailoverMonitor(cfg.Database, metrics).Monitor())
+	if cfg.ReadPreference != "" && cfg.ReadPreference != "primary" {
+		mode, err := readpref.ModeFromString(cfg.ReadPreference)
+		if err != nil {
+			return nil, fmt.Errorf("parse MongoDB read preference: %w", err)
+		}
+		pref, err := readpref.New(mode)
+		if err != nil {
+			return nil, fmt.Errorf("parse MongoDB read preference: %w", err)
+		}
+		// Causal sessions only read their writes from secondaries with
+		// majority concerns
+		opts.SetReadPreference(pref).
+			SetReadConcern(readconcern.Majority()).
+			SetWriteConcern(writeconcern.Majority())
+	}
+	if pool != nil {
+		opts.SetPoolMonitor(pool.Monitor())
+	}
+	client, err := mongo.Connect(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	if slow != nil {
+		slow.SetClient(client)
+	}
+	return client, nil
+}
+
+// newKeyring parses the data keys of field level encryption, read from
+// KeysFile when it is set, and the key of their blind indexes. It returns
+// nil when no keys are configured.
+func newKeyring(cfg config.EncryptionConfig) (*fieldcrypt.Keyring, error) {
+	spec := cfg.Keys
+	if cfg.KeysFile != "" {
+		b, err := os.ReadFile(cfg.KeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("read encryption keys: %w", err)
+		}
+		spec = string(b)
+	}
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	keys, err := fieldcrypt.ParseKeys(spec)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.IndexKey == "" {
+		return nil, errors.New("FIELD_ENCRYPTION_INDEX_KEY is required with encryption keys, to keep the emails unique across key rotations")
+	}
+	if err := keys.SetIndexKey(cfg.IndexKey); err != nil {
+		return nil, fmt.Errorf("FIELD_ENCRYPTION_INDEX_KEY: %w", err)
+	}
+	return keys, nil
+}
+
+// mongoHook pings MongoDB on start and disconnects on stop
+func mongoHook(name string, client *mongo.Client, cfg config.MongoConfig) Hook {
+	return Hook{
+		Name: name,
+		OnStart: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+			defer cancel()
+			if err := client.Ping(ctx, nil); err != nil {
+				return err
+			}
+			topology, err := repo.DescribeTopology(ctx, client)
+			if err != nil {
+				log.Printf("Connected to %s, topology unknown: %v", name, err)
+				return nil
+			}
+			log.Printf("Connected to %s: %s", name, topology)
+			if topology.SetName == "" && cfg.ReadPreference != "" && cfg.ReadPreference != "primary" {
+				log.Printf("WARNING: Read preference %s of %s has no secondaries to read from", cfg.ReadPreference, name)
+			}
+			return nil
+		},
+		OnStop: client.Disconnect,
+	}
+}
+
+// newRedisClient creates a traced Redis client
+func newRedisClient(cfg config.RedisConfig) redis.UniversalClient {
+	return redistrace.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+}
+
+// redisHook pings Redis on start and closes the client on stop
+func redisHook(rdb redis.UniversalClient) Hook {
+	return Hook{
+		Name: "redis",
+		OnStart: func(ctx context.Context) error {
+			return rdb.Ping(ctx).Err()
+		},
+		OnStop: func(context.Context) error {
+			return rdb.Close()
+		},
+	}
+}
+
+// userStore is the store of the users selected by the config, along with
+// the components reading or writing the users outside of it
+type userStore struct {
+	repo.UserStore
+	stats    service.StatsComputer
+	signups  service.SignupSource
+	counters service.CounterStore
+	// rotate re-encrypts the stored emails with the current key
+	rotate func(ctx context.Context, keys *fieldcrypt.Keyring) (int, error)
+	// tx runs merges, nil when the users are not in MongoDB: a merge moves
+	// the records of a user along with it, which are, in one transaction
+	tx service.Transactor
+	// mysql is the pool of the MySQL store, nil for MongoDB
+	mysql *sql.DB
+}
+
+// newUserStore returns the store of the users selected by the config, in
+// the users collection of the database of client unless MySQL is
+// selected, in which case the hooks of its pool are appended to lc
+func newUserStore(cfg config.Config, client *mongo.Client, keys *fieldcrypt.Keyring, lc *Lifecycle) (*userStore, error) {
+	db := client.Database(cfg.Mongo.Database)
+	switch cfg.UserStore.Backend {
+	case "", "mongo":
+		users := db.Collection("users")
+		return &userStore{
+			UserStore: repo.NewMongoUserRepository(users, repo.MongoOptions{
+				AtlasSearchIndex: cfg.Mongo.AtlasSearchIndex,
+				SortFields:       cfg.Mongo.SortFields,
+				FilterFields:     cfg.Mongo.FilterFields,
+				Encryption:       keys,
+				ListFields:       cfg.Mongo.ListFields,
+				BatchSize:        cfg.Mongo.ListBatchSize,
+			}),
+			stats:    repo.NewUserStatistics(db),
+			signups:  repo.NewSignupStats(db),
+			counters: repo.NewUserCounters(db),
+			rotate: func(ctx context.Context, keys *fieldcrypt.Keyring) (int, error) {
+				return repo.RotateEmails(ctx, users, keys)
+			},
+			tx: repo.NewTransactions(client),
+		}, nil
+	case "mysql":
+		mysqlDB, err := newMySQLDB(cfg.UserStore)
+		if err != nil {
+			return nil, err
+		}
+		lc.Append(mysqlHook(mysqlDB, cfg.UserStore))
+		users := repo.NewMySQLUserRepository(mysqlDB, repo.MySQLOptions{
+			SortFields:   cfg.Mongo.SortFields,
+			FilterFields: cfg.Mongo.FilterFields,
+			Encryption:   keys,
+			ListFields:   cfg.Mongo.ListFields,
+		})
+		lc.Append(Hook{Name: "mysql schema", OnStart: users.EnsureIndexes})
+		return &userStore{
+			UserStore: users,
+			stats:     repo.NewMySQLUserStatistics(mysqlDB),
+			signups:   repo.NewMySQLSignupStats(mysqlDB, db.Collection("organizations")),
+			counters:  repo.NewMySQLUserCounters(mysqlDB, db),
+			rotate: func(ctx context.Context, keys *fieldcrypt.Keyring) (int, error) {
+				return repo.RotateMySQLEmails(ctx, mysqlDB, keys)
+			},
+			mysql: mysqlDB,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown user store %q, want mongo or mysql", cfg.UserStore.Backend)
+	}
+}
+
+// MongoIndexes returns the registry of the indexes of the MongoDB
+// collections of the config, without those of the users when they are in
+// MySQL, whose schema indexes them
+func MongoIndexes(cfg config.Config) []repo.IndexSpec {
+	if cfg.UserStore.Backend != "mysql" {
+		return repo.Indexes
+	}
+	return slices.DeleteFunc(slices.Clone(repo.Indexes), func(s repo.IndexSpec) bool {
+		return s.Collection == "users"
+	})
+}
+
+// newMySQLDB opens the MySQL pool of the users store, traced with
+// Database Monitoring propagation in full mode: every query carries the
+// trace context in a comment, so DBM links its samples to the traces.
+func newMySQLDB(cfg config.UserStoreConfig) (*sql.DB, error) {
+	dsn, err := mysql.ParseDSN(cfg.MySQLDSN)
+	if err != nil {
+		return nil, fmt.Errorf("parse MYSQL_DSN: %w", err)
+	}
+	dsn.ParseTime = true
+	dsn.Loc = time.UTC
+	// Queries with arguments are otherwise prepared, and the comment of a
+	// prepared statement only carries the service, not the trace context
+	dsn.InterpolateParams = true
+	db, err := sqltrace.Open("mysql", dsn.FormatDSN(), sqltrace.WithDBMPropagation(tracer.DBMPropagationModeFull))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MySQLMaxOpenConns)
+	return db, nil
+}
+
+// mysqlHook checks the MySQL connection at startup and closes the pool
+// once every query is done
+func mysqlHook(db *sql.DB, cfg config.UserStoreConfig) Hook {
+	return Hook{
+		Name: "mysql",
+		OnStart: func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+			defer cancel()
+			if err := db.PingContext(ctx); err != nil {
+				return err
+			}
+			log.Printf("Connected to mysql")
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			return db.Close()
+		},
+	}
+}
+
+// newPreflight builds the startup checks. They run as the last start hook,
+// once every dependency is connected and before the port is bound.
+func newPreflight(cfg config.Config, client *mongo.Client, users repo.UserStore) *preflight.Suite {
+	suite := preflight.NewSuite(cfg.Preflight.Required, cfg.Preflight.Timeout)
+	suite.Add(preflight.Env(cfg.Preflight.RequiredEnv))
+	suite.Add(preflight.Check{Name: "mongodb", Run: func(ctx context.Context) error {
+		return client.Ping(ctx, nil)
+	}})
+	suite.Add(preflight.Check{Name: "indexes", Run: users.CheckIndexes})
+	suite.Add(preflight.Check{Name: "migrations", Run: migrate.New(client.Database(cfg.Mongo.Database), migrate.Migrations).Check})
+	suite.Add(preflight.TraceAgent(cfg.Datadog.TraceAgentURL))
+	suite.Add(preflight.DogStatsD(cfg.Datadog.DogStatsDAddr))
+	return suite
+}
+
+// newMailer returns a retrying SMTP mailer, or a log-only mailer when no
+// relay is configured
+func newMailer(cfg config.MailConfig) (mail.Mailer, error) {
+	if cfg.SMTPAddr == "" {
+		return mail.LogMailer{}, nil
+	}
+	m, err := mail.NewSMTPMailer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return mail.Retry(m, cfg.Attempts, cfg.Backoff), nil
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"datadog-golang-example/internal/model"
+)
+
+// bannerTimeout bounds the MongoDB queries of the startup banner
+const bannerTimeout = 5 * time.Second
+
+// logBanner logs the diagnostics of the instance once it started, the
+// same ones GET /admin/diagnostics serves, so a misconfigured demo shows
+// in the first lines of its logs
+func logBanner(d *model.Diagnostics) {
+	log.Printf("%s %s (env %s) built with %s", d.Service, d.Version, d.Env, d.Build["go"])
+	log.Printf("Features: %s", enabledOf(d.Features))
+	if flags := enabledOf(d.FeatureFlags); flags != "none" {
+		log.Printf("Feature flags: %s", flags)
+	}
+
+	tracer := "started"
+	if !d.Tracer.Started {
+		tracer = "not started, spans are no-ops"
+	}
+	log.Printf("Tracer: %s, agent %s, sample rate %g, propagation %s / %s",
+		tracer, d.Tracer.AgentURL, d.Tracer.SampleRate, d.Tracer.Inject, d.Tracer.Extract)
+	if d.Tracer.AgentError != "" {
+		log.Printf("WARNING: Datadog agent unreachable: %s", d.Tracer.AgentError)
+	}
+	if !d.Profiler.Running {
+		log.Printf("Profiler: off, %s", d.Profiler.Reason)
+	}
+
+	if d.MongoDB.Error != "" {
+		log.Printf("WARNING: MongoDB %s: %s", d.MongoDB.Database, d.MongoDB.Error)
+	} else {
+		log.Printf("MongoDB %s: %s, read preference %s", d.MongoDB.Database, d.MongoDB.Deployment, d.MongoDB.ReadPreference)
+	}
+	switch {
+	case d.Indexes.Error != "":
+		log.Printf("WARNING: Indexes unknown: %s", d.Indexes.Error)
+	case !d.Indexes.InSync:
+		log.Printf("WARNING: Indexes drift from the registry, see GET /admin/diagnostics")
+	}
+	switch {
+	case d.Migrations.Error != "":
+		log.Printf("WARNING: Migrations unknown: %s", d.Migrations.Error)
+	case len(d.Migrations.Pending) > 0:
+		log.Printf("WARNING: Pending migrations: %s", strings.Join(d.Migrations.Pending, ", "))
+	case d.Migrations.Last != nil:
+		log.Printf("Last migration: %d %s, applied %s", d.Migrations.Last.Version, d.Migrations.Last.Name, d.Migrations.Last.AppliedAt.Format(time.RFC3339))
+	}
+}
+
+// enabledOf lists the names set in flags, sorted, or "none"
+func enabledOf(flags map[string]bool) string {
+	var names []string
+	for _, name := range slices.Sorted(maps.Keys(flags)) {
+		if flags[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
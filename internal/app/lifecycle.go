@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"golang.org/x/sync/errgroup"
+)
+
+// Hook is a pair of callbacks run when the application starts and stops,
+// with an optional long-running part in between
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+	// Run serves until ctx is cancelled or OnStop is called. Returning
+	// before that, with or without an error, shuts the application down.
+	Run func(ctx context.Context) error
+	// StopsTracer marks the hook that flushes and stops the tracer; the
+	// shutdown span is finished before it stops so it is still sent
+	StopsTracer bool
+}
+
+// errExited reports a Run that returned while the application was running
+var errExited = errors.New("exited unexpectedly")
+
+// Lifecycle runs registered hooks in order on start and in reverse on stop
+type Lifecycle struct {
+	hooks   []Hook
+	started int
+	running *errgroup.Group
+}
+
+// Append registers a hook; hooks start in registration order
+func (l *Lifecycle) Append(h Hook) {
+	l.hooks = append(l.hooks, h)
+}
+
+// Start runs every OnStart hook, stopping at the first failure
+func (l *Lifecycle) Start(ctx context.Context) error {
+	for _, h := range l.hooks {
+		if h.OnStart != nil {
+			if err := h.OnStart(ctx); err != nil {
+				return fmt.Errorf("start %s: %w", h.Name, err)
+			}
+		}
+		l.started++
+		log.Printf("Started %s", h.Name)
+	}
+	return nil
+}
+
+// Run runs the Run callbacks of the started hooks together until ctx is
+// cancelled or one of them returns, and returns that hook's error. The
+// callbacks keep running until Stop.
+func (l *Lifecycle) Run(ctx context.Context) error {
+	g, gctx := errgroup.WithContext(ctx)
+	l.running = g
+	for _, h := range l.hooks[:l.started] {
+		if h.Run == nil {
+			continue
+		}
+		g.Go(func() error {
+			err := h.Run(gctx)
+			if err == nil && gctx.Err() == nil {
+				err = errExited
+			}
+			if err != nil {
+				return fmt.Errorf("run %s: %w", h.Name, err)
+			}
+			return nil
+		})
+	}
+
+	<-gctx.Done()
+	if ctx.Err() != nil {
+		return nil
+	}
+	return context.Cause(gctx)
+}
+
+// Stop runs the OnStop hooks of every started hook in reverse order, then
+// waits for the Run callbacks to return. Each hook is traced as an
+// app.stop span under the span of ctx, if any.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	parent, traced := tracer.SpanFromContext(ctx)
+	var firstErr error
+	for ; l.started > 0; l.started-- {
+		h := l.hooks[l.started-1]
+		if h.StopsTracer && traced {
+			parent.Finish()
+			traced = false
+		}
+		if h.OnStop == nil {
+			continue
+		}
+		start := time.Now()
+		err := l.stop(ctx, h, traced)
+		if err != nil {
+			log.Printf("Error stopping %s: %v", h.Name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("stop %s: %w", h.Name, err)
+			}
+			continue
+		}
+		log.Printf("Stopped %s in %s", h.Name, time.Since(start).Round(time.Millisecond))
+	}
+	if traced {
+		parent.Finish()
+	}
+
+	if l.running != nil {
+		if err := l.wait(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		l.running = nil
+	}
+	return firstErr
+}
+
+// stop runs the OnStop hook of h, in a span when traced
+func (l *Lifecycle) stop(ctx context.Context, h Hook, traced bool) error {
+	if !traced {
+		return h.OnStop(ctx)
+	}
+	span, ctx := tracer.StartSpanFromContext(ctx, "app.stop", tracer.ResourceName(h.Name))
+	err := h.OnStop(ctx)
+	span.Finish(tracer.WithError(err))
+	return err
+}
+
+// wait waits for the Run callbacks to return, or for ctx to expire. Their
+// failures were already returned by Run.
+func (l *Lifecycle) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		l.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for running hooks: %w", ctx.Err())
+	}
+}
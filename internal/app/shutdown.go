@@ -0,0 +1,105 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+
+	"datadog-golang-example/internal/telemetry"
+	"datadog-golang-example/internal/worker"
+)
+
+// inFlight counts the HTTP requests being served
+type inFlight struct {
+	n atomic.Int64
+}
+
+// wrap returns h counting its requests in f
+func (f *inFlight) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.n.Add(1)
+		defer f.n.Add(-1)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// eventQueue is implemented by the event buses queueing events in the
+// process, whose pending events are lost if the shutdown times out
+type eventQueue interface {
+	Pending() int
+}
+
+// shutdownCounts is the state of the work in progress and of the flushes
+// at one point of the shutdown
+type shutdownCounts struct {
+	requests int64
+	tasks    worker.Stats
+	events   int
+	traces   telemetry.FlushStats
+	metrics  statsd.Telemetry
+}
+
+// shutdownCounts takes the counts of the components the shutdown drains
+// and flushes
+func (a *App) shutdownCounts() shutdownCounts {
+	c := shutdownCounts{
+		requests: a.requests.n.Load(),
+		tasks:    a.pool.Stats(),
+		traces:   a.tracer.Flushes(),
+		metrics:  a.metrics.GetTelemetry(),
+	}
+	if queue, ok := a.bus.(eventQueue); ok {
+		c.events = queue.Pending()
+	}
+	return c
+}
+
+// logShutdownReport logs what the shutdown drained, flushed and lost
+// between before and after, so the traces lost during a deploy can be
+// told from the ones never sent. The work still in progress after the
+// shutdown timeout is abandoned.
+func logShutdownReport(before, after shutdownCounts, took time.Duration, err error) {
+	level := slog.LevelInfo
+	lost := after.requests > 0 || after.tasks.Queued+int(after.tasks.Running) > 0 || after.events > 0 ||
+		after.traces.FailedTraces > before.traces.FailedTraces || after.metrics.TotalPayloadsDropped > before.metrics.TotalPayloadsDropped
+	if err != nil || lost {
+		level = slog.LevelWarn
+	}
+	slog.Log(context.Background(), level, "Shutdown report",
+		"duration", took.Round(time.Millisecond).String(),
+		"error", errString(err),
+		slog.Group("requests",
+			"in_flight", before.requests,
+			"drained", before.requests-after.requests,
+			"abandoned", after.requests),
+		slog.Group("traces",
+			"payloads_flushed", after.traces.Payloads-before.traces.Payloads,
+			"flushed", after.traces.Traces-before.traces.Traces,
+			"failed", after.traces.FailedTraces-before.traces.FailedTraces,
+			"failed_total", after.traces.FailedTraces),
+		slog.Group("metrics",
+			"payloads_flushed", after.metrics.TotalPayloadsSent-before.metrics.TotalPayloadsSent,
+			"payloads_dropped", after.metrics.TotalPayloadsDropped-before.metrics.TotalPayloadsDropped,
+			"payloads_dropped_total", after.metrics.TotalPayloadsDropped,
+			"sent_total", after.metrics.TotalMetrics),
+		slog.Group("events",
+			"pending", before.events,
+			"abandoned", after.events),
+		slog.Group("worker_tasks",
+			"pending", before.tasks.Queued+int(before.tasks.Running),
+			"completed", after.tasks.Done-before.tasks.Done,
+			"abandoned", after.tasks.Queued+int(after.tasks.Running)),
+	)
+}
+
+// errString returns the message of err, empty when nil
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
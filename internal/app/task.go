@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/fieldcrypt"
+	"datadog-golang-example/internal/mail"
+	"datadog-golang-example/internal/repo"
+	"datadog-golang-example/internal/service"
+)
+
+// Task is a one-off operational job, such as a migration, run by the CLI
+type Task struct {
+	Name string
+	// Mongo connects to MongoDB before Run and sets TaskDeps.DB
+	Mongo bool
+	// Users also opens the user store selected by the config, as the
+	// server does, and sets TaskDeps.Users with the components of the
+	// store
+	Users bool
+	Run   func(ctx context.Context, deps TaskDeps) error
+}
+
+// TaskDeps are the components a Task runs with
+type TaskDeps struct {
+	Metrics statsd.ClientInterface
+	DB      *mongo.Database
+	Users   repo.UserStore
+	// Signups, Counters and RotateEmails read or write the users of Users
+	// outside of it
+	Signups      service.SignupSource
+	Counters     service.CounterStore
+	RotateEmails func(ctx context.Context, keys *fieldcrypt.Keyring) (int, error)
+	// Keys encrypts fields at rest, nil when encryption is off
+	Keys *fieldcrypt.Keyring
+	// Mailer sends emails like the server does
+	Mailer mail.Mailer
+}
+
+// RunTask runs t with the same telemetry as the server. The components
+// start before t.Run and stop after it, and t.Run is traced as a whole
+// under a cli.task span.
+func RunTask(ctx context.Context, cfg config.Config, t Task) error {
+	var lc Lifecycle
+	metrics, _, _, err := newTelemetry(cfg.Datadog, &lc)
+	if err != nil {
+		return err
+	}
+	keys, err := newKeyring(cfg.Encryption)
+	if err != nil {
+		return err
+	}
+	mailer, err := newMailer(cfg.Mail)
+	if err != nil {
+		return err
+	}
+	deps := TaskDeps{Metrics: metrics, Keys: keys, Mailer: mailer}
+	var client *mongo.Client
+	if t.Mongo || t.Users {
+		client, err = newMongoClient(cfg.Mongo, metrics, nil)
+		if err != nil {
+			return err
+		}
+		lc.Append(mongoHook("mongodb", client, cfg.Mongo))
+		deps.DB = client.Database(cfg.Mongo.Database)
+	}
+	if t.Users {
+		store, err := newUserStore(cfg, client, keys, &lc)
+		if err != nil {
+			return err
+		}
+		deps.Users, deps.Signups, deps.Counters, deps.RotateEmails = store.UserStore, store.signups, store.counters, store.rotate
+	}
+
+	if err := lc.Start(ctx); err != nil {
+		lc.Stop(context.Background())
+		return err
+	}
+
+	span, spanCtx := tracer.StartSpanFromContext(ctx, "cli.task", tracer.ResourceName(t.Name))
+	err = t.Run(spanCtx, deps)
+	span.Finish(tracer.WithError(err))
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if stopErr := lc.Stop(stopCtx); stopErr != nil && err == nil {
+		err = stopErr
+	}
+	return err
+}
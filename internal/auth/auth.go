@@ -0,0 +1,76 @@
+// Package auth identifies API clients from their API keys.
+package auth
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// Level is the authentication level of a caller
+type Level int
+
+// Authentication levels, from least to most privileged
+const (
+	Anonymous Level = iota
+	Client
+	Admin
+)
+
+// String returns the level name used in tags and configuration
+func (l Level) String() string {
+	switch l {
+	case Client:
+		return "api_key"
+	case Admin:
+		return "admin"
+	default:
+		return "anonymous"
+	}
+}
+
+// Principal is the identity a request is made under
+type Principal struct {
+	Name  string
+	Level Level
+}
+
+// AnonymousPrincipal is used for requests without an API key
+var AnonymousPrincipal = Principal{Name: "anonymous", Level: Anonymous}
+
+// KeyStore resolves API keys to principals. Keys are held only as SHA-256
+// digests so they never sit in memory in plain text after startup.
+type KeyStore struct {
+	keys map[[sha256.Size]byte]Principal
+}
+
+// ParseKeys builds a KeyStore from a comma-separated list of
+// name:key[:admin] entries, e.g. "ci-bot:s3cret,ops:t0ps3cret:admin"
+func ParseKeys(spec string) (*KeyStore, error) {
+	s := &KeyStore{keys: make(map[[sha256.Size]byte]Principal)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid API key entry %q, want name:key[:admin]", entry)
+		}
+		p := Principal{Name: parts[0], Level: Client}
+		if len(parts) == 3 {
+			if parts[2] != "admin" {
+				return nil, fmt.Errorf("invalid role %q for API key %s", parts[2], p.Name)
+			}
+			p.Level = Admin
+		}
+		s.keys[sha256.Sum256([]byte(parts[1]))] = p
+	}
+	return s, nil
+}
+
+// Lookup returns the principal owning key
+func (s *KeyStore) Lookup(key string) (Principal, bool) {
+	p, ok := s.keys[sha256.Sum256([]byte(key))]
+	return p, ok
+}
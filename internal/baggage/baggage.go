@@ -0,0 +1,104 @@
+// Package baggage controls the business context, such as the tenant or
+// plan of a request, carried as trace baggage. It reaches every span of
+// the trace, outbound HTTP calls and event messages through the trace
+// propagation headers, so only allowlisted keys with short, plain values
+// are let in.
+package baggage
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/config"
+)
+
+// Trace propagation headers that carry baggage
+const (
+	w3cHeader = "Baggage"
+	otPrefix  = "Ot-Baggage-"
+)
+
+// validValue keeps values to identifiers, which rules out emails, names
+// and other personal data as well as unbounded cardinality
+var validValue = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// Policy filters the baggage of incoming requests
+type Policy struct {
+	allowed []string
+	// headers maps baggage keys to the request header setting them
+	headers map[string]string
+}
+
+// New creates the policy of cfg. Headers setting keys that are not
+// allowed are ignored.
+func New(cfg config.BaggageConfig) *Policy {
+	p := &Policy{allowed: cfg.Allowed, headers: make(map[string]string)}
+	for key, header := range cfg.Headers {
+		if !slices.Contains(cfg.Allowed, key) {
+			log.Printf("WARNING: Ignoring baggage header %s, %q is not an allowed key", header, key)
+			continue
+		}
+		p.headers[key] = http.CanonicalHeaderKey(header)
+	}
+	return p
+}
+
+// Filter rewrites the baggage propagation headers of h, before the trace
+// is extracted from them, to the allowed items with valid values. Items
+// set by the headers of the policy replace those propagated by callers.
+func (p *Policy) Filter(h http.Header) {
+	items := map[string]string{}
+	for _, entry := range strings.Split(h.Get(w3cHeader), ",") {
+		member, _, _ := strings.Cut(entry, ";")
+		k, v, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok {
+			continue
+		}
+		k, errK := url.PathUnescape(strings.TrimSpace(k))
+		v, errV := url.PathUnescape(strings.TrimSpace(v))
+		if errK == nil && errV == nil {
+			items[k] = v
+		}
+	}
+	for name, values := range h {
+		if key, ok := strings.CutPrefix(name, otPrefix); ok {
+			items[strings.ToLower(key)] = values[0]
+			h.Del(name)
+		}
+	}
+	for key, header := range p.headers {
+		if v := h.Get(header); v != "" {
+			items[key] = v
+		}
+	}
+
+	var members []string
+	for _, key := range p.allowed {
+		if v, ok := items[key]; ok && validValue.MatchString(v) {
+			members = append(members, key+"="+v)
+		}
+	}
+	if len(members) == 0 {
+		h.Del(w3cHeader)
+		return
+	}
+	h.Set(w3cHeader, strings.Join(members, ","))
+}
+
+// Tag sets the baggage of span as baggage.<key> tags, so spans can be
+// searched by business context
+func Tag(span *tracer.Span) {
+	if span == nil {
+		return
+	}
+	span.Context().ForeachBaggageItem(func(k, v string) bool {
+		span.SetTag("baggage."+k, v)
+		return true
+	})
+}
@@ -0,0 +1,88 @@
+// Package brownout watches MongoDB for degradation, so non-essential
+// traffic can be shed while the database struggles instead of the whole
+// API slowing down with it.
+package brownout
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+
+	"datadog-golang-example/internal/config"
+)
+
+// Probe checks the database, such as with a ping
+type Probe func(ctx context.Context) error
+
+// Watchdog periodically probes the database and reports it degraded when
+// the last probe failed or was slower than the configured threshold, zero
+// disabling the latency check
+type Watchdog struct {
+	probe    Probe
+	cfg      config.BrownoutConfig
+	metrics  statsd.ClientInterface
+	degraded atomic.Bool
+}
+
+// NewWatchdog creates a Watchdog running probe every cfg.CheckInterval
+func NewWatchdog(probe Probe, cfg config.BrownoutConfig, metrics statsd.ClientInterface) *Watchdog {
+	return &Watchdog{probe: probe, cfg: cfg, metrics: metrics}
+}
+
+// Degraded reports whether the database is degraded
+func (w *Watchdog) Degraded() bool {
+	return w.degraded.Load()
+}
+
+// Run probes the database until ctx is cancelled
+func (w *Watchdog) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		w.check(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// check runs one probe and logs when the database degrades or recovers
+func (w *Watchdog) check(ctx context.Context) {
+	// A probe slower than twice the threshold is degraded whatever it
+	// returns, so it is not waited for any longer
+	timeout := 2 * w.cfg.SlowPing
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	err := w.probe(probeCtx)
+	latency := time.Since(start)
+	if ctx.Err() != nil {
+		// Shutting down: the probe was cancelled, not the database slow
+		return
+	}
+
+	degraded := err != nil || (w.cfg.SlowPing > 0 && latency > w.cfg.SlowPing)
+	switch was := w.degraded.Swap(degraded); {
+	case degraded && !was && err != nil:
+		log.Printf("WARNING: MongoDB degraded, shedding non-essential traffic: %v", err)
+	case degraded && !was:
+		log.Printf("WARNING: MongoDB degraded, shedding non-essential traffic: ping took %s", latency)
+	case !degraded && was:
+		log.Printf("MongoDB recovered, serving all traffic again")
+	}
+
+	value := 0.0
+	if degraded {
+		value = 1
+	}
+	_ = w.metrics.Gauge("brownout.degraded", value, nil, 1)
+	_ = w.metrics.Timing("brownout.probe.latency", latency, nil, 1)
+}
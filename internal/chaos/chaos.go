@@ -0,0 +1,125 @@
+// Package chaos injects latency and failures into requests on demand so
+// APM latency and anomaly features can be demonstrated.
+package chaos
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Rule describes the faults injected into the requests of one route
+type Rule struct {
+	// Route is the Gin route pattern, e.g. "/api/v1/users/:id", or "*"
+	// for every route
+	Route string `json:"route"`
+	// Percent of matching requests that get LatencyMS plus up to JitterMS
+	// of extra delay
+	Percent   float64 `json:"percent"`
+	LatencyMS int     `json:"latency_ms"`
+	JitterMS  int     `json:"jitter_ms"`
+	// ErrorPercent of matching requests fail with ErrorStatus (500 or 503)
+	ErrorPercent float64 `json:"error_percent"`
+	ErrorStatus  int     `json:"error_status,omitempty"`
+}
+
+// Settings is the complete chaos configuration
+type Settings struct {
+	Enabled bool   `json:"enabled"`
+	Rules   []Rule `json:"rules"`
+}
+
+// Validate checks that every rule is usable
+func (s Settings) Validate() error {
+	for i, r := range s.Rules {
+		switch {
+		case r.Route == "":
+			return fmt.Errorf("rule %d: route is required", i)
+		case r.Percent < 0 || r.Percent > 100 || r.ErrorPercent < 0 || r.ErrorPercent > 100:
+			return fmt.Errorf("rule %d: percentages must be between 0 and 100", i)
+		case r.LatencyMS < 0 || r.JitterMS < 0:
+			return fmt.Errorf("rule %d: latency and jitter must not be negative", i)
+		case r.ErrorStatus != 0 && r.ErrorStatus != 500 && r.ErrorStatus != 503:
+			return fmt.Errorf("rule %d: error_status must be 500 or 503", i)
+		}
+	}
+	return nil
+}
+
+// ParseSettings decodes the JSON rule list used by CHAOS_RULES
+func ParseSettings(enabled bool, rules string) (Settings, error) {
+	s := Settings{Enabled: enabled}
+	if rules != "" {
+		if err := json.Unmarshal([]byte(rules), &s.Rules); err != nil {
+			return Settings{}, fmt.Errorf("parse chaos rules: %w", err)
+		}
+	}
+	return s, s.Validate()
+}
+
+// Fault is the decision for one request
+type Fault struct {
+	Delay  time.Duration
+	Status int
+}
+
+// Injector holds the active settings; it is safe for concurrent use and
+// can be reconfigured at runtime
+type Injector struct {
+	mu       sync.RWMutex
+	settings Settings
+}
+
+// NewInjector creates an Injector with the given initial settings
+func NewInjector(s Settings) *Injector {
+	return &Injector{settings: s}
+}
+
+// Settings returns the active settings
+func (i *Injector) Settings() Settings {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.settings
+}
+
+// Set replaces the active settings
+func (i *Injector) Set(s Settings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.settings = s
+	return nil
+}
+
+// Decide rolls the dice for a request on route
+func (i *Injector) Decide(route string) (Fault, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if !i.settings.Enabled {
+		return Fault{}, false
+	}
+
+	var f Fault
+	for _, r := range i.settings.Rules {
+		if r.Route != "*" && r.Route != route {
+			continue
+		}
+		if r.Percent > 0 && rand.Float64()*100 < r.Percent {
+			f.Delay += time.Duration(r.LatencyMS) * time.Millisecond
+			if r.JitterMS > 0 {
+				f.Delay += time.Duration(rand.IntN(r.JitterMS+1)) * time.Millisecond
+			}
+		}
+		if f.Status == 0 && r.ErrorPercent > 0 && rand.Float64()*100 < r.ErrorPercent {
+			f.Status = r.ErrorStatus
+			if f.Status == 0 {
+				f.Status = 500
+			}
+		}
+	}
+	return f, f.Delay > 0 || f.Status != 0
+}
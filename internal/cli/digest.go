@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"datadog-golang-example/internal/app"
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/service"
+)
+
+// newDigestCommand sends the digest of the new users once, as the server
+// does every period, so it can also run from a scheduler such as cron
+func newDigestCommand(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "send-digest",
+		Short: "Email the digest of the users created over the last period",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if len(cfg.Digest.Recipients) == 0 {
+				return fmt.Errorf("DIGEST_RECIPIENTS is empty: nobody to send the digest to")
+			}
+			return app.RunTask(cmd.Context(), *cfg, app.Task{
+				Name:  "send-digest",
+				Users: true,
+				Run: func(ctx context.Context, deps app.TaskDeps) error {
+					digest, err := service.NewDigestService(deps.Signups, deps.Mailer, cfg.Digest).Send(ctx, time.Now())
+					if digest != nil {
+						fmt.Fprintf(cmd.OutOrStdout(), "Sent the digest of %d user(s) in %d organization(s) to %d recipient(s)\n",
+							digest.Total, len(digest.Orgs), len(cfg.Digest.Recipients))
+					}
+					return err
+				},
+			})
+		},
+	}
+}
@@ -0,0 +1,226 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"datadog-golang-example/internal/app"
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// newInspectCommand groups the read-only commands printing the demo data
+// as tables, so it can be poked at without mongosh. Users are read from
+// the configured user store, as the server reads them.
+func newInspectCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Print users, organization sizes and index status",
+	}
+
+	var filter model.UserFilter
+	var tag string
+	users := &cobra.Command{
+		Use:   "users",
+		Short: "List users",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runInspect(cmd, cfg, "inspect.users", func(ctx context.Context, deps app.TaskDeps) error {
+				if tag != "" {
+					filter.Filters = map[string]string{"tag": tag}
+				}
+				list, err := deps.Users.List(ctx, filter)
+				if err != nil {
+					return err
+				}
+				total, err := deps.Users.Count(ctx, filter, true)
+				if err != nil {
+					return err
+				}
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "ID\tUSERNAME\tNAME\tEMAIL\tAGE\tORG\tTAGS\tCREATED AT")
+				for _, u := range list {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", u.PublicID, u.Username, u.Name, u.Email, u.Age,
+						u.OrgID, strings.Join(u.Tags, ","), u.CreatedAt.Format(time.RFC3339))
+				}
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%d of %d user(s)\n", len(list), total)
+				return nil
+			})
+		},
+	}
+	users.Flags().IntVar(&filter.Limit, "limit", 20, "number of users to list, 0 for all of them")
+	users.Flags().IntVar(&filter.Offset, "offset", 0, "number of users to skip")
+	users.Flags().StringVar(&filter.Sort, "sort", "", "field to sort by, prefixed with - for descending order")
+	users.Flags().StringVar(&filter.OrgID, "org", "", "only list the members of this organization")
+	users.Flags().StringVar(&tag, "tag", "", "only list the users with this tag")
+
+	cmd.AddCommand(
+		users,
+		&cobra.Command{
+			Use:   "user <email|id>",
+			Short: "Print a user found by email or ID",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runInspect(cmd, cfg, "inspect.user", func(ctx context.Context, deps app.TaskDeps) error {
+					user, err := findUser(ctx, deps.Users, args[0])
+					if err != nil {
+						return err
+					}
+					return printUser(cmd.OutOrStdout(), user)
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "orgs",
+			Short: "Count the users of each organization",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return runInspect(cmd, cfg, "inspect.orgs", func(ctx context.Context, deps app.TaskDeps) error {
+					counts, err := deps.Users.CountByOrg(ctx)
+					if err != nil {
+						return err
+					}
+					orgs := repo.NewMongoOrgRepository(deps.DB.Collection("organizations"))
+					w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+					fmt.Fprintln(w, "ORG\tNAME\tUSERS")
+					for _, c := range counts {
+						name, err := orgName(ctx, orgs, c.OrgID)
+						if err != nil {
+							return err
+						}
+						fmt.Fprintf(w, "%s\t%s\t%d\n", c.OrgID, name, c.Users)
+					}
+					return w.Flush()
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "indexes",
+			Short: "Print the status of the indexes of the registry",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return runInspect(cmd, cfg, "inspect.indexes", func(ctx context.Context, deps app.TaskDeps) error {
+					specs := app.MongoIndexes(*cfg)
+					drift, err := repo.NewIndexManager(deps.DB, specs, deps.Metrics).Drift(ctx)
+					if err != nil {
+						return err
+					}
+					if err := printIndexes(cmd.OutOrStdout(), specs, drift); err != nil {
+						return err
+					}
+					if cfg.UserStore.Backend == "mysql" {
+						status := "ok"
+						if err := deps.Users.CheckIndexes(ctx); err != nil {
+							status = err.Error()
+						}
+						fmt.Fprintf(cmd.OutOrStdout(), "MySQL users table: %s\n", status)
+					}
+					return nil
+				})
+			},
+		},
+	)
+	return cmd
+}
+
+// runInspect runs fn as a task with the stores of the config
+func runInspect(cmd *cobra.Command, cfg *config.Config, name string, fn func(context.Context, app.TaskDeps) error) error {
+	return app.RunTask(cmd.Context(), *cfg, app.Task{Name: name, Users: true, Run: fn})
+}
+
+// findUser returns the user with the email, or with the ID when s is not
+// an email
+func findUser(ctx context.Context, users repo.UserRepository, s string) (*model.User, error) {
+	if strings.Contains(s, "@") {
+		return users.GetByEmail(ctx, s)
+	}
+	ref, err := model.ParseUserRef(s)
+	if err != nil {
+		return nil, err
+	}
+	return users.Get(ctx, ref)
+}
+
+// printUser prints the fields of user, one per line
+func printUser(out io.Writer, user *model.User) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fields := [][2]string{
+		{"id", user.PublicID},
+		{"object_id", user.ID.Hex()},
+		{"username", user.Username},
+		{"name", user.Name},
+		{"email", user.Email},
+		{"email_risk", user.EmailRisk},
+		{"age", fmt.Sprint(user.Age)},
+		{"org_id", user.OrgID},
+		{"tags", strings.Join(user.Tags, ",")},
+		{"created_at", user.CreatedAt.Format(time.RFC3339)},
+		{"updated_at", user.UpdatedAt.Format(time.RFC3339)},
+		{"version", fmt.Sprint(user.Version)},
+		{"attachments", fmt.Sprint(user.AttachmentsCount)},
+	}
+	if location := user.Location.Request(); location != nil {
+		fields = append(fields, [2]string{"location", fmt.Sprintf("%g,%g", location.Lat, location.Lng)})
+	}
+	if user.ErasedAt != nil {
+		fields = append(fields, [2]string{"erased_at", user.ErasedAt.Format(time.RFC3339)})
+	}
+	fmt.Fprintln(w, "FIELD\tVALUE")
+	for _, f := range fields {
+		fmt.Fprintf(w, "%s\t%s\n", f[0], f[1])
+	}
+	return w.Flush()
+}
+
+// orgName returns the name of the organization with the ID, (none) for
+// the users in none and (deleted) for an organization that is gone
+func orgName(ctx context.Context, orgs repo.OrgRepository, id string) (string, error) {
+	if id == "" {
+		return "(none)", nil
+	}
+	org, err := orgs.Get(ctx, id)
+	if errors.Is(err, model.ErrNotFound) {
+		return "(deleted)", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return org.Name, nil
+}
+
+// printIndexes prints the status of every index of specs, then the
+// indexes they lack
+func printIndexes(out io.Writer, specs []repo.IndexSpec, drift repo.IndexDrift) error {
+	status := map[string]string{}
+	for _, s := range drift.Missing {
+		status[s.String()] = "missing"
+	}
+	for _, c := range drift.Conflicting {
+		status[c.Want.String()] = "conflicting: " + c.Reason + ", have " + c.Have
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "COLLECTION\tINDEX\tSTATUS")
+	for _, s := range specs {
+		st, ok := status[s.String()]
+		if !ok {
+			st = "ok"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Collection, s.Name(), st)
+	}
+	for _, name := range drift.Unexpected {
+		collection, index, _ := strings.Cut(name, ".")
+		fmt.Fprintf(w, "%s\t%s\tunexpected\n", collection, index)
+	}
+	return w.Flush()
+}
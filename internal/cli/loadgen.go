@@ -0,0 +1,321 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"datadog-golang-example/internal/app"
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/fixtures"
+	"datadog-golang-example/internal/httpclient"
+)
+
+// loadgenOptions are the loadgen flags
+type loadgenOptions struct {
+	url         string
+	apiKey      string
+	rps         int
+	duration    time.Duration
+	concurrency int
+	// sampling forces the sampling decision of the traces: auto leaves it
+	// to the tracer and the agent, mixed keeps keepPercent of them
+	sampling    string
+	keepPercent int
+	// origin marks the traces as coming from e.g. synthetics
+	origin string
+	// fixtures is a fixtures file whose users are read from the start,
+	// loaded beforehand with seed --fixtures
+	fixtures string
+}
+
+// Sampling modes of the loadgen traces
+const (
+	samplingAuto  = "auto"
+	samplingKeep  = "keep"
+	samplingDrop  = "drop"
+	samplingMixed = "mixed"
+)
+
+// Sampling priorities set by a user decision
+const (
+	priorityUserDrop = "-1"
+	priorityUserKeep = "2"
+)
+
+// forced reports whether the trace headers are set by hand rather than by
+// the traced client
+func (o loadgenOptions) forced() bool {
+	return o.sampling != samplingAuto || o.origin != ""
+}
+
+// validate checks the sampling flags
+func (o loadgenOptions) validate() error {
+	switch o.sampling {
+	case samplingAuto, samplingKeep, samplingDrop, samplingMixed:
+	default:
+		return fmt.Errorf("unknown sampling mode %q: expected auto, keep, drop or mixed", o.sampling)
+	}
+	if o.keepPercent < 0 || o.keepPercent > 100 {
+		return fmt.Errorf("keep percent must be between 0 and 100, got %d", o.keepPercent)
+	}
+	return nil
+}
+
+// newLoadgenCommand sends a mix of API requests at a steady rate, so the
+// service map and APM dashboards have traffic to show
+func newLoadgenCommand(cfg *config.Config) *cobra.Command {
+	var opts loadgenOptions
+	cmd := &cobra.Command{
+		Use:   "loadgen",
+		Short: "Generate traffic against a running server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := opts.validate(); err != nil {
+				return err
+			}
+			var ids []string
+			if opts.fixtures != "" {
+				set, err := fixtures.ReadFile(opts.fixtures)
+				if err != nil {
+					return err
+				}
+				for _, u := range set.Users {
+					ids = append(ids, fixtures.UserID(u.Key))
+				}
+			}
+			return app.RunTask(cmd.Context(), *cfg, app.Task{
+				Name: "loadgen",
+				Run: func(ctx context.Context, deps app.TaskDeps) error {
+					client := httpclient.New(cfg.Client.Timeout)
+					if opts.forced() {
+						// The traced client would replace the trace headers
+						client = &http.Client{Timeout: cfg.Client.Timeout}
+					}
+					g := &loadgen{opts: opts, client: client, deps: deps, ids: ids, statuses: map[int]int{}}
+					g.run(ctx)
+					g.report(cmd.OutOrStdout())
+					return nil
+				},
+			})
+		},
+	}
+	cmd.Flags().StringVar(&opts.url, "url", "http://localhost:8080", "base URL of the server")
+	cmd.Flags().StringVar(&opts.apiKey, "api-key", "", "API key sent as X-API-Key")
+	cmd.Flags().IntVar(&opts.rps, "rps", 10, "requests per second")
+	cmd.Flags().DurationVar(&opts.duration, "duration", time.Minute, "how long to generate traffic")
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", 4, "maximum requests in flight")
+	cmd.Flags().StringVar(&opts.sampling, "sampling", samplingAuto, "sampling decision of the traces: auto, keep, drop or mixed")
+	cmd.Flags().IntVar(&opts.keepPercent, "keep-percent", 10, "percentage of the traces kept in mixed sampling")
+	cmd.Flags().StringVar(&opts.origin, "origin", "", "origin of the traces, such as synthetics")
+	cmd.Flags().StringVar(&opts.fixtures, "fixtures", "", "fixtures file whose users are read, loaded beforehand with seed --fixtures")
+	return cmd
+}
+
+// loadgen issues the requests and counts the responses by status
+type loadgen struct {
+	opts   loadgenOptions
+	client *http.Client
+	deps   app.TaskDeps
+
+	// sent numbers the requests for the mixed sampling
+	sent atomic.Uint64
+
+	mu       sync.Mutex
+	ids      []string
+	statuses map[int]int
+	failures int
+}
+
+// run sends requests until the duration elapses or ctx is cancelled
+func (g *loadgen) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, g.opts.duration)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second / time.Duration(max(g.opts.rps, 1)))
+	defer ticker.Stop()
+	slots := make(chan struct{}, max(g.opts.concurrency, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			// Every slot is busy: skip the tick rather than queue up
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			g.send()
+		}()
+	}
+}
+
+// send issues one request picked from the mix. Each request starts its
+// own trace rather than joining the task's span.
+func (g *loadgen) send() {
+	priority := g.priority()
+	method, path, body := g.pick()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, g.opts.url+path, body)
+	if err != nil {
+		g.record(0, nil, priority)
+		return
+	}
+	if g.opts.forced() {
+		setTraceHeaders(req.Header, priority, g.opts.origin)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if g.opts.apiKey != "" {
+		req.Header.Set("X-API-Key", g.opts.apiKey)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		g.record(0, nil, priority)
+		return
+	}
+	defer resp.Body.Close()
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if method == http.MethodPost && resp.StatusCode == http.StatusCreated {
+		json.NewDecoder(resp.Body).Decode(&created)
+	} else {
+		io.Copy(io.Discard, resp.Body)
+	}
+	g.record(resp.StatusCode, &created.ID, priority)
+}
+
+// priority returns the sampling priority of the next trace, empty when
+// the tracer decides. Mixed sampling keeps the first keepPercent of every
+// hundred requests, so runs are repeatable.
+func (g *loadgen) priority() string {
+	switch g.opts.sampling {
+	case samplingKeep:
+		return priorityUserKeep
+	case samplingDrop:
+		return priorityUserDrop
+	case samplingMixed:
+		if int((g.sent.Add(1)-1)%100) < g.opts.keepPercent {
+			return priorityUserKeep
+		}
+		return priorityUserDrop
+	}
+	return ""
+}
+
+// setTraceHeaders starts a new trace in the Datadog propagation headers of
+// h, with the given sampling priority and origin when they are set
+func setTraceHeaders(h http.Header, priority, origin string) {
+	h.Set("x-datadog-trace-id", strconv.FormatUint(rand.Uint64()>>1|1, 10))
+	h.Set("x-datadog-parent-id", strconv.FormatUint(rand.Uint64()>>1|1, 10))
+	if priority != "" {
+		h.Set("x-datadog-sampling-priority", priority)
+		// Decision maker -4 records a manual decision
+		h.Set("x-datadog-tags", "_dd.p.dm=-4")
+	}
+	if origin != "" {
+		h.Set("x-datadog-origin", origin)
+	}
+}
+
+// pick returns the next request: mostly reads, some creates
+func (g *loadgen) pick() (method, path string, body io.Reader) {
+	g.mu.Lock()
+	id := ""
+	if len(g.ids) > 0 {
+		id = g.ids[rand.IntN(len(g.ids))]
+	}
+	g.mu.Unlock()
+
+	switch n := rand.IntN(100); {
+	case n < 10 || id == "":
+		user := sampleUser()
+		b, _ := json.Marshal(user)
+		return http.MethodPost, "/api/v1/users", bytes.NewReader(b)
+	case n < 40:
+		return http.MethodGet, "/api/v1/users/" + id, nil
+	case n < 60:
+		return http.MethodGet, "/api/v1/users/suggest?q=" + firstNames[rand.IntN(len(firstNames))][:2], nil
+	case n < 75:
+		city := cities[rand.IntN(len(cities))]
+		return http.MethodGet, fmt.Sprintf("/api/v1/users/nearby?lat=%f&lng=%f", city.Lat, city.Lng), nil
+	case n < 80:
+		// Exercises the 404 path
+		return http.MethodGet, "/api/v1/users/000000000000000000000000", nil
+	default:
+		return http.MethodGet, "/api/v1/users", nil
+	}
+}
+
+// record counts a response, status 0 being a transport failure, and keeps
+// the ID of a created user for later reads
+func (g *loadgen) record(status int, createdID *string, priority string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if status == 0 {
+		g.failures++
+	} else {
+		g.statuses[status]++
+	}
+	if createdID != nil && *createdID != "" {
+		g.ids = append(g.ids, *createdID)
+	}
+	tags := []string{"status:" + strconv.Itoa(status), "sampling:" + samplingTag(priority)}
+	if g.opts.origin != "" {
+		tags = append(tags, "origin:"+g.opts.origin)
+	}
+	g.deps.Metrics.Incr("loadgen.requests", tags, 1)
+}
+
+// samplingTag names a sampling priority in metric tags
+func samplingTag(priority string) string {
+	switch priority {
+	case priorityUserKeep:
+		return samplingKeep
+	case priorityUserDrop:
+		return samplingDrop
+	}
+	return samplingAuto
+}
+
+// report prints the response counts
+func (g *loadgen) report(w io.Writer) {
+	codes := make([]int, 0, len(g.statuses))
+	total := g.failures
+	for code, n := range g.statuses {
+		codes = append(codes, code)
+		total += n
+	}
+	sort.Ints(codes)
+	fmt.Fprintf(w, "Sent %d request(s)\n", total)
+	for _, code := range codes {
+		fmt.Fprintf(w, "  %d: %d\n", code, g.statuses[code])
+	}
+	if g.failures > 0 {
+		fmt.Fprintf(w, "  failed: %d\n", g.failures)
+	}
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"datadog-golang-example/internal/app"
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/migrate"
+	"datadog-golang-example/internal/repo"
+)
+
+// newMigrateCommand groups the data migration commands
+func newMigrateCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply, roll back or list data migrations",
+	}
+	cmd.AddCommand(newIndexesCommand(cfg))
+
+	var steps int
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Roll back the latest applied migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runMigrator(cmd, cfg, "migrate.down", func(ctx context.Context, m *migrate.Migrator) error {
+				done, err := m.Down(ctx, steps)
+				fmt.Fprintf(cmd.OutOrStdout(), "Rolled back %d migration(s)\n", len(done))
+				return err
+			})
+		},
+	}
+	down.Flags().IntVar(&steps, "steps", 1, "number of migrations to roll back")
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "up",
+			Short: "Apply every pending migration",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return runMigrator(cmd, cfg, "migrate.up", func(ctx context.Context, m *migrate.Migrator) error {
+					done, err := m.Up(ctx)
+					fmt.Fprintf(cmd.OutOrStdout(), "Applied %d migration(s)\n", len(done))
+					return err
+				})
+			},
+		},
+		down,
+		&cobra.Command{
+			Use:   "status",
+			Short: "List the migrations and when they were applied",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return runMigrator(cmd, cfg, "migrate.status", func(ctx context.Context, m *migrate.Migrator) error {
+					statuses, err := m.Status(ctx)
+					if err != nil {
+						return err
+					}
+					w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+					fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+					for _, s := range statuses {
+						applied := "pending"
+						if s.AppliedAt != nil {
+							applied = s.AppliedAt.Format(time.RFC3339)
+						}
+						fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+					}
+					return w.Flush()
+				})
+			},
+		},
+	)
+	return cmd
+}
+
+// runMigrator runs fn as a task with a Migrator for the configured database
+func runMigrator(cmd *cobra.Command, cfg *config.Config, name string, fn func(context.Context, *migrate.Migrator) error) error {
+	return app.RunTask(cmd.Context(), *cfg, app.Task{
+		Name:  name,
+		Mongo: true,
+		Run: func(ctx context.Context, deps app.TaskDeps) error {
+			return fn(ctx, migrate.New(deps.DB, migrate.Migrations))
+		},
+	})
+}
+
+// newIndexesCommand creates the missing indexes of the registry and
+// reports the drift of the database from it
+func newIndexesCommand(cfg *config.Config) *cobra.Command {
+	var check bool
+	cmd := &cobra.Command{
+		Use:   "indexes",
+		Short: "Create missing indexes and report index drift",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return app.RunTask(cmd.Context(), *cfg, app.Task{
+				Name:  "migrate.indexes",
+				Mongo: true,
+				Run: func(ctx context.Context, deps app.TaskDeps) error {
+					m := repo.NewIndexManager(deps.DB, app.MongoIndexes(*cfg), deps.Metrics)
+					var drift repo.IndexDrift
+					var err error
+					if check {
+						drift, err = m.Drift(ctx)
+						m.Report(drift)
+					} else {
+						drift, err = m.Sync(ctx)
+					}
+					if err != nil {
+						return err
+					}
+					w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+					fmt.Fprintln(w, "DRIFT\tINDEX\tDETAIL")
+					for _, s := range drift.Missing {
+						fmt.Fprintf(w, "missing\t%s\t\n", s)
+					}
+					for _, c := range drift.Conflicting {
+						fmt.Fprintf(w, "conflicting\t%s\t%s, want %s\n", c.Have, c.Reason, c.Want)
+					}
+					for _, name := range drift.Unexpected {
+						fmt.Fprintf(w, "unexpected\t%s\t\n", name)
+					}
+					if err := w.Flush(); err != nil {
+						return err
+					}
+					if check && !drift.Empty() {
+						return errors.New("indexes drifted from the registry")
+					}
+					return nil
+				},
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&check, "check", false, "only report the drift, failing when there is any")
+	return cmd
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"datadog-golang-example/internal/app"
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/repo"
+	"datadog-golang-example/internal/service"
+)
+
+// newReconcileCommand recounts the derived counters of the users once and
+// fixes those that drifted, as the server does periodically
+func newReconcileCommand(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "reconcile-counters",
+		Short: "Recount the derived counters of the users and fix their drift",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return app.RunTask(cmd.Context(), *cfg, app.Task{
+				Name:  "reconcile-counters",
+				Users: true,
+				Run: func(ctx context.Context, deps app.TaskDeps) error {
+					marks := repo.NewMongoWatermarks(deps.DB.Collection("watermarks"))
+					reconciler := service.NewCounterReconciler(deps.Counters, marks, deps.Metrics, 0)
+					drifts, err := reconciler.Reconcile(ctx)
+					for _, d := range drifts {
+						fmt.Fprintf(cmd.OutOrStdout(), "%s: checked %d, drifted %d (off by %d), fixed %d, orphans %d\n",
+							d.Counter, d.Checked, d.Drifted, d.Delta, d.Fixed, d.Orphans)
+					}
+					return err
+				},
+			})
+		},
+	}
+}
@@ -0,0 +1,51 @@
+// Package cli implements the app command line: the server and the
+// operational tasks that run against the same configuration.
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/logging"
+)
+
+// Execute runs the command named by the arguments until it completes or
+// the process is interrupted
+func Execute() error {
+	// Stop gracefully on Ctrl+C or docker stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return newRootCommand().ExecuteContext(ctx)
+}
+
+// newRootCommand builds the command tree. The configuration is loaded
+// from the environment once, before any subcommand runs.
+func newRootCommand() *cobra.Command {
+	cfg := new(config.Config)
+	root := &cobra.Command{
+		Use:           "app",
+		Short:         "Go API demo instrumented with Datadog",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRun: func(*cobra.Command, []string) {
+			*cfg = config.Load()
+			logging.Setup(cfg.Log)
+		},
+	}
+	root.AddCommand(
+		newServeCommand(cfg),
+		newMigrateCommand(cfg),
+		newSeedCommand(cfg),
+		newLoadgenCommand(cfg),
+		newRotateKeysCommand(cfg),
+		newReconcileCommand(cfg),
+		newDigestCommand(cfg),
+		newInspectCommand(cfg),
+	)
+	return root
+}
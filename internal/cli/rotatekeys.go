@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"datadog-golang-example/internal/app"
+	"datadog-golang-example/internal/config"
+)
+
+// newRotateKeysCommand re-encrypts the emails of the configured user store
+// with the current key, so older keys can be removed from
+// FIELD_ENCRYPTION_KEYS afterwards
+func newRotateKeysCommand(cfg *config.Config) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rotate-keys",
+		Short: "Re-encrypt stored emails with the current encryption key",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return app.RunTask(cmd.Context(), *cfg, app.Task{
+				Name:  "rotate-keys",
+				Users: true,
+				Run: func(ctx context.Context, deps app.TaskDeps) error {
+					if deps.Keys == nil {
+						return errors.New("no encryption keys configured, set FIELD_ENCRYPTION_KEYS")
+					}
+					n, err := deps.RotateEmails(ctx, deps.Keys)
+					fmt.Fprintf(cmd.OutOrStdout(), "Re-encrypted %d email(s) with key %s\n", n, deps.Keys.Current())
+					return err
+				},
+			})
+		},
+	}
+}
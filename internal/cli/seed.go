@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+
+	"github.com/spf13/cobra"
+
+	"datadog-golang-example/internal/app"
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/fixtures"
+	"datadog-golang-example/internal/ids"
+	"datadog-golang-example/internal/mail"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+	"datadog-golang-example/internal/service"
+)
+
+// Sample data for generated users
+var (
+	firstNames = []string{"Ana", "Bruno", "Chloé", "David", "Elif", "Fatima", "Gabriel", "Hana", "Igor", "Joana", "Kofi", "Léa", "Marta", "Nuno", "Olga", "Pedro"}
+	lastNames  = []string{"Silva", "Santos", "Müller", "García", "Rossi", "Kowalski", "Nakamura", "Okafor", "Dubois", "Yilmaz", "Costa", "Novak"}
+	cities     = []model.LocationRequest{
+		{Lat: 38.7223, Lng: -9.1393},   // Lisbon
+		{Lat: 40.7128, Lng: -74.0060},  // New York
+		{Lat: 48.8566, Lng: 2.3522},    // Paris
+		{Lat: -23.5505, Lng: -46.6333}, // São Paulo
+		{Lat: 35.6762, Lng: 139.6503},  // Tokyo
+	}
+)
+
+// newSeedCommand creates sample users in the configured user store through
+// the user service, so they get usernames, folded names and versions like
+// users created over HTTP.
+// With --fixtures, the users and organizations of a fixtures file are
+// loaded instead.
+func newSeedCommand(cfg *config.Config) *cobra.Command {
+	var count int
+	var fixturesPath string
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Create sample users",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return app.RunTask(cmd.Context(), *cfg, app.Task{
+				Name:  "seed",
+				Users: true,
+				Run: func(ctx context.Context, deps app.TaskDeps) error {
+					users := deps.Users
+					if err := users.EnsureIndexes(ctx); err != nil {
+						return err
+					}
+					if fixturesPath != "" {
+						return seedFixtures(ctx, cmd.OutOrStdout(), users, repo.NewMongoOrgRepository(deps.DB.Collection("organizations")), fixturesPath)
+					}
+					generator, err := ids.New(cfg.IDs)
+					if err != nil {
+						return err
+					}
+					// Seeded users get no welcome email
+					svc := service.NewUserService(users, generator, cfg.Suggest, discardTasks{}, mail.LogMailer{}, nil, nil, nil, nil)
+					for i := 0; i < count; i++ {
+						if _, err := svc.Create(ctx, sampleUser()); err != nil {
+							return fmt.Errorf("seed user %d: %w", i+1, err)
+						}
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "Created %d user(s)\n", count)
+					return nil
+				},
+			})
+		},
+	}
+	cmd.Flags().IntVar(&count, "count", 50, "number of users to create")
+	cmd.Flags().StringVar(&fixturesPath, "fixtures", "", "YAML or JSON fixtures file to load instead of creating random users")
+	return cmd
+}
+
+// seedFixtures loads the fixtures file at path
+func seedFixtures(ctx context.Context, w io.Writer, users repo.UserRepository, orgs repo.OrgRepository, path string) error {
+	set, err := fixtures.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	loaded, err := fixtures.NewLoader(users, orgs).Load(ctx, set)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Loaded %d organization(s) and %d user(s), %d of them new\n", len(loaded.Orgs), len(loaded.Users), loaded.Created)
+	return nil
+}
+
+// sampleUser returns a random user living near one of the sample cities
+func sampleUser() model.CreateUserRequest {
+	first, last := firstNames[rand.IntN(len(firstNames))], lastNames[rand.IntN(len(lastNames))]
+	city := cities[rand.IntN(len(cities))]
+	return model.CreateUserRequest{
+		Name:  first + " " + last,
+		Email: fmt.Sprintf("%s.%s.%06d@example.com", model.Slugify(first), model.Slugify(last), rand.IntN(1000000)),
+		Age:   18 + rand.IntN(63),
+		Location: &model.LocationRequest{
+			Lat: city.Lat + (rand.Float64()-0.5)/10,
+			Lng: city.Lng + (rand.Float64()-0.5)/10,
+		},
+	}
+}
+
+// discardTasks drops the post-write work; a one-off command has no cache
+// to invalidate
+type discardTasks struct{}
+
+func (discardTasks) Submit(context.Context, string, func(context.Context) error) error {
+	return nil
+}
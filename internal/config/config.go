@@ -0,0 +1,985 @@
+// Package config loads the application configuration from the environment.
+package config
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds every setting the application needs to start
+type Config struct {
+	HTTP       HTTPConfig
+	Mongo      MongoConfig
+	Datadog    DatadogConfig
+	Suggest    SuggestConfig
+	Auth       AuthConfig
+	RateLimit  RateLimitConfig
+	Redis      RedisConfig
+	Quota      QuotaConfig
+	SLO        SLOConfig
+	Chaos      ChaosConfig
+	Client     ClientConfig
+	Debug      DebugConfig
+	Worker     WorkerConfig
+	Mail       MailConfig
+	RUM        RUMConfig
+	Cache      CacheConfig
+	Shadow     ShadowConfig
+	Preflight  PreflightConfig
+	GRPC       GRPCConfig
+	Log        LogConfig
+	Reload     ReloadConfig
+	Dedup      DedupConfig
+	EmailCheck EmailCheckConfig
+	Encryption EncryptionConfig
+	DBBudget   DBBudgetConfig
+	Events     EventsConfig
+	Baggage    BaggageConfig
+	Export     ExportConfig
+	Storage    StorageConfig
+	Counters   CountersConfig
+	Brownout   BrownoutConfig
+	IDs        IDConfig
+	Digest     DigestConfig
+	Import     ImportConfig
+	Audit      AuditConfig
+	UserStats  UserStatsConfig
+	UserStore  UserStoreConfig
+	Profiles   ProfilesConfig
+}
+
+// HTTPConfig holds the HTTP server settings
+type HTTPConfig struct {
+	Addr            string
+	ShutdownTimeout time.Duration
+	// GinMode is release, debug or test
+	GinMode string
+	// ReadTimeout bounds the reading of a request, headers and body, and
+	// WriteTimeout the writing of its response, so it must outlast the
+	// longest route timeout (2m for streams and imports). IdleTimeout
+	// bounds how long an idle connection is kept open.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// MaxHeaderBytes bounds the size of the request headers
+	MaxHeaderBytes int
+	// TrustedProxies lists the proxy IPs and CIDRs whose X-Forwarded-For
+	// and X-Real-IP headers are believed; empty trusts no proxy
+	TrustedProxies []string
+	// GeoIPDatabase is the path of a MaxMind country database used to tag
+	// requests with the client's country; empty disables the lookup
+	GeoIPDatabase string
+	// DrainDelay is how long /readyz fails before the server shuts down,
+	// giving load balancers time to stop routing to this instance
+	DrainDelay time.Duration
+	// Middleware and APIMiddleware name the middleware chains of every
+	// route and of the /api/v1 routes, outermost first
+	Middleware    []string
+	APIMiddleware []string
+	// CORSOrigins are the origins the cors middleware allows, "*" for any
+	CORSOrigins []string
+	// ReadOnly starts the API in read-only mode, refusing the routes that
+	// write; the settings file and PUT /admin/read-only can switch it
+	ReadOnly bool
+}
+
+// MongoConfig holds the MongoDB connection settings
+type MongoConfig struct {
+	URI            string
+	Database       string
+	ConnectTimeout time.Duration
+	// AtlasSearchIndex enables Atlas Search autocomplete when set
+	AtlasSearchIndex string
+	// SortFields and FilterFields allow list parameters; fields without
+	// an index are dropped at startup
+	SortFields   []string
+	FilterFields []string
+	// ListFields are the fields lists read when they ask for none, and
+	// ListBatchSize the number of users their cursors ask for at a time
+	ListFields    []string
+	ListBatchSize int32
+	// SlowQueryThreshold is the duration above which commands are
+	// explained; zero disables it
+	SlowQueryThreshold time.Duration
+	// ReadPreference is the read preference mode, such as
+	// secondaryPreferred; reads and writes then use majority concerns
+	ReadPreference string
+	// CausalRoutes run in causally consistent sessions, as "METHOD /pattern"
+	CausalRoutes []string
+}
+
+// DatadogConfig holds the unified service tagging used by the tracer
+type DatadogConfig struct {
+	Service string
+	Env     string
+	Version string
+	// TraceAgentURL and DogStatsDAddr locate the agent, either over the
+	// network or as unix:// socket addresses
+	TraceAgentURL string
+	DogStatsDAddr string
+	// AgentCheckInterval is how often the trace agent is probed
+	AgentCheckInterval time.Duration
+	// StatsInterval is how often the pool and runtime stats are reported;
+	// zero disables them
+	StatsInterval time.Duration
+	// NoopFallback leaves the tracer off when the agent is unreachable at
+	// startup
+	NoopFallback bool
+	// DevExport writes finished spans as JSON lines to "stdout" or to a
+	// file instead of sending them to the agent; empty sends them
+	DevExport string
+	// RemoteConfig lets the tracer take its settings from the Datadog UI
+	// through Remote Configuration, and RemoteConfigTUFRoot is the TUF
+	// root their targets are verified against, if any; they are read from
+	// the variables the tracer reads
+	RemoteConfig        bool
+	RemoteConfigTUFRoot string
+}
+
+// SuggestConfig holds the typeahead suggestion settings
+type SuggestConfig struct {
+	Limit    int
+	Timeout  time.Duration
+	CacheTTL time.Duration
+}
+
+// AuthConfig holds the API keys accepted by the service
+type AuthConfig struct {
+	// APIKeys is a comma-separated list of name:key[:admin] entries
+	APIKeys string
+}
+
+// Limit is a token-bucket rate of RPS requests per second with bursts of
+// up to Burst requests. A zero RPS means unlimited.
+type Limit struct {
+	RPS   float64
+	Burst int
+}
+
+// Unlimited reports whether the limit imposes no restriction
+func (l Limit) Unlimited() bool {
+	return l.RPS <= 0
+}
+
+// TierLimits holds the limits applied to each authentication level of a
+// route group; admins are never limited
+type TierLimits struct {
+	Anonymous Limit
+	APIKey    Limit
+}
+
+// RateLimitConfig holds the rate limits of each route group
+type RateLimitConfig struct {
+	Groups map[string]TierLimits
+}
+
+// RedisConfig holds the Redis connection settings. Features backed by
+// Redis are disabled when Addr is empty.
+type RedisConfig struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
+// QuotaConfig holds the monthly request quotas of API-key clients
+type QuotaConfig struct {
+	Monthly   int64
+	Overrides map[string]int64
+}
+
+// DedupConfig controls the detection of duplicate POST requests. It needs
+// Redis, and a zero Window disables it.
+type DedupConfig struct {
+	// Window is how long an identical request replays the first response
+	Window time.Duration
+	// WaitTimeout bounds how long a duplicate waits for the first request
+	// to finish before it is rejected
+	WaitTimeout time.Duration
+}
+
+// DBBudgetConfig bounds the MongoDB operations of a single request
+type DBBudgetConfig struct {
+	// MaxOps is the budget per request; zero disables the guard
+	MaxOps int
+	// Enforce fails requests over budget instead of only reporting them,
+	// meant for development
+	Enforce bool
+}
+
+// EmailCheckConfig holds the settings of the email verification API
+// called on user creation. Checks are disabled when URL is empty.
+type EmailCheckConfig struct {
+	URL    string
+	APIKey string
+	// Mode is "reject" to refuse risky addresses or "flag" to accept them
+	// and mark the user
+	Mode     string
+	Timeout  time.Duration
+	CacheTTL time.Duration
+}
+
+// EncryptionConfig holds the data keys of field level encryption
+type EncryptionConfig struct {
+	// Keys is a comma-separated list of id:base64key entries, current key
+	// first; empty stores fields in clear
+	Keys string
+	// KeysFile holds Keys instead, as mounted by Docker or Kubernetes
+	// secrets
+	KeysFile string
+	// IndexKey is the base64 key of the blind indexes keeping the emails
+	// unique across key rotations; required with Keys and never rotated
+	IndexKey string
+}
+
+// SLOConfig holds the service level objectives the request events are
+// classified against
+type SLOConfig struct {
+	// AvailabilityTarget is the fraction of requests that must not fail
+	// with a server error, e.g. 0.999
+	AvailabilityTarget float64
+	// LatencyTarget is the fraction of requests that must complete within
+	// LatencyThreshold, e.g. 0.99 for a p99 objective
+	LatencyTarget    float64
+	LatencyThreshold time.Duration
+}
+
+// ChaosConfig holds the initial fault injection settings; they can be
+// changed at runtime through the admin API
+type ChaosConfig struct {
+	Enabled bool
+	// Rules is a JSON array of chaos rules, see package chaos
+	Rules string
+}
+
+// ClientConfig holds the settings of the outbound HTTP client
+type ClientConfig struct {
+	Timeout time.Duration
+}
+
+// DebugConfig controls the deliberately failing /_debug routes
+type DebugConfig struct {
+	Enabled bool
+	// DownstreamURL is called by the downstream failure scenario; it
+	// defaults to this service's own failing upstream endpoint
+	DownstreamURL string
+	// Pprof exposes net/http/pprof to admins under /debug/pprof
+	Pprof bool
+}
+
+// WorkerConfig sizes the background task pool
+type WorkerConfig struct {
+	Size        int
+	QueueSize   int
+	TaskTimeout time.Duration
+}
+
+// MailConfig holds the SMTP relay settings. Without SMTPAddr emails are
+// only logged.
+type MailConfig struct {
+	SMTPAddr string
+	Username string
+	Password string
+	From     string
+	Attempts int
+	Backoff  time.Duration
+}
+
+// RUMConfig holds the browser RUM settings of the demo front end. The
+// client token is public by design.
+type RUMConfig struct {
+	ApplicationID string
+	ClientToken   string
+	Site          string
+}
+
+// CacheConfig holds the response cache settings. Only the routes listed
+// in TTLs, keyed by route pattern, are cached.
+type CacheConfig struct {
+	Size int
+	TTLs map[string]time.Duration
+}
+
+// ShadowConfig enables dual writes to a shadow MongoDB database, used to
+// demo backend migrations
+type ShadowConfig struct {
+	Enabled bool
+	Mongo   MongoConfig
+}
+
+// PreflightConfig controls the startup checks. Checks named in Required
+// abort startup when they fail; the others only warn.
+type PreflightConfig struct {
+	Enabled     bool
+	Required    []string
+	RequiredEnv []string
+	Timeout     time.Duration
+}
+
+// GRPCConfig holds the gRPC server settings. The server only runs when
+// Addr is set.
+type GRPCConfig struct {
+	Addr string
+	// HealthInterval is how often the health service re-runs the
+	// readiness checks
+	HealthInterval time.Duration
+}
+
+// LogConfig holds the structured logger settings
+type LogConfig struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string
+}
+
+// ReloadConfig controls hot reloading of the runtime settings file. Only
+// settings that are safe to change while serving are read from it.
+type ReloadConfig struct {
+	// File is the JSON settings file; empty disables reloading
+	File     string
+	Interval time.Duration
+}
+
+// BaggageConfig allowlists the trace baggage of requests. Headers maps
+// baggage keys to the request headers that set them.
+type BaggageConfig struct {
+	Allowed []string
+	Headers map[string]string
+}
+
+// EventsConfig selects the event bus user changes are published on
+type EventsConfig struct {
+	// Bus is none, memory or nats
+	Bus string
+	// BufferSize bounds the events queued by the memory bus
+	BufferSize int
+	// NATSURL is the NATS server; empty embeds one in the process
+	NATSURL string
+	// NATSListen is the address the embedded server accepts other
+	// processes on; empty keeps it in-process
+	NATSListen string
+	// SubjectPrefix namespaces the NATS subjects of the events
+	SubjectPrefix string
+	// HistoryWindow is how far back user lists can be asked the changes
+	// of
+	HistoryWindow time.Duration
+}
+
+// ExportConfig sets where admin exports are written
+type ExportConfig struct {
+	// Sink is file or s3
+	Sink string
+	// Dir holds the files of the file sink
+	Dir string
+	// S3Bucket and S3Prefix locate the objects of the s3 sink
+	S3Bucket string
+	S3Prefix string
+	// Retain bounds the finished jobs kept for status polling
+	Retain int
+}
+
+// StorageConfig locates the object store of user attachments
+type StorageConfig struct {
+	// Bucket holds the attachments; empty disables them
+	Bucket string
+	// PathStyle addresses the bucket in the URL path, for S3-compatible
+	// stores such as MinIO
+	PathStyle bool
+	// URLTTL is how long presigned URLs stay valid
+	URLTTL time.Duration
+}
+
+// CountersConfig schedules the reconciliation of the derived counters of
+// the users
+type CountersConfig struct {
+	// ReconcileInterval is how often they are recounted; zero disables it
+	ReconcileInterval time.Duration
+}
+
+// BrownoutConfig sheds non-essential traffic while MongoDB is degraded
+type BrownoutConfig struct {
+	// CheckInterval is how often MongoDB is pinged; zero disables shedding
+	CheckInterval time.Duration
+	// SlowPing is the ping latency from which MongoDB counts as degraded
+	SlowPing time.Duration
+	// ShedPercent of the requests to Routes are rejected while degraded
+	ShedPercent int
+	// Routes are the non-essential routes, as "METHOD /pattern"
+	Routes []string
+}
+
+// IDConfig selects how the public IDs of new entities are generated
+type IDConfig struct {
+	// Strategy is objectid, uuidv7 or snowflake
+	Strategy string
+	// Node numbers the instance in Snowflake IDs, from 0 to 1023, and
+	// must differ between the instances sharing a database
+	Node int
+}
+
+// DigestConfig schedules the email digest of the new users
+type DigestConfig struct {
+	// Recipients get the digest; none disables it
+	Recipients []string
+	// Period is both how often it is sent and the span it covers
+	Period time.Duration
+}
+
+// ImportConfig bounds the imports of users from CSV files
+type ImportConfig struct {
+	// AllowedHosts are the hosts files are downloaded from, "*" for any
+	AllowedHosts []string
+	// MaxBytes and MaxRows bound the size of a file
+	MaxBytes int64
+	MaxRows  int
+	// BatchSize is the number of rows upserted in one bulk write
+	BatchSize int
+	// DownloadTimeout bounds the download of a file
+	DownloadTimeout time.Duration
+}
+
+// UserStatsConfig schedules the aggregation of the user statistics,
+// served from a snapshot
+type UserStatsConfig struct {
+	// RefreshInterval is how often the snapshot is recomputed in the
+	// background; zero only computes it on demand
+	RefreshInterval time.Duration
+	// MaxAge is the age past which a snapshot is recomputed on demand
+	MaxAge time.Duration
+	// Timeout bounds one aggregation
+	Timeout time.Duration
+}
+
+// UserStoreConfig selects the database the users are stored in. The
+// other records, such as organizations and the activity feed, stay in
+// MongoDB either way.
+type UserStoreConfig struct {
+	// Backend is mongo or mysql
+	Backend string
+	// MySQLDSN is the go-sql-driver/mysql DSN of the mysql backend
+	MySQLDSN string
+	// MySQLMaxOpenConns caps the connections of the pool; zero is no cap
+	MySQLMaxOpenConns int
+	ConnectTimeout    time.Duration
+}
+
+// ProfilesConfig controls GET /api/v1/profiles/:id, which fans out to the
+// user endpoint and to an enrichment service in parallel
+type ProfilesConfig struct {
+	Enabled bool
+	// UsersURL and EnrichmentURL are the base URLs the ID of the user is
+	// appended to; they default to this service's own user endpoint and
+	// mock enrichment service
+	UsersURL      string
+	EnrichmentURL string
+	// Timeout bounds each call of the fan-out
+	Timeout time.Duration
+	// EnrichmentService is the service name the mock enrichment service
+	// reports its spans under, and EnrichmentFailurePercent the share of
+	// its requests failing
+	EnrichmentService        string
+	EnrichmentFailurePercent int
+}
+
+// AuditConfig holds the key the audit trail is signed with
+type AuditConfig struct {
+	// SigningKey is a base64 key of at least 32 bytes; empty leaves the
+	// audit entries in the log only
+	SigningKey string
+}
+
+// Load reads the configuration from the environment, falling back to
+// defaults that match the docker-compose setup
+func Load() Config {
+	return Config{
+		HTTP: HTTPConfig{
+			Addr:            getEnv("HTTP_ADDR", ":8080"),
+			ShutdownTimeout: 5 * time.Second,
+			GinMode:         getEnv("GIN_MODE", "release"),
+			ReadTimeout:     getDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:    getDuration("HTTP_WRITE_TIMEOUT", 150*time.Second),
+			IdleTimeout:     getDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+			MaxHeaderBytes:  getInt("HTTP_MAX_HEADER_BYTES", 64<<10),
+			TrustedProxies:  getCIDRs("TRUSTED_PROXIES"),
+			GeoIPDatabase:   os.Getenv("GEOIP_DATABASE"),
+			DrainDelay:      getDuration("DRAIN_DELAY", 0),
+			Middleware:      getList("HTTP_MIDDLEWARE", "logger,baggage,tracing,client_metadata,analytics,slo,errors,recover,auth,read_only,db_budget,envelope"),
+			APIMiddleware:   getList("API_MIDDLEWARE", "brownout,ratelimit,quota,chaos,dry_run,dedup,cache,causal"),
+			CORSOrigins:     getList("CORS_ALLOWED_ORIGINS", "*"),
+			ReadOnly:        getBool("READ_ONLY", false),
+		},
+		Mongo: MongoConfig{
+			URI:                mongoURI(),
+			Database:           getEnv("MONGO_DB", "go_api_demo"),
+			ConnectTimeout:     10 * time.Second,
+			AtlasSearchIndex:   os.Getenv("MONGO_ATLAS_SEARCH_INDEX"),
+			SortFields:         getList("LIST_SORT_FIELDS", "username,name"),
+			FilterFields:       getList("LIST_FILTER_FIELDS", "tag,username,email"),
+			ListFields:         getList("LIST_FIELDS", defaultListFields),
+			ListBatchSize:      int32(getInt("LIST_BATCH_SIZE", 100)),
+			SlowQueryThreshold: getDuration("MONGO_SLOW_QUERY_THRESHOLD", 100*time.Millisecond),
+			ReadPreference:     getEnv("MONGO_READ_PREFERENCE", "primary"),
+			CausalRoutes: getList("MONGO_CAUSAL_ROUTES", "PUT /api/v1/users/:id,PATCH /api/v1/users/:id,PATCH /api/v1/users/bulk,"+
+				"POST /api/v1/users/:id/tags,DELETE /api/v1/users/:id/tags/:tag,POST /api/v1/orgs/:id/members"),
+		},
+		Datadog: DatadogConfig{
+			Service:             getEnv("DD_SERVICE", "go-api-demo"),
+			Env:                 getEnv("DD_ENV", "dev"),
+			Version:             getEnv("DD_VERSION", "1.0.0"),
+			TraceAgentURL:       traceAgentURL(),
+			DogStatsDAddr:       dogStatsDAddr(),
+			AgentCheckInterval:  getDuration("DD_AGENT_CHECK_INTERVAL", 30*time.Second),
+			StatsInterval:       getDuration("STATS_INTERVAL", 10*time.Second),
+			NoopFallback:        getBool("DD_TRACE_NOOP_FALLBACK", false),
+			DevExport:           os.Getenv("DD_TRACE_DEV_EXPORT"),
+			RemoteConfig:        getBool("DD_REMOTE_CONFIGURATION_ENABLED", true),
+			RemoteConfigTUFRoot: os.Getenv("DD_RC_TUF_ROOT"),
+		},
+		Suggest: SuggestConfig{
+			Limit:    getInt("SUGGEST_LIMIT", 10),
+			Timeout:  getDuration("SUGGEST_TIMEOUT", 200*time.Millisecond),
+			CacheTTL: getDuration("SUGGEST_CACHE_TTL", 30*time.Second),
+		},
+		Auth: AuthConfig{
+			APIKeys: os.Getenv("API_KEYS"),
+		},
+		RateLimit: RateLimitConfig{
+			Groups: map[string]TierLimits{
+				"api": getTierLimits("RATE_LIMIT_API", TierLimits{
+					Anonymous: Limit{RPS: 5, Burst: 10},
+					APIKey:    Limit{RPS: 50, Burst: 100},
+				}),
+				"search": getTierLimits("RATE_LIMIT_SEARCH", TierLimits{
+					Anonymous: Limit{RPS: 2, Burst: 5},
+					APIKey:    Limit{RPS: 20, Burst: 40},
+				}),
+			},
+		},
+		Redis: RedisConfig{
+			Addr:     os.Getenv("REDIS_ADDR"),
+			Password: os.Getenv("REDIS_PASSWORD"),
+			DB:       getInt("REDIS_DB", 0),
+		},
+		Quota: QuotaConfig{
+			Monthly:   int64(getInt("QUOTA_MONTHLY_REQUESTS", 100000)),
+			Overrides: getOverrides("QUOTA_OVERRIDES"),
+		},
+		EmailCheck: EmailCheckConfig{
+			URL:      os.Getenv("EMAIL_VERIFY_URL"),
+			APIKey:   os.Getenv("EMAIL_VERIFY_API_KEY"),
+			Mode:     getEnv("EMAIL_VERIFY_MODE", "flag"),
+			Timeout:  getDuration("EMAIL_VERIFY_TIMEOUT", 2*time.Second),
+			CacheTTL: getDuration("EMAIL_VERIFY_CACHE_TTL", 24*time.Hour),
+		},
+		Encryption: EncryptionConfig{
+			Keys:     os.Getenv("FIELD_ENCRYPTION_KEYS"),
+			KeysFile: os.Getenv("FIELD_ENCRYPTION_KEYS_FILE"),
+			IndexKey: os.Getenv("FIELD_ENCRYPTION_INDEX_KEY"),
+		},
+		DBBudget: DBBudgetConfig{
+			MaxOps:  getInt("DB_OPS_BUDGET", 25),
+			Enforce: getBool("DB_OPS_BUDGET_ENFORCE", false),
+		},
+		Dedup: DedupConfig{
+			Window:      getDuration("DEDUP_WINDOW", 10*time.Second),
+			WaitTimeout: getDuration("DEDUP_WAIT_TIMEOUT", 5*time.Second),
+		},
+		SLO: SLOConfig{
+			AvailabilityTarget: getFloat("SLO_AVAILABILITY_TARGET", 0.999),
+			LatencyTarget:      getFloat("SLO_LATENCY_TARGET", 0.99),
+			LatencyThreshold:   getDuration("SLO_LATENCY_THRESHOLD", 300*time.Millisecond),
+		},
+		Chaos: ChaosConfig{
+			Enabled: getBool("CHAOS_ENABLED", false),
+			Rules:   os.Getenv("CHAOS_RULES"),
+		},
+		Client: ClientConfig{
+			Timeout: getDuration("HTTP_CLIENT_TIMEOUT", 5*time.Second),
+		},
+		Debug: DebugConfig{
+			Enabled:       getBool("DEBUG_ROUTES_ENABLED", false),
+			DownstreamURL: getEnv("DEBUG_DOWNSTREAM_URL", "http://localhost:8080/api/v1/_debug/upstream"),
+			Pprof:         getBool("PPROF_ENABLED", false),
+		},
+		Worker: WorkerConfig{
+			Size:        getInt("WORKER_POOL_SIZE", 4),
+			QueueSize:   getInt("WORKER_QUEUE_SIZE", 100),
+			TaskTimeout: getDuration("WORKER_TASK_TIMEOUT", 10*time.Second),
+		},
+		Mail: MailConfig{
+			SMTPAddr: os.Getenv("SMTP_ADDR"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     getEnv("MAIL_FROM", "Go API Demo <no-reply@example.com>"),
+			Attempts: getInt("MAIL_SEND_ATTEMPTS", 3),
+			Backoff:  getDuration("MAIL_RETRY_BACKOFF", 500*time.Millisecond),
+		},
+		RUM: RUMConfig{
+			ApplicationID: os.Getenv("DD_RUM_APPLICATION_ID"),
+			ClientToken:   os.Getenv("DD_RUM_CLIENT_TOKEN"),
+			Site:          getEnv("DD_SITE", "datadoghq.com"),
+		},
+		Shadow: ShadowConfig{
+			Enabled: getBool("SHADOW_ENABLED", false),
+			Mongo: MongoConfig{
+				URI:            getEnv("SHADOW_MONGO_URI", mongoURI()),
+				Database:       getEnv("SHADOW_MONGO_DB", "go_api_demo_shadow"),
+				ConnectTimeout: 10 * time.Second,
+			},
+		},
+		Preflight: PreflightConfig{
+			Enabled:     getBool("PREFLIGHT_ENABLED", true),
+			Required:    getList("PREFLIGHT_REQUIRED", "env,mongodb,indexes"),
+			RequiredEnv: getList("PREFLIGHT_REQUIRED_ENV", ""),
+			Timeout:     getDuration("PREFLIGHT_TIMEOUT", 3*time.Second),
+		},
+		Cache: CacheConfig{
+			Size: getInt("RESPONSE_CACHE_SIZE", 1000),
+			TTLs: getRouteTTLs("RESPONSE_CACHE_TTLS", "/api/v1/users/:id=30s,/api/v1/users/by-username/:username=30s"),
+		},
+		GRPC: GRPCConfig{
+			Addr:           os.Getenv("GRPC_ADDR"),
+			HealthInterval: getDuration("GRPC_HEALTH_INTERVAL", 5*time.Second),
+		},
+		Log: LogConfig{
+			Level: getEnv("LOG_LEVEL", "info"),
+		},
+		Reload: ReloadConfig{
+			File:     os.Getenv("CONFIG_FILE"),
+			Interval: getDuration("CONFIG_RELOAD_INTERVAL", 10*time.Second),
+		},
+		Baggage: BaggageConfig{
+			Allowed: getList("BAGGAGE_ALLOWED_KEYS", "tenant,plan,experiment"),
+			Headers: getPairs("BAGGAGE_HEADERS", "tenant=X-Tenant-ID,plan=X-Plan,experiment=X-Experiment-Bucket"),
+		},
+		Events: EventsConfig{
+			Bus:           getEnv("EVENTS_BUS", "none"),
+			BufferSize:    getInt("EVENTS_BUFFER_SIZE", 1000),
+			NATSURL:       os.Getenv("NATS_URL"),
+			NATSListen:    os.Getenv("NATS_EMBEDDED_LISTEN"),
+			SubjectPrefix: getEnv("NATS_SUBJECT_PREFIX", "go_api_demo"),
+			HistoryWindow: getDuration("EVENTS_HISTORY_WINDOW", 30*24*time.Hour),
+		},
+		Export: ExportConfig{
+			Sink:     getEnv("EXPORT_SINK", "file"),
+			Dir:      getEnv("EXPORT_DIR", "exports"),
+			S3Bucket: os.Getenv("EXPORT_S3_BUCKET"),
+			S3Prefix: getEnv("EXPORT_S3_PREFIX", "exports/"),
+			Retain:   getInt("EXPORT_RETAIN_JOBS", 100),
+		},
+		Storage: StorageConfig{
+			Bucket:    os.Getenv("STORAGE_S3_BUCKET"),
+			PathStyle: getBool("STORAGE_S3_PATH_STYLE", false),
+			URLTTL:    getDuration("STORAGE_URL_TTL", 15*time.Minute),
+		},
+		Counters: CountersConfig{
+			ReconcileInterval: getDuration("COUNTERS_RECONCILE_INTERVAL", time.Hour),
+		},
+		Brownout: BrownoutConfig{
+			CheckInterval: getDuration("BROWNOUT_CHECK_INTERVAL", 5*time.Second),
+			SlowPing:      getDuration("BROWNOUT_SLOW_PING", 250*time.Millisecond),
+			ShedPercent:   getInt("BROWNOUT_SHED_PERCENT", 50),
+			Routes: getList("BROWNOUT_SHED_ROUTES", "GET /api/v1/users,POST /api/v1/users/query,GET /api/v1/users/suggest,"+
+				"GET /api/v1/users/nearby,GET /api/v1/activity,GET /api/v1/orgs/:id/members,GET /api/v1/users/:id/attachments"),
+		},
+		IDs: IDConfig{
+			Strategy: getEnv("ID_STRATEGY", "uuidv7"),
+			Node:     getInt("ID_NODE", 0),
+		},
+		Digest: DigestConfig{
+			Recipients: getList("DIGEST_RECIPIENTS", ""),
+			Period:     getDuration("DIGEST_PERIOD", 7*24*time.Hour),
+		},
+		Import: ImportConfig{
+			AllowedHosts:    getList("IMPORT_ALLOWED_HOSTS", "docs.google.com,.googleusercontent.com"),
+			MaxBytes:        int64(getInt("IMPORT_MAX_BYTES", 10<<20)),
+			MaxRows:         getInt("IMPORT_MAX_ROWS", 10000),
+			BatchSize:       getInt("IMPORT_BATCH_SIZE", 100),
+			DownloadTimeout: getDuration("IMPORT_DOWNLOAD_TIMEOUT", 30*time.Second),
+		},
+		Audit: AuditConfig{
+			SigningKey: os.Getenv("AUDIT_SIGNING_KEY"),
+		},
+		UserStats: UserStatsConfig{
+			RefreshInterval: getDuration("USER_STATS_REFRESH_INTERVAL", time.Minute),
+			MaxAge:          getDuration("USER_STATS_MAX_AGE", 5*time.Minute),
+			Timeout:         getDuration("USER_STATS_TIMEOUT", 30*time.Second),
+		},
+		UserStore: UserStoreConfig{
+			Backend:           getEnv("USER_STORE", "mongo"),
+			MySQLDSN:          getEnv("MYSQL_DSN", "root:password@tcp(mysql:3306)/go_api_demo"),
+			MySQLMaxOpenConns: getInt("MYSQL_MAX_OPEN_CONNS", 20),
+			ConnectTimeout:    10 * time.Second,
+		},
+		Profiles: ProfilesConfig{
+			Enabled:                  getBool("PROFILES_ENABLED", false),
+			UsersURL:                 getEnv("PROFILES_USERS_URL", "http://localhost:8080/api/v1/users/"),
+			EnrichmentURL:            getEnv("PROFILES_ENRICHMENT_URL", "http://localhost:8080/api/v1/_mock/enrichment/"),
+			Timeout:                  getDuration("PROFILES_TIMEOUT", 2*time.Second),
+			EnrichmentService:        getEnv("PROFILES_ENRICHMENT_SERVICE", "profile-enrichment"),
+			EnrichmentFailurePercent: getInt("PROFILES_ENRICHMENT_FAILURE_PERCENT", 10),
+		},
+	}
+}
+
+// mongoURI returns MONGO_URI or builds one from the individual MONGO_* variables
+func mongoURI() string {
+	if uri := os.Getenv("MONGO_URI"); uri != "" {
+		return uri
+	}
+	// Default connection string for docker-compose setup
+	user := getEnv("MONGO_USER", "root")
+	pass := getEnv("MONGO_PASSWORD", "password")
+	host := getEnv("MONGO_HOST", "mongodb")
+	return "mongodb://" + user + ":" + pass + "@" + host + ":27017/?authSource=admin"
+}
+
+// defaultListFields are every field of a user, so lists read all of them
+// but none of the internal ones
+const defaultListFields = "id,username,name,email,email_risk,age,location,tags,org_id," +
+	"created_at,updated_at,version,erased_at,attachments_count"
+
+// Socket paths of the agent in Kubernetes admission controller setups
+const (
+	defaultTraceSocket     = "/var/run/datadog/apm.socket"
+	defaultDogStatsDSocket = "/var/run/datadog/dsd.socket"
+)
+
+// traceAgentURL returns DD_TRACE_AGENT_URL, the default socket when
+// DD_USE_UDS is set, or the TCP address from DD_AGENT_HOST and
+// DD_TRACE_AGENT_PORT
+func traceAgentURL() string {
+	if url := os.Getenv("DD_TRACE_AGENT_URL"); url != "" {
+		return url
+	}
+	if getBool("DD_USE_UDS", false) {
+		return "unix://" + defaultTraceSocket
+	}
+	return "http://" + getEnv("DD_AGENT_HOST", "localhost") + ":" + getEnv("DD_TRACE_AGENT_PORT", "8126")
+}
+
+// dogStatsDAddr returns DD_DOGSTATSD_URL, the default socket when
+// DD_USE_UDS is set, or the UDP address from DD_AGENT_HOST and
+// DD_DOGSTATSD_PORT
+func dogStatsDAddr() string {
+	if url := os.Getenv("DD_DOGSTATSD_URL"); url != "" {
+		return url
+	}
+	if getBool("DD_USE_UDS", false) {
+		return "unix://" + defaultDogStatsDSocket
+	}
+	return getEnv("DD_AGENT_HOST", "localhost") + ":" + getEnv("DD_DOGSTATSD_PORT", "8125")
+}
+
+// getEnv returns the value of the environment variable or def when unset
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// getBool returns the environment variable parsed as a bool, or def when
+// unset or invalid
+func getBool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %t", key, v, def)
+		return def
+	}
+	return b
+}
+
+// getInt returns the environment variable parsed as an int, or def when
+// unset or invalid
+func getInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+// getFloat returns the environment variable parsed as a float, or def
+// when unset or invalid
+func getFloat(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %g", key, v, def)
+		return def
+	}
+	return f
+}
+
+// getDuration returns the environment variable parsed as a duration, or
+// def when unset or invalid
+func getDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %s", key, v, def)
+		return def
+	}
+	return d
+}
+
+// getTierLimits parses the tier limits spec in key, falling back to def
+// entirely when it is invalid
+func getTierLimits(key string, def TierLimits) TierLimits {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	limits, err := ParseTierLimits(v, def)
+	if err != nil {
+		log.Printf("Invalid %s=%q: %v, using defaults", key, v, err)
+		return def
+	}
+	return limits
+}
+
+// ParseTierLimits parses a spec such as "anonymous=5:10,api_key=50:100"
+// (requests per second and burst per tier). Tiers missing from the spec
+// keep the limits of def.
+func ParseTierLimits(v string, def TierLimits) (TierLimits, error) {
+	limits := def
+	for _, entry := range strings.Split(v, ",") {
+		tier, spec, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		limit, ok := parseLimit(spec)
+		if !ok {
+			return def, fmt.Errorf("invalid limit %q", spec)
+		}
+		switch tier {
+		case "anonymous":
+			limits.Anonymous = limit
+		case "api_key":
+			limits.APIKey = limit
+		default:
+			return def, fmt.Errorf("unknown tier %q", tier)
+		}
+	}
+	return limits, nil
+}
+
+// parseLimit parses "rps:burst"; a bare "0" means unlimited
+func parseLimit(spec string) (Limit, bool) {
+	rpsStr, burstStr, _ := strings.Cut(spec, ":")
+	rps, err := strconv.ParseFloat(rpsStr, 64)
+	if err != nil || rps < 0 {
+		return Limit{}, false
+	}
+	if rps == 0 {
+		return Limit{}, true
+	}
+	burst, err := strconv.Atoi(burstStr)
+	if err != nil || burst < 1 {
+		return Limit{}, false
+	}
+	return Limit{RPS: rps, Burst: burst}, true
+}
+
+// getOverrides parses a comma-separated list of name=value integers,
+// skipping invalid entries
+func getOverrides(key string) map[string]int64 {
+	overrides := make(map[string]int64)
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		name, v, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Printf("Invalid %s entry %q, skipping", key, entry)
+			continue
+		}
+		overrides[name] = n
+	}
+	return overrides
+}
+
+// getList returns the environment variable, or def when unset, split on
+// commas with blanks removed
+func getList(key, def string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, def), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getRouteTTLs parses a comma-separated list of route=duration pairs,
+// using def when the variable is unset and skipping invalid entries
+func getRouteTTLs(key, def string) map[string]time.Duration {
+	ttls := make(map[string]time.Duration)
+	for _, entry := range strings.Split(getEnv(key, def), ",") {
+		route, v, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			log.Printf("Invalid %s entry %q, skipping", key, entry)
+			continue
+		}
+		ttls[route] = ttl
+	}
+	return ttls
+}
+
+// getPairs parses a comma-separated list of key=value pairs, using def
+// when the variable is unset and skipping invalid entries
+func getPairs(key, def string) map[string]string {
+	pairs := make(map[string]string)
+	for _, entry := range strings.Split(getEnv(key, def), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || k == "" || v == "" {
+			if entry != "" {
+				log.Printf("Invalid %s entry %q, skipping", key, entry)
+			}
+			continue
+		}
+		pairs[k] = v
+	}
+	return pairs
+}
+
+// getCIDRs parses a comma-separated list of IPs and CIDRs, skipping
+// invalid entries
+func getCIDRs(key string) []string {
+	var cidrs []string
+	for _, entry := range strings.Split(os.Getenv(key), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, err := netip.ParsePrefix(entry); err != nil {
+			if _, err := netip.ParseAddr(entry); err != nil {
+				log.Printf("Invalid %s entry %q, skipping", key, entry)
+				continue
+			}
+		}
+		cidrs = append(cidrs, entry)
+	}
+	return cidrs
+}
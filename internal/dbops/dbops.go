@@ -0,0 +1,79 @@
+// Package dbops counts the MongoDB operations issued on behalf of a
+// request, so handlers doing far more queries than expected, such as N+1
+// lookups, stand out.
+package dbops
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// ErrBudgetExceeded is the cause of the request context cancelled by an
+// enforcing Counter
+var ErrBudgetExceeded = errors.New("database operations budget exceeded")
+
+// counterKey is the context key of the Counter
+type counterKey struct{}
+
+// Counter counts the operations of one request against a budget
+type Counter struct {
+	budget int64
+	n      atomic.Int64
+	// cancel ends the request once the budget is exceeded; nil only counts
+	cancel context.CancelCauseFunc
+}
+
+// WithCounter returns a context whose operations are counted against
+// budget. With enforce, the context is cancelled with ErrBudgetExceeded
+// when the budget is exceeded, failing the operations that follow.
+func WithCounter(ctx context.Context, budget int, enforce bool) (context.Context, *Counter) {
+	c := &Counter{budget: int64(budget)}
+	if enforce {
+		ctx, c.cancel = context.WithCancelCause(ctx)
+	}
+	return context.WithValue(ctx, counterKey{}, c), c
+}
+
+// Count returns the number of operations so far
+func (c *Counter) Count() int {
+	return int(c.n.Load())
+}
+
+// Exceeded reports whether more operations than the budget were issued
+func (c *Counter) Exceeded() bool {
+	return c.n.Load() > c.budget
+}
+
+// Done releases the resources of an enforcing counter
+func (c *Counter) Done() {
+	if c.cancel != nil {
+		c.cancel(nil)
+	}
+}
+
+// add counts one operation
+func (c *Counter) add() {
+	if c.n.Add(1) > c.budget && c.cancel != nil {
+		c.cancel(ErrBudgetExceeded)
+	}
+}
+
+// Monitor counts every command started with a counting context, then
+// passes the events on to next
+func Monitor(next *event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			if c, ok := ctx.Value(counterKey{}).(*Counter); ok {
+				c.add()
+			}
+			if next.Started != nil {
+				next.Started(ctx, evt)
+			}
+		},
+		Succeeded: next.Succeeded,
+		Failed:    next.Failed,
+	}
+}
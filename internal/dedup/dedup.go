@@ -0,0 +1,112 @@
+// Package dedup detects duplicate submissions, such as double clicks, by
+// remembering the response to each request fingerprint in Redis for a
+// short window.
+package dedup
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"datadog-golang-example/internal/config"
+)
+
+// ErrInFlight is returned by Wait when the first request is still running
+// after the wait timeout
+var ErrInFlight = errors.New("dedup: original request still in flight")
+
+// pending marks a fingerprint whose first request has not finished
+const pending = "pending"
+
+// pollInterval is how often Wait checks for the first response
+const pollInterval = 50 * time.Millisecond
+
+// Response is the replayable response to the first request
+type Response struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Location    string `json:"location,omitempty"`
+	Body        []byte `json:"body"`
+}
+
+// Store keeps request fingerprints and their responses
+type Store struct {
+	rdb redis.UniversalClient
+	cfg config.DedupConfig
+}
+
+// NewStore creates a Store keeping its entries in rdb
+func NewStore(rdb redis.UniversalClient, cfg config.DedupConfig) *Store {
+	return &Store{rdb: rdb, cfg: cfg}
+}
+
+// Fingerprint identifies a request by its client and content
+func Fingerprint(client, method, uri string, body []byte) string {
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(client), []byte(method), []byte(uri)} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return "dedup:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Claim records key as in flight. It reports false when an identical
+// request was already seen within the window.
+func (s *Store) Claim(ctx context.Context, key string) (bool, error) {
+	return s.rdb.SetNX(ctx, key, pending, s.cfg.Window).Result()
+}
+
+// Complete stores the response of the claimed request for the rest of the
+// window
+func (s *Store) Complete(ctx context.Context, key string, resp Response) error {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, key, b, s.cfg.Window).Err()
+}
+
+// Release forgets a claimed request that failed, so it can be retried
+func (s *Store) Release(ctx context.Context, key string) error {
+	return s.rdb.Del(ctx, key).Err()
+}
+
+// Wait returns the response of the first request with key, waiting up to
+// WaitTimeout for it to finish. A nil response means the first request
+// failed and released its claim.
+func (s *Store) Wait(ctx context.Context, key string) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.WaitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		v, err := s.rdb.Get(ctx, key).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+			return nil, nil
+		case err != nil && ctx.Err() != nil:
+			return nil, ErrInFlight
+		case err != nil:
+			return nil, err
+		case v != pending:
+			var resp Response
+			if err := json.Unmarshal([]byte(v), &resp); err != nil {
+				return nil, err
+			}
+			return &resp, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ErrInFlight
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Package emailcheck asks an external email verification API whether an
+// address is risky, caching verdicts in Redis.
+package emailcheck
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/redis/go-redis/v9"
+
+	"datadog-golang-example/internal/config"
+)
+
+// Verdict is the outcome of verifying one address
+type Verdict struct {
+	// Status is "valid", "risky" or "invalid"
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Risky reports whether the address should not be trusted
+func (v Verdict) Risky() bool {
+	return v.Status != "valid"
+}
+
+// Verifier checks addresses against the verification API. The API is
+// called as GET <url>?email=<address> with the API key as a bearer token
+// and must answer with a Verdict.
+type Verifier struct {
+	cfg    config.EmailCheckConfig
+	client *http.Client
+	// rdb caches verdicts; nil disables the cache
+	rdb redis.UniversalClient
+}
+
+// NewVerifier creates a Verifier calling the API through client
+func NewVerifier(cfg config.EmailCheckConfig, client *http.Client, rdb redis.UniversalClient) *Verifier {
+	return &Verifier{cfg: cfg, client: client, rdb: rdb}
+}
+
+// Mode returns what to do with risky addresses: "reject" or "flag"
+func (v *Verifier) Mode() string {
+	return v.cfg.Mode
+}
+
+// Verify returns the verdict for email, from the cache when possible
+func (v *Verifier) Verify(ctx context.Context, email string) (_ Verdict, err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "emailcheck.verify")
+	defer func() { span.Finish(tracer.WithError(err)) }()
+
+	key := cacheKey(email)
+	if verdict, ok := v.cached(ctx, key); ok {
+		span.SetTag("emailcheck.cache_hit", true)
+		span.SetTag("emailcheck.status", verdict.Status)
+		return verdict, nil
+	}
+	span.SetTag("emailcheck.cache_hit", false)
+
+	verdict, err := v.call(ctx, email)
+	if err != nil {
+		return Verdict{}, err
+	}
+	span.SetTag("emailcheck.status", verdict.Status)
+	v.store(ctx, key, verdict)
+	return verdict, nil
+}
+
+// Forget drops the cached verdict for email, as when its owner is erased
+func (v *Verifier) Forget(ctx context.Context, email string) error {
+	if v.rdb == nil {
+		return nil
+	}
+	return v.rdb.Del(ctx, cacheKey(email)).Err()
+}
+
+// call asks the verification API
+func (v *Verifier) call(ctx context.Context, email string) (Verdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, v.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.URL+"?email="+url.QueryEscape(email), nil)
+	if err != nil {
+		return Verdict{}, err
+	}
+	if v.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+v.cfg.APIKey)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("verify email: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("verify email: unexpected status %d", resp.StatusCode)
+	}
+
+	var verdict Verdict
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return Verdict{}, fmt.Errorf("decode verdict: %w", err)
+	}
+	switch verdict.Status {
+	case "valid", "risky", "invalid":
+		return verdict, nil
+	default:
+		return Verdict{}, fmt.Errorf("verify email: unknown status %q", verdict.Status)
+	}
+}
+
+// cached returns the cached verdict for key, if any. Cache errors are
+// logged and treated as misses.
+func (v *Verifier) cached(ctx context.Context, key string) (Verdict, bool) {
+	if v.rdb == nil {
+		return Verdict{}, false
+	}
+	b, err := v.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Email verdict cache read failed: %v", err)
+		}
+		return Verdict{}, false
+	}
+	var verdict Verdict
+	if err := json.Unmarshal(b, &verdict); err != nil {
+		return Verdict{}, false
+	}
+	return verdict, true
+}
+
+// store caches verdict for CacheTTL
+func (v *Verifier) store(ctx context.Context, key string, verdict Verdict) {
+	if v.rdb == nil {
+		return
+	}
+	b, _ := json.Marshal(verdict)
+	if err := v.rdb.Set(ctx, key, b, v.cfg.CacheTTL).Err(); err != nil {
+		log.Printf("Email verdict cache write failed: %v", err)
+	}
+}
+
+// cacheKey hashes the address so the cache holds no personal data
+func cacheKey(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return "emailcheck:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,76 @@
+// Package events publishes the changes made to users on an event bus, so
+// other instances and services can react to them. The bus is in memory
+// or NATS, either a server of its own or one embedded in the process.
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"datadog-golang-example/internal/config"
+)
+
+// Types of the user events
+const (
+	UserCreated = "user.created"
+	UserUpdated = "user.updated"
+	UserDeleted = "user.deleted"
+	UserErased  = "user.erased"
+	// UserMerged is published for the user another one was merged into
+	UserMerged = "user.merged"
+)
+
+// Event is a change to a user
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// UserID is the public ID of the user
+	UserID string    `json:"user_id"`
+	At     time.Time `json:"at"`
+}
+
+// Handler processes a delivered event. Errors are logged and counted;
+// the event is not redelivered.
+type Handler func(ctx context.Context, e Event) error
+
+// Bus publishes events and delivers them to the subscribers of their type
+type Bus interface {
+	Publish(ctx context.Context, e Event) error
+	// Subscribe delivers the events whose type matches pattern, in which
+	// "*" matches one dot-separated token and a final ">" the rest
+	Subscribe(pattern string, h Handler) error
+	Close(ctx context.Context) error
+}
+
+// New creates the bus selected by cfg, nil when events are off
+func New(cfg config.EventsConfig) (Bus, error) {
+	switch cfg.Bus {
+	case "", "none":
+		return nil, nil
+	case "memory":
+		return NewMemoryBus(cfg.BufferSize), nil
+	case "nats":
+		return NewNATSBus(cfg)
+	default:
+		return nil, fmt.Errorf("unknown event bus %q, want none, memory or nats", cfg.Bus)
+	}
+}
+
+// matches reports whether an event type matches a subscription pattern,
+// with the wildcards of NATS subjects
+func matches(pattern, typ string) bool {
+	want, got := strings.Split(pattern, "."), strings.Split(typ, ".")
+	for i, token := range want {
+		switch {
+		case token == ">" && i == len(want)-1:
+			return len(got) > i
+		case i >= len(got):
+			return false
+		case token != "*" && token != got[i]:
+			return false
+		}
+	}
+	return len(want) == len(got)
+}
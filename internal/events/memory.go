@@ -0,0 +1,123 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+)
+
+// errClosed is returned by a closed bus
+var errClosed = errors.New("event bus closed")
+
+// envelope is an event queued in a MemoryBus with its trace headers
+type envelope struct {
+	event   Event
+	headers http.Header
+}
+
+// MemoryBus delivers events to the subscribers of this process. Events are
+// queued and delivered in order by one goroutine; publishing to a full
+// queue drops the event rather than block the write that caused it.
+type MemoryBus struct {
+	queue chan envelope
+	done  chan struct{}
+
+	mu     sync.RWMutex
+	subs   []subscription
+	closed bool
+}
+
+// subscription is a handler and the pattern of the events it receives
+type subscription struct {
+	pattern string
+	handler Handler
+}
+
+// NewMemoryBus creates a MemoryBus queueing up to size events
+func NewMemoryBus(size int) *MemoryBus {
+	b := &MemoryBus{
+		queue: make(chan envelope, size),
+		done:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Publish queues e for the subscribers
+func (b *MemoryBus) Publish(ctx context.Context, e Event) error {
+	headers := http.Header{}
+	span, _ := startPublish(ctx, "memory", e.Type, headers)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var err error
+	if b.closed {
+		err = errClosed
+	} else {
+		select {
+		case b.queue <- envelope{event: e, headers: headers}:
+		default:
+			err = errors.New("event queue full")
+		}
+	}
+	span.Finish(tracer.WithError(err))
+	return err
+}
+
+// Subscribe delivers the events matching pattern to h
+func (b *MemoryBus) Subscribe(pattern string, h Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errClosed
+	}
+	b.subs = append(b.subs, subscription{pattern: pattern, handler: h})
+	return nil
+}
+
+// Close stops accepting events and waits for the queued ones to be
+// delivered, or for ctx to end
+func (b *MemoryBus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pending returns the number of events queued and not delivered yet
+func (b *MemoryBus) Pending() int {
+	return len(b.queue)
+}
+
+// run delivers the queued events until the queue is closed
+func (b *MemoryBus) run() {
+	defer close(b.done)
+	for env := range b.queue {
+		b.mu.RLock()
+		subs := b.subs
+		b.mu.RUnlock()
+		for _, sub := range subs {
+			if matches(sub.pattern, env.event.Type) {
+				deliver("memory", env.event.Type, env.headers, sub.handler, env.event)
+			}
+		}
+	}
+}
+
+// logHandlerError logs the failure of a handler
+func logHandlerError(e Event, err error) {
+	log.Printf("Event handler failed for %s %s of user %s: %v", e.Type, e.ID, e.UserID, err)
+}
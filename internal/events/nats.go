@@ -0,0 +1,176 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+
+	"datadog-golang-example/internal/config"
+)
+
+// NATSBus publishes events as JSON messages on NATS subjects named after
+// their type. It connects to the server in the configuration, or embeds
+// one when there is none, so a single binary needs no broker.
+type NATSBus struct {
+	conn   *nats.Conn
+	prefix string
+	// embedded is the server run in-process, nil for an external one
+	embedded *server.Server
+
+	mu   sync.Mutex
+	subs []*nats.Subscription
+}
+
+// NewNATSBus connects to cfg.NATSURL, or starts an embedded server when
+// it is empty
+func NewNATSBus(cfg config.EventsConfig) (*NATSBus, error) {
+	b := &NATSBus{prefix: cfg.SubjectPrefix}
+	opts := []nats.Option{
+		nats.Name(cfg.SubjectPrefix),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Disconnected from NATS: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			log.Printf("Reconnected to NATS at %s", c.ConnectedUrl())
+		}),
+	}
+
+	url := cfg.NATSURL
+	if url == "" {
+		srv, err := startEmbedded(cfg.NATSListen)
+		if err != nil {
+			return nil, err
+		}
+		b.embedded = srv
+		opts = append(opts, nats.InProcessServer(srv))
+	}
+	conn, err := nats.Connect(url, opts...)
+	if err != nil {
+		b.shutdownEmbedded()
+		return nil, fmt.Errorf("connect to NATS: %w", err)
+	}
+	b.conn = conn
+	return b, nil
+}
+
+// startEmbedded runs a NATS server in the process. It only accepts
+// connections from other processes when listen is set.
+func startEmbedded(listen string) (*server.Server, error) {
+	opts := &server.Options{
+		ServerName: "embedded",
+		NoLog:      true,
+		NoSigs:     true,
+		DontListen: listen == "",
+	}
+	if listen != "" {
+		host, port, err := net.SplitHostPort(listen)
+		if err != nil {
+			return nil, fmt.Errorf("parse NATS listen address: %w", err)
+		}
+		if opts.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("parse NATS listen port: %w", err)
+		}
+		opts.Host = host
+	}
+
+	srv, err := server.NewServer(opts)
+	if err != nil {
+		return nil, fmt.Errorf("create embedded NATS server: %w", err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		srv.Shutdown()
+		return nil, fmt.Errorf("embedded NATS server not ready")
+	}
+	if listen != "" {
+		log.Printf("Embedded NATS server listening on %s", srv.Addr())
+	}
+	return srv, nil
+}
+
+// subject is the NATS subject of an event type or pattern
+func (b *NATSBus) subject(typ string) string {
+	if b.prefix == "" {
+		return typ
+	}
+	return b.prefix + "." + typ
+}
+
+// Publish sends e with the context of its span in the message headers
+func (b *NATSBus) Publish(ctx context.Context, e Event) error {
+	subject := b.subject(e.Type)
+	msg := nats.NewMsg(subject)
+	span, _ := startPublish(ctx, "nats", subject, msg.Header)
+	span.SetTag(ext.MessagingDestinationName, subject)
+
+	data, err := json.Marshal(e)
+	if err == nil {
+		msg.Data = data
+		err = b.conn.PublishMsg(msg)
+	}
+	span.Finish(tracer.WithError(err))
+	if err != nil {
+		return fmt.Errorf("publish %s: %w", subject, err)
+	}
+	return nil
+}
+
+// Subscribe delivers the events matching pattern to h. Each handler gets
+// its own subscription, whose messages are handled in order.
+func (b *NATSBus) Subscribe(pattern string, h Handler) error {
+	sub, err := b.conn.Subscribe(b.subject(pattern), func(msg *nats.Msg) {
+		var e Event
+		if err := json.Unmarshal(msg.Data, &e); err != nil {
+			log.Printf("Dropping malformed event on %s: %v", msg.Subject, err)
+			return
+		}
+		deliver("nats", msg.Subject, msg.Header, h, e)
+	})
+	if err != nil {
+		return fmt.Errorf("subscribe to %s: %w", pattern, err)
+	}
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+	return nil
+}
+
+// Close drains the subscriptions and the connection, handling the
+// messages already received, then stops the embedded server
+func (b *NATSBus) Close(ctx context.Context) error {
+	defer b.shutdownEmbedded()
+	if err := b.conn.Drain(); err != nil {
+		b.conn.Close()
+		return fmt.Errorf("drain NATS connection: %w", err)
+	}
+	for !b.conn.IsClosed() {
+		select {
+		case <-ctx.Done():
+			b.conn.Close()
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// shutdownEmbedded stops the embedded server, if any
+func (b *NATSBus) shutdownEmbedded() {
+	if b.embedded != nil {
+		b.embedded.Shutdown()
+		b.embedded.WaitForShutdown()
+	}
+}
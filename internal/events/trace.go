@@ -0,0 +1,53 @@
+package events
+
+import (
+	"context"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/baggage"
+)
+
+// startPublish starts the span of a publish and injects its context into
+// headers, which travel with the message
+func startPublish(ctx context.Context, system, subject string, headers map[string][]string) (*tracer.Span, context.Context) {
+	span, ctx := tracer.StartSpanFromContext(ctx, system+".publish",
+		tracer.ResourceName(subject),
+		tracer.SpanType(ext.SpanTypeMessageProducer),
+		tracer.Tag(ext.MessagingSystem, system),
+		tracer.Tag(ext.SpanKind, ext.SpanKindProducer),
+	)
+	baggage.Tag(span)
+	if err := tracer.Inject(span.Context(), tracer.HTTPHeadersCarrier(headers)); err != nil {
+		span.SetTag("events.inject_error", err.Error())
+	}
+	return span, ctx
+}
+
+// startConsume starts the span of a delivery as a child of the publish
+// whose context headers carry, or of nothing when they carry none
+func startConsume(system, subject string, headers map[string][]string) (*tracer.Span, context.Context) {
+	opts := []tracer.StartSpanOption{
+		tracer.ResourceName(subject),
+		tracer.SpanType(ext.SpanTypeMessageConsumer),
+		tracer.Tag(ext.MessagingSystem, system),
+		tracer.Tag(ext.SpanKind, ext.SpanKindConsumer),
+	}
+	if parent, err := tracer.Extract(tracer.HTTPHeadersCarrier(headers)); err == nil {
+		opts = append(opts, tracer.ChildOf(parent))
+	}
+	span, ctx := tracer.StartSpanFromContext(context.Background(), system+".consume", opts...)
+	baggage.Tag(span)
+	return span, ctx
+}
+
+// deliver runs h for e in the span of its delivery
+func deliver(system, subject string, headers map[string][]string, h Handler, e Event) {
+	span, ctx := startConsume(system, subject, headers)
+	err := h(ctx, e)
+	span.Finish(tracer.WithError(err))
+	if err != nil {
+		logHandlerError(e, err)
+	}
+}
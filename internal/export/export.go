@@ -0,0 +1,83 @@
+// Package export writes the files produced by admin exports to a sink,
+// a local directory or an S3 bucket.
+package export
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"datadog-golang-example/internal/config"
+)
+
+// Sink stores the named files of exports. Write stores the bytes written
+// by write, only once it returns without error, and returns where the
+// file can be found.
+type Sink interface {
+	Write(ctx context.Context, name string, write func(io.Writer) error) (location string, err error)
+}
+
+// New creates the sink selected by cfg
+func New(ctx context.Context, cfg config.ExportConfig) (Sink, error) {
+	switch cfg.Sink {
+	case "", "file":
+		return NewFileSink(cfg.Dir), nil
+	case "s3":
+		return NewS3Sink(ctx, cfg.S3Bucket, cfg.S3Prefix)
+	default:
+		return nil, fmt.Errorf("unknown export sink %q, want file or s3", cfg.Sink)
+	}
+}
+
+// FileSink writes exports as files of a local directory
+type FileSink struct {
+	dir string
+}
+
+// NewFileSink creates a sink writing to dir, created when missing
+func NewFileSink(dir string) *FileSink {
+	return &FileSink{dir: dir}
+}
+
+// Write implements Sink. The file is written under a temporary name and
+// renamed once complete, so readers never see a partial export.
+func (s *FileSink) Write(ctx context.Context, name string, write func(io.Writer) error) (string, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", fmt.Errorf("create export directory: %w", err)
+	}
+	path := filepath.Join(s.dir, name)
+	tmp, err := writeTemp(s.dir, write)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("rename export: %w", err)
+	}
+	return "file://" + path, nil
+}
+
+// writeTemp writes a temporary file of dir with write and returns its
+// path; the file is removed on error
+func writeTemp(dir string, write func(io.Writer) error) (string, error) {
+	f, err := os.CreateTemp(dir, ".export-*")
+	if err != nil {
+		return "", fmt.Errorf("create export file: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("close export file: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"datadog-golang-example/internal/storage"
+)
+
+// S3Sink uploads exports as objects of an S3 bucket. Its calls are
+// traced as aws.request spans of the s3 service.
+type S3Sink struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Sink creates a sink uploading to bucket under prefix, with the
+// client of storage.NewS3Client
+func NewS3Sink(ctx context.Context, bucket, prefix string) (*S3Sink, error) {
+	if bucket == "" {
+		return nil, errors.New("the s3 export sink needs EXPORT_S3_BUCKET")
+	}
+	client, err := storage.NewS3Client(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Sink{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+// Write implements Sink. The export is spooled to a temporary file, then
+// uploaded in one request of known length.
+func (s *S3Sink) Write(ctx context.Context, name string, write func(io.Writer) error) (string, error) {
+	tmp, err := writeTemp(os.TempDir(), write)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+
+	f, err := os.Open(tmp)
+	if err != nil {
+		return "", fmt.Errorf("open export file: %w", err)
+	}
+	defer f.Close()
+
+	key := s.prefix + name
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        f,
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("upload export: %w", err)
+	}
+	return "s3://" + s.bucket + "/" + key, nil
+}
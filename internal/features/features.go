@@ -0,0 +1,40 @@
+// Package features holds the feature flags toggled at runtime.
+package features
+
+import (
+	"maps"
+	"sync"
+)
+
+// Flags is a set of named boolean flags; it is safe for concurrent use.
+// Unknown flags are disabled.
+type Flags struct {
+	mu    sync.RWMutex
+	flags map[string]bool
+}
+
+// New creates an empty set of flags
+func New() *Flags {
+	return &Flags{flags: make(map[string]bool)}
+}
+
+// Enabled reports whether the named flag is on
+func (f *Flags) Enabled(name string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.flags[name]
+}
+
+// All returns a copy of every flag
+func (f *Flags) All() map[string]bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return maps.Clone(f.flags)
+}
+
+// Set replaces every flag
+func (f *Flags) Set(flags map[string]bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.flags = maps.Clone(flags)
+}
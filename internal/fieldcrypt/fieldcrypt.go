@@ -0,0 +1,190 @@
+// Package fieldcrypt encrypts single document fields at rest with an
+// AES-256-GCM envelope. Encryption is deterministic, like the
+// deterministic algorithm of MongoDB client-side field level encryption:
+// equal values encrypt equally under a key, so unique indexes and
+// equality queries keep working on the encrypted field. A value encrypts
+// differently under each key, so its blind index, an HMAC under a key
+// that is never rotated, keeps it unique while keys are being rotated.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks encrypted values, as "enc:<key id>:<payload>". Values
+// without it were stored before encryption and are read as they are.
+const prefix = "enc:"
+
+// ErrUnknownKey is returned when a value was encrypted with a key that is
+// not in the keyring
+var ErrUnknownKey = errors.New("fieldcrypt: unknown key")
+
+// key is one data key. The nonce of a value is derived from the value
+// with a separate MAC key, which makes encryption deterministic without
+// reusing a nonce for different values.
+type key struct {
+	aead  cipher.AEAD
+	nonce []byte
+}
+
+// Keyring holds the data keys. New values are encrypted with the current
+// key; the others remain readable until every value is rotated.
+type Keyring struct {
+	current string
+	ids     []string
+	keys    map[string]key
+	// index is the MAC key of the blind indexes
+	index []byte
+}
+
+// ParseKeys builds a Keyring from a comma-separated list of id:key
+// entries, where key is 32 bytes in base64. The first entry is the
+// current key, e.g. "2024-06:<new key>,2024-01:<old key>".
+func ParseKeys(spec string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]key)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid encryption key entry, want id:base64key")
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("duplicate encryption key %s", id)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(secret) != 32 {
+			return nil, fmt.Errorf("encryption key %s must be 32 bytes in base64", id)
+		}
+		block, err := aes.NewCipher(derive(secret, "encrypt"))
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		if k.current == "" {
+			k.current = id
+		}
+		k.ids = append(k.ids, id)
+		k.keys[id] = key{aead: aead, nonce: derive(secret, "nonce")}
+	}
+	if k.current == "" {
+		return nil, errors.New("no encryption key")
+	}
+	return k, nil
+}
+
+// SetIndexKey sets the key of the blind indexes, 32 bytes in base64. It
+// must stay the same for as long as the indexes are stored.
+func (k *Keyring) SetIndexKey(encoded string) error {
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(secret) != 32 {
+		return errors.New("the blind index key must be 32 bytes in base64")
+	}
+	k.index = derive(secret, "blind-index")
+	return nil
+}
+
+// BlindIndex returns the blind index of plaintext: equal for equal values
+// whatever key encrypts them, and revealing nothing else. The empty string
+// and a keyring without index key have none.
+func (k *Keyring) BlindIndex(plaintext string) string {
+	if plaintext == "" || k.index == nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.index)
+	mac.Write([]byte(plaintext))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// derive derives the subkey for purpose from secret
+func derive(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// Current returns the ID of the key new values are encrypted with
+func (k *Keyring) Current() string {
+	return k.current
+}
+
+// Encrypt encrypts plaintext with the current key. The empty string is
+// kept as is so optional fields stay absent.
+func (k *Keyring) Encrypt(plaintext string) string {
+	return k.encrypt(k.current, plaintext)
+}
+
+// encrypt encrypts plaintext with the key id
+func (k *Keyring) encrypt(id, plaintext string) string {
+	if plaintext == "" {
+		return ""
+	}
+	key := k.keys[id]
+	mac := hmac.New(sha256.New, key.nonce)
+	mac.Write([]byte(plaintext))
+	nonce := mac.Sum(nil)[:key.aead.NonceSize()]
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), []byte(id))
+	return prefix + id + ":" + base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// Decrypt returns the plaintext of value. Values that are not encrypted
+// are returned unchanged.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	id, payload, ok := split(value)
+	if !ok {
+		return value, nil
+	}
+	key, found := k.keys[id]
+	if !found {
+		return "", fmt.Errorf("%w %s", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < key.aead.NonceSize() {
+		return "", fmt.Errorf("fieldcrypt: malformed value under key %s", id)
+	}
+	n := key.aead.NonceSize()
+	plaintext, err := key.aead.Open(nil, sealed[:n], sealed[n:], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("fieldcrypt: decrypt under key %s: %w", id, err)
+	}
+	return string(plaintext), nil
+}
+
+// Candidates returns every form plaintext may be stored in: encrypted
+// under each key, and in clear for values written before encryption.
+// Equality queries match any of them while keys are being rotated.
+func (k *Keyring) Candidates(plaintext string) []string {
+	candidates := make([]string, 0, len(k.ids)+1)
+	for _, id := range k.ids {
+		candidates = append(candidates, k.encrypt(id, plaintext))
+	}
+	return append(candidates, plaintext)
+}
+
+// Stale reports whether value is in clear or under a key other than the
+// current one, and so should be rotated
+func (k *Keyring) Stale(value string) bool {
+	id, _, ok := split(value)
+	return value != "" && (!ok || id != k.current)
+}
+
+// split splits an encrypted value into its key ID and payload
+func split(value string) (id, payload string, ok bool) {
+	rest, ok := strings.CutPrefix(value, prefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
@@ -0,0 +1,203 @@
+// Package fixtures loads declarative sets of organizations and users from
+// YAML or JSON files, so integration tests, the seed command and the load
+// generator share the same known data. The IDs of the loaded documents
+// derive from their keys in the set, so they are the same on every load
+// and can be referenced before the set is loaded.
+package fixtures
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+
+	"datadog-golang-example/internal/model"
+)
+
+// Set is a set of fixtures. Users reference the organizations of the
+// same set by key.
+type Set struct {
+	Orgs  []Org  `json:"orgs" yaml:"orgs"`
+	Users []User `json:"users" yaml:"users"`
+}
+
+// Org is an organization fixture
+type Org struct {
+	// Key names the organization within the set, such as acme
+	Key  string `json:"key" yaml:"key"`
+	Name string `json:"name" yaml:"name"`
+}
+
+// User is a user fixture, validated as a created user would be
+type User struct {
+	// Key names the user within the set, such as ana
+	Key   string `json:"key" yaml:"key"`
+	Name  string `json:"name" yaml:"name"`
+	Email string `json:"email" yaml:"email"`
+	Age   int    `json:"age" yaml:"age"`
+	// Username is derived from the name when empty
+	Username string   `json:"username,omitempty" yaml:"username,omitempty"`
+	Tags     []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Org is the key of the organization of the user, if any
+	Org      string    `json:"org,omitempty" yaml:"org,omitempty"`
+	Location *Location `json:"location,omitempty" yaml:"location,omitempty"`
+	// CreatedAt is Epoch when zero
+	CreatedAt time.Time `json:"created_at,omitzero" yaml:"created_at,omitempty"`
+}
+
+// Location is where a user fixture lives
+type Location struct {
+	Lat float64 `json:"lat" yaml:"lat"`
+	Lng float64 `json:"lng" yaml:"lng"`
+}
+
+// Epoch is when the users of a set were created unless they say otherwise,
+// so loading a set twice stores the same documents
+var Epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// namespace is the UUID namespace the IDs of the fixtures are derived in
+var namespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/i3onilha/datadog-golang-example/fixtures"))
+
+// UserID returns the public ID of the user fixture with the given key
+func UserID(key string) string {
+	return uuid.NewSHA1(namespace, []byte("user/"+key)).String()
+}
+
+// OrgID returns the public ID of the organization fixture with the given
+// key
+func OrgID(key string) string {
+	return uuid.NewSHA1(namespace, []byte("org/"+key)).String()
+}
+
+// objectID derives the internal ID of a fixture from its public ID, so a
+// reload replaces the document instead of clashing on _id
+func objectID(publicID string) primitive.ObjectID {
+	var id primitive.ObjectID
+	sum := sha256.Sum256([]byte(publicID))
+	copy(id[:], sum[:len(id)])
+	return id
+}
+
+// ReadFile reads and validates the set in the file at path, decoded as
+// JSON for .json files and as YAML otherwise
+func ReadFile(path string) (*Set, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		format = "json"
+	}
+	s, err := Parse(b, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Parse decodes and validates a set in the given format, json or yaml.
+// Unknown fields are errors, so a mistyped field is not silently dropped.
+func Parse(b []byte, format string) (*Set, error) {
+	s := new(Set)
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(s); err != nil {
+			return nil, fmt.Errorf("decode fixtures: %w", err)
+		}
+	case "yaml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		// An empty file is an empty set
+		if err := dec.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("decode fixtures: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown fixtures format %q: expected json or yaml", format)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate checks that the keys of the set are unique, that its users are
+// valid and have distinct emails and usernames, and that they only
+// reference organizations of the set
+func (s *Set) Validate() error {
+	orgs := map[string]bool{}
+	for i, o := range s.Orgs {
+		switch {
+		case o.Key == "":
+			return fmt.Errorf("org %d: key is required", i+1)
+		case orgs[o.Key]:
+			return fmt.Errorf("org %s: key is declared twice", o.Key)
+		case o.Name == "":
+			return fmt.Errorf("org %s: name is required", o.Key)
+		}
+		orgs[o.Key] = true
+	}
+
+	users, emails, usernames := map[string]bool{}, map[string]string{}, map[string]string{}
+	for i, u := range s.Users {
+		switch {
+		case u.Key == "":
+			return fmt.Errorf("user %d: key is required", i+1)
+		case users[u.Key]:
+			return fmt.Errorf("user %s: key is declared twice", u.Key)
+		}
+		users[u.Key] = true
+		if u.Org != "" && !orgs[u.Org] {
+			return fmt.Errorf("user %s: org %s is not declared", u.Key, u.Org)
+		}
+		if err := model.Validate(u.request()); err != nil {
+			return fmt.Errorf("user %s: %w", u.Key, err)
+		}
+		if _, err := model.NormalizeTags(u.Tags); err != nil {
+			return fmt.Errorf("user %s: %w", u.Key, err)
+		}
+		email := strings.ToLower(u.Email)
+		if other, ok := emails[email]; ok {
+			return fmt.Errorf("user %s: email is already used by %s", u.Key, other)
+		}
+		emails[email] = u.Key
+		if u.Username != "" && model.Slugify(u.Username) != u.Username {
+			return fmt.Errorf("user %s: username must be lowercase letters, digits and hyphens", u.Key)
+		}
+		username := u.username()
+		if other, ok := usernames[username]; ok {
+			return fmt.Errorf("user %s: username %s is already used by %s", u.Key, username, other)
+		}
+		usernames[username] = u.Key
+	}
+	return nil
+}
+
+// request is the creation request the fixture is validated as
+func (u *User) request() model.CreateUserRequest {
+	req := model.CreateUserRequest{Name: u.Name, Email: u.Email, Age: u.Age}
+	if u.Location != nil {
+		req.Location = &model.LocationRequest{Lat: u.Location.Lat, Lng: u.Location.Lng}
+	}
+	return req
+}
+
+// username is the username the user is stored with
+func (u *User) username() string {
+	if u.Username != "" {
+		return u.Username
+	}
+	return model.Slugify(u.Name)
+}
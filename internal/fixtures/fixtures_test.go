@@ -0,0 +1,152 @@
+package fixtures
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// memoryUsers stores the users replaced by public ID
+type memoryUsers struct {
+	repo.UserRepository
+	users map[string]model.User
+}
+
+func (r *memoryUsers) Replace(_ context.Context, user *model.User, _ int64, upsert bool) (bool, error) {
+	_, found := r.users[user.PublicID]
+	if !found && !upsert {
+		return false, model.ErrNotFound
+	}
+	r.users[user.PublicID] = *user
+	return !found, nil
+}
+
+// memoryOrgs stores the organizations created by public ID
+type memoryOrgs struct {
+	orgs    map[string]model.Organization
+	creates int
+}
+
+func (r *memoryOrgs) Create(_ context.Context, org *model.Organization) error {
+	if _, found := r.orgs[org.PublicID]; found {
+		return model.ErrConflict
+	}
+	r.creates++
+	r.orgs[org.PublicID] = *org
+	return nil
+}
+
+func (r *memoryOrgs) Get(_ context.Context, publicID string) (*model.Organization, error) {
+	org, found := r.orgs[publicID]
+	if !found {
+		return nil, model.ErrNotFound
+	}
+	return &org, nil
+}
+
+// TestReadFile checks that the demo set and the JSON test set are valid
+func TestReadFile(t *testing.T) {
+	for path, users := range map[string]int{"../../fixtures/demo.yaml": 5, "testdata/set.json": 2} {
+		s, err := ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile(%s): %v", path, err)
+		}
+		if len(s.Users) != users {
+			t.Errorf("ReadFile(%s) has %d user(s), want %d", path, len(s.Users), users)
+		}
+	}
+}
+
+// TestIDs guards the IDs of the fixtures against changing, which would
+// orphan the documents of the sets already loaded
+func TestIDs(t *testing.T) {
+	if got, want := UserID("ana"), "f88c72d7-df0e-52c8-afd7-6f4e47e89811"; got != want {
+		t.Errorf("UserID(ana) = %s, want %s", got, want)
+	}
+	if got, want := OrgID("acme"), "d485008f-6e1e-5f9e-b892-1c1f9709b297"; got != want {
+		t.Errorf("OrgID(acme) = %s, want %s", got, want)
+	}
+	if _, err := model.ParseUserRef(UserID("ana")); err != nil {
+		t.Errorf("UserID(ana) is not a user ID clients can use: %v", err)
+	}
+	if UserID("acme") == OrgID("acme") {
+		t.Error("a user and an organization with the same key share their ID")
+	}
+}
+
+// TestLoadTwice checks that loading a set again stores the same users and
+// organizations, linked by their derived IDs
+func TestLoadTwice(t *testing.T) {
+	s, err := ReadFile("../../fixtures/demo.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := &memoryUsers{users: map[string]model.User{}}
+	orgs := &memoryOrgs{orgs: map[string]model.Organization{}}
+	loader := NewLoader(users, orgs)
+
+	first, err := loader.Load(context.Background(), s)
+	if err != nil {
+		t.Fatalf("first load: %v", err)
+	}
+	stored := users.users[UserID("ana")]
+	second, err := loader.Load(context.Background(), s)
+	if err != nil {
+		t.Fatalf("second load: %v", err)
+	}
+	if first.Created != 5 || second.Created != 0 {
+		t.Errorf("loads created %d and %d user(s), want 5 and 0", first.Created, second.Created)
+	}
+	if len(users.users) != 5 || orgs.creates != 2 {
+		t.Errorf("stored %d user(s) and created %d organization(s), want 5 and 2", len(users.users), orgs.creates)
+	}
+
+	ana := users.users[UserID("ana")]
+	if ana.ID != stored.ID || ana.OrgID != OrgID("acme") || ana.Username != "ana-silva" || !ana.CreatedAt.Equal(Epoch) {
+		t.Errorf("reloaded ana = %+v, first stored as %+v", ana, stored)
+	}
+	if ana.Location == nil || ana.Version != 1 || !slices.Equal(ana.Tags, []string{"admin", "lisbon"}) {
+		t.Errorf("ana stored as %+v", ana)
+	}
+	if kofi := users.users[UserID("kofi")]; kofi.Username != "kofi" || kofi.CreatedAt.Year() != 2023 || kofi.OrgID != "" {
+		t.Errorf("kofi stored as %+v", kofi)
+	}
+	for key, user := range second.Users {
+		if user.OrgID == "" {
+			continue
+		}
+		if _, found := orgs.orgs[user.OrgID]; !found {
+			t.Errorf("user %s references organization %s, which is not stored", key, user.OrgID)
+		}
+	}
+}
+
+// TestParseRejects checks the sets that are refused before anything is
+// written
+func TestParseRejects(t *testing.T) {
+	const ana = "{key: ana, name: Ana, email: ana@example.com, age: 30}"
+	tests := map[string]struct {
+		yaml string
+		want string
+	}{
+		"undeclared org":  {"users: [{key: ana, name: Ana, email: ana@example.com, age: 30, org: acme}]", "org acme is not declared"},
+		"duplicate user":  {"users: [" + ana + ", " + ana + "]", "key is declared twice"},
+		"duplicate org":   {"orgs: [{key: acme, name: Acme}, {key: acme, name: Acme}]", "key is declared twice"},
+		"missing key":     {"users: [{name: Ana, email: ana@example.com, age: 30}]", "key is required"},
+		"duplicate email": {"users: [" + ana + ", {key: ann, name: Ann, email: ANA@example.com, age: 30}]", "email is already used by ana"},
+		"username taken":  {"users: [" + ana + ", {key: ana2, name: Ana, email: ana2@example.com, age: 30}]", "username ana is already used by ana"},
+		"bad username":    {"users: [{key: ana, name: Ana, email: ana@example.com, age: 30, username: Ana}]", "username must be"},
+		"invalid user":    {"users: [{key: ana, name: Ana, email: nope, age: 30}]", "user ana"},
+		"unknown field":   {"users: [{key: ana, name: Ana, email: ana@example.com, age: 30, mail: x}]", "field mail not found"},
+	}
+	for name, tt := range tests {
+		_, err := Parse([]byte(tt.yaml), "yaml")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one containing %q", name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package fixtures
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// Loader writes sets of fixtures into the repositories
+type Loader struct {
+	users repo.UserRepository
+	orgs  repo.OrgRepository
+}
+
+// NewLoader creates a Loader writing to the given repositories. Users are
+// stamped by the hooks of repo.NewUserHooks, like users written over HTTP.
+func NewLoader(users repo.UserRepository, orgs repo.OrgRepository) *Loader {
+	return &Loader{users: repo.NewHookedUserRepository(users, repo.NewUserHooks()), orgs: orgs}
+}
+
+// Loaded is what a load wrote, by fixture key
+type Loaded struct {
+	Orgs  map[string]*model.Organization
+	Users map[string]*model.User
+	// Created is the number of users that were not stored yet
+	Created int
+}
+
+// Load writes the organizations of s, then its users. Organizations that
+// are already stored are kept, while users are replaced by their fixture,
+// so loading a set again undoes the changes made to its users since. A
+// load is not atomic: on error, the fixtures written before stay.
+func (l *Loader) Load(ctx context.Context, s *Set) (*Loaded, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	loaded := &Loaded{Orgs: map[string]*model.Organization{}, Users: map[string]*model.User{}}
+	for _, o := range s.Orgs {
+		org, err := l.loadOrg(ctx, o)
+		if err != nil {
+			return nil, fmt.Errorf("load org %s: %w", o.Key, err)
+		}
+		loaded.Orgs[o.Key] = org
+	}
+	for _, u := range s.Users {
+		user, err := u.user()
+		if err != nil {
+			return nil, fmt.Errorf("load user %s: %w", u.Key, err)
+		}
+		created, err := l.users.Replace(ctx, user, 0, true)
+		if err != nil {
+			return nil, fmt.Errorf("load user %s: %w", u.Key, err)
+		}
+		if created {
+			loaded.Created++
+		}
+		loaded.Users[u.Key] = user
+	}
+	return loaded, nil
+}
+
+// loadOrg returns the stored organization of o, creating it when missing.
+// A conflict means a concurrent load created it first.
+func (l *Loader) loadOrg(ctx context.Context, o Org) (*model.Organization, error) {
+	publicID := OrgID(o.Key)
+	org, err := l.orgs.Get(ctx, publicID)
+	if !errors.Is(err, model.ErrNotFound) {
+		return org, err
+	}
+	org = &model.Organization{
+		ID:        objectID(publicID),
+		PublicID:  publicID,
+		Name:      o.Name,
+		NameKey:   model.FoldName(o.Name),
+		CreatedAt: Epoch,
+	}
+	err = l.orgs.Create(ctx, org)
+	if errors.Is(err, model.ErrConflict) {
+		return l.orgs.Get(ctx, publicID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// user builds the user stored for the fixture
+func (u *User) user() (*model.User, error) {
+	tags, err := model.NormalizeTags(u.Tags)
+	if err != nil {
+		return nil, err
+	}
+	publicID := UserID(u.Key)
+	user := &model.User{
+		ID:        objectID(publicID),
+		PublicID:  publicID,
+		Username:  u.username(),
+		Name:      u.Name,
+		NameKey:   model.FoldName(u.Name),
+		Email:     u.Email,
+		Age:       u.Age,
+		Location:  u.request().Location.Point(),
+		Tags:      tags,
+		CreatedAt: u.CreatedAt,
+	}
+	if u.Org != "" {
+		user.OrgID = OrgID(u.Org)
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = Epoch
+	}
+	return user, nil
+}
@@ -0,0 +1,37 @@
+// Package geoip resolves client IPs to countries with a MaxMind database.
+package geoip
+
+import (
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// DB is an open MaxMind country or city database
+type DB struct {
+	reader *geoip2.Reader
+}
+
+// Open opens the database file at path
+func Open(path string) (*DB, error) {
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{reader: reader}, nil
+}
+
+// Country returns the ISO 3166-1 code of the country of ip, or "" when
+// the address is not in the database
+func (d *DB) Country(ip net.IP) (string, error) {
+	record, err := d.reader.Country(ip)
+	if err != nil {
+		return "", err
+	}
+	return record.Country.IsoCode, nil
+}
+
+// Close releases the database
+func (d *DB) Close() error {
+	return d.reader.Close()
+}
@@ -0,0 +1,133 @@
+// Package grpcserver runs the gRPC server. It implements the standard
+// grpc.health.v1.Health service so Kubernetes gRPC probes and service
+// meshes gate traffic on the same checks as /readyz.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"datadog-golang-example/internal/config"
+	httpapi "datadog-golang-example/internal/http"
+)
+
+// Readiness runs the readiness checks shared with /readyz
+type Readiness interface {
+	Readiness(ctx context.Context) httpapi.Readiness
+}
+
+// Server serves gRPC on the configured address
+type Server struct {
+	cfg       config.GRPCConfig
+	readiness Readiness
+	server    *grpc.Server
+	health    *health.Server
+	listener  net.Listener
+	done      chan struct{}
+}
+
+// New creates a Server whose health service reports readiness. Nothing
+// listens until Start is called.
+func New(cfg config.GRPCConfig, readiness Readiness) *Server {
+	s := &Server{
+		cfg:       cfg,
+		readiness: readiness,
+		server:    grpc.NewServer(),
+		health:    health.NewServer(),
+		done:      make(chan struct{}),
+	}
+	healthpb.RegisterHealthServer(s.server, s.health)
+	return s
+}
+
+// Start listens and runs the readiness checks once, so a busy port fails
+// the startup
+func (s *Server) Start(ctx context.Context) error {
+	lis, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	s.listener = lis
+	s.update(ctx)
+	return nil
+}
+
+// Serve answers RPCs and keeps the health statuses up to date until Stop
+// is called
+func (s *Server) Serve(context.Context) error {
+	go s.watch()
+	log.Printf("gRPC server running on %s", s.cfg.Addr)
+	if err := s.server.Serve(s.listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// Drain reports every service as NOT_SERVING from now on, while the
+// server keeps answering
+func (s *Server) Drain() {
+	s.health.Shutdown()
+}
+
+// Stop fails the health checks, then waits for in-flight RPCs until ctx
+// expires
+func (s *Server) Stop(ctx context.Context) error {
+	close(s.done)
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
+// watch re-runs the checks every HealthInterval until Stop is called, so
+// Watch streams see status changes
+func (s *Server) watch() {
+	ticker := time.NewTicker(s.cfg.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.update(context.Background())
+		}
+	}
+}
+
+// update publishes the overall readiness as the "" service and each check
+// under its own name
+func (s *Server) update(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	r := s.readiness.Readiness(ctx)
+	s.health.SetServingStatus("", servingStatus(r.Ready()))
+	for name, check := range r.Checks {
+		s.health.SetServingStatus(name, servingStatus(check.Status == "ok"))
+	}
+}
+
+// servingStatus converts a check outcome to its health protocol status
+func servingStatus(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// ActivityService is the business logic the activity handler depends on
+type ActivityService interface {
+	Feed(ctx context.Context, filter model.ActivityFilter) (*model.ActivityFeed, error)
+}
+
+// ActivityHandler serves the activity feed
+type ActivityHandler struct {
+	activity ActivityService
+}
+
+// NewActivityHandler creates an ActivityHandler backed by the given service
+func NewActivityHandler(activity ActivityService) *ActivityHandler {
+	return &ActivityHandler{activity: activity}
+}
+
+// defaultActivityPage is the page size of the feed when none is asked for
+const defaultActivityPage = 50
+
+// getActivity retrieves the recent mutations, newest first. The type,
+// user_id, since and before query parameters filter them, as in
+// ?type=user&since=2026-01-01T00:00:00Z, and limit and offset page
+// through them.
+func (h *ActivityHandler) getActivity(c *gin.Context) {
+	var params ActivityParams
+	if err := bindQuery(c, &params); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	filter := model.ActivityFilter{
+		Type:   params.Type,
+		UserID: params.UserID,
+		Since:  params.Since,
+		Before: params.Before,
+		Limit:  params.Limit,
+		Offset: params.Offset,
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultActivityPage
+	}
+
+	ctx := c.Request.Context()
+
+	feed, err := h.activity.Feed(ctx, filter)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	body := gin.H{"items": feed.Items, "counts": feed.Counts}
+	total := 0
+	for _, n := range feed.Counts {
+		total += n
+	}
+	if filter.Offset+len(feed.Items) < total {
+		body["next_offset"] = filter.Offset + len(feed.Items)
+	}
+	c.JSON(200, body)
+}
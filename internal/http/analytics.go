@@ -0,0 +1,46 @@
+package http
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/gin-gonic/gin"
+)
+
+// Analytics emits per-route, per-status and per-client usage metrics to
+// DogStatsD: a request counter, a latency distribution and request and
+// response size distributions, all sharing the same tags so one
+// dashboard can slice them consistently. Requests the client cancelled
+// are also counted in http.requests.cancelled, so they can be told apart
+// from the errors of the service. It must run outside ErrorHandler so it
+// observes the final status code.
+func Analytics(metrics statsd.ClientInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := c.Writer.Status()
+		tags := []string{
+			"route:" + route,
+			"method:" + c.Request.Method,
+			"status:" + strconv.Itoa(status),
+			"status_class:" + strconv.Itoa(status/100) + "xx",
+			"client:" + principal(c).Name,
+		}
+
+		_ = metrics.Incr("http.requests", tags, 1)
+		if status == statusClientClosedRequest {
+			_ = metrics.Incr("http.requests.cancelled", tags, 1)
+		}
+		_ = metrics.Distribution("http.request.duration", time.Since(start).Seconds(), tags, 1)
+		if c.Request.ContentLength > 0 {
+			_ = metrics.Distribution("http.request.size", float64(c.Request.ContentLength), tags, 1)
+		}
+		_ = metrics.Distribution("http.response.size", float64(max(c.Writer.Size(), 0)), tags, 1)
+	}
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/storage"
+)
+
+// AttachmentService is the business logic the attachment handlers depend
+// on
+type AttachmentService interface {
+	Create(ctx context.Context, ref model.UserRef, req model.CreateAttachmentRequest) (*model.Attachment, *storage.Presigned, error)
+	List(ctx context.Context, ref model.UserRef) ([]model.Attachment, error)
+	Download(ctx context.Context, ref model.UserRef, id string) (*model.Attachment, *storage.Presigned, error)
+}
+
+// AttachmentHandler serves the user attachment endpoints
+type AttachmentHandler struct {
+	attachments AttachmentService
+}
+
+// NewAttachmentHandler creates an AttachmentHandler backed by the given
+// service
+func NewAttachmentHandler(attachments AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{attachments: attachments}
+}
+
+// createAttachment records an attachment of the user and answers with
+// the presigned URL its bytes are then uploaded to
+func (h *AttachmentHandler) createAttachment(c *gin.Context) {
+	var req model.CreateAttachmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	a, upload, err := h.attachments.Create(ctx, userRef(c), req)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(201, gin.H{"attachment": a, "upload": upload})
+}
+
+// listAttachments retrieves the attachments of the user, newest first
+func (h *AttachmentHandler) listAttachments(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	attachments, err := h.attachments.List(ctx, userRef(c))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"attachments": attachments, "count": len(attachments)})
+}
+
+// getAttachment retrieves an attachment of the user with the presigned
+// URL to download its bytes from
+func (h *AttachmentHandler) getAttachment(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	a, download, err := h.attachments.Download(ctx, userRef(c), c.Param("attachment"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(200, gin.H{"attachment": a, "download": download})
+}
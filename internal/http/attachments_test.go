@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/ids"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+	"datadog-golang-example/internal/service"
+	"datadog-golang-example/internal/storage"
+)
+
+// memoryUsers holds one user, listed by the user routes
+type memoryUsers struct {
+	repo.UserRepository
+	user model.User
+}
+
+func (r *memoryUsers) Get(context.Context, model.UserRef) (*model.User, error) {
+	user := r.user
+	return &user, nil
+}
+
+func (r *memoryUsers) List(context.Context, model.UserFilter) ([]model.User, error) {
+	return []model.User{r.user}, nil
+}
+
+// memoryAttachments records the attachments without storing their bytes
+type memoryAttachments struct {
+	repo.AttachmentRepository
+	storage.Store
+	created int
+}
+
+func (r *memoryAttachments) Create(context.Context, *model.Attachment) error {
+	r.created++
+	return nil
+}
+
+func (r *memoryAttachments) PresignUpload(_ context.Context, key, _ string) (*storage.Presigned, error) {
+	return &storage.Presigned{URL: "https://bucket.example/" + key}, nil
+}
+
+// memoryCounters counts the attachments of the users
+type memoryCounters struct {
+	service.CounterStore
+	users *memoryUsers
+}
+
+func (c memoryCounters) Add(_ context.Context, _ repo.Counter, _ string, delta int64) error {
+	c.users.user.AttachmentsCount += delta
+	return nil
+}
+
+// memoryWatermarks keeps the watermarks in memory
+type memoryWatermarks map[string]time.Time
+
+func (m memoryWatermarks) Touch(_ context.Context, collection string, at time.Time) error {
+	m[collection] = at
+	return nil
+}
+
+func (m memoryWatermarks) LastModified(_ context.Context, collection string) (time.Time, error) {
+	return m[collection], nil
+}
+
+// syncTasks runs the post-write work right away
+type syncTasks struct{}
+
+func (syncTasks) Submit(ctx context.Context, _ string, task func(context.Context) error) error {
+	return task(ctx)
+}
+
+// TestAttachmentRevalidation checks that an attachment changes the users
+// for the clients revalidating the list, its count being part of them
+func TestAttachmentRevalidation(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	users := &memoryUsers{user: model.User{PublicID: "0192a8e2-7b3c-7def-8000-0123456789ab", Name: "Alice", Version: 1}}
+	modified := time.Now().Add(-time.Hour).Truncate(time.Second)
+	marks := memoryWatermarks{"users": modified}
+	publicIDs, err := ids.New(config.IDConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userService := service.NewUserService(users, publicIDs, config.SuggestConfig{}, syncTasks{}, nil, nil, nil, marks, nil)
+	attachments := &memoryAttachments{}
+	h := NewUserHandler(userService, nil)
+	a := NewAttachmentHandler(service.NewAttachmentService(attachments, attachments, userService, memoryCounters{users: users}))
+	r := gin.New()
+	r.GET("/api/v1/users", h.getUsers)
+	r.POST("/api/v1/users/:id/attachments", RequireUserRef("id"), a.createAttachment)
+
+	list := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		req.Header.Set("If-Modified-Since", modified.UTC().Format(http.TimeFormat))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	if status := list(); status != http.StatusNotModified {
+		t.Fatalf("status %d before the attachment, want %d", status, http.StatusNotModified)
+	}
+
+	body := `{"name": "avatar.png", "content_type": "image/png"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/"+users.user.PublicID+"/attachments", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("attachment: status %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	if status := list(); status != http.StatusOK {
+		t.Errorf("status %d after the attachment, want %d", status, http.StatusOK)
+	}
+}
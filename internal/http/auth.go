@@ -0,0 +1,80 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/auth"
+	"datadog-golang-example/internal/model"
+)
+
+// principalKey is the context key under which Authenticate stores the caller
+const principalKey = "principal"
+
+// Authenticate resolves the caller from the X-API-Key header (or an
+// Authorization: Bearer token). Requests without a key are anonymous; an
+// unknown key is rejected with 401.
+func Authenticate(keys *auth.KeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := auth.AnonymousPrincipal
+		if key := apiKey(c); key != "" {
+			var ok bool
+			if p, ok = keys.Lookup(key); !ok {
+				abortWithError(c, errInvalidAPIKey)
+				return
+			}
+		}
+		c.Set(principalKey, p)
+		if span, ok := tracer.SpanFromContext(c.Request.Context()); ok {
+			span.SetTag("auth.level", p.Level.String())
+			if p.Level != auth.Anonymous {
+				span.SetTag("usr.id", p.Name)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireLevel rejects callers below the given authentication level
+func RequireLevel(level auth.Level) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := principal(c)
+		switch {
+		case p.Level >= level:
+			c.Next()
+		case p.Level == auth.Anonymous:
+			abortWithError(c, errAuthRequired)
+		default:
+			abortWithError(c, errInsufficientLevel)
+		}
+	}
+}
+
+// principal returns the caller stored by Authenticate
+func principal(c *gin.Context) auth.Principal {
+	if p, ok := c.Get(principalKey); ok {
+		return p.(auth.Principal)
+	}
+	return auth.AnonymousPrincipal
+}
+
+// apiKey extracts the API key from the request headers
+func apiKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// Authentication errors
+var (
+	errInvalidAPIKey     = fmt.Errorf("invalid API key: %w", model.ErrUnauthorized)
+	errAuthRequired      = fmt.Errorf("an API key is required: %w", model.ErrUnauthorized)
+	errInsufficientLevel = fmt.Errorf("insufficient privileges: %w", model.ErrForbidden)
+)
@@ -0,0 +1,17 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/baggage"
+)
+
+// Baggage restricts the trace baggage of the request to what policy
+// allows and adds the items set by request headers. It must run before
+// tracing, which extracts the baggage into the request span.
+func Baggage(policy *baggage.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy.Filter(c.Request.Header)
+		c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/model"
+)
+
+// DegradationSignal reports whether a dependency is degraded
+type DegradationSignal interface {
+	Degraded() bool
+}
+
+// errShed is returned for the non-essential requests shed in a brownout
+var errShed = fmt.Errorf("shedding non-essential traffic while the database is degraded: %w", model.ErrUnavailable)
+
+// Brownout sheds cfg.ShedPercent of the requests to the non-essential
+// cfg.Routes, given as "METHOD /pattern", while signal reports the
+// database degraded. Single-document reads and writes stay served. Each
+// decision taken while degraded is counted and tagged on the request span.
+// It must run inside ErrorHandler.
+func Brownout(cfg config.BrownoutConfig, signal DegradationSignal, metrics statsd.ClientInterface) gin.HandlerFunc {
+	retryAfter := strconv.Itoa(int(math.Ceil(cfg.CheckInterval.Seconds())))
+	return func(c *gin.Context) {
+		if !signal.Degraded() {
+			c.Next()
+			return
+		}
+		route := c.Request.Method + " " + c.FullPath()
+		essential := !slices.Contains(cfg.Routes, route)
+		shed := !essential && rand.IntN(100) < cfg.ShedPercent
+
+		span, _ := tracer.SpanFromContext(c.Request.Context())
+		span.SetTag("brownout.degraded", true)
+		span.SetTag("brownout.shed", shed)
+		decision := "served"
+		if shed {
+			decision = "shed"
+		}
+		_ = metrics.Incr("brownout.requests", []string{"route:" + c.FullPath(), "essential:" + strconv.FormatBool(essential), "decision:" + decision}, 1)
+
+		if shed {
+			c.Header("Retry-After", retryAfter)
+			abortWithError(c, errShed)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,181 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
+
+	"datadog-golang-example/internal/cache"
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/model"
+)
+
+// cachedResponse is a successful GET response kept for replay
+type cachedResponse struct {
+	contentType  string
+	lastModified string
+	body         []byte
+}
+
+// ResponseCache caches successful GET responses of the routes that have a
+// TTL. Concurrent misses for the same URL are coalesced into a single
+// handler call.
+type ResponseCache struct {
+	cfg      config.CacheConfig
+	entries  *cache.LRU[*cachedResponse]
+	inflight singleflight.Group
+	metrics  statsd.ClientInterface
+
+	lookups atomic.Int64
+	hits    atomic.Int64
+}
+
+// NewResponseCache creates a ResponseCache
+func NewResponseCache(cfg config.CacheConfig, metrics statsd.ClientInterface) *ResponseCache {
+	return &ResponseCache{
+		cfg:     cfg,
+		entries: cache.NewLRU[*cachedResponse](cfg.Size),
+		metrics: metrics,
+	}
+}
+
+// Cache serves cached GET responses and clears the cache after any
+// successful write other than a dry run, since a write may change several
+// cached URLs (list, by ID, by username). The routes reading with another
+// method, such as the queries, leave it as is. It must run inside
+// ErrorHandler.
+func Cache(rc *ResponseCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			if route := routeOf(c); route != nil && !route.writes() {
+				return
+			}
+			if c.Writer.Status() < 400 && len(c.Errors) == 0 && !model.IsDryRun(c.Request.Context()) {
+				rc.entries.Purge()
+			}
+			return
+		}
+
+		ttl, ok := rc.cfg.TTLs[c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		key := c.Request.URL.RequestURI()
+		if resp, ok := rc.entries.Get(key); ok {
+			rc.record(c, "hit")
+			replay(c, resp)
+			return
+		}
+
+		led := false
+		v, _, _ := rc.inflight.Do(key, func() (any, error) {
+			// X-Cache is set before the handler writes the headers
+			led = true
+			rc.record(c, "miss")
+			rec := &recordingWriter{ResponseWriter: c.Writer}
+			c.Writer = rec
+			c.Next()
+			c.Writer = rec.ResponseWriter
+
+			if rec.Status() != http.StatusOK || len(c.Errors) > 0 {
+				return (*cachedResponse)(nil), nil
+			}
+			resp := &cachedResponse{
+				contentType:  rec.Header().Get("Content-Type"),
+				lastModified: rec.Header().Get("Last-Modified"),
+				body:         rec.body,
+			}
+			rc.entries.Set(key, resp, ttl)
+			return resp, nil
+		})
+		if led {
+			return
+		}
+
+		// Another request fetched the response; reuse it when it succeeded
+		if resp := v.(*cachedResponse); resp != nil {
+			rc.record(c, "coalesced")
+			replay(c, resp)
+			return
+		}
+		rc.record(c, "miss")
+		c.Next()
+	}
+}
+
+// HandleEvent drops the cached responses naming a user updated, deleted or
+// erased by any instance, as the writes served here clear the cache. An
+// event without a user clears it all.
+func (rc *ResponseCache) HandleEvent(_ context.Context, e events.Event) error {
+	switch e.Type {
+	case events.UserUpdated, events.UserDeleted, events.UserErased:
+	default:
+		return nil
+	}
+	if e.UserID == "" {
+		rc.entries.Purge()
+		return nil
+	}
+	quoted := []byte(strconv.Quote(e.UserID))
+	rc.entries.PurgeFunc(func(key string, resp *cachedResponse) bool {
+		return strings.Contains(key, e.UserID) || resp != nil && bytes.Contains(resp.body, quoted)
+	})
+	return nil
+}
+
+// record reports a cache lookup on the span and in metrics
+func (rc *ResponseCache) record(c *gin.Context, result string) {
+	lookups := rc.lookups.Add(1)
+	hits := rc.hits.Load()
+	if result != "miss" {
+		hits = rc.hits.Add(1)
+	}
+
+	c.Header("X-Cache", result)
+	if span, ok := tracer.SpanFromContext(c.Request.Context()); ok {
+		span.SetTag("cache.result", result)
+		span.SetTag("cache.hit", result != "miss")
+	}
+	tags := []string{"route:" + c.FullPath(), "result:" + result}
+	rc.metrics.Incr("response_cache.requests", tags, 1)
+	rc.metrics.Gauge("response_cache.hit_ratio", float64(hits)/float64(lookups), nil, 1)
+	rc.metrics.Gauge("response_cache.entries", float64(rc.entries.Len()), nil, 1)
+}
+
+// replay writes a cached response and stops the handler chain
+func replay(c *gin.Context, resp *cachedResponse) {
+	c.Header("Content-Length", strconv.Itoa(len(resp.body)))
+	if resp.lastModified != "" {
+		c.Header("Last-Modified", resp.lastModified)
+	}
+	c.Data(http.StatusOK, resp.contentType, resp.body)
+	c.Abort()
+}
+
+// recordingWriter keeps a copy of the response body
+type recordingWriter struct {
+	gin.ResponseWriter
+	body []byte
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body = append(w.body, b...)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body = append(w.body, s...)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/events"
+)
+
+// cacheRouter serves a cached list of users, a query reading them and a
+// create writing them, counting the calls of the list
+func cacheRouter(calls *int) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	rc := NewResponseCache(config.CacheConfig{Size: 10, TTLs: map[string]time.Duration{"/api/v1/users": time.Minute}}, &statsd.NoOpClient{})
+	routes := []Route{
+		{Method: http.MethodGet, Path: "/api/v1/users"},
+		{Method: http.MethodPost, Path: "/api/v1/users/query", AllowReadOnly: true},
+		{Method: http.MethodPost, Path: "/api/v1/users"},
+	}
+	r := gin.New()
+	r.Use(ErrorHandler(), describeRoutes(routes), Cache(rc))
+	r.GET("/api/v1/users", func(c *gin.Context) {
+		*calls++
+		c.JSON(http.StatusOK, gin.H{"data": []string{}})
+	})
+	r.POST("/api/v1/users/query", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"data": []string{}})
+	})
+	r.POST("/api/v1/users", func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{})
+	})
+	return r
+}
+
+// serveCache serves a request without a body
+func serveCache(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestCacheHeaders(t *testing.T) {
+	var calls int
+	r := cacheRouter(&calls)
+	for _, want := range []string{"miss", "hit"} {
+		w := serveCache(r, http.MethodGet, "/api/v1/users")
+		if got := w.Header().Get("X-Cache"); got != want {
+			t.Errorf("X-Cache %q, want %q", got, want)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("the list ran %d times, want 1", calls)
+	}
+}
+
+// TestCachePurge checks that the writes clear the cache and that the
+// queries sent with POST keep it
+func TestCachePurge(t *testing.T) {
+	for _, tc := range []struct {
+		path  string
+		calls int
+	}{
+		{"/api/v1/users/query", 1},
+		{"/api/v1/users", 2},
+	} {
+		var calls int
+		r := cacheRouter(&calls)
+		serveCache(r, http.MethodGet, "/api/v1/users")
+		serveCache(r, http.MethodPost, tc.path)
+		serveCache(r, http.MethodGet, "/api/v1/users")
+		if calls != tc.calls {
+			t.Errorf("POST %s: the list ran %d times, want %d", tc.path, calls, tc.calls)
+		}
+	}
+}
+
+// TestCacheEvents checks that the user events published by any instance
+// drop the cached responses naming their user, by ID or by username, and
+// keep the others
+func TestCacheEvents(t *testing.T) {
+	const alice, bob = "0192a8e2-7b3c-7def-8000-0123456789ab", "0192a8e2-7b3c-7def-8000-0123456789cd"
+	responses := map[string]string{
+		"/api/v1/users/" + alice:          `{"id":"` + alice + `","username":"alice"}`,
+		"/api/v1/users/by-username/alice": `{"id":"` + alice + `","username":"alice"}`,
+		"/api/v1/users/" + bob:            `{"id":"` + bob + `","username":"bob"}`,
+	}
+	for _, tc := range []struct {
+		event events.Event
+		kept  []string
+	}{
+		{events.Event{Type: events.UserUpdated, UserID: alice}, []string{"/api/v1/users/" + bob}},
+		{events.Event{Type: events.UserDeleted, UserID: alice}, []string{"/api/v1/users/" + bob}},
+		{events.Event{Type: events.UserErased, UserID: bob}, []string{"/api/v1/users/" + alice, "/api/v1/users/by-username/alice"}},
+		{events.Event{Type: events.UserCreated, UserID: alice}, []string{"/api/v1/users/" + alice, "/api/v1/users/" + bob, "/api/v1/users/by-username/alice"}},
+	} {
+		rc := NewResponseCache(config.CacheConfig{Size: 10}, &statsd.NoOpClient{})
+		for key, body := range responses {
+			rc.entries.Set(key, &cachedResponse{contentType: "application/json", body: []byte(body)}, time.Minute)
+		}
+		if err := rc.HandleEvent(context.Background(), tc.event); err != nil {
+			t.Fatal(err)
+		}
+		var kept []string
+		for key := range responses {
+			if _, ok := rc.entries.Get(key); ok {
+				kept = append(kept, key)
+			}
+		}
+		slices.Sort(kept)
+		if !slices.Equal(kept, tc.kept) {
+			t.Errorf("%s of %s kept %v, want %v", tc.event.Type, tc.event.UserID, kept, tc.kept)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package http
+
+import (
+	"context"
+	"log"
+	"slices"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+)
+
+// SessionStarter starts the database sessions requests run in
+type SessionStarter interface {
+	Start(ctx context.Context) (context.Context, func(), error)
+}
+
+// CausalSessions runs the requests of routes, given as "METHOD /pattern",
+// in a causally consistent session, so the document a handler reads back
+// after its write reflects it even when reads go to secondaries. A
+// request whose session cannot start runs without one.
+func CausalSessions(sessions SessionStarter, routes []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(routes, c.Request.Method+" "+c.FullPath()) {
+			c.Next()
+			return
+		}
+		ctx, end, err := sessions.Start(c.Request.Context())
+		if err != nil {
+			log.Printf("Serving %s %s without a causal session: %v", c.Request.Method, c.FullPath(), err)
+			c.Next()
+			return
+		}
+		defer end()
+		if span, ok := tracer.SpanFromContext(ctx); ok {
+			span.SetTag("mongodb.causal_session", true)
+		}
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
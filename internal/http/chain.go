@@ -0,0 +1,117 @@
+package http
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	gintrace "github.com/DataDog/dd-trace-go/contrib/gin-gonic/gin/v2"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/ratelimit"
+)
+
+// middleware builds one named middleware; a nil result means it is
+// disabled by its own settings, such as a zero DB budget
+type middleware func() gin.HandlerFunc
+
+// globalMiddleware are the middleware that can run on every route. The
+// order of the chain matters: baggage must run before tracing, analytics
+// and slo must wrap errors to see the final status, recover, auth,
+// read_only, db_budget and the API middleware must run inside errors, and
+// envelope inside compression. read_only refuses the routes of list.
+func globalMiddleware(cfg RouterConfig, list []Route) map[string]middleware {
+	return map[string]middleware{
+		"logger":          gin.Logger,
+		"tracing":         func() gin.HandlerFunc { return gintrace.Middleware(cfg.Service) },
+		"client_metadata": func() gin.HandlerFunc { return ClientMetadata(cfg.GeoIP) },
+		"analytics":       func() gin.HandlerFunc { return Analytics(cfg.Metrics) },
+		"slo":             func() gin.HandlerFunc { return SLO(cfg.SLO, cfg.Metrics) },
+		"errors":          ErrorHandler,
+		"recover":         Recover,
+		"compression":     Compress,
+		"envelope":        Envelope,
+		"cors":            func() gin.HandlerFunc { return CORS(cfg.CORSOrigins) },
+		"auth":            func() gin.HandlerFunc { return Authenticate(cfg.Keys) },
+		"baggage": func() gin.HandlerFunc {
+			if cfg.Baggage == nil {
+				return nil
+			}
+			return Baggage(cfg.Baggage)
+		},
+		"read_only": func() gin.HandlerFunc {
+			if cfg.ReadOnly == nil {
+				return nil
+			}
+			return ReadOnly(cfg.ReadOnly, list)
+		},
+		"db_budget": func() gin.HandlerFunc {
+			if cfg.DBBudget.MaxOps <= 0 {
+				return nil
+			}
+			return DBBudget(cfg.DBBudget, cfg.Metrics)
+		},
+	}
+}
+
+// apiRateLimit is the rate limit group of the /api/v1 routes that do not
+// name theirs
+const apiRateLimit = "api"
+
+// apiMiddleware are the middleware that can run on the /api/v1 routes
+func apiMiddleware(cfg RouterConfig, limiter *ratelimit.Limiter) map[string]middleware {
+	return map[string]middleware{
+		"brownout": func() gin.HandlerFunc {
+			if cfg.Degradation == nil {
+				return nil
+			}
+			return Brownout(cfg.Brownout, cfg.Degradation, cfg.Metrics)
+		},
+		"ratelimit": func() gin.HandlerFunc { return RateLimit(apiRateLimit, cfg.RateLimit, limiter, cfg.Metrics) },
+		"quota": func() gin.HandlerFunc {
+			if cfg.Quotas == nil {
+				return nil
+			}
+			return Quota(cfg.Quotas, cfg.Metrics)
+		},
+		"chaos":   func() gin.HandlerFunc { return Chaos(cfg.Injector) },
+		"dry_run": DryRun,
+		"dedup": func() gin.HandlerFunc {
+			if cfg.Dedup == nil {
+				return nil
+			}
+			return Dedup(cfg.Dedup, cfg.Metrics)
+		},
+		"cache": func() gin.HandlerFunc { return Cache(cfg.Cache) },
+		"causal": func() gin.HandlerFunc {
+			if cfg.Sessions == nil || len(cfg.CausalRoutes) == 0 {
+				return nil
+			}
+			return CausalSessions(cfg.Sessions, cfg.CausalRoutes)
+		},
+	}
+}
+
+// buildChain returns the middleware named by names, in order, and logs
+// the resulting chain. Unknown and repeated names are logged and skipped.
+func buildChain(kind string, names []string, available map[string]middleware) []gin.HandlerFunc {
+	var chain []gin.HandlerFunc
+	var active []string
+	for _, name := range names {
+		build, ok := available[name]
+		switch {
+		case !ok:
+			log.Printf("WARNING: Ignoring unknown %s middleware %q", kind, name)
+			continue
+		case slices.Contains(active, name):
+			log.Printf("WARNING: Ignoring repeated %s middleware %q", kind, name)
+			continue
+		}
+		if h := build(); h != nil {
+			chain = append(chain, h)
+			active = append(active, name)
+		}
+	}
+	log.Printf("%s middleware: %s", kind, strings.Join(active, " > "))
+	return chain
+}
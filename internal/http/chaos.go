@@ -0,0 +1,86 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/chaos"
+	"datadog-golang-example/internal/model"
+)
+
+// Chaos injects the latency and errors decided by inj. Injected faults are
+// tagged on the request span so they can be told apart from real ones.
+func Chaos(inj *chaos.Injector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		f, ok := inj.Decide(c.FullPath())
+		if !ok {
+			c.Next()
+			return
+		}
+
+		span, hasSpan := tracer.SpanFromContext(c.Request.Context())
+		if f.Delay > 0 {
+			if hasSpan {
+				span.SetTag("chaos.latency_ms", f.Delay.Milliseconds())
+			}
+			select {
+			case <-time.After(f.Delay):
+			case <-c.Request.Context().Done():
+			}
+		}
+		if f.Status != 0 {
+			if hasSpan {
+				span.SetTag("chaos.error", true)
+			}
+			if f.Status == http.StatusServiceUnavailable {
+				abortWithError(c, errChaosUnavailable)
+			} else {
+				abortWithError(c, errChaosFailure)
+			}
+			return
+		}
+		c.Next()
+	}
+}
+
+// Injected failures
+var (
+	errChaosFailure     = errors.New("chaos: injected failure")
+	errChaosUnavailable = fmt.Errorf("chaos: injected outage: %w", model.ErrUnavailable)
+)
+
+// ChaosHandler serves the admin endpoints that reconfigure fault injection
+type ChaosHandler struct {
+	inj *chaos.Injector
+}
+
+// NewChaosHandler creates a ChaosHandler for inj
+func NewChaosHandler(inj *chaos.Injector) *ChaosHandler {
+	return &ChaosHandler{inj: inj}
+}
+
+// getChaos returns the active chaos settings
+func (h *ChaosHandler) getChaos(c *gin.Context) {
+	c.JSON(200, h.inj.Settings())
+}
+
+// putChaos replaces the active chaos settings
+func (h *ChaosHandler) putChaos(c *gin.Context) {
+	var s chaos.Settings
+	if err := c.ShouldBindJSON(&s); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+	if err := h.inj.Set(s); err != nil {
+		abortWithError(c, &model.ValidationError{Field: "rules", Reason: err.Error()})
+		return
+	}
+	log.Printf("Chaos settings changed by %s: enabled=%t rules=%d", principal(c).Name, s.Enabled, len(s.Rules))
+	c.JSON(200, s)
+}
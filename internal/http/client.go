@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+	"github.com/mssola/useragent"
+
+	"datadog-golang-example/internal/baggage"
+)
+
+// CountryLookup resolves an IP address to an ISO country code
+type CountryLookup interface {
+	Country(ip net.IP) (string, error)
+}
+
+// ClientMetadata tags the request span with the client IP, resolved by
+// Gin from the forwarding headers of trusted proxies, the browser and OS
+// parsed from the User-Agent and, when geo is not nil, the client country
+func ClientMetadata(geo CountryLookup) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		span, ok := tracer.SpanFromContext(c.Request.Context())
+		if !ok {
+			c.Next()
+			return
+		}
+
+		baggage.Tag(span)
+		ip := c.ClientIP()
+		span.SetTag("http.client_ip", ip)
+
+		if raw := c.Request.UserAgent(); raw != "" {
+			ua := useragent.New(raw)
+			browser, version := ua.Browser()
+			span.SetTag("http.useragent_details.browser.family", browser)
+			span.SetTag("http.useragent_details.browser.version", version)
+			span.SetTag("http.useragent_details.os.family", ua.OSInfo().Name)
+			span.SetTag("http.useragent_details.device.mobile", ua.Mobile())
+			span.SetTag("http.useragent_details.bot", ua.Bot())
+		}
+
+		if geo != nil {
+			if parsed := net.ParseIP(ip); parsed != nil {
+				if country, err := geo.Country(parsed); err == nil && country != "" {
+					span.SetTag("network.client.geoip.country.iso_code", country)
+				}
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gzipWriters are reused across responses
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// gzipWriter compresses the body written through it
+type gzipWriter struct {
+	gin.ResponseWriter
+	gz *gzip.Writer
+	// compressed is set by the first write of the body, plain when the
+	// headers went out before any body, which is then sent as it is
+	compressed bool
+	plain      bool
+}
+
+// Write compresses b. The length set by the handler is that of the
+// uncompressed body, so it is dropped.
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if w.plain {
+		return w.ResponseWriter.Write(b)
+	}
+	if !w.compressed {
+		w.compressed = true
+		w.Header().Del("Content-Length")
+	}
+	return w.gz.Write(b)
+}
+
+// WriteString compresses s
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends what was compressed so far, for streamed responses
+func (w *gzipWriter) Flush() {
+	if w.compressed {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// WriteHeaderNow sends the headers, without Content-Encoding when no body
+// was written yet, as for c.AbortWithStatus
+func (w *gzipWriter) WriteHeaderNow() {
+	if !w.compressed && !w.Written() {
+		w.plain = true
+		w.Header().Del("Content-Encoding")
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Compress gzips responses for clients that accept it. Responses without
+// a body, such as 204s, are sent as they are.
+func Compress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") {
+			c.Next()
+			return
+		}
+
+		gz := gzipWriters.Get().(*gzip.Writer)
+		defer gzipWriters.Put(gz)
+		gz.Reset(c.Writer)
+		c.Header("Content-Encoding", "gzip")
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		original := c.Writer
+		w := &gzipWriter{ResponseWriter: original, gz: gz}
+		c.Writer = w
+		c.Next()
+		c.Writer = original
+
+		if w.compressed {
+			_ = gz.Close()
+		} else if !w.plain {
+			c.Writer.Header().Del("Content-Encoding")
+		}
+	}
+}
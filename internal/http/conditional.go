@@ -0,0 +1,23 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notModified sets Last-Modified from modified and reports whether the
+// If-Modified-Since of the request shows the client is up to date. HTTP
+// dates have a one-second resolution, so a time within the last second
+// is not advertised: a write later in that second would go unnoticed.
+func notModified(c *gin.Context, modified time.Time) bool {
+	if modified.IsZero() || time.Since(modified) < time.Second {
+		return false
+	}
+	modified = modified.Truncate(time.Second)
+	c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+
+	since, err := http.ParseTime(c.GetHeader("If-Modified-Since"))
+	return err == nil && !modified.After(since)
+}
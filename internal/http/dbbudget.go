@@ -0,0 +1,41 @@
+package http
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/dbops"
+)
+
+// DBBudget counts the MongoDB operations of each request and tags the
+// request span with the count. Requests over budget are logged, tagged
+// and counted in metrics; when enforced, they fail with a 500 problem. It
+// must run inside ErrorHandler.
+func DBBudget(cfg config.DBBudgetConfig, metrics statsd.ClientInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, counter := dbops.WithCounter(c.Request.Context(), cfg.MaxOps, cfg.Enforce)
+		defer counter.Done()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		span, _ := tracer.SpanFromContext(ctx)
+		span.SetTag("db.operations", counter.Count())
+		if !counter.Exceeded() {
+			return
+		}
+		span.SetTag("db.budget_exceeded", true)
+		_ = metrics.Incr("http.db_budget.exceeded", []string{"route:" + c.FullPath()}, 1)
+		log.Printf("%s %s ran %d MongoDB operations, over the budget of %d", c.Request.Method, c.FullPath(), counter.Count(), cfg.MaxOps)
+
+		if cfg.Enforce && !c.Writer.Written() {
+			// Replaces the error of the operations cancelled by the counter
+			c.Errors = c.Errors[:0]
+			abortWithError(c, fmt.Errorf("%w: %d operations for a budget of %d", dbops.ErrBudgetExceeded, counter.Count(), cfg.MaxOps))
+		}
+	}
+}
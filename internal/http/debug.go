@@ -0,0 +1,139 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// SlowQuerier runs a database query that is guaranteed to time out
+type SlowQuerier interface {
+	SlowQuery(ctx context.Context, sleep, maxTime time.Duration) error
+}
+
+// DebugHandler serves endpoints that fail on purpose, one per error
+// scenario, to exercise Datadog Error Tracking
+type DebugHandler struct {
+	db            SlowQuerier
+	client        *http.Client
+	downstreamURL string
+}
+
+// NewDebugHandler creates a DebugHandler. client must be traced so the
+// downstream call shows up as a child span.
+func NewDebugHandler(db SlowQuerier, client *http.Client, downstreamURL string) *DebugHandler {
+	return &DebugHandler{db: db, client: client, downstreamURL: downstreamURL}
+}
+
+// errUnknownScenario is returned for an unsupported :kind
+var errUnknownScenario = fmt.Errorf("unknown error scenario: %w", model.ErrNotFound)
+
+// triggerError runs the scenario named by :kind. gintrace reports every
+// 5xx request span with a generic status message, so the real error is
+// recorded on a child span named after the scenario.
+func (h *DebugHandler) triggerError(c *gin.Context) {
+	kind := c.Param("kind")
+	span, ctx := tracer.StartSpanFromContext(c.Request.Context(), "debug.scenario", tracer.ResourceName(kind))
+
+	var err error
+	switch kind {
+	case "panic":
+		span.Finish()
+		ordersByUser(c.Query("user"))
+		return
+	case "wrapped":
+		err = loadProfile()
+	case "mongo-timeout":
+		err = h.db.SlowQuery(ctx, 2*time.Second, 100*time.Millisecond)
+	case "validation":
+		err = &model.ValidationError{Field: "age", Reason: "must be between 0 and 150"}
+	case "downstream":
+		err = h.callDownstream(ctx)
+	default:
+		err = errUnknownScenario
+	}
+	span.Finish(tracer.WithError(err))
+	abortWithError(c, err)
+}
+
+// ordersByUser indexes past the end of a slice, panicking with a runtime
+// error that Recover turns into a 500
+func ordersByUser(user string) []string {
+	orders := make([]string, len(user))
+	return orders[len(user) : len(user)+1]
+}
+
+// errSettingsCorrupt is the root cause of the wrapped scenario
+var errSettingsCorrupt = errors.New("settings document is corrupt")
+
+// loadProfile returns an error wrapped twice, as real code usually does
+func loadProfile() error {
+	if err := decodeSettings(); err != nil {
+		return fmt.Errorf("load profile: %w", err)
+	}
+	return nil
+}
+
+func decodeSettings() error {
+	return fmt.Errorf("decode settings: %w", errSettingsCorrupt)
+}
+
+// callDownstream calls the configured downstream service and maps a 5xx
+// answer to model.ErrUnavailable
+func (h *DebugHandler) callDownstream(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.downstreamURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("call downstream: %v: %w", err, model.ErrUnavailable)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("downstream returned %d: %w", resp.StatusCode, model.ErrUnavailable)
+	}
+	return nil
+}
+
+// upstream plays a failing dependency for the downstream scenario. The
+// status defaults to 503 and can be changed with ?status=.
+func (h *DebugHandler) upstream(c *gin.Context) {
+	status, err := strconv.Atoi(c.DefaultQuery("status", "503"))
+	if err != nil || status < 200 || status > 599 {
+		abortWithError(c, &model.ValidationError{Field: "status", Reason: "must be an HTTP status code"})
+		return
+	}
+	if status == http.StatusServiceUnavailable {
+		abortWithError(c, fmt.Errorf("upstream: simulated outage: %w", model.ErrUnavailable))
+		return
+	}
+	c.JSON(status, gin.H{"status": status})
+}
+
+// disposableDomains are the domains the fake verification API distrusts
+var disposableDomains = map[string]bool{"mailinator.com": true, "guerrillamail.com": true, "10minutemail.com": true}
+
+// emailCheck plays the email verification API, so EMAIL_VERIFY_URL can
+// point back at the service in demos: disposable domains are risky and
+// addresses at example.invalid are invalid
+func (h *DebugHandler) emailCheck(c *gin.Context) {
+	_, domain, ok := strings.Cut(strings.ToLower(c.Query("email")), "@")
+	switch {
+	case !ok || domain == "example.invalid":
+		c.JSON(200, gin.H{"status": "invalid", "reason": "undeliverable"})
+	case disposableDomains[domain]:
+		c.JSON(200, gin.H{"status": "risky", "reason": "disposable"})
+	default:
+		c.JSON(200, gin.H{"status": "valid"})
+	}
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/auth"
+	"datadog-golang-example/internal/dedup"
+	"datadog-golang-example/internal/model"
+)
+
+// errDuplicateInFlight is returned for a duplicate whose original request
+// has not finished
+var errDuplicateInFlight = fmt.Errorf("an identical request is still being processed: %w", model.ErrConflict)
+
+// Dedup replays the response of the first of several identical POST
+// requests from the same client within the dedup window, marking replays
+// with a Duplicate: true header. Only successful responses are replayed;
+// a failed first request can be retried. Redis errors let the request
+// through. It must run inside ErrorHandler.
+func Dedup(store *dedup.Store, metrics statsd.ClientInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || model.IsDryRun(c.Request.Context()) {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abortWithError(c, bindError(err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		client := "ip:" + c.ClientIP()
+		if p := principal(c); p.Level != auth.Anonymous {
+			client = "key:" + p.Name
+		}
+		key := dedup.Fingerprint(client, c.Request.Method, c.Request.URL.RequestURI(), body)
+
+		ctx := c.Request.Context()
+		first, err := store.Claim(ctx, key)
+		if err != nil {
+			log.Printf("Dedup check failed: %v", err)
+			_ = metrics.Incr("dedup.errors", nil, 1)
+			c.Next()
+			return
+		}
+		if first {
+			_ = metrics.Incr("dedup.requests", []string{"outcome:unique"}, 1)
+			complete(c, store, key)
+			return
+		}
+
+		resp, err := store.Wait(ctx, key)
+		switch {
+		case errors.Is(err, dedup.ErrInFlight):
+			_ = metrics.Incr("dedup.requests", []string{"outcome:in_flight"}, 1)
+			abortWithError(c, errDuplicateInFlight)
+		case err != nil:
+			log.Printf("Dedup lookup failed: %v", err)
+			_ = metrics.Incr("dedup.errors", nil, 1)
+			c.Next()
+		case resp == nil:
+			// The first request failed, so this one is not a duplicate
+			_ = metrics.Incr("dedup.requests", []string{"outcome:unique"}, 1)
+			c.Next()
+		default:
+			_ = metrics.Incr("dedup.requests", []string{"outcome:duplicate"}, 1)
+			if span, ok := tracer.SpanFromContext(ctx); ok {
+				span.SetTag("dedup.duplicate", true)
+			}
+			c.Header("Duplicate", "true")
+			if resp.Location != "" {
+				c.Header("Location", resp.Location)
+			}
+			c.Data(resp.Status, resp.ContentType, resp.Body)
+			c.Abort()
+		}
+	}
+}
+
+// complete runs the handler of a claimed request and stores its response
+// for replay, or releases the claim when it failed
+func complete(c *gin.Context, store *dedup.Store, key string) {
+	rec := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = rec
+	c.Next()
+	c.Writer = rec.ResponseWriter
+
+	// The handler may have cancelled its context; the bookkeeping must run
+	ctx := context.WithoutCancel(c.Request.Context())
+	if rec.Status() >= 300 || len(c.Errors) > 0 {
+		if err := store.Release(ctx, key); err != nil {
+			log.Printf("Failed to release dedup claim: %v", err)
+		}
+		return
+	}
+	resp := dedup.Response{
+		Status:      rec.Status(),
+		ContentType: rec.Header().Get("Content-Type"),
+		Location:    rec.Header().Get("Location"),
+		Body:        rec.body,
+	}
+	if err := store.Complete(ctx, key, resp); err != nil {
+		log.Printf("Failed to store response for dedup: %v", err)
+	}
+}
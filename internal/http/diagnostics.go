@@ -0,0 +1,32 @@
+package http
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// Diagnoser describes how the running instance is wired
+type Diagnoser interface {
+	Diagnose(ctx context.Context) *model.Diagnostics
+}
+
+// DiagnosticsHandler serves the self-diagnostics of the instance
+type DiagnosticsHandler struct {
+	diagnostics Diagnoser
+}
+
+// NewDiagnosticsHandler creates a DiagnosticsHandler backed by the given
+// diagnoser
+func NewDiagnosticsHandler(diagnostics Diagnoser) *DiagnosticsHandler {
+	return &DiagnosticsHandler{diagnostics: diagnostics}
+}
+
+// getDiagnostics reports the versions, features, tracer and MongoDB state
+// of the instance. Broken parts hold their error, so the answer is a 200
+// whenever the diagnostics could be gathered.
+func (h *DiagnosticsHandler) getDiagnostics(c *gin.Context) {
+	c.JSON(200, h.diagnostics.Diagnose(c.Request.Context()))
+}
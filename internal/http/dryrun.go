@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// DryRun honours ?dry_run=true on mutating requests: the request runs its
+// validation and conflict checks and answers as it would have, but
+// nothing is written
+func DryRun() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		v := c.Query("dry_run")
+		if v == "" || c.Request.Method == http.MethodGet {
+			c.Next()
+			return
+		}
+		dry, err := strconv.ParseBool(v)
+		if err != nil {
+			abortWithError(c, &model.ValidationError{Field: "dry_run", Reason: "must be a boolean"})
+			return
+		}
+		if !dry {
+			c.Next()
+			return
+		}
+
+		c.Request = c.Request.WithContext(model.WithDryRun(c.Request.Context()))
+		if span, ok := tracer.SpanFromContext(c.Request.Context()); ok {
+			span.SetTag("dry_run", true)
+		}
+		c.Header("X-Dry-Run", "true")
+		c.Next()
+	}
+}
@@ -0,0 +1,193 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecation announces that a route is going away, so its clients
+// migrate off it before Sunset
+type Deprecation struct {
+	// Since is when the route was deprecated
+	Since time.Time
+	// Sunset is when the route stops answering; zero leaves it unannounced
+	Sunset time.Time
+	// Successor is the path of the route replacing it, if any
+	Successor string
+	// Warning tells the clients what to use instead
+	Warning string
+}
+
+// warningsKey is the context key under which addWarning stores the
+// warnings of a request
+const warningsKey = "warnings"
+
+// addWarning adds a warning to the response, such as the use of a
+// deprecated parameter. It must be called before the body is written.
+func addWarning(c *gin.Context, text string) {
+	c.Set(warningsKey, append(c.GetStringSlice(warningsKey), text))
+}
+
+// warnings returns the warnings of the route of the request followed by
+// the ones its handlers added
+func warnings(c *gin.Context) []string {
+	var list []string
+	if route := routeOf(c); route != nil {
+		if route.Deprecation != nil && route.Deprecation.Warning != "" {
+			list = append(list, route.Deprecation.Warning)
+		}
+		list = append(list, route.Warnings...)
+	}
+	return append(list, c.GetStringSlice(warningsKey)...)
+}
+
+// Envelope announces the deprecation of the route of the request in the
+// Deprecation, Sunset and Link headers, and sends its warnings as Warning
+// headers and in the meta.warnings array of successful JSON object bodies.
+// It must run inside compression, which would hide the body, and outside
+// cache, so replayed responses are announced too.
+func Envelope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if route := routeOf(c); route != nil && route.Deprecation != nil {
+			announceDeprecation(c.Writer.Header(), route.Deprecation)
+		}
+		w := &envelopeWriter{ResponseWriter: c.Writer, c: c}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		w.finish()
+	}
+}
+
+// announceDeprecation sets the headers of RFC 9745 and RFC 8594 for d
+func announceDeprecation(h http.Header, d *Deprecation) {
+	h.Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+	if !d.Sunset.IsZero() {
+		h.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Successor != "" {
+		h.Add("Link", "<"+d.Successor+`>; rel="successor-version"`)
+	}
+}
+
+// envelopeWriter holds back a successful JSON body while the request has
+// warnings, so they can be added to it; other bodies pass through. The
+// Warning headers are set when the body starts, once the handlers added
+// theirs.
+type envelopeWriter struct {
+	gin.ResponseWriter
+	c       *gin.Context
+	started bool
+	// held is the body held back, nil when it passes through
+	held     *bytes.Buffer
+	warnings []string
+}
+
+// start decides whether the body is held back before it is first written
+func (w *envelopeWriter) start() {
+	if w.started {
+		return
+	}
+	w.started = true
+	w.warnings = warnings(w.c)
+	for _, text := range w.warnings {
+		w.Header().Add("Warning", `299 - "`+quotedText(text)+`"`)
+	}
+	status := w.Status()
+	if len(w.warnings) > 0 && status >= 200 && status < 300 && strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		w.held = new(bytes.Buffer)
+	}
+}
+
+func (w *envelopeWriter) Write(b []byte) (int, error) {
+	w.start()
+	if w.held != nil {
+		return w.held.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *envelopeWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *envelopeWriter) WriteHeaderNow() {
+	w.start()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Flush passes through unless the body is held back, which only JSON
+// bodies are, never streams
+func (w *envelopeWriter) Flush() {
+	w.start()
+	if w.held == nil {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *envelopeWriter) Written() bool {
+	return w.held != nil || w.ResponseWriter.Written()
+}
+
+func (w *envelopeWriter) Size() int {
+	if w.held != nil {
+		return w.held.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+// finish writes the body held back with the warnings in its meta. The
+// Warning headers of bodiless responses, and of the problems rendered
+// after the handlers, are set here.
+func (w *envelopeWriter) finish() {
+	w.start()
+	if w.held != nil {
+		w.ResponseWriter.Write(withWarnings(w.held.Bytes(), w.warnings))
+	}
+}
+
+// withWarnings adds a meta.warnings array to a JSON object body. Bodies
+// that are not objects, or already have a meta, are returned as is.
+func withWarnings(body []byte, warnings []string) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return body
+	}
+	if _, ok := fields["meta"]; ok {
+		return body
+	}
+	meta, err := json.Marshal(gin.H{"warnings": warnings})
+	if err != nil {
+		return body
+	}
+	rest := bytes.TrimLeft(bytes.TrimLeft(body, " \t\r\n")[1:], " \t\r\n")
+	out := append([]byte(`{"meta":`), meta...)
+	if rest[0] != '}' {
+		out = append(out, ',')
+	}
+	return append(out, rest...)
+}
+
+// quotedText escapes text for the quoted-string of a Warning header,
+// replacing the characters a header cannot hold
+func quotedText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,169 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/dbops"
+	"datadog-golang-example/internal/model"
+)
+
+// problemContentType is the media type of RFC 7807 problem documents
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	TraceID  string `json:"trace_id,omitempty"`
+}
+
+// Patch media types accepted by PATCH /users/:id
+const (
+	jsonPatchContentType  = "application/json-patch+json"
+	mergePatchContentType = "application/merge-patch+json"
+)
+
+// errUnsupportedMediaType is returned for request bodies in a media type
+// the endpoint does not accept
+var errUnsupportedMediaType = errors.New("unsupported media type")
+
+// statusClientClosedRequest is the nginx status of requests whose client
+// went away before the answer, which is not a server error
+const statusClientClosedRequest = 499
+
+// errClientClosed marks the errors of requests whose client went away
+var errClientClosed = errors.New("client closed the request")
+
+// clientClosed reports whether the client cancelled the request of ctx,
+// the context it came in with, as opposed to a timeout or a cancellation
+// of the server's own
+func clientClosed(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
+// electionRetryAfter is the Retry-After, in seconds, of requests failing
+// while a replica set elects a primary, which usually takes a few seconds
+const electionRetryAfter = "5"
+
+// problemKind describes how a class of domain errors is reported
+type problemKind struct {
+	target error
+	status int
+	typ    string
+}
+
+// problemKinds maps each domain error to its status and problem type,
+// checked in order
+var problemKinds = []problemKind{
+	// Whatever failed, the client no longer waits for the answer
+	{errClientClosed, statusClientClosedRequest, "/problems/client-closed-request"},
+	{model.ErrValidation, http.StatusBadRequest, "/problems/validation-error"},
+	{model.ErrNotFound, http.StatusNotFound, "/problems/not-found"},
+	{model.ErrConflict, http.StatusConflict, "/problems/conflict"},
+	{model.ErrElection, http.StatusServiceUnavailable, "/problems/election"},
+	{model.ErrUnavailable, http.StatusServiceUnavailable, "/problems/unavailable"},
+	{model.ErrUnauthorized, http.StatusUnauthorized, "/problems/unauthorized"},
+	{model.ErrForbidden, http.StatusForbidden, "/problems/forbidden"},
+	{model.ErrRateLimited, http.StatusTooManyRequests, "/problems/rate-limited"},
+	{errUnsupportedMediaType, http.StatusUnsupportedMediaType, "/problems/unsupported-media-type"},
+	{errReadOnly, http.StatusMethodNotAllowed, "/problems/read-only"},
+	{dbops.ErrBudgetExceeded, http.StatusInternalServerError, "/problems/db-budget-exceeded"},
+}
+
+// kindFor returns the problem kind matching err, if any
+func kindFor(err error) (problemKind, bool) {
+	for _, k := range problemKinds {
+		if errors.Is(err, k.target) {
+			return k, true
+		}
+	}
+	return problemKind{}, false
+}
+
+// newProblem builds the problem document for err. Unexpected errors are
+// reported without detail so internals do not leak to clients.
+func newProblem(c *gin.Context, err error) Problem {
+	p := Problem{
+		Type:     "about:blank",
+		Status:   http.StatusInternalServerError,
+		Detail:   "An unexpected error occurred",
+		Instance: c.Request.URL.Path,
+	}
+	if k, ok := kindFor(err); ok {
+		p.Type, p.Status, p.Detail = k.typ, k.status, err.Error()
+	}
+	p.Title = http.StatusText(p.Status)
+	if p.Status == statusClientClosedRequest {
+		p.Title = "Client Closed Request"
+	}
+	if span, ok := tracer.SpanFromContext(c.Request.Context()); ok {
+		p.TraceID = span.Context().TraceID()
+	}
+	return p
+}
+
+// writeProblem writes p as an application/problem+json response
+func writeProblem(c *gin.Context, p Problem) {
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
+// ErrorHandler renders the last error attached to the context with
+// c.Error as a problem document, unless the handler already responded.
+// The errors of requests the client cancelled are answered with a 499,
+// which does not mark the request span as a server error, and tag it with
+// http.client_closed. The context the request came in with is the one
+// checked, as the middleware downstream cancel the contexts they derive.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inbound := c.Request.Context()
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		if clientClosed(inbound) {
+			err = fmt.Errorf("%w: %w", errClientClosed, err)
+			if span, ok := tracer.SpanFromContext(c.Request.Context()); ok {
+				span.SetTag("http.client_closed", true)
+			}
+		}
+		if errors.Is(err, model.ErrElection) {
+			c.Header("Retry-After", electionRetryAfter)
+		}
+		writeProblem(c, newProblem(c, err))
+	}
+}
+
+// abortWithError records err for ErrorHandler and stops the handler chain
+func abortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// itemError returns the status and message reporting err for one item of
+// a batch, as its problem document would. Unexpected errors are reported
+// without detail.
+func itemError(err error) (int, string) {
+	if k, ok := kindFor(err); ok {
+		return k.status, err.Error()
+	}
+	return http.StatusInternalServerError, "An unexpected error occurred"
+}
+
+// bindError wraps a request binding failure as a validation error
+func bindError(err error) error {
+	return fmt.Errorf("%w: %w", model.ErrValidation, err)
+}
+
+// errRouteNotFound is returned for requests that match no route
+var errRouteNotFound = fmt.Errorf("route %w", model.ErrNotFound)
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// TestErrorHandlerDerivedContext checks that a middleware cancelling the
+// context it derived does not turn the error of the handler into a 499
+func TestErrorHandlerDerivedContext(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(ErrorHandler(), func(c *gin.Context) {
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	})
+	r.GET("/users/:id", func(c *gin.Context) {
+		abortWithError(c, model.ErrNotFound)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want %d: %s", w.Code, http.StatusNotFound, w.Body)
+	}
+}
+
+// TestErrorHandlerClientClosed checks that the error of a request its
+// client cancelled is a 499
+func TestErrorHandlerClientClosed(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.GET("/users/:id", func(c *gin.Context) {
+		abortWithError(c, c.Request.Context().Err())
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/1", nil).WithContext(ctx))
+	if w.Code != 499 {
+		t.Fatalf("status %d, want 499: %s", w.Code, w.Body)
+	}
+}
+
+// TestRouterErrorStatus checks that the errors of the timed routes keep
+// their status once the timeout of the route is cancelled
+func TestRouterErrorStatus(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := NewRouter(RouterConfig{
+		Users:      NewUserHandler(&benchUserService{}, nil),
+		Middleware: []string{"errors"},
+	})
+	for _, tc := range []struct {
+		method, path string
+		status       int
+	}{
+		{http.MethodGet, "/api/v1/users/notanid", http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/users:bogus", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.status {
+			t.Errorf("%s %s: status %d, want %d: %s", tc.method, tc.path, w.Code, tc.status, w.Body)
+		}
+	}
+}
+
+// TestTimeoutRestoresContext checks that the middleware upstream of a
+// Timeout get the request back with a context that is not cancelled
+func TestTimeoutRestoresContext(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	var err error
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		err = c.Request.Context().Err()
+	}, Timeout(readTimeout))
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if err != nil {
+		t.Fatalf("the context of the request is done after the timeout: %v", err)
+	}
+}
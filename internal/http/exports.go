@@ -0,0 +1,58 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// ExportService is the business logic the export handlers depend on
+type ExportService interface {
+	Start(ctx context.Context, req model.ExportRequest) (*model.ExportJob, error)
+	Get(ctx context.Context, id string) (*model.ExportJob, error)
+}
+
+// ExportHandler serves the admin export jobs
+type ExportHandler struct {
+	exports ExportService
+}
+
+// NewExportHandler creates an ExportHandler backed by the given service
+func NewExportHandler(exports ExportService) *ExportHandler {
+	return &ExportHandler{exports: exports}
+}
+
+// startExport starts exporting the users selected by the optional body
+// and answers 202 with the job, whose progress is polled at Location
+func (h *ExportHandler) startExport(c *gin.Context) {
+	var req model.ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		abortWithError(c, bindError(err))
+		return
+	}
+
+	// Counting the users to export is the only work done in the request
+	ctx := c.Request.Context()
+
+	job, err := h.exports.Start(ctx, req)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Header("Location", "/admin/exports/"+job.ID)
+	c.JSON(202, job)
+}
+
+// getExport reports the status and progress of an export job
+func (h *ExportHandler) getExport(c *gin.Context) {
+	job, err := h.exports.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(200, job)
+}
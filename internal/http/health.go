@@ -0,0 +1,91 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadinessCheck is one dependency reported by /readyz. Only critical
+// checks make the service unready; the others mark it degraded.
+type ReadinessCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+// CheckStatus is the /readyz entry of one check
+type CheckStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthHandler serves the readiness endpoint
+type HealthHandler struct {
+	checks   []ReadinessCheck
+	draining atomic.Bool
+}
+
+// NewHealthHandler creates a HealthHandler running checks
+func NewHealthHandler(checks ...ReadinessCheck) *HealthHandler {
+	return &HealthHandler{checks: checks}
+}
+
+// Drain makes /readyz fail from now on, so the instance is taken out of
+// rotation while it finishes the requests in flight
+func (h *HealthHandler) Drain() {
+	h.draining.Store(true)
+}
+
+// Readiness is the outcome of running every readiness check
+type Readiness struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckStatus `json:"checks,omitempty"`
+}
+
+// Ready reports whether the service can take traffic. A degraded service
+// is still ready.
+func (r Readiness) Ready() bool {
+	return r.Status == "ready" || r.Status == "degraded"
+}
+
+// Readiness runs the checks, or reports "draining" without running them
+// once Drain was called
+func (h *HealthHandler) Readiness(ctx context.Context) Readiness {
+	if h.draining.Load() {
+		return Readiness{Status: "draining"}
+	}
+
+	r := Readiness{Status: "ready", Checks: make(map[string]CheckStatus, len(h.checks))}
+	for _, check := range h.checks {
+		err := check.Check(ctx)
+		switch {
+		case err == nil:
+			r.Checks[check.Name] = CheckStatus{Status: "ok"}
+		case check.Critical:
+			r.Checks[check.Name] = CheckStatus{Status: "fail", Error: err.Error()}
+			r.Status = "not_ready"
+		default:
+			r.Checks[check.Name] = CheckStatus{Status: "degraded", Error: err.Error()}
+			if r.Status == "ready" {
+				r.Status = "degraded"
+			}
+		}
+	}
+	return r
+}
+
+// readyz reports whether the service can take traffic, with the status of
+// every dependency
+func (h *HealthHandler) readyz(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	r := h.Readiness(ctx)
+	code := http.StatusOK
+	if !r.Ready() {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, r)
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// ImportService is the business logic the import handler depends on
+type ImportService interface {
+	Import(ctx context.Context, sourceURL string) (*model.ImportResult, error)
+}
+
+// ImportHandler serves the admin imports of users
+type ImportHandler struct {
+	imports ImportService
+}
+
+// NewImportHandler creates an ImportHandler backed by the given service
+func NewImportHandler(imports ImportService) *ImportHandler {
+	return &ImportHandler{imports: imports}
+}
+
+// importUsers imports the users of the CSV file at the source_url query
+// parameter. Rows that cannot be imported are reported with the status
+// they would have had as single writes; the request itself succeeds.
+func (h *ImportHandler) importUsers(c *gin.Context) {
+	result, err := h.imports.Import(c.Request.Context(), c.Query("source_url"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	for i := range result.Errors {
+		e := &result.Errors[i]
+		e.Status, e.Message = itemError(e.Err)
+	}
+	c.JSON(200, result)
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/logging"
+	"datadog-golang-example/internal/model"
+)
+
+// maxLogLevelRevert bounds how long a temporary log level can last
+const maxLogLevelRevert = 24 * time.Hour
+
+// logLevelRequest is the body of PUT /admin/loglevel
+type logLevelRequest struct {
+	Level string `json:"level" binding:"required"`
+	// RevertAfter, such as "15m", restores the previous level once elapsed
+	RevertAfter string `json:"revert_after"`
+}
+
+// logLevelResponse describes the active level and any pending revert
+type logLevelResponse struct {
+	Level    string     `json:"level"`
+	RevertTo string     `json:"revert_to,omitempty"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// getLogLevel returns the active log level
+func getLogLevel(c *gin.Context) {
+	c.JSON(200, currentLogLevel())
+}
+
+// putLogLevel changes the log level, optionally for a limited time, so
+// debug logging can be turned on during an incident without a redeploy
+func putLogLevel(c *gin.Context) {
+	var req logLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+	l, err := logging.ParseLevel(req.Level)
+	if err != nil {
+		abortWithError(c, &model.ValidationError{Field: "level", Reason: "must be debug, info, warn or error"})
+		return
+	}
+
+	old := logging.Level()
+	if req.RevertAfter == "" {
+		logging.SetLevel(l)
+	} else {
+		d, err := time.ParseDuration(req.RevertAfter)
+		if err != nil || d <= 0 || d > maxLogLevelRevert {
+			abortWithError(c, &model.ValidationError{Field: "revert_after", Reason: "must be a duration between 0s and 24h"})
+			return
+		}
+		logging.SetLevelFor(l, d)
+	}
+	logging.Audit("Changed log level", "actor", principal(c).Name, "setting", "log_level", "old", old.String(), "new", l.String(), "revert_after", req.RevertAfter)
+	c.JSON(200, currentLogLevel())
+}
+
+func currentLogLevel() logLevelResponse {
+	resp := logLevelResponse{Level: logging.Level().String()}
+	if base, at, ok := logging.Revert(); ok {
+		resp.RevertTo, resp.RevertAt = base.String(), &at
+	}
+	return resp
+}
@@ -0,0 +1,43 @@
+package http
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// MergeService is the business logic the merge handler depends on
+type MergeService interface {
+	Merge(ctx context.Context, ref model.UserRef, req model.MergeRequest) (*model.MergeReport, error)
+}
+
+// MergeHandler serves the merge of duplicate users
+type MergeHandler struct {
+	merges MergeService
+}
+
+// NewMergeHandler creates a MergeHandler backed by the given service
+func NewMergeHandler(merges MergeService) *MergeHandler {
+	return &MergeHandler{merges: merges}
+}
+
+// mergeUser merges the user of the request body into the user of the
+// path and answers with the merge report
+func (h *MergeHandler) mergeUser(c *gin.Context) {
+	var req model.MergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	report, err := h.merges.Merge(ctx, userRef(c), req)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(200, report)
+}
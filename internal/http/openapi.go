@@ -0,0 +1,168 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/auth"
+)
+
+// openAPIDocument is the subset of OpenAPI 3.1 derived from the routes
+type openAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// openAPIOperation documents one route. The timeout, rate limit group and
+// authentication level are vendor extensions, so they are visible to
+// readers without tooling support.
+type openAPIOperation struct {
+	Summary    string                `json:"summary,omitempty"`
+	Parameters []openAPIParameter    `json:"parameters,omitempty"`
+	Security   []map[string][]string `json:"security,omitempty"`
+	Responses  map[string]any        `json:"responses"`
+	Level      string                `json:"x-auth-level"`
+	Timeout    string                `json:"x-timeout,omitempty"`
+	RateLimit  string                `json:"x-rate-limit-group,omitempty"`
+	Deprecated bool                  `json:"deprecated,omitempty"`
+	Sunset     string                `json:"x-sunset,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   map[string]any `json:"schema"`
+}
+
+type openAPIComponents struct {
+	SecuritySchemes map[string]any `json:"securitySchemes"`
+}
+
+// apiKeyScheme is the security scheme of the routes needing an API key
+const apiKeyScheme = "apiKey"
+
+// openAPISpec serves the OpenAPI document of the routes
+type openAPISpec struct {
+	doc []byte
+}
+
+// build renders the document of routes, whose API routes default to the
+// apiRateLimit group
+func (s *openAPISpec) build(title, version, apiRateLimit string, routes []Route) error {
+	doc := openAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    openAPIInfo{Title: title, Version: version},
+		Paths:   map[string]map[string]openAPIOperation{},
+		Components: openAPIComponents{SecuritySchemes: map[string]any{
+			apiKeyScheme: map[string]string{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+		}},
+	}
+	for _, route := range routes {
+		path, params := openAPIPath(route.Path)
+		if route.Query != nil {
+			params = append(params, openAPIQuery(route.Query)...)
+		}
+		op := openAPIOperation{
+			Summary:    route.Summary,
+			Parameters: params,
+			Responses: map[string]any{
+				"default": map[string]any{"description": "The answer, or a problem document for errors"},
+			},
+			Level: route.Level.String(),
+		}
+		if route.Level > auth.Anonymous {
+			op.Security = []map[string][]string{{apiKeyScheme: {}}}
+		}
+		if route.Timeout > 0 {
+			op.Timeout = route.Timeout.String()
+		}
+		if route.Deprecation != nil {
+			op.Deprecated = true
+			if !route.Deprecation.Sunset.IsZero() {
+				op.Sunset = route.Deprecation.Sunset.UTC().Format(time.RFC3339)
+			}
+		}
+		if strings.HasPrefix(route.Path, "/api/") {
+			op.RateLimit = route.RateLimit
+			if op.RateLimit == "" {
+				op.RateLimit = apiRateLimit
+			}
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]openAPIOperation{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	var err error
+	s.doc, err = json.MarshalIndent(doc, "", "  ")
+	return err
+}
+
+// openAPIPath converts a Gin pattern into an OpenAPI path and its
+// parameters: /users/:id becomes /users/{id}
+func openAPIPath(pattern string) (string, []openAPIParameter) {
+	var params []openAPIParameter
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		prefix, name, ok := strings.Cut(segment, ":")
+		if !ok {
+			prefix, name, ok = strings.Cut(segment, "*")
+		}
+		if !ok {
+			continue
+		}
+		segments[i] = prefix + "{" + name + "}"
+		params = append(params, openAPIParameter{Name: name, In: "path", Required: true, Schema: map[string]any{"type": "string"}})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// openAPIQuery documents the query parameters of the parameter struct
+// params
+func openAPIQuery(params any) []openAPIParameter {
+	var documented []openAPIParameter
+	for _, f := range queryFields(reflect.TypeOf(params)) {
+		required := slices.Contains(strings.Split(f.Tag.Get("binding"), ","), "required")
+		documented = append(documented, openAPIParameter{Name: paramName(f), In: "query", Required: required, Schema: openAPISchema(f.Type)})
+	}
+	return documented
+}
+
+// openAPISchema returns the schema of a query parameter of type t
+func openAPISchema(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeFor[time.Time]():
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// serve writes the OpenAPI document
+func (s *openAPISpec) serve(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", s.doc)
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// OrgService is the business logic the organization handlers depend on
+type OrgService interface {
+	Create(ctx context.Context, req model.CreateOrgRequest) (*model.Organization, error)
+	Get(ctx context.Context, id string) (*model.Organization, error)
+	AddMember(ctx context.Context, id string, ref model.UserRef) (*model.User, error)
+	Members(ctx context.Context, id string, filter model.UserFilter) ([]model.User, error)
+}
+
+// OrgHandler serves the organization endpoints
+type OrgHandler struct {
+	orgs OrgService
+}
+
+// NewOrgHandler creates an OrgHandler backed by the given service
+func NewOrgHandler(orgs OrgService) *OrgHandler {
+	return &OrgHandler{orgs: orgs}
+}
+
+// orgIDKey is the context key under which RequireOrgID stores the ID
+const orgIDKey = "orgID"
+
+// RequireOrgID parses the named route parameter as the public ID of an
+// organization and stores it in the context, aborting with a 400 problem
+// when it is malformed
+func RequireOrgID(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := model.ParseOrgID(c.Param(param))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Set(orgIDKey, id)
+		c.Next()
+	}
+}
+
+// createOrg creates a new organization
+func (h *OrgHandler) createOrg(c *gin.Context) {
+	var req model.CreateOrgRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	org, err := h.orgs.Create(ctx, req)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(201, org)
+}
+
+// getOrg retrieves a single organization
+func (h *OrgHandler) getOrg(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	org, err := h.orgs.Get(ctx, c.GetString(orgIDKey))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(200, org)
+}
+
+// addMember adds the user of the request body to the organization
+func (h *OrgHandler) addMember(c *gin.Context) {
+	var req model.AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+	ref, err := model.ParseUserRef(req.UserID)
+	if err != nil {
+		abortWithError(c, &model.ValidationError{Field: "user_id", Reason: "must be an ObjectID, a UUID or a Snowflake ID"})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.orgs.AddMember(ctx, c.GetString(orgIDKey), ref)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(200, user)
+}
+
+// listMembers retrieves the members of the organization, with the same
+// filters, sort and pages as the user list
+func (h *OrgHandler) listMembers(c *gin.Context) {
+	filter, _, err := listFilter(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	users, err := h.orgs.Members(ctx, c.GetString(orgIDKey), filter)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.JSON(200, listPage(filter, users))
+}
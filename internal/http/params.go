@@ -0,0 +1,208 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+
+	"datadog-golang-example/internal/model"
+)
+
+// userRefKey is the context key under which RequireUserRef stores the ID
+const userRefKey = "userRef"
+
+// RequireUserRef parses the named route parameter as an ObjectID or a
+// public ID and stores it in the context, aborting with a 400 problem when it is
+// malformed
+func RequireUserRef(param string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ref, err := model.ParseUserRef(c.Param(param))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Set(userRefKey, ref)
+		c.Next()
+	}
+}
+
+// userRef returns the reference stored by RequireUserRef
+func userRef(c *gin.Context) model.UserRef {
+	return c.MustGet(userRefKey).(model.UserRef)
+}
+
+// Page is the paging of a list, shared by every list endpoint: limit
+// bounds the page to at most 100 items, all of them when zero, and offset
+// skips the first ones
+type Page struct {
+	Limit  int `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset int `form:"offset" binding:"min=0,max=2147483647"`
+}
+
+// ListUsersParams are the query parameters of a user list that are not
+// filters. With Stream the list is written as it is read; with
+// ChangedSince, an RFC 3339 time, it is marked with the changes since.
+type ListUsersParams struct {
+	Sort         string    `form:"sort"`
+	Stream       bool      `form:"stream"`
+	ChangedSince time.Time `form:"changed_since"`
+	Page
+}
+
+// SuggestParams are the query parameters of the user suggestions
+type SuggestParams struct {
+	Q string `form:"q"`
+}
+
+// NearbyParams are the query parameters of the proximity search; a zero
+// Radius uses the default radius
+type NearbyParams struct {
+	Lat    *float64 `form:"lat" binding:"required"`
+	Lng    *float64 `form:"lng" binding:"required"`
+	Radius float64  `form:"radius"`
+}
+
+// ActivityParams are the query parameters of the activity feed; Since and
+// Before are RFC 3339 times
+type ActivityParams struct {
+	Type   string    `form:"type"`
+	UserID string    `form:"user_id"`
+	Since  time.Time `form:"since"`
+	Before time.Time `form:"before"`
+	Page
+}
+
+// bindQuery binds the query parameters into params, a pointer to one of
+// the parameter structs, as ShouldBindQuery does but with queryBinding
+func bindQuery(c *gin.Context, params any) error {
+	return c.ShouldBindWith(params, queryBinding{})
+}
+
+// queryBinding binds query parameters as binding.Query does, but reports
+// a failure as a model.ValidationError naming the parameter, so it is
+// answered with a validation problem the client can act on
+type queryBinding struct{}
+
+// Name implements binding.Binding
+func (queryBinding) Name() string {
+	return "query"
+}
+
+// Bind implements binding.Binding
+func (queryBinding) Bind(req *http.Request, obj any) error {
+	values := req.URL.Query()
+	if err := binding.MapFormWithTag(obj, values, "form"); err != nil {
+		return malformedParam(obj, values, err)
+	}
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		var invalid validator.ValidationErrors
+		if !errors.As(err, &invalid) {
+			return bindError(err)
+		}
+		fe := invalid[0]
+		return &model.ValidationError{Field: queryName(reflect.TypeOf(obj).Elem(), fe.StructField()), Reason: paramReason(fe)}
+	}
+	return nil
+}
+
+// malformedParam finds the parameter of values that failed to map into
+// obj with err, by mapping each one alone into an empty struct
+func malformedParam(obj any, values url.Values, err error) error {
+	t := reflect.TypeOf(obj).Elem()
+	for name, v := range values {
+		scratch := reflect.New(t).Interface()
+		if binding.MapFormWithTag(scratch, map[string][]string{name: v}, "form") == nil {
+			continue
+		}
+		reason := "is malformed"
+		for _, f := range queryFields(t) {
+			if paramName(f) == name {
+				reason = typeReason(f.Type)
+			}
+		}
+		return &model.ValidationError{Field: name, Reason: reason}
+	}
+	return bindError(err)
+}
+
+// queryFields returns the fields of the parameter struct t bound to query
+// parameters, including those of the structs it embeds
+func queryFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			fields = append(fields, queryFields(f.Type)...)
+		} else if paramName(f) != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// paramName returns the query parameter bound to f
+func paramName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("form"), ",")
+	return name
+}
+
+// queryName returns the query parameter of the field of t, or of the
+// structs it embeds, named field
+func queryName(t reflect.Type, field string) string {
+	if f, ok := t.FieldByName(field); ok {
+		return paramName(f)
+	}
+	return field
+}
+
+// queryNames returns the query parameters bound by the parameter struct
+// params
+func queryNames(params any) []string {
+	var names []string
+	for _, f := range queryFields(reflect.TypeOf(params)) {
+		names = append(names, paramName(f))
+	}
+	return names
+}
+
+// typeReason explains what a parameter of type t must look like
+func typeReason(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeFor[time.Time]():
+		return "must be an RFC 3339 time"
+	case t.Kind() == reflect.Bool:
+		return "must be true or false"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return "must be an integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return "must be a number"
+	default:
+		return "is malformed"
+	}
+}
+
+// paramReason explains the validation rule a parameter broke
+func paramReason(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	default:
+		return "is invalid"
+	}
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/perf"
+)
+
+// benchUserService answers from memory, so the benchmarks of the handlers
+// measure the handlers alone
+type benchUserService struct {
+	UserService
+	users []model.User
+}
+
+func (s *benchUserService) Create(_ context.Context, req model.CreateUserRequest) (*model.User, error) {
+	u := s.users[0]
+	u.Name, u.Email, u.Age = req.Name, req.Email, req.Age
+	return &u, nil
+}
+
+func (s *benchUserService) List(_ context.Context, filter model.UserFilter) ([]model.User, error) {
+	return s.users[:min(filter.Limit, len(s.users))], nil
+}
+
+func (s *benchUserService) LastModified(context.Context) (time.Time, error) {
+	return time.Time{}, nil
+}
+
+func (s *benchUserService) Get(context.Context, model.UserRef) (*model.User, error) {
+	return &s.users[0], nil
+}
+
+// benchRouter serves the hot user routes of a UserHandler backed by 100
+// users
+func benchRouter() *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	users := make([]model.User, 100)
+	created := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	for i := range users {
+		users[i] = model.User{
+			PublicID:  fmt.Sprintf("0192a8e2-0000-7000-8000-%012d", i),
+			Username:  fmt.Sprintf("user-%d", i),
+			Name:      fmt.Sprintf("User %d", i),
+			Email:     fmt.Sprintf("user%d@example.com", i),
+			Age:       20 + i%50,
+			Tags:      []string{"beta", "demo"},
+			CreatedAt: created,
+			UpdatedAt: created,
+			Version:   1,
+		}
+	}
+	h := NewUserHandler(&benchUserService{users: users}, nil)
+	r := gin.New()
+	r.POST("/api/v1/users", h.createUser)
+	r.GET("/api/v1/users", h.getUsers)
+	r.GET("/api/v1/users/:id", RequireUserRef("id"), h.getUserByID)
+	return r
+}
+
+// benchmarkRoute serves the request of newRequest b.N times, failing on
+// an unexpected status
+func benchmarkRoute(b *testing.B, status int, newRequest func() *http.Request) {
+	r := benchRouter()
+	b.ReportAllocs()
+	for b.Loop() {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, newRequest())
+		if w.Code != status {
+			b.Fatalf("status %d, want %d: %s", w.Code, status, w.Body)
+		}
+	}
+}
+
+func BenchmarkGetUser(b *testing.B) {
+	benchmarkRoute(b, 200, func() *http.Request {
+		return httptest.NewRequest(http.MethodGet, "/api/v1/users/0192a8e2-0000-7000-8000-000000000000", nil)
+	})
+}
+
+func BenchmarkListUsers(b *testing.B) {
+	benchmarkRoute(b, 200, func() *http.Request {
+		return httptest.NewRequest(http.MethodGet, "/api/v1/users?tag=beta&sort=-name&limit=20", nil)
+	})
+}
+
+func BenchmarkCreateUser(b *testing.B) {
+	const body = `{"name": "Alice", "email": "alice@example.com", "age": 30, "tags": ["beta"]}`
+	benchmarkRoute(b, 201, func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	})
+}
+
+// TestPerfHandlers fails when the hot user handlers get slower or
+// allocate more than their baseline
+func TestPerfHandlers(t *testing.T) {
+	perf.Check(t,
+		perf.Benchmark{Name: "GetUser", F: BenchmarkGetUser},
+		perf.Benchmark{Name: "ListUsers", F: BenchmarkListUsers},
+		perf.Benchmark{Name: "CreateUser", F: BenchmarkCreateUser},
+	)
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/profile"
+)
+
+// ProfileHandler serves the profiles, fanned out to the user endpoint and
+// the enrichment service, and a mock of the enrichment service so the
+// demo needs no other deployment
+type ProfileHandler struct {
+	profiles *profile.Fetcher
+	cfg      config.ProfilesConfig
+}
+
+// NewProfileHandler creates a ProfileHandler building the profiles with
+// profiles
+func NewProfileHandler(profiles *profile.Fetcher, cfg config.ProfilesConfig) *ProfileHandler {
+	return &ProfileHandler{profiles: profiles, cfg: cfg}
+}
+
+// getProfile answers the profile of the user, degraded when only the
+// enrichment failed
+func (h *ProfileHandler) getProfile(c *gin.Context) {
+	p, err := h.profiles.Fetch(c.Request.Context(), c.Param("id"), c.Request.Header)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if p.Degraded {
+		addWarning(c, "the enrichment service failed: the profile lacks its enrichment")
+	}
+	c.JSON(200, p)
+}
+
+// mockCompanies and mockTitles are the values the mock enrichment picks
+// from
+var (
+	mockCompanies = []string{"Initech", "Globex", "Umbrella", "Hooli", "Stark Industries", "Wayne Enterprises"}
+	mockTitles    = []string{"Engineer", "Designer", "Product Manager", "SRE", "Data Scientist", "Support Lead"}
+)
+
+// mockEnrichment plays the enrichment service. Its request span reports
+// the enrichment service name, so it shows up as a service of its own on
+// the service map. The answer and latency are derived from the ID, and
+// EnrichmentFailurePercent of the requests fail with a 503.
+func (h *ProfileHandler) mockEnrichment(c *gin.Context) {
+	if span, ok := tracer.SpanFromContext(c.Request.Context()); ok {
+		span.SetTag(ext.ServiceName, h.cfg.EnrichmentService)
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(c.Param("id")))
+	sum := int(hash.Sum32())
+
+	select {
+	case <-time.After(time.Duration(20+sum%80) * time.Millisecond):
+	case <-c.Request.Context().Done():
+		abortWithError(c, c.Request.Context().Err())
+		return
+	}
+	if rand.IntN(100) < h.cfg.EnrichmentFailurePercent {
+		abortWithError(c, fmt.Errorf("enrichment: simulated outage: %w", model.ErrUnavailable))
+		return
+	}
+	c.JSON(200, profile.Enrichment{
+		Company: mockCompanies[sum%len(mockCompanies)],
+		Title:   mockTitles[(sum/len(mockCompanies))%len(mockTitles)],
+		Score:   sum % 101,
+	})
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// queryUsers lists the users matching the structured query of the body,
+// with the same filters, sort and pages as the user list, the fields to
+// return and how to count the matches
+func (h *UserHandler) queryUsers(c *gin.Context) {
+	var req model.UserQuery
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+	filter := model.UserFilter{
+		Filters: req.Filters,
+		Sort:    req.Sort,
+		Offset:  req.Offset,
+		Fields:  req.Fields,
+	}
+	if filter.Filters == nil {
+		filter.Filters = map[string]string{}
+	}
+	// One more user than asked for tells whether there is a next page
+	if req.Limit > 0 {
+		filter.Limit = req.Limit + 1
+	}
+
+	ctx := c.Request.Context()
+
+	result, err := h.users.Query(ctx, filter, req.Count)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	body := listPage(filter, result.Users)
+	if len(filter.Fields) > 0 {
+		if body["users"], err = project(body["users"].([]model.User), filter.Fields); err != nil {
+			abortWithError(c, err)
+			return
+		}
+	}
+	if result.Count != model.CountNone {
+		body["total"] = result.Total
+	}
+	body["total_accuracy"] = result.Count
+	c.JSON(200, body)
+}
+
+// project keeps the given JSON fields of each user, and its ID
+func project(users []model.User, fields []string) ([]map[string]any, error) {
+	projected := make([]map[string]any, len(users))
+	for i, u := range users {
+		data, err := json.Marshal(u)
+		if err != nil {
+			return nil, err
+		}
+		var all map[string]any
+		if err := json.Unmarshal(data, &all); err != nil {
+			return nil, err
+		}
+		projected[i] = map[string]any{"id": all["id"]}
+		for _, f := range fields {
+			if v, ok := all[f]; ok {
+				projected[i][f] = v
+			}
+		}
+	}
+	return projected, nil
+}
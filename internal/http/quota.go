@@ -0,0 +1,96 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/auth"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/quota"
+)
+
+// Quota enforces the monthly quota of API-key clients. Anonymous callers
+// and admins are not metered. Redis failures fail open: the request is
+// served and the error is counted, since quotas are not worth an outage.
+func Quota(tracker *quota.Tracker, metrics statsd.ClientInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := principal(c)
+		if p.Level != auth.Client {
+			c.Next()
+			return
+		}
+
+		tags := []string{"api_key:" + p.Name}
+		u, err := tracker.Consume(c.Request.Context(), p.Name)
+		if err != nil {
+			log.Printf("Quota check failed for %s: %v", p.Name, err)
+			_ = metrics.Incr("quota.errors", tags, 1)
+			c.Next()
+			return
+		}
+
+		setQuotaHeaders(c, u)
+		_ = metrics.Gauge("quota.used", float64(u.Used), tags, 1)
+		_ = metrics.Gauge("quota.remaining", float64(u.Remaining), tags, 1)
+		if span, ok := tracer.SpanFromContext(c.Request.Context()); ok {
+			span.SetTag("quota.remaining", u.Remaining)
+		}
+
+		if u.Exhausted() {
+			_ = metrics.Incr("quota.requests", append(tags, "outcome:exhausted"), 1)
+			c.Header("Retry-After", strconv.FormatInt(int64(time.Until(u.ResetsAt).Seconds())+1, 10))
+			abortWithError(c, errQuotaExhausted)
+			return
+		}
+		_ = metrics.Incr("quota.requests", append(tags, "outcome:allowed"), 1)
+		c.Next()
+	}
+}
+
+// setQuotaHeaders reports the quota state on the response
+func setQuotaHeaders(c *gin.Context, u quota.Usage) {
+	c.Header("X-Quota-Limit", strconv.FormatInt(u.Limit, 10))
+	c.Header("X-Quota-Remaining", strconv.FormatInt(u.Remaining, 10))
+	c.Header("X-Quota-Reset", strconv.FormatInt(u.ResetsAt.Unix(), 10))
+}
+
+// QuotaHandler serves the quota endpoint
+type QuotaHandler struct {
+	tracker *quota.Tracker
+}
+
+// NewQuotaHandler creates a QuotaHandler; tracker may be nil when quotas
+// are disabled
+func NewQuotaHandler(tracker *quota.Tracker) *QuotaHandler {
+	return &QuotaHandler{tracker: tracker}
+}
+
+// getQuota returns the calling key's usage for the current month
+func (h *QuotaHandler) getQuota(c *gin.Context) {
+	if h.tracker == nil {
+		abortWithError(c, errQuotasDisabled)
+		return
+	}
+
+	p := principal(c)
+	u, err := h.tracker.Usage(c.Request.Context(), p.Name)
+	if err != nil {
+		abortWithError(c, fmt.Errorf("%w: %w", model.ErrUnavailable, err))
+		return
+	}
+
+	setQuotaHeaders(c, u)
+	c.JSON(200, gin.H{"api_key": p.Name, "quota": u})
+}
+
+// Quota errors
+var (
+	errQuotaExhausted = fmt.Errorf("monthly quota exhausted: %w", model.ErrRateLimited)
+	errQuotasDisabled = fmt.Errorf("quotas are not enabled: %w", model.ErrNotFound)
+)
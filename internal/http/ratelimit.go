@@ -0,0 +1,77 @@
+package http
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/auth"
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/ratelimit"
+)
+
+// RateLimit applies the tier limits of a route group, the one the Route
+// names or else group: anonymous callers share a bucket per IP, API-key
+// clients get a bucket per key and admins are exempt. Every decision is
+// counted, and API-key quota consumption is exported as a gauge so
+// Datadog monitors can alert before clients hit 429s. The limits are read
+// from policy on every request.
+func RateLimit(group string, policy *ratelimit.Policy, limiter *ratelimit.Limiter, metrics statsd.ClientInterface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		group := group
+		if route := routeOf(c); route != nil && route.RateLimit != "" {
+			group = route.RateLimit
+		}
+		p := principal(c)
+		limits := policy.Limits(group)
+
+		var key string
+		var limit config.Limit
+		tags := []string{"route_group:" + group, "tier:" + p.Level.String()}
+		switch p.Level {
+		case auth.Admin:
+			c.Next()
+			return
+		case auth.Client:
+			key, limit = group+"|key|"+p.Name, limits.APIKey
+			tags = append(tags, "api_key:"+p.Name)
+		default:
+			// Tagging by IP would explode metric cardinality, so anonymous
+			// traffic is only tagged by tier
+			key, limit = group+"|ip|"+c.ClientIP(), limits.Anonymous
+		}
+		if limit.Unlimited() {
+			c.Next()
+			return
+		}
+
+		d := limiter.Allow(key, limit)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		if p.Level == auth.Client {
+			_ = metrics.Gauge("ratelimit.quota_used", 1-float64(d.Remaining)/float64(d.Limit), tags, 1)
+		}
+
+		if !d.Allowed {
+			_ = metrics.Incr("ratelimit.requests", append(tags, "outcome:limited"), 1)
+			if span, ok := tracer.SpanFromContext(c.Request.Context()); ok {
+				span.SetTag("ratelimit.limited", true)
+			}
+			if d.RetryAfter > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds()))))
+			}
+			abortWithError(c, errRateLimited)
+			return
+		}
+		_ = metrics.Incr("ratelimit.requests", append(tags, "outcome:allowed"), 1)
+		c.Next()
+	}
+}
+
+// errRateLimited is returned when the caller exhausted its bucket
+var errRateLimited = fmt.Errorf("too many requests, slow down: %w", model.ErrRateLimited)
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/logging"
+	"datadog-golang-example/internal/readonly"
+)
+
+// errReadOnly is returned for the routes that write while the API is
+// read-only
+var errReadOnly = errors.New("the API is read-only: only reads are served")
+
+// writes reports whether the route changes stored data, and so is refused
+// in read-only mode
+func (r Route) writes() bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return !r.AllowReadOnly
+}
+
+// ReadOnly refuses the routes that write with a 405 while mode is on,
+// the Allow header listing the methods of the path still served. The
+// request spans are tagged with http.read_only meanwhile. It must run
+// inside ErrorHandler.
+func ReadOnly(mode *readonly.Mode, list []Route) gin.HandlerFunc {
+	allowed := map[string][]string{}
+	for _, route := range list {
+		if !route.writes() {
+			allowed[route.Path] = append(allowed[route.Path], route.Method)
+		}
+	}
+	for _, methods := range allowed {
+		slices.Sort(methods)
+	}
+	return func(c *gin.Context) {
+		if !mode.Enabled() {
+			c.Next()
+			return
+		}
+		route := routeOf(c)
+		refused := route != nil && route.writes()
+		if span, ok := tracer.SpanFromContext(c.Request.Context()); ok {
+			span.SetTag("http.read_only", true)
+			span.SetTag("http.read_only.refused", refused)
+		}
+		if refused {
+			c.Header("Allow", strings.Join(allowed[route.Path], ", "))
+			abortWithError(c, errReadOnly)
+			return
+		}
+		c.Next()
+	}
+}
+
+// readOnlyRequest is the body of PUT /admin/read-only
+type readOnlyRequest struct {
+	ReadOnly *bool `json:"read_only" binding:"required"`
+}
+
+// readOnlyResponse tells whether the API is read-only
+type readOnlyResponse struct {
+	ReadOnly bool `json:"read_only"`
+}
+
+// ReadOnlyHandler serves the admin endpoints that switch the read-only
+// mode
+type ReadOnlyHandler struct {
+	mode *readonly.Mode
+}
+
+// NewReadOnlyHandler creates a ReadOnlyHandler for mode
+func NewReadOnlyHandler(mode *readonly.Mode) *ReadOnlyHandler {
+	return &ReadOnlyHandler{mode: mode}
+}
+
+// getReadOnly tells whether the API is read-only
+func (h *ReadOnlyHandler) getReadOnly(c *gin.Context) {
+	c.JSON(200, readOnlyResponse{ReadOnly: h.mode.Enabled()})
+}
+
+// putReadOnly switches the read-only mode, such as for an incident freeze.
+// The change is audited and tagged on the request span.
+func (h *ReadOnlyHandler) putReadOnly(c *gin.Context) {
+	var req readOnlyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+	old := h.mode.Set(*req.ReadOnly)
+	if old != *req.ReadOnly {
+		logging.Audit("Switched read-only mode", "actor", principal(c).Name, "setting", "read_only", "old", old, "new", *req.ReadOnly)
+	}
+	if span, ok := tracer.SpanFromContext(c.Request.Context()); ok {
+		span.SetTag("read_only.old", old)
+		span.SetTag("read_only.new", *req.ReadOnly)
+	}
+	c.JSON(200, readOnlyResponse{ReadOnly: *req.ReadOnly})
+}
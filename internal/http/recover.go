@@ -0,0 +1,48 @@
+package http
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+)
+
+// Recover turns a handler panic into a 500 problem document. The panic is
+// recorded on a child span, finished while the panic stack is still live,
+// so the stack trace in Datadog points at the panicking line.
+func Recover() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+
+			err, ok := r.(error)
+			if !ok {
+				err = fmt.Errorf("%v", r)
+			}
+			err = &panicError{cause: err}
+			span, _ := tracer.StartSpanFromContext(c.Request.Context(), "panic.recover")
+			span.Finish(tracer.WithError(err))
+
+			log.Printf("Recovered panic on %s %s: %v\n%s", c.Request.Method, c.Request.URL.Path, r, debug.Stack())
+			abortWithError(c, err)
+		}()
+		c.Next()
+	}
+}
+
+// panicError wraps the value a handler panicked with
+type panicError struct {
+	cause error
+}
+
+func (e *panicError) Error() string { return "panic: " + e.cause.Error() }
+func (e *panicError) Unwrap() error { return e.cause }
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/auth"
+	"datadog-golang-example/internal/baggage"
+	"datadog-golang-example/internal/chaos"
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/dedup"
+	"datadog-golang-example/internal/quota"
+	"datadog-golang-example/internal/ratelimit"
+	"datadog-golang-example/internal/readonly"
+)
+
+// RouterConfig holds the handlers and middleware dependencies of the router
+type RouterConfig struct {
+	Service string
+	Version string
+	Users   *UserHandler
+	Orgs    *OrgHandler
+	// Merges serves the merges of duplicate users; nil leaves them
+	// unregistered
+	Merges *MergeHandler
+	// Attachments serves the user attachments; nil leaves them unregistered
+	Attachments *AttachmentHandler
+	// Activity serves the activity feed; nil leaves it unregistered
+	Activity  *ActivityHandler
+	Views     *ViewHandler
+	UI        *UIHandler
+	Quota     *QuotaHandler
+	Chaos     *ChaosHandler
+	Exports   *ExportHandler
+	Imports   *ImportHandler
+	Keys      *auth.KeyStore
+	RateLimit *ratelimit.Policy
+	SLO       config.SLOConfig
+	// Quotas meters API-key clients; nil disables quota enforcement
+	Quotas *quota.Tracker
+	// Dedup replays duplicate POSTs; nil disables the detection
+	Dedup *dedup.Store
+	// Brownout sheds non-essential traffic while Degradation reports the
+	// database degraded; a nil Degradation disables the shedding
+	Brownout    config.BrownoutConfig
+	Degradation DegradationSignal
+	// DBBudget bounds the MongoDB operations of each request
+	DBBudget config.DBBudgetConfig
+	Injector *chaos.Injector
+	Metrics  statsd.ClientInterface
+	// Debug serves the failure scenarios; nil leaves them unregistered
+	Debug *DebugHandler
+	// Profiles serves the fanned out profiles and the mock enrichment
+	// service; nil leaves them unregistered
+	Profiles *ProfileHandler
+	// Audit verifies the signed audit trail; nil leaves it unregistered
+	Audit *AuditHandler
+	// Diagnostics describes how the instance is wired
+	Diagnostics *DiagnosticsHandler
+	// Stats serves the user statistics
+	Stats *StatsHandler
+	// ReadOnly refuses the routes that write while it is on, and
+	// ReadOnlySwitch serves the admin endpoints switching it; nil serves
+	// every route
+	ReadOnly       *readonly.Mode
+	ReadOnlySwitch *ReadOnlyHandler
+	// Pprof exposes the runtime profiles to admins under /debug/pprof
+	Pprof bool
+	// TrustedProxies are allowed to report the client IP in forwarding headers
+	TrustedProxies []string
+	// Middleware and APIMiddleware name the middleware of every route and
+	// of the /api/v1 routes, outermost first
+	Middleware    []string
+	APIMiddleware []string
+	// CORSOrigins are the origins allowed by the cors middleware
+	CORSOrigins []string
+	// Sessions start the causal sessions of CausalRoutes, given as
+	// "METHOD /pattern"; nil disables them
+	Sessions     SessionStarter
+	CausalRoutes []string
+	// Baggage filters the trace baggage of requests; nil lets it through
+	Baggage *baggage.Policy
+	// GeoIP resolves client countries; nil disables the lookup
+	GeoIP  CountryLookup
+	Cache  *ResponseCache
+	Health *HealthHandler
+}
+
+// NewRouter creates the Gin router with middleware and the routes of
+// the registry
+func NewRouter(cfg RouterConfig) *gin.Engine {
+	r := gin.New()
+	r.SetHTMLTemplate(templates)
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("Ignoring trusted proxies: %v", err)
+		r.SetTrustedProxies(nil)
+	}
+	limiter := ratelimit.New()
+	spec := new(openAPISpec)
+	list := routes(cfg, spec)
+	if err := spec.build(cfg.Service, cfg.Version, apiRateLimit, list); err != nil {
+		log.Printf("Serving an empty OpenAPI document: %v", err)
+	}
+
+	// Panics escaping the configured chain still must not kill the server
+	r.Use(gin.Recovery())
+	r.Use(describeRoutes(list))
+	r.Use(buildChain("HTTP", cfg.Middleware, globalMiddleware(cfg, list))...)
+	if cfg.ReadOnly != nil && !slices.Contains(cfg.Middleware, "read_only") {
+		log.Printf("WARNING: The read_only middleware is not in the HTTP chain: the read-only mode refuses no route")
+	}
+	r.NoRoute(func(c *gin.Context) {
+		abortWithError(c, errRouteNotFound)
+	})
+
+	// Each route joins the first group its path starts with
+	groups := []*gin.RouterGroup{
+		r.Group("/api/v1", buildChain("API", cfg.APIMiddleware, apiMiddleware(cfg, limiter))...),
+		r.Group("/users", HTMLErrors()),
+		&r.RouterGroup,
+	}
+	for _, route := range list {
+		for _, group := range groups {
+			if base := strings.TrimSuffix(group.BasePath(), "/"); strings.HasPrefix(route.Path, base) {
+				group.Handle(route.Method, strings.TrimPrefix(route.Path, base), route.handlers()...)
+				break
+			}
+		}
+	}
+	r.StaticFS("/ui/assets", uiAssets)
+	return r
+}
@@ -0,0 +1,248 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/auth"
+)
+
+// Route declares one endpoint: its handler and the metadata the router,
+// the middleware and the OpenAPI document read, so each is stated once
+type Route struct {
+	Method string
+	// Path is the full Gin pattern, such as /api/v1/users/:id
+	Path    string
+	Handler gin.HandlerFunc
+	// Level is the least authentication level allowed in
+	Level auth.Level
+	// RateLimit names the rate limit group of the route; empty uses the
+	// group of the ratelimit middleware
+	RateLimit string
+	// Timeout bounds the request context; zero leaves it unbounded
+	Timeout time.Duration
+	// Summary documents the route in the OpenAPI document
+	Summary string
+	// Query is the parameter struct the handler binds the query string
+	// into, documented in the OpenAPI document
+	Query any
+	// Middleware run after the authentication check, before Handler
+	Middleware []gin.HandlerFunc
+	// Deprecation announces that the route is going away; nil leaves it
+	// current
+	Deprecation *Deprecation
+	// Warnings are sent with every response of the route, such as the
+	// changes coming to it
+	Warnings []string
+	// AllowReadOnly keeps a route whose method writes served in read-only
+	// mode, because it changes no stored data, such as a search
+	AllowReadOnly bool
+}
+
+// key identifies the route as "METHOD /pattern", as c.FullPath reports it
+func (r Route) key() string {
+	return r.Method + " " + r.Path
+}
+
+// handlers returns the handler chain of the route
+func (r Route) handlers() []gin.HandlerFunc {
+	var chain []gin.HandlerFunc
+	if r.Level > auth.Anonymous {
+		chain = append(chain, RequireLevel(r.Level))
+	}
+	if r.Timeout > 0 {
+		chain = append(chain, Timeout(r.Timeout))
+	}
+	chain = append(chain, r.Middleware...)
+	return append(chain, r.Handler)
+}
+
+// routeKey is the context key under which describeRoutes stores the route
+const routeKey = "route"
+
+// describeRoutes stores the Route matching each request in the context,
+// so the middleware running before its handlers can read its metadata
+func describeRoutes(routes []Route) gin.HandlerFunc {
+	byKey := make(map[string]*Route, len(routes))
+	for i := range routes {
+		byKey[routes[i].key()] = &routes[i]
+	}
+	return func(c *gin.Context) {
+		if route, ok := byKey[c.Request.Method+" "+c.FullPath()]; ok {
+			c.Set(routeKey, route)
+		}
+		c.Next()
+	}
+}
+
+// routeOf returns the Route of the request, nil when it matched none
+func routeOf(c *gin.Context) *Route {
+	if route, ok := c.Get(routeKey); ok {
+		return route.(*Route)
+	}
+	return nil
+}
+
+// Timeouts of the routes by the work they do
+const (
+	readTimeout   = 5 * time.Second
+	searchTimeout = 10 * time.Second
+	writeTimeout  = 5 * time.Second
+	batchTimeout  = 10 * time.Second
+	importTimeout = 2 * time.Minute
+)
+
+// Rate limit group of the routes running expensive queries
+const searchRateLimit = "search"
+
+// routes is the registry of every endpoint of the router, the OpenAPI
+// document being served by spec. Routes whose handler is not configured
+// are left out.
+func routes(cfg RouterConfig, spec *openAPISpec) []Route {
+	users := cfg.Users
+	userRef := []gin.HandlerFunc{RequireUserRef("id")}
+	orgID := []gin.HandlerFunc{RequireOrgID("id")}
+
+	list := []Route{
+		{Method: http.MethodGet, Path: "/ping", Handler: ping, Summary: "Check that the server is up"},
+		{Method: http.MethodGet, Path: "/readyz", Handler: cfg.Health.readyz, Timeout: 2 * time.Second,
+			Summary: "Report whether the service can take traffic, with the status of its dependencies"},
+		{Method: http.MethodGet, Path: "/openapi.json", Handler: spec.serve, Summary: "Describe the API as an OpenAPI document"},
+
+		// HTML views
+		{Method: http.MethodGet, Path: "/users", Handler: cfg.Views.listUsers, Timeout: searchTimeout, Summary: "Show the users as an HTML page"},
+		{Method: http.MethodGet, Path: "/users/:id", Handler: cfg.Views.showUser, Timeout: readTimeout, Middleware: userRef,
+			Summary: "Show a user as an HTML page"},
+
+		// Demo front end
+		{Method: http.MethodGet, Path: "/ui", Handler: cfg.UI.index, Summary: "Serve the demo front end"},
+
+		// CRUD endpoints
+		{Method: http.MethodGet, Path: "/api/v1/quota", Handler: cfg.Quota.getQuota, Level: auth.Client,
+			Summary: "Report the monthly quota of the API key"},
+		{Method: http.MethodPost, Path: "/api/v1/users", Handler: users.createUser, Timeout: writeTimeout, Summary: "Create a user"},
+		// Streamed lists run for up to streamTimeout; pages bound themselves
+		// to searchTimeout
+		{Method: http.MethodGet, Path: "/api/v1/users", Handler: users.getUsers, Timeout: streamTimeout, RateLimit: searchRateLimit, Query: ListUsersParams{},
+			Summary: "List, filter, sort and page through the users, or stream them"},
+		// Gin treats ":action" as a parameter, so it also captures the
+		// leading colon of custom methods such as /users:batchGet
+		{Method: http.MethodPost, Path: "/api/v1/users:action", Handler: users.usersAction, Timeout: readTimeout, AllowReadOnly: true,
+			Summary: "Run a custom method on the users, such as :batchGet"},
+		{Method: http.MethodPost, Path: "/api/v1/users/query", Handler: users.queryUsers, Timeout: searchTimeout, RateLimit: searchRateLimit, AllowReadOnly: true,
+			Summary: "Search the users with a structured query"},
+		{Method: http.MethodGet, Path: "/api/v1/users/suggest", Handler: users.suggestUsers, RateLimit: searchRateLimit, Query: SuggestParams{},
+			Summary: "Suggest users whose name starts with a prefix"},
+		{Method: http.MethodGet, Path: "/api/v1/users/nearby", Handler: users.nearbyUsers, Timeout: readTimeout, RateLimit: searchRateLimit, Query: NearbyParams{},
+			Summary: "Find the users near a location"},
+		// Served from a snapshot, which a cold cache waits for
+		{Method: http.MethodGet, Path: "/api/v1/users/stats", Handler: cfg.Stats.getUserStats, Timeout: searchTimeout, RateLimit: searchRateLimit,
+			Summary: "Sum up the users, their ages and their most used tags"},
+		{Method: http.MethodGet, Path: "/api/v1/users/by-username/:username", Handler: users.getUserByUsername, Timeout: readTimeout,
+			Summary: "Get a user by username"},
+		{Method: http.MethodPatch, Path: "/api/v1/users/bulk", Handler: users.bulkUpdateUsers, Timeout: batchTimeout,
+			Summary: "Update many users at once"},
+
+		{Method: http.MethodGet, Path: "/api/v1/users/:id", Handler: users.getUserByID, Timeout: readTimeout, Middleware: userRef,
+			Summary: "Get a user"},
+		{Method: http.MethodPut, Path: "/api/v1/users/:id", Handler: users.replaceUser, Timeout: writeTimeout, Middleware: userRef,
+			Summary: "Create or replace a user"},
+		{Method: http.MethodPatch, Path: "/api/v1/users/:id", Handler: users.patchUser, Timeout: writeTimeout, Middleware: userRef,
+			Summary: "Update a user with a partial document or a JSON patch"},
+		{Method: http.MethodDelete, Path: "/api/v1/users/:id", Handler: users.deleteUser, Timeout: writeTimeout, Middleware: userRef,
+			Summary: "Delete a user"},
+		{Method: http.MethodPost, Path: "/api/v1/users/:id/tags", Handler: users.addUserTags, Timeout: writeTimeout, Middleware: userRef,
+			Summary: "Add tags to a user"},
+		{Method: http.MethodDelete, Path: "/api/v1/users/:id/tags/:tag", Handler: users.removeUserTag, Timeout: writeTimeout, Middleware: userRef,
+			Summary: "Remove a tag from a user"},
+		{Method: http.MethodPost, Path: "/api/v1/users/:id/erase", Handler: users.eraseUser, Level: auth.Admin, Timeout: writeTimeout, Middleware: userRef,
+			Summary: "Erase the personal data of a user"},
+
+		{Method: http.MethodPost, Path: "/api/v1/orgs", Handler: cfg.Orgs.createOrg, Timeout: writeTimeout, Summary: "Create an organization"},
+		{Method: http.MethodGet, Path: "/api/v1/orgs/:id", Handler: cfg.Orgs.getOrg, Timeout: readTimeout, Middleware: orgID,
+			Summary: "Get an organization"},
+		{Method: http.MethodPost, Path: "/api/v1/orgs/:id/members", Handler: cfg.Orgs.addMember, Timeout: writeTimeout, Middleware: orgID,
+			Summary: "Add a user to an organization"},
+		{Method: http.MethodGet, Path: "/api/v1/orgs/:id/members", Handler: cfg.Orgs.listMembers, Timeout: searchTimeout, Middleware: orgID, Query: ListUsersParams{},
+			Summary: "List the members of an organization"},
+
+		// Admin endpoints
+		{Method: http.MethodGet, Path: "/admin/chaos", Handler: cfg.Chaos.getChaos, Level: auth.Admin, Summary: "Get the fault injection settings"},
+		{Method: http.MethodPut, Path: "/admin/chaos", Handler: cfg.Chaos.putChaos, Level: auth.Admin, AllowReadOnly: true,
+			Summary: "Change the fault injection settings"},
+		{Method: http.MethodGet, Path: "/admin/loglevel", Handler: getLogLevel, Level: auth.Admin, Summary: "Get the log level"},
+		{Method: http.MethodPut, Path: "/admin/loglevel", Handler: putLogLevel, Level: auth.Admin, AllowReadOnly: true,
+			Summary: "Change the log level, for a while or for good"},
+		{Method: http.MethodGet, Path: "/admin/diagnostics", Handler: cfg.Diagnostics.getDiagnostics, Level: auth.Admin, Timeout: batchTimeout,
+			Summary: "Report the versions, features, tracer and MongoDB state of the instance"},
+		{Method: http.MethodPost, Path: "/admin/exports", Handler: cfg.Exports.startExport, Level: auth.Admin, Timeout: batchTimeout,
+			Summary: "Start exporting users as NDJSON"},
+		{Method: http.MethodGet, Path: "/admin/exports/:id", Handler: cfg.Exports.getExport, Level: auth.Admin, Summary: "Get the status of an export"},
+		{Method: http.MethodPost, Path: "/admin/import", Handler: cfg.Imports.importUsers, Level: auth.Admin, Timeout: importTimeout,
+			Summary: "Import the users of the CSV file at source_url, upserting them by email"},
+	}
+
+	if cfg.Merges != nil {
+		list = append(list, Route{Method: http.MethodPost, Path: "/api/v1/users/:id/merge", Handler: cfg.Merges.mergeUser,
+			Timeout: batchTimeout, Middleware: userRef, Summary: "Merge a duplicate user into a user"})
+	}
+	if cfg.Attachments != nil {
+		list = append(list,
+			Route{Method: http.MethodPost, Path: "/api/v1/users/:id/attachments", Handler: cfg.Attachments.createAttachment,
+				Timeout: writeTimeout, Middleware: userRef, Summary: "Record an attachment of a user and get its upload URL"},
+			Route{Method: http.MethodGet, Path: "/api/v1/users/:id/attachments", Handler: cfg.Attachments.listAttachments,
+				Timeout: readTimeout, Middleware: userRef, Summary: "List the attachments of a user"},
+			Route{Method: http.MethodGet, Path: "/api/v1/users/:id/attachments/:attachment", Handler: cfg.Attachments.getAttachment,
+				Timeout: readTimeout, Middleware: userRef, Summary: "Get an attachment of a user and its download URL"},
+		)
+	}
+	if cfg.ReadOnlySwitch != nil {
+		list = append(list,
+			Route{Method: http.MethodGet, Path: "/admin/read-only", Handler: cfg.ReadOnlySwitch.getReadOnly, Level: auth.Admin,
+				Summary: "Tell whether the API is read-only"},
+			Route{Method: http.MethodPut, Path: "/admin/read-only", Handler: cfg.ReadOnlySwitch.putReadOnly, Level: auth.Admin, AllowReadOnly: true,
+				Summary: "Make the API read-only, refusing the routes that write, or writable again"},
+		)
+	}
+	if cfg.Activity != nil {
+		list = append(list, Route{Method: http.MethodGet, Path: "/api/v1/activity", Handler: cfg.Activity.getActivity,
+			Timeout: searchTimeout, Query: ActivityParams{}, Summary: "List the latest events, newest first"})
+	}
+	if cfg.Profiles != nil {
+		list = append(list,
+			Route{Method: http.MethodGet, Path: "/api/v1/profiles/:id", Handler: cfg.Profiles.getProfile, Timeout: readTimeout, Middleware: userRef,
+				Summary: "Get the profile of a user, merged from the user and its enrichment"},
+			Route{Method: http.MethodGet, Path: "/api/v1/_mock/enrichment/:id", Handler: cfg.Profiles.mockEnrichment, Timeout: readTimeout,
+				Summary: "Play the enrichment service of the profiles"},
+		)
+	}
+	if cfg.Audit != nil {
+		list = append(list, Route{Method: http.MethodGet, Path: "/admin/audit/verify", Handler: cfg.Audit.verifyAudit, Level: auth.Admin,
+			Timeout: batchTimeout, Summary: "Check that the signed audit trail was not tampered with"})
+	}
+	if cfg.Debug != nil {
+		list = append(list,
+			Route{Method: http.MethodGet, Path: "/api/v1/_debug/error/:kind", Handler: cfg.Debug.triggerError, Summary: "Fail with the given kind of error"},
+			Route{Method: http.MethodGet, Path: "/api/v1/_debug/upstream", Handler: cfg.Debug.upstream, Summary: "Call the downstream service"},
+			Route{Method: http.MethodGet, Path: "/api/v1/_debug/emailcheck", Handler: cfg.Debug.emailCheck, Summary: "Play the email verification API"},
+		)
+	}
+	// On-demand runtime profiles
+	if cfg.Pprof {
+		list = append(list,
+			Route{Method: http.MethodGet, Path: "/debug/pprof/*profile", Handler: servePprof, Level: auth.Admin, Summary: "Serve a runtime profile"},
+			Route{Method: http.MethodPost, Path: "/debug/pprof/symbol", Handler: servePprof, Level: auth.Admin, AllowReadOnly: true,
+				Summary: "Look up program counters"},
+		)
+	}
+	return list
+}
+
+// ping answers that the server is up
+func ping(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"message": "pong",
+	})
+}
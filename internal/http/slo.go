@@ -0,0 +1,53 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/config"
+)
+
+// SLO classifies every request as a good or bad event for the availability
+// and latency objectives in cfg. Datadog metric-based SLOs and burn-rate
+// monitors are built directly on these counters:
+//
+//	good: sum:go_api_demo.slo.availability.events{outcome:good}.as_count()
+//	total: sum:go_api_demo.slo.availability.events{*}.as_count()
+//
+// Only server errors count against availability, and requests the client
+// cancelled are no events at all; the targets are attached as tags so
+// monitors can be written without hard-coding them.
+func SLO(cfg config.SLOConfig, metrics statsd.ClientInterface) gin.HandlerFunc {
+	availabilityTarget := "slo_target:" + strconv.FormatFloat(cfg.AvailabilityTarget*100, 'f', -1, 64)
+	latencyTarget := "slo_target:" + strconv.FormatFloat(cfg.LatencyTarget*100, 'f', -1, 64)
+	latencyThreshold := "slo_threshold_ms:" + strconv.FormatInt(cfg.LatencyThreshold.Milliseconds(), 10)
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		if c.Writer.Status() == statusClientClosedRequest {
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		outcome := "outcome:good"
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			outcome = "outcome:bad"
+		}
+		_ = metrics.Incr("slo.availability.events", []string{"route:" + route, outcome, availabilityTarget}, 1)
+
+		outcome = "outcome:good"
+		if time.Since(start) > cfg.LatencyThreshold {
+			outcome = "outcome:bad"
+		}
+		_ = metrics.Incr("slo.latency.events", []string{"route:" + route, outcome, latencyTarget, latencyThreshold}, 1)
+	}
+}
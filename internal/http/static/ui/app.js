@@ -0,0 +1,107 @@
+// Minimal front end for the users API. Every call goes through fetch, so
+// RUM records it as a resource and injects the trace headers.
+const api = '/api/v1/users';
+const usersEl = document.getElementById('users');
+const statusEl = document.getElementById('status');
+const suggestionsEl = document.getElementById('suggestions');
+
+function setStatus(text, isError) {
+  statusEl.textContent = text;
+  statusEl.className = isError ? 'error' : '';
+}
+
+async function request(url, options) {
+  const res = await fetch(url, options);
+  if (res.status === 204) return null;
+  const body = await res.json();
+  if (!res.ok) {
+    const err = new Error(body.detail || body.title || res.statusText);
+    err.traceId = body.trace_id;
+    throw err;
+  }
+  return body;
+}
+
+function reportError(action, err) {
+  setStatus(`${action} failed: ${err.message}`, true);
+  if (window.DD_RUM) {
+    window.DD_RUM.addError(err, { action, trace_id: err.traceId });
+  }
+}
+
+function cell(text) {
+  const td = document.createElement('td');
+  td.textContent = text;
+  return td;
+}
+
+async function loadUsers() {
+  try {
+    const { users } = await request(api);
+    usersEl.replaceChildren(...users.map((u) => {
+      const tr = document.createElement('tr');
+      const del = document.createElement('button');
+      del.textContent = 'Delete';
+      del.onclick = () => deleteUser(u);
+      const actions = document.createElement('td');
+      actions.append(del);
+      tr.append(cell(u.username), cell(u.name), cell(u.age), actions);
+      return tr;
+    }));
+    setStatus(`${users.length} users`);
+  } catch (err) {
+    reportError('load users', err);
+  }
+}
+
+async function deleteUser(user) {
+  try {
+    await request(`${api}/${user.id}`, { method: 'DELETE' });
+    await loadUsers();
+  } catch (err) {
+    reportError('delete user', err);
+  }
+}
+
+document.getElementById('create').addEventListener('submit', async (event) => {
+  event.preventDefault();
+  const form = new FormData(event.target);
+  const user = { name: form.get('name') };
+  if (form.get('email')) user.email = form.get('email');
+  if (form.get('age')) user.age = Number(form.get('age'));
+  try {
+    await request(api, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(user),
+    });
+    event.target.reset();
+    await loadUsers();
+  } catch (err) {
+    reportError('create user', err);
+  }
+});
+
+let searchTimer;
+document.getElementById('search').addEventListener('input', (event) => {
+  clearTimeout(searchTimer);
+  const q = event.target.value.trim();
+  if (!q) {
+    suggestionsEl.replaceChildren();
+    return;
+  }
+  searchTimer = setTimeout(async () => {
+    try {
+      const { suggestions } = await request(`${api}/suggest?q=${encodeURIComponent(q)}`);
+      suggestionsEl.replaceChildren(...suggestions.map((s) => {
+        const li = document.createElement('li');
+        li.textContent = `${s.name} (@${s.username})`;
+        return li;
+      }));
+    } catch (err) {
+      reportError('suggest', err);
+    }
+  }, 150);
+});
+
+loadUsers();
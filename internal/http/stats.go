@@ -0,0 +1,40 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// UserStatsSource serves the snapshot of the user statistics
+type UserStatsSource interface {
+	Stats(ctx context.Context) (*model.UserStats, error)
+}
+
+// StatsHandler serves the user statistics
+type StatsHandler struct {
+	stats UserStatsSource
+}
+
+// NewStatsHandler creates a StatsHandler backed by the given source
+func NewStatsHandler(stats UserStatsSource) *StatsHandler {
+	return &StatsHandler{stats: stats}
+}
+
+// getUserStats answers the latest snapshot of the user statistics. Its
+// staleness is given by the Age header, in seconds, and Last-Modified.
+func (h *StatsHandler) getUserStats(c *gin.Context) {
+	stats, err := h.stats.Stats(c.Request.Context())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.Header("Age", strconv.Itoa(int(time.Since(stats.ComputedAt).Seconds())))
+	c.Header("Last-Modified", stats.ComputedAt.Format(http.TimeFormat))
+	c.JSON(200, stats)
+}
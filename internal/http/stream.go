@@ -0,0 +1,78 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/ext"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// Streamed lists run longer than pages and are flushed as they go
+const (
+	streamTimeout    = 2 * time.Minute
+	streamFlushEvery = 100
+)
+
+// streamUsers writes the users matching filter as the cursor returns them,
+// in the body of a list page without next_offset, flushing every
+// streamFlushEvery users. The status is sent with the first user, so a
+// failure after it can only cut the body short, which leaves it invalid
+// JSON for the client to detect. The users streamed are tagged on the
+// request span.
+func (h *UserHandler) streamUsers(ctx context.Context, c *gin.Context, filter model.UserFilter) {
+	// Without a next page there is no extra user to ask for
+	if filter.Limit > 0 {
+		filter.Limit--
+	}
+	span, _ := tracer.SpanFromContext(c.Request.Context())
+
+	w := c.Writer
+	enc := json.NewEncoder(w)
+	begin := func() {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.WriteString(`{"users":[`)
+	}
+	n := 0
+	for user, err := range h.users.Stream(ctx, filter) {
+		if err != nil && n == 0 {
+			abortWithError(c, err)
+			return
+		}
+		if err != nil {
+			log.Printf("Cutting user stream short after %d users: %v", n, err)
+			span.SetTag("users.streamed", n)
+			span.SetTag(ext.Error, err)
+			return
+		}
+
+		if n == 0 {
+			begin()
+		} else {
+			w.WriteString(",")
+		}
+		if err := enc.Encode(user); err != nil {
+			log.Printf("Cutting user stream short after %d users: %v", n, err)
+			span.SetTag("users.streamed", n)
+			return
+		}
+		n++
+		if n%streamFlushEvery == 0 {
+			w.Flush()
+		}
+	}
+
+	if n == 0 {
+		begin()
+	}
+	w.WriteString(`],"count":` + strconv.Itoa(n) + "}")
+	span.SetTag("users.streamed", n)
+}
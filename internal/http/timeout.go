@@ -0,0 +1,22 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout bounds the context of the request to d, so the work of the
+// handler is cancelled once it runs out. The request gets its own context
+// back afterwards, so the middleware upstream do not see it cancelled.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parent := c.Request.Context()
+		ctx, cancel := context.WithTimeout(parent, d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		c.Request = c.Request.WithContext(parent)
+	}
+}
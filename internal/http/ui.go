@@ -0,0 +1,62 @@
+package http
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	gintrace "github.com/DataDog/dd-trace-go/contrib/gin-gonic/gin/v2"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/config"
+)
+
+//go:embed static/ui
+var staticFS embed.FS
+
+// uiAssets holds the front end's scripts and styles
+var uiAssets = func() http.FileSystem {
+	sub, err := fs.Sub(staticFS, "static/ui")
+	if err != nil {
+		panic(err)
+	}
+	return http.FS(sub)
+}()
+
+// UIHandler serves the single-page demo front end with Datadog RUM
+type UIHandler struct {
+	rum config.RUMConfig
+	dd  config.DatadogConfig
+}
+
+// NewUIHandler creates a UIHandler. RUM stays off unless rum names an
+// application.
+func NewUIHandler(rum config.RUMConfig, dd config.DatadogConfig) *UIHandler {
+	return &UIHandler{rum: rum, dd: dd}
+}
+
+// index renders the page shell with the RUM configuration inlined
+func (h *UIHandler) index(c *gin.Context) {
+	gintrace.HTML(c, http.StatusOK, "ui.tmpl", gin.H{
+		"RUM":       h.rum,
+		"CDNRegion": cdnRegion(h.rum.Site),
+		"Service":   h.dd.Service + "-frontend",
+		"Env":       h.dd.Env,
+		"Version":   h.dd.Version,
+	})
+}
+
+// cdnRegion returns the browser SDK CDN region serving a Datadog site
+func cdnRegion(site string) string {
+	switch {
+	case site == "datadoghq.eu":
+		return "eu1"
+	case site == "ddog-gov.com":
+		return "us1"
+	case strings.HasSuffix(site, ".datadoghq.com"):
+		return strings.TrimSuffix(site, ".datadoghq.com")
+	default:
+		return "us1"
+	}
+}
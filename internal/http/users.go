@@ -0,0 +1,462 @@
+// Package http exposes the services over HTTP using Gin.
+package http
+
+import (
+	"context"
+	"iter"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// UserService is the business logic the user handlers depend on
+type UserService interface {
+	Create(ctx context.Context, req model.CreateUserRequest) (*model.User, error)
+	List(ctx context.Context, filter model.UserFilter) ([]model.User, error)
+	Stream(ctx context.Context, filter model.UserFilter) iter.Seq2[model.User, error]
+	Query(ctx context.Context, filter model.UserFilter, count string) (*model.UserQueryResult, error)
+	LastModified(ctx context.Context) (time.Time, error)
+	Get(ctx context.Context, ref model.UserRef) (*model.User, error)
+	BatchGet(ctx context.Context, ids []string) (*model.BatchGetResult, error)
+	GetByUsername(ctx context.Context, username string) (*model.User, error)
+	Suggest(ctx context.Context, q string) ([]model.Suggestion, error)
+	Nearby(ctx context.Context, lat, lng, radiusMeters float64) ([]model.NearbyUser, error)
+	Update(ctx context.Context, ref model.UserRef, req model.UpdateUserRequest) (*model.User, error)
+	BulkUpdate(ctx context.Context, req model.BulkUpdateRequest) (*model.BulkUpdateResult, error)
+	Replace(ctx context.Context, ref model.UserRef, req model.ReplaceUserRequest) (*model.User, bool, error)
+	ApplyJSONPatch(ctx context.Context, ref model.UserRef, patch []byte) (*model.User, error)
+	ApplyMergePatch(ctx context.Context, ref model.UserRef, patch []byte) (*model.User, error)
+	AddTags(ctx context.Context, ref model.UserRef, tags []string) (*model.User, error)
+	RemoveTag(ctx context.Context, ref model.UserRef, tag string) (*model.User, error)
+	Erase(ctx context.Context, ref model.UserRef, actor string) (*model.ErasureReceipt, error)
+	Delete(ctx context.Context, ref model.UserRef) error
+}
+
+// UserHistory lists the current users along with their changes since a
+// past instant
+type UserHistory interface {
+	UsersChangedSince(ctx context.Context, filter model.UserFilter, since time.Time) (*model.UserHistory, error)
+}
+
+// UserHandler serves the user CRUD endpoints
+type UserHandler struct {
+	users   UserService
+	history UserHistory
+}
+
+// NewUserHandler creates a UserHandler backed by the given services; a nil
+// history rejects the lists asked with their changes
+func NewUserHandler(users UserService, history UserHistory) *UserHandler {
+	return &UserHandler{users: users, history: history}
+}
+
+// createUser creates a new user
+func (h *UserHandler) createUser(c *gin.Context) {
+	var req model.CreateUserRequest
+	if err := bindStep(c, "create", &req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.users.Create(ctx, req)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(201, user)
+}
+
+// bindStep binds and validates the JSON body into req under a
+// users.validate span, the first step of the create and update flame graphs
+func bindStep(c *gin.Context, useCase string, req any) error {
+	span, _ := tracer.StartSpanFromContext(c.Request.Context(), "users.validate", tracer.ResourceName(useCase))
+	err := c.ShouldBindJSON(req)
+	if err != nil {
+		err = bindError(err)
+	}
+	span.Finish(tracer.WithError(err))
+	return err
+}
+
+// listParams are the query parameters of getUsers that are not filters
+var listParams = queryNames(ListUsersParams{})
+
+// getUsers retrieves all users. The sort query parameter orders them,
+// limit and offset page through them and every other parameter filters
+// them, as in ?tag=beta&sort=-username&limit=20. A page followed by
+// another one gives the offset of the next in next_offset. With
+// stream=true the list is written as it is read instead, and with
+// changed_since it is marked with the changes since then.
+func (h *UserHandler) getUsers(c *gin.Context) {
+	filter, params, err := listFilter(c)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if !params.ChangedSince.IsZero() && params.Stream {
+		abortWithError(c, &model.ValidationError{Field: "changed_since", Reason: "cannot be streamed"})
+		return
+	}
+
+	// The route gives streams streamTimeout; pages get searchTimeout
+	ctx := c.Request.Context()
+	if !params.Stream {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, searchTimeout)
+		defer cancel()
+	}
+
+	if !params.ChangedSince.IsZero() {
+		h.usersChangedSince(ctx, c, filter, params.ChangedSince)
+		return
+	}
+
+	// Polling clients revalidate with If-Modified-Since; without a
+	// watermark the list is simply served
+	modified, err := h.users.LastModified(ctx)
+	if err != nil {
+		log.Printf("Serving users without Last-Modified: %v", err)
+	}
+	if notModified(c, modified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	if params.Stream {
+		h.streamUsers(ctx, c, filter)
+		return
+	}
+
+	users, err := h.users.List(ctx, filter)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, listPage(filter, users))
+}
+
+// usersChangedSince answers a list with its changes since a past instant:
+// the page of the users existing then, in their current state, with the
+// IDs of those of the page changed since and of every user deleted since.
+// The deletions keep changing the answer, so it is not revalidated.
+func (h *UserHandler) usersChangedSince(ctx context.Context, c *gin.Context, filter model.UserFilter, since time.Time) {
+	if h.history == nil {
+		abortWithError(c, &model.ValidationError{Field: "changed_since", Reason: "needs the activity feed, which is off without an event bus"})
+		return
+	}
+	history, err := h.history.UsersChangedSince(ctx, filter, since)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	body := listPage(filter, history.Users)
+	page := body["users"].([]model.User)
+	changed := slices.DeleteFunc(history.Changed, func(id string) bool {
+		return !slices.ContainsFunc(page, func(u model.User) bool { return u.PublicID == id })
+	})
+	body["changed_since"], body["changed"], body["removed"] = history.Since, changed, history.Removed
+	c.JSON(200, body)
+}
+
+// listFilter binds the parameters of a user list and reads its filters
+// from the other query parameters. One more user than the limit is asked
+// for, which tells whether there is a next page.
+func listFilter(c *gin.Context) (model.UserFilter, ListUsersParams, error) {
+	var params ListUsersParams
+	if err := bindQuery(c, &params); err != nil {
+		return model.UserFilter{}, params, err
+	}
+	filter := model.UserFilter{Sort: params.Sort, Offset: params.Offset, Filters: map[string]string{}}
+	for field, values := range c.Request.URL.Query() {
+		if !slices.Contains(listParams, field) {
+			filter.Filters[field] = values[0]
+		}
+	}
+	if params.Limit > 0 {
+		filter.Limit = params.Limit + 1
+	}
+	return filter, params, nil
+}
+
+// listPage is the body of a page of the users listed for filter
+func listPage(filter model.UserFilter, users []model.User) gin.H {
+	body := gin.H{}
+	if limit := filter.Limit - 1; filter.Limit > 0 && len(users) > limit {
+		users = users[:limit]
+		body["next_offset"] = filter.Offset + limit
+	}
+	body["users"], body["count"] = users, len(users)
+	return body
+}
+
+// getUserByID retrieves a user by ID
+func (h *UserHandler) getUserByID(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, err := h.users.Get(ctx, userRef(c))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+// usersAction dispatches the custom methods registered as
+// POST /users:<method>, e.g. /users:batchGet
+func (h *UserHandler) usersAction(c *gin.Context) {
+	switch c.Param("action") {
+	case ":batchGet":
+		h.batchGetUsers(c)
+	default:
+		abortWithError(c, errRouteNotFound)
+	}
+}
+
+// batchGetUsers retrieves many users by ID in a single query
+func (h *UserHandler) batchGetUsers(c *gin.Context) {
+	var req model.BatchGetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	result, err := h.users.BatchGet(ctx, req.IDs)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, result)
+}
+
+// getUserByUsername retrieves a user by username
+func (h *UserHandler) getUserByUsername(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, err := h.users.GetByUsername(ctx, c.Param("username"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+// suggestUsers returns typeahead matches for the q prefix
+func (h *UserHandler) suggestUsers(c *gin.Context) {
+	var params SuggestParams
+	if err := bindQuery(c, &params); err != nil {
+		abortWithError(c, err)
+		return
+	}
+	suggestions, err := h.users.Suggest(c.Request.Context(), params.Q)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, gin.H{"suggestions": suggestions})
+}
+
+// nearbyUsers returns the users close to the lat/lng query parameters
+func (h *UserHandler) nearbyUsers(c *gin.Context) {
+	var params NearbyParams
+	if err := bindQuery(c, &params); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	users, err := h.users.Nearby(ctx, *params.Lat, *params.Lng, params.Radius)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, gin.H{"users": users, "count": len(users)})
+}
+
+// updateUser updates a user by ID
+func (h *UserHandler) updateUser(c *gin.Context) {
+	var req model.UpdateUserRequest
+	if err := bindStep(c, "update", &req); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.users.Update(ctx, userRef(c), req)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+// replaceUser creates or replaces a user with the full request body,
+// answering 201 when the user was created under the requested ID
+func (h *UserHandler) replaceUser(c *gin.Context) {
+	var req model.ReplaceUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, created, err := h.users.Replace(ctx, userRef(c), req)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if created {
+		c.Header("Location", "/api/v1/users/"+user.PublicID)
+		c.JSON(201, user)
+		return
+	}
+	c.JSON(200, user)
+}
+
+// bulkUpdateUsers applies per-item updates to many users. Items that
+// cannot be applied are reported with the status they would have had as
+// single updates; the request itself succeeds.
+func (h *UserHandler) bulkUpdateUsers(c *gin.Context) {
+	var req model.BulkUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	result, err := h.users.BulkUpdate(ctx, req)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	for i := range result.Errors {
+		e := &result.Errors[i]
+		e.Status, e.Message = itemError(e.Err)
+	}
+	c.JSON(200, result)
+}
+
+// patchUser applies a partial update; the Content-Type selects between
+// RFC 6902 JSON Patch, RFC 7386 merge patch and plain JSON field updates
+func (h *UserHandler) patchUser(c *gin.Context) {
+	switch c.ContentType() {
+	case jsonPatchContentType:
+		h.jsonPatchUser(c)
+	case mergePatchContentType:
+		h.mergePatchUser(c)
+	case gin.MIMEJSON:
+		h.updateUser(c)
+	default:
+		abortWithError(c, errUnsupportedMediaType)
+	}
+}
+
+// jsonPatchUser applies an RFC 6902 JSON Patch document to a user
+func (h *UserHandler) jsonPatchUser(c *gin.Context) {
+	h.applyPatch(c, h.users.ApplyJSONPatch)
+}
+
+// mergePatchUser applies an RFC 7386 JSON Merge Patch document to a user
+func (h *UserHandler) mergePatchUser(c *gin.Context) {
+	h.applyPatch(c, h.users.ApplyMergePatch)
+}
+
+// applyPatch reads the raw patch document and applies it with apply
+func (h *UserHandler) applyPatch(c *gin.Context, apply func(context.Context, model.UserRef, []byte) (*model.User, error)) {
+	patch, err := c.GetRawData()
+	if err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := apply(ctx, userRef(c), patch)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+// addUserTags adds the tags of the request body to a user
+func (h *UserHandler) addUserTags(c *gin.Context) {
+	var req model.TagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindError(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	user, err := h.users.AddTags(ctx, userRef(c), req.Tags)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+// removeUserTag removes the tag named in the path from a user
+func (h *UserHandler) removeUserTag(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, err := h.users.RemoveTag(ctx, userRef(c), c.Param("tag"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+// eraseUser anonymizes the personal data of a user and returns the
+// erasure receipt
+func (h *UserHandler) eraseUser(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	receipt, err := h.users.Erase(ctx, userRef(c), principal(c).Name)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, receipt)
+}
+
+// deleteUser deletes a user by ID
+func (h *UserHandler) deleteUser(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if err := h.users.Delete(ctx, userRef(c)); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "User deleted successfully"})
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+// testContext returns a Gin context for req, answered into a recorder
+func testContext(req *http.Request) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = req
+	return c
+}
+
+func FuzzCreateUserBody(f *testing.F) {
+	f.Add([]byte(`{"name": "Alice", "email": "alice@example.com", "age": 30}`))
+	f.Add([]byte(`{"name": "Alice", "email": "alice@example.com", "age": 30, "location": {"lat": 48.85, "lng": 2.35}}`))
+	f.Add([]byte(`{"name": "Alice", "email": "alice@example.com", "age": 151}`))
+	f.Add([]byte(`{"name": {"$ne": ""}, "email": "alice@example.com", "age": 30}`))
+	f.Add([]byte(`{"name": "Alice", "email": {"$gt": ""}, "age": 30}`))
+	f.Add([]byte(`{"name": "Alice", "email": "alice@example.com", "age": "30"}`))
+	f.Add([]byte(`[{"name": "Alice"}]`))
+	f.Add([]byte(`{"name": "Alice"`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		c := testContext(httptest.NewRequest(http.MethodPost, "/users", bytes.NewReader(body)))
+		var req model.CreateUserRequest
+		err := bindStep(c, "create", &req)
+		if err != nil {
+			if !errors.Is(err, model.ErrValidation) {
+				t.Fatalf("body %q failed with %v, want a validation error", body, err)
+			}
+			return
+		}
+		if req.Name == "" || req.Age < 1 || req.Age > 150 {
+			t.Fatalf("body %q binds to %+v, which does not pass the binding rules", body, req)
+		}
+	})
+}
+
+func FuzzListFilter(f *testing.F) {
+	f.Add("tag=beta&sort=-name&limit=10")
+	f.Add("offset=20&limit=0")
+	f.Add("limit=-1")
+	f.Add("limit=abc&offset=1e9")
+	f.Add("changed_since=2026-01-02T15:04:05Z&stream=true")
+	f.Add("name[$ne]=&age[$gt]=1")
+	f.Add("$where=1&tag=a&tag=b")
+	f.Add("%zz=1")
+
+	f.Fuzz(func(t *testing.T, query string) {
+		// The request is built by hand: httptest.NewRequest panics on some
+		// query strings a client can still send
+		req := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/users", RawQuery: query}, Header: http.Header{}}
+		filter, params, err := listFilter(testContext(req))
+		if err != nil {
+			if !errors.Is(err, model.ErrValidation) {
+				t.Fatalf("query %q failed with %v, want a validation error", query, err)
+			}
+			return
+		}
+		for field := range filter.Filters {
+			if slices.Contains(listParams, field) {
+				t.Fatalf("query %q filters on the list parameter %q", query, field)
+			}
+		}
+		if params.Limit > 0 && filter.Limit != params.Limit+1 || params.Limit <= 0 && filter.Limit != 0 {
+			t.Fatalf("query %q asks for %d users with limit %d", query, filter.Limit, params.Limit)
+		}
+		if filter.Offset < 0 || filter.Sort != params.Sort {
+			t.Fatalf("query %q lists %+v from params %+v", query, filter, params)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+
+	gintrace "github.com/DataDog/dd-trace-go/contrib/gin-gonic/gin/v2"
+	"github.com/gin-gonic/gin"
+
+	"datadog-golang-example/internal/model"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// templates are the HTML views, parsed once at startup
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.tmpl"))
+
+// ViewHandler serves the server-rendered HTML pages
+type ViewHandler struct {
+	users UserService
+}
+
+// NewViewHandler creates a ViewHandler on top of the user service
+func NewViewHandler(users UserService) *ViewHandler {
+	return &ViewHandler{users: users}
+}
+
+// listUsers renders the user list
+func (h *ViewHandler) listUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	users, err := h.users.List(ctx, model.UserFilter{})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	gintrace.HTML(c, http.StatusOK, "users.tmpl", gin.H{"Users": users})
+}
+
+// showUser renders a single user
+func (h *ViewHandler) showUser(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, err := h.users.Get(ctx, userRef(c))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	gintrace.HTML(c, http.StatusOK, "user.tmpl", gin.H{"User": user})
+}
+
+// HTMLErrors renders errors of HTML routes as an error page instead of a
+// problem document. It must run inside ErrorHandler.
+func HTMLErrors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		p := newProblem(c, c.Errors.Last().Err)
+		gintrace.HTML(c, p.Status, "error.tmpl", p)
+	}
+}
@@ -0,0 +1,16 @@
+// Package httpclient provides the traced HTTP client used for outbound
+// calls, so downstream requests appear as child spans and carry trace
+// propagation headers.
+package httpclient
+
+import (
+	"net/http"
+	"time"
+
+	httptrace "github.com/DataDog/dd-trace-go/contrib/net/http/v2"
+)
+
+// New creates a traced HTTP client with the given overall request timeout
+func New(timeout time.Duration) *http.Client {
+	return httptrace.WrapClient(&http.Client{Timeout: timeout})
+}
@@ -0,0 +1,124 @@
+// Package ids generates the public IDs of new users, organizations and
+// attachments. The strategy is chosen by configuration to show the
+// trade-offs between ID formats:
+//
+//   - objectid: 12 bytes, 24 hex characters, ordered by second
+//   - uuidv7: 16 bytes, 36 characters, ordered by millisecond
+//   - snowflake: 8 bytes, up to 19 digits, strictly ordered per node
+//
+// Every format stays accepted in requests whatever the strategy, so it
+// can change without breaking existing IDs.
+package ids
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"datadog-golang-example/internal/config"
+)
+
+// Strategies of ID generation
+const (
+	ObjectID  = "objectid"
+	UUIDv7    = "uuidv7"
+	Snowflake = "snowflake"
+)
+
+// Generator generates unique public IDs
+type Generator interface {
+	NewID() (string, error)
+}
+
+// New returns the generator of the strategy selected by cfg
+func New(cfg config.IDConfig) (Generator, error) {
+	switch cfg.Strategy {
+	case ObjectID:
+		return objectIDs{}, nil
+	case UUIDv7, "":
+		return uuids{}, nil
+	case Snowflake:
+		return NewSnowflakes(cfg.Node)
+	default:
+		return nil, fmt.Errorf("unknown ID strategy %q: expected objectid, uuidv7 or snowflake", cfg.Strategy)
+	}
+}
+
+// objectIDs generates MongoDB ObjectIDs
+type objectIDs struct{}
+
+// NewID returns the hex form of a new ObjectID
+func (objectIDs) NewID() (string, error) {
+	return primitive.NewObjectID().Hex(), nil
+}
+
+// uuids generates time-ordered UUIDs
+type uuids struct{}
+
+// NewID returns a new UUIDv7
+func (uuids) NewID() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// Layout of a Snowflake ID, after its unused sign bit
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+	maxNode      = 1<<nodeBits - 1
+	maxSequence  = 1<<sequenceBits - 1
+)
+
+// snowflakeEpoch is the zero of the Snowflake timestamps, which leaves
+// them room for about 69 years
+var snowflakeEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Snowflakes generates 64-bit IDs made of a millisecond timestamp, the
+// node number and a sequence within the millisecond. IDs are unique as
+// long as every instance has its own node number.
+type Snowflakes struct {
+	node int64
+
+	mu       sync.Mutex
+	last     int64
+	sequence int64
+}
+
+// NewSnowflakes creates the Snowflake generator of node, from 0 to 1023
+func NewSnowflakes(node int) (*Snowflakes, error) {
+	if node < 0 || node > maxNode {
+		return nil, fmt.Errorf("snowflake node must be between 0 and %d, got %d", maxNode, node)
+	}
+	return &Snowflakes{node: int64(node)}, nil
+}
+
+// NewID returns the next Snowflake ID in decimal. Once the sequence of a
+// millisecond is exhausted it waits for the next one, and a clock moving
+// backwards keeps the last timestamp so IDs never go back.
+func (s *Snowflakes) NewID() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := max(time.Since(snowflakeEpoch).Milliseconds(), s.last)
+	if now == s.last {
+		s.sequence = (s.sequence + 1) & maxSequence
+		if s.sequence == 0 {
+			for now <= s.last {
+				time.Sleep(time.Millisecond / 10)
+				now = time.Since(snowflakeEpoch).Milliseconds()
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.last = now
+	id := now<<(nodeBits+sequenceBits) | s.node<<sequenceBits | s.sequence
+	return strconv.FormatInt(id, 10), nil
+}
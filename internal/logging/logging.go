@@ -0,0 +1,149 @@
+// Package logging configures the structured JSON logger. Messages written
+// with the standard log package go through it too, at the info level.
+package logging
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"datadog-golang-example/internal/config"
+)
+
+// level is the minimum level of the default logger, changeable at runtime
+var level slog.LevelVar
+
+// audit records configuration changes whatever the level
+var audit = slog.New(slog.NewJSONHandler(os.Stderr, nil)).With("audit", true)
+
+// Setup installs the JSON logger as the default for slog and log
+func Setup(cfg config.LogConfig) {
+	l, err := ParseLevel(cfg.Level)
+	if err != nil {
+		log.Printf("Invalid LOG_LEVEL=%q, using info", cfg.Level)
+	}
+	level.Set(l)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: &level})))
+}
+
+// ParseLevel parses debug, info, warn or error, case-insensitively
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
+// Level returns the current minimum level
+func Level() slog.Level {
+	return level.Level()
+}
+
+// override is a temporary level that reverts to base when timer fires
+var override struct {
+	mu       sync.Mutex
+	timer    *time.Timer
+	base     slog.Level
+	revertAt time.Time
+}
+
+// SetLevel changes the minimum level of every logger, cancelling any
+// pending revert
+func SetLevel(l slog.Level) {
+	override.mu.Lock()
+	defer override.mu.Unlock()
+	stopRevert()
+	level.Set(l)
+}
+
+// SetLevelFor changes the minimum level for d, after which the level in
+// force before the first temporary change is restored. It returns that
+// level.
+func SetLevelFor(l slog.Level, d time.Duration) slog.Level {
+	override.mu.Lock()
+	defer override.mu.Unlock()
+	if override.timer == nil {
+		override.base = level.Level()
+	}
+	stopRevert()
+	level.Set(l)
+	override.revertAt = time.Now().Add(d)
+	override.timer = time.AfterFunc(d, revert)
+	return override.base
+}
+
+// Revert returns the level a temporary change reverts to and when, if one
+// is pending
+func Revert() (slog.Level, time.Time, bool) {
+	override.mu.Lock()
+	defer override.mu.Unlock()
+	return override.base, override.revertAt, override.timer != nil
+}
+
+// revert restores the base level once a temporary change expires
+func revert() {
+	override.mu.Lock()
+	defer override.mu.Unlock()
+	if override.timer == nil || time.Now().Before(override.revertAt) {
+		// Cancelled or re-armed after this timer fired
+		return
+	}
+	override.timer = nil
+	old := level.Level()
+	level.Set(override.base)
+	Audit("Reverted log level", "setting", "log_level", "old", old.String(), "new", override.base.String())
+}
+
+// stopRevert cancels the pending revert; override.mu must be held
+func stopRevert() {
+	if override.timer != nil {
+		override.timer.Stop()
+		override.timer = nil
+	}
+}
+
+// auditAppendTimeout bounds the write of an audit entry to its sink
+const auditAppendTimeout = 5 * time.Second
+
+// AuditSink stores the audit entries besides the log, such as a signed
+// trail
+type AuditSink interface {
+	Append(ctx context.Context, msg string, attrs map[string]string) error
+}
+
+// sink is the AuditSink installed by SetAuditSink, if any
+var sink atomic.Pointer[AuditSink]
+
+// SetAuditSink makes Audit append its entries to s too; nil stops it
+func SetAuditSink(s AuditSink) {
+	if s == nil {
+		sink.Store(nil)
+		return
+	}
+	sink.Store(&s)
+}
+
+// Audit logs an audit entry; the level never filters it out. The entry is
+// appended to the audit sink too, a failure being logged.
+func Audit(msg string, args ...any) {
+	audit.Info(msg, args...)
+	s := sink.Load()
+	if s == nil {
+		return
+	}
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0)
+	r.Add(args...)
+	attrs := make(map[string]string, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs[a.Key] = a.Value.Resolve().String()
+		return true
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), auditAppendTimeout)
+	defer cancel()
+	if err := (*s).Append(ctx, msg, attrs); err != nil {
+		slog.Error("Could not append to the audit trail", "audit_message", msg, "error", err)
+	}
+}
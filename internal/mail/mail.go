@@ -0,0 +1,67 @@
+// Package mail sends transactional emails.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer logs emails instead of sending them, for local runs without
+// an SMTP server
+type LogMailer struct{}
+
+// Send logs the message
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("Email to %s: %s", msg.To, msg.Subject)
+	return nil
+}
+
+// retryMailer retries failed sends with exponential backoff
+type retryMailer struct {
+	next     Mailer
+	attempts int
+	backoff  time.Duration
+}
+
+// Retry wraps m so a failed send is attempted up to attempts times,
+// doubling the wait from backoff between attempts
+func Retry(m Mailer, attempts int, backoff time.Duration) Mailer {
+	return &retryMailer{next: m, attempts: max(attempts, 1), backoff: backoff}
+}
+
+// Send sends msg, retrying until it succeeds, the attempts run out or ctx
+// is done
+func (r *retryMailer) Send(ctx context.Context, msg Message) error {
+	wait := r.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = r.next.Send(ctx, msg); err == nil || attempt == r.attempts {
+			break
+		}
+		log.Printf("Email %q failed (attempt %d/%d), retrying in %s: %v", msg.Subject, attempt, r.attempts, wait, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("send email: %w", ctx.Err())
+		}
+		wait *= 2
+	}
+	if err != nil {
+		return fmt.Errorf("send email after %d attempts: %w", r.attempts, err)
+	}
+	return nil
+}
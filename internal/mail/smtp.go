@@ -0,0 +1,100 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/config"
+)
+
+// SMTPMailer sends emails through an SMTP relay
+type SMTPMailer struct {
+	cfg  config.MailConfig
+	host string
+	from string
+}
+
+// NewSMTPMailer creates a mailer for the relay at cfg.SMTPAddr
+func NewSMTPMailer(cfg config.MailConfig) (*SMTPMailer, error) {
+	host, _, err := net.SplitHostPort(cfg.SMTPAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_ADDR %q: %w", cfg.SMTPAddr, err)
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM %q: %w", cfg.From, err)
+	}
+	return &SMTPMailer{cfg: cfg, host: host, from: from.Address}, nil
+}
+
+// Send delivers msg in a single SMTP session traced as one span. The
+// recipient is not tagged to keep email addresses out of traces.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) (err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "smtp.send",
+		tracer.ResourceName("SEND"),
+		tracer.SpanType("smtp"),
+		tracer.Tag("out.host", m.host),
+	)
+	defer func() { span.Finish(tracer.WithError(err)) }()
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", m.cfg.SMTPAddr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.format(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// format renders msg as an RFC 5322 message
+func (m *SMTPMailer) format(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
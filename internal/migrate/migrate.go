@@ -0,0 +1,171 @@
+// Package migrate applies versioned data migrations to MongoDB and
+// records which ones ran in the schema_migrations collection.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrIrreversible is returned when rolling back a migration without Down
+var ErrIrreversible = errors.New("migrate: migration is irreversible")
+
+// Migration is one versioned change. Versions must be unique and
+// increasing; Down may be nil when the change cannot be undone.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// Status is the state of one migration
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// record is the schema_migrations document of an applied migration
+type record struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// Migrator runs migrations against a database
+type Migrator struct {
+	db         *mongo.Database
+	coll       *mongo.Collection
+	migrations []Migration
+}
+
+// New creates a Migrator for migrations, which must be sorted by version
+func New(db *mongo.Database, migrations []Migration) *Migrator {
+	return &Migrator{db: db, coll: db.Collection("schema_migrations"), migrations: migrations}
+}
+
+// Status lists every migration with the time it was applied, if it was
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if r, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &r.AppliedAt
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in version order and returns the
+// ones it applied. It stops at the first failure.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if err := m.run(ctx, "migrate.up", mig, mig.Up); err != nil {
+			return done, err
+		}
+		r := record{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}
+		if _, err := m.coll.InsertOne(ctx, r); err != nil {
+			return done, fmt.Errorf("record migration %d: %w", mig.Version, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and
+// returns the ones it rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == nil {
+			return done, fmt.Errorf("roll back %d %s: %w", mig.Version, mig.Name, ErrIrreversible)
+		}
+		if err := m.run(ctx, "migrate.down", mig, mig.Down); err != nil {
+			return done, err
+		}
+		if _, err := m.coll.DeleteOne(ctx, bson.M{"_id": mig.Version}); err != nil {
+			return done, fmt.Errorf("unrecord migration %d: %w", mig.Version, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// Check fails when migrations are pending, for use as a preflight check
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%d %s", s.Version, s.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// run executes one direction of a migration in its own span
+func (m *Migrator) run(ctx context.Context, op string, mig Migration, fn func(context.Context, *mongo.Database) error) error {
+	span, ctx := tracer.StartSpanFromContext(ctx, op, tracer.ResourceName(mig.Name), tracer.Tag("migration.version", mig.Version))
+	start := time.Now()
+	err := fn(ctx, m.db)
+	span.Finish(tracer.WithError(err))
+	if err != nil {
+		return fmt.Errorf("%s %d %s: %w", op, mig.Version, mig.Name, err)
+	}
+	log.Printf("Ran %s %d %s in %s", op, mig.Version, mig.Name, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// applied returns the applied migrations keyed by version
+func (m *Migrator) applied(ctx context.Context) (map[int]record, error) {
+	cursor, err := m.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("list applied migrations: %w", err)
+	}
+	var records []record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("list applied migrations: %w", err)
+	}
+	applied := make(map[int]record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"datadog-golang-example/internal/model"
+)
+
+// Migrations are the data migrations of the users collection, oldest
+// first. They backfill fields added after the first release, so they are
+// irreversible.
+var Migrations = []Migration{
+	{Version: 1, Name: "backfill_public_ids", Up: backfillPublicIDs},
+	{Version: 2, Name: "backfill_name_keys", Up: backfillNameKeys},
+	{Version: 3, Name: "backfill_versions", Up: backfillVersions},
+}
+
+// backfillPublicIDs gives a UUID to the users created before public IDs
+func backfillPublicIDs(ctx context.Context, db *mongo.Database) error {
+	return eachUser(ctx, db, bson.M{"public_id": bson.M{"$exists": false}}, func(u model.User) (bson.M, error) {
+		id, err := model.NewPublicID()
+		return bson.M{"public_id": id}, err
+	})
+}
+
+// backfillNameKeys sets the folded name used by suggestions
+func backfillNameKeys(ctx context.Context, db *mongo.Database) error {
+	return eachUser(ctx, db, bson.M{"name_key": bson.M{"$exists": false}}, func(u model.User) (bson.M, error) {
+		return bson.M{"name_key": model.FoldName(u.Name)}, nil
+	})
+}
+
+// backfillVersions starts optimistic concurrency at version 1
+func backfillVersions(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("users").UpdateMany(ctx,
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": 1}},
+	)
+	return err
+}
+
+// eachUser applies the $set computed by set to every user matching filter
+func eachUser(ctx context.Context, db *mongo.Database, filter bson.M, set func(model.User) (bson.M, error)) error {
+	coll := db.Collection("users")
+	cursor, err := coll.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var u model.User
+		if err := cursor.Decode(&u); err != nil {
+			return err
+		}
+		fields, err := set(u)
+		if err != nil {
+			return err
+		}
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": u.ID}, bson.M{"$set": fields}); err != nil {
+			return fmt.Errorf("update user %s: %w", u.ID.Hex(), err)
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+// Activity is a mutation of the activity feed, recorded from the event
+// published for it. Its ID is the ID of the event, so an event delivered
+// twice is recorded once.
+type Activity struct {
+	ID       string    `json:"id" bson:"_id"`
+	Type     string    `json:"type" bson:"type"`
+	Resource string    `json:"resource" bson:"resource"`
+	UserID   string    `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	At       time.Time `json:"at" bson:"at"`
+}
+
+// ActivityFilter narrows the activity feed, newest first
+type ActivityFilter struct {
+	// Type is an event type such as "user.created", or a resource such as
+	// "user" for all of its events
+	Type   string
+	UserID string
+	// Since and Before bound the time of the mutations; zero is open
+	Since  time.Time
+	Before time.Time
+	Limit  int
+	Offset int
+}
+
+// ActivityFeed is a page of the activity feed. Counts holds the number of
+// mutations of each type matching the filter, across every page.
+type ActivityFeed struct {
+	Items  []Activity     `json:"items" bson:"items"`
+	Counts map[string]int `json:"counts" bson:"-"`
+}
+
+// UserChange sums up the events of a user over a period by the types of
+// its first and last ones
+type UserChange struct {
+	UserID string `bson:"_id"`
+	First  string `bson:"first"`
+	Last   string `bson:"last"`
+}
+
+// UserHistory is a page of the current users created before an instant,
+// marked with the events published since. It is not the users as they
+// were then: the events do not record the state they changed.
+type UserHistory struct {
+	Since time.Time
+	// Users existed at Since and still do, in their current state;
+	// Changed are the IDs of those changed since
+	Users   []User
+	Changed []string
+	// Removed are the IDs of the users existing at Since and deleted
+	// since, whatever the filters
+	Removed []string
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// Attachment is a file of a user kept in the object store under Key.
+// Its bytes are transferred through presigned URLs.
+type Attachment struct {
+	ID          string    `json:"id" bson:"_id"`
+	UserID      string    `json:"user_id" bson:"user_id"`
+	Name        string    `json:"name" bson:"name"`
+	ContentType string    `json:"content_type" bson:"content_type"`
+	Key         string    `json:"-" bson:"key"`
+	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
+}
+
+// CreateAttachmentRequest represents the body of POST
+// /users/:id/attachments
+type CreateAttachmentRequest struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	ContentType string `json:"content_type" binding:"required,max=100"`
+}
+
+// AttachmentKey returns the object key of an attachment. The keys of a
+// user share the prefix of AttachmentPrefix.
+func AttachmentKey(userID, id string) string {
+	return AttachmentPrefix(userID) + id
+}
+
+// AttachmentPrefix returns the prefix of the object keys of a user
+func AttachmentPrefix(userID string) string {
+	return "users/" + userID + "/attachments/"
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// AuditEntry is an entry of the signed audit trail. Hash signs the entry
+// together with PrevHash, the hash of the entry before it, so changing,
+// reordering or removing an entry breaks the chain from there on.
+type AuditEntry struct {
+	Seq      int64             `json:"seq" bson:"_id"`
+	At       time.Time         `json:"at" bson:"at"`
+	Message  string            `json:"message" bson:"message"`
+	Attrs    map[string]string `json:"attrs,omitempty" bson:"attrs,omitempty"`
+	PrevHash string            `json:"prev_hash" bson:"prev_hash"`
+	Hash     string            `json:"hash" bson:"hash"`
+}
+
+// AuditReport is the outcome of verifying the audit trail. LastHash
+// anchors the verified chain: recorded elsewhere, it also reveals entries
+// removed from the end.
+type AuditReport struct {
+	Entries int64 `json:"entries"`
+	Intact  bool  `json:"intact"`
+	// BrokenAt is the sequence number of the first entry failing
+	// verification, and Reason why it fails
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	LastHash string `json:"last_hash,omitempty"`
+}
@@ -0,0 +1,38 @@
+package model
+
+// BulkUpdateRequest represents the request body of a bulk update of at
+// most 100 users
+type BulkUpdateRequest struct {
+	Items []BulkUpdateItem `json:"items" binding:"required,min=1,max=100,dive"`
+}
+
+// BulkUpdateItem is the update of one user in a bulk update
+type BulkUpdateItem struct {
+	ID string `json:"id" binding:"required"`
+	UpdateUserRequest
+}
+
+// BulkUpdate is an update addressed to one user of a bulk write
+type BulkUpdate struct {
+	Index  int
+	Ref    UserRef
+	Update UserUpdate
+}
+
+// BulkItemError reports why one item of a bulk update was not applied.
+// Status and Message are derived from Err when rendering.
+type BulkItemError struct {
+	Index   int    `json:"index"`
+	ID      string `json:"id"`
+	Status  int    `json:"status"`
+	Message string `json:"error"`
+	Err     error  `json:"-"`
+}
+
+// BulkUpdateResult summarizes a bulk update. Items not listed in Errors
+// were applied.
+type BulkUpdateResult struct {
+	Matched  int64           `json:"matched"`
+	Modified int64           `json:"modified"`
+	Errors   []BulkItemError `json:"errors"`
+}
@@ -0,0 +1,82 @@
+package model
+
+import "time"
+
+// Diagnostics describes how a running instance is wired, so a demo
+// environment can be checked in one place before presenting it. A part
+// that could not be read holds the error instead of failing the rest.
+type Diagnostics struct {
+	Service   string    `json:"service"`
+	Env       string    `json:"env"`
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"started_at"`
+	// Build holds the Go version and the versions of the main modules
+	Build map[string]string `json:"build"`
+	// Features tells which optional parts of the service are enabled
+	Features     map[string]bool `json:"features"`
+	FeatureFlags map[string]bool `json:"feature_flags"`
+	Tracer       TracerStatus    `json:"tracer"`
+	Profiler     ProfilerStatus  `json:"profiler"`
+	MongoDB      MongoStatus     `json:"mongodb"`
+	Indexes      IndexStatus     `json:"indexes"`
+	Migrations   MigrationStatus `json:"migrations"`
+}
+
+// TracerStatus is the state of the Datadog tracer
+type TracerStatus struct {
+	// Started is false when the tracer fell back to no-op spans
+	Started    bool    `json:"started"`
+	AgentURL   string  `json:"agent_url"`
+	AgentError string  `json:"agent_error,omitempty"`
+	DevExport  string  `json:"dev_export,omitempty"`
+	SampleRate float64 `json:"sample_rate"`
+	// RemoteSampleRate is the sample rate set from the Datadog UI, which
+	// replaces SampleRate while set
+	RemoteSampleRate *float64 `json:"remote_sample_rate,omitempty"`
+	// Inject and Extract are the propagation styles of outgoing and
+	// incoming trace headers
+	Inject  string `json:"propagation_inject"`
+	Extract string `json:"propagation_extract"`
+}
+
+// ProfilerStatus is the state of the Datadog profiler
+type ProfilerStatus struct {
+	Running bool   `json:"running"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// MongoStatus describes the MongoDB deployment the users are stored in
+type MongoStatus struct {
+	Database       string   `json:"database"`
+	ReadPreference string   `json:"read_preference"`
+	Deployment     string   `json:"deployment,omitempty"`
+	ReplicaSet     string   `json:"replica_set,omitempty"`
+	Primary        string   `json:"primary,omitempty"`
+	Hosts          []string `json:"hosts,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// IndexStatus compares the indexes of the database with the ones the
+// service requires, as collection.name
+type IndexStatus struct {
+	InSync      bool     `json:"in_sync"`
+	Missing     []string `json:"missing,omitempty"`
+	Conflicting []string `json:"conflicting,omitempty"`
+	Unexpected  []string `json:"unexpected,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// MigrationStatus tells which data migrations ran
+type MigrationStatus struct {
+	// Last is the latest migration applied, nil when none was
+	Last    *AppliedMigration `json:"last"`
+	Pending []string          `json:"pending,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// AppliedMigration is a data migration that ran
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// OrgSignups counts the users created in an organization over a period.
+// Users outside any organization are counted with an empty OrgID.
+type OrgSignups struct {
+	OrgID string `json:"org_id" bson:"org_id"`
+	Name  string `json:"name" bson:"name"`
+	Users int64  `json:"users" bson:"users"`
+}
+
+// Digest summarizes the users created from Since until Until, by
+// organization, the largest first
+type Digest struct {
+	Since time.Time    `json:"since"`
+	Until time.Time    `json:"until"`
+	Orgs  []OrgSignups `json:"orgs"`
+	Total int64        `json:"total"`
+}
@@ -0,0 +1,16 @@
+package model
+
+import "context"
+
+type dryRunKey struct{}
+
+// WithDryRun marks ctx so that writes are validated but not performed
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun reports whether ctx was marked with WithDryRun
+func IsDryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
+}
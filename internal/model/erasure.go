@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Erasure records that the personal data of a user was erased. It keeps
+// no personal data itself.
+type Erasure struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	UserID   string             `json:"user_id" bson:"user_id"`
+	Actor    string             `json:"actor" bson:"actor"`
+	Fields   []string           `json:"fields" bson:"fields"`
+	ErasedAt time.Time          `json:"erased_at" bson:"erased_at"`
+	// Digest is an HMAC-SHA256 of the erased values keyed with the salt of
+	// the receipt, which is not stored
+	Digest string `json:"digest" bson:"digest"`
+}
+
+// ErasureReceipt is returned to the caller of an erasure. Whoever holds
+// it can prove which values were erased by recomputing the digest, while
+// the stored record alone reveals nothing about them.
+type ErasureReceipt struct {
+	Erasure
+	Salt string `json:"salt"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Domain errors returned by the repository and service layers. Callers
+// test for them with errors.Is; the HTTP layer maps each one to a status.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnavailable  = errors.New("service unavailable")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrRateLimited  = errors.New("rate limit exceeded")
+	// ErrElection is an ErrUnavailable returned while a replica set elects
+	// a primary, which clients should retry shortly
+	ErrElection = fmt.Errorf("primary election in progress: %w", ErrUnavailable)
+)
+
+// ValidationError reports an invalid input field
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return e.Field + ": " + e.Reason
+}
+
+// Is makes a ValidationError match ErrValidation
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// ConflictError reports a uniqueness violation on a field
+type ConflictError struct {
+	Field string
+}
+
+// Error implements the error interface
+func (e *ConflictError) Error() string {
+	return e.Field + " already exists"
+}
+
+// Is makes a ConflictError match ErrConflict
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Statuses of an export job
+const (
+	ExportPending   = "pending"
+	ExportRunning   = "running"
+	ExportSucceeded = "succeeded"
+	ExportFailed    = "failed"
+)
+
+// ExportRequest represents the body of POST /admin/exports. Filters and
+// OrgID select the users as for a user list; all users when empty.
+type ExportRequest struct {
+	Filters map[string]string `json:"filters"`
+	OrgID   string            `json:"org_id"`
+}
+
+// ExportJob is a background export of users as NDJSON. Exported counts
+// the users written so far out of Total, counted when the job starts.
+type ExportJob struct {
+	ID         string            `json:"id"`
+	Status     string            `json:"status"`
+	Filters    map[string]string `json:"filters,omitempty"`
+	OrgID      string            `json:"org_id,omitempty"`
+	Exported   int64             `json:"exported"`
+	Total      int64             `json:"total"`
+	Location   string            `json:"location,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
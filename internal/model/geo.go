@@ -0,0 +1,42 @@
+package model
+
+// GeoPoint is a GeoJSON point as stored in MongoDB. Coordinates are
+// [longitude, latitude], in that order.
+type GeoPoint struct {
+	Type        string    `json:"type" bson:"type"`
+	Coordinates []float64 `json:"coordinates" bson:"coordinates"`
+}
+
+// NewGeoPoint creates a GeoJSON point from a latitude and longitude
+func NewGeoPoint(lat, lng float64) *GeoPoint {
+	return &GeoPoint{Type: "Point", Coordinates: []float64{lng, lat}}
+}
+
+// Request converts the point back into the client-facing representation
+func (p *GeoPoint) Request() *LocationRequest {
+	if p == nil || len(p.Coordinates) != 2 {
+		return nil
+	}
+	return &LocationRequest{Lat: p.Coordinates[1], Lng: p.Coordinates[0]}
+}
+
+// LocationRequest is a location supplied by API clients
+type LocationRequest struct {
+	Lat float64 `json:"lat" binding:"gte=-90,lte=90"`
+	Lng float64 `json:"lng" binding:"gte=-180,lte=180"`
+}
+
+// Point converts the request into a GeoJSON point
+func (l *LocationRequest) Point() *GeoPoint {
+	if l == nil {
+		return nil
+	}
+	return NewGeoPoint(l.Lat, l.Lng)
+}
+
+// NearbyUser is a user returned by a proximity query along with its
+// distance from the query point
+type NearbyUser struct {
+	User           `bson:",inline"`
+	DistanceMeters float64 `json:"distance_m" bson:"distance_m"`
+}
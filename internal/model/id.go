@@ -0,0 +1,59 @@
+package model
+
+import (
+	"strconv"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserRef identifies a user either by its internal ObjectID or by its
+// public ID. Exactly one of the fields is set.
+type UserRef struct {
+	ObjectID primitive.ObjectID
+	PublicID string
+}
+
+// ParseUserRef accepts a 24-character hex ObjectID, a UUID or a Snowflake
+// ID. Users created with ObjectID public IDs share them with their
+// internal ID, so the ObjectID reference finds them.
+func ParseUserRef(s string) (UserRef, error) {
+	if id, err := primitive.ObjectIDFromHex(s); err == nil {
+		return UserRef{ObjectID: id}, nil
+	}
+	id, ok := parsePublicID(s)
+	if !ok {
+		return UserRef{}, &ValidationError{Field: "id", Reason: "must be an ObjectID, a UUID or a Snowflake ID"}
+	}
+	return UserRef{PublicID: id}, nil
+}
+
+// parsePublicID returns the canonical form of a UUID or of a Snowflake
+// ID, the decimal form of a positive 63-bit integer
+func parsePublicID(s string) (string, bool) {
+	if id, err := uuid.Parse(s); err == nil {
+		return id.String(), true
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 && strconv.FormatInt(n, 10) == s {
+		return s, true
+	}
+	return "", false
+}
+
+// String returns the identifier as it appeared in the request
+func (r UserRef) String() string {
+	if r.PublicID != "" {
+		return r.PublicID
+	}
+	return r.ObjectID.Hex()
+}
+
+// NewPublicID generates a time-ordered UUIDv7, for the IDs that do not
+// follow the configured strategy such as those of events
+func NewPublicID() (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func FuzzParseUserRef(f *testing.F) {
+	f.Add("0192a8e2-7b3c-7def-8000-0123456789ab")
+	f.Add("{0192A8E2-7B3C-7DEF-8000-0123456789AB}")
+	f.Add("urn:uuid:0192a8e2-7b3c-7def-8000-0123456789ab")
+	f.Add("65f1c0ffee0000000000abcd")
+	f.Add("7212345678901234567")
+	f.Add("007")
+	f.Add("-1")
+	f.Add("9223372036854775808")
+	f.Add(`{"$ne": null}`)
+	f.Add("$where")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		ref, err := ParseUserRef(s)
+		orgID, orgErr := ParseOrgID(s)
+		if err != nil {
+			var invalid *ValidationError
+			if !errors.As(err, &invalid) || orgErr == nil {
+				t.Fatalf("ParseUserRef(%q) = %v, ParseOrgID = %v; want validation errors for both", s, err, orgErr)
+			}
+			return
+		}
+		if ref.PublicID != "" && !ref.ObjectID.IsZero() {
+			t.Fatalf("ParseUserRef(%q) = %+v, want one ID", s, ref)
+		}
+
+		// The canonical form parses to the same reference, and holds
+		// nothing a query could read as an operator
+		id := ref.String()
+		if again, err := ParseUserRef(id); err != nil || again != ref {
+			t.Fatalf("ParseUserRef(%q) = %+v, %v; want %+v back", id, again, err, ref)
+		}
+		if strings.Trim(id, "0123456789abcdef-") != "" {
+			t.Fatalf("ParseUserRef(%q) gives %q, want lowercase hex, digits and dashes", s, id)
+		}
+		if orgErr != nil || orgID != id {
+			t.Fatalf("ParseOrgID(%q) = %q, %v; want %q", s, orgID, orgErr, id)
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package model
+
+// ImportedUser is a user read from a row of an imported CSV file. Row is
+// its line number, the header being line 1.
+type ImportedUser struct {
+	Row  int
+	User User
+}
+
+// ImportRowError reports why one row of an import was not upserted.
+// Status and Message are derived from Err when rendering.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Email   string `json:"email,omitempty"`
+	Status  int    `json:"status"`
+	Message string `json:"error"`
+	Err     error  `json:"-"`
+}
+
+// ImportResult summarizes an import of users from a CSV file, upserted by
+// email. Rows not listed in Errors were applied.
+type ImportResult struct {
+	Rows    int              `json:"rows"`
+	Created int64            `json:"created"`
+	Updated int64            `json:"updated"`
+	Errors  []ImportRowError `json:"errors"`
+	// CreatedIDs and UpdatedIDs are the public IDs of the users inserted
+	// and of those updated
+	CreatedIDs []string `json:"-"`
+	UpdatedIDs []string `json:"-"`
+}
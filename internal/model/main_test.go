@@ -0,0 +1,11 @@
+package model
+
+import (
+	"testing"
+
+	"datadog-golang-example/internal/testrun"
+)
+
+func TestMain(m *testing.M) {
+	testrun.Main(m)
+}
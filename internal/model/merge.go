@@ -0,0 +1,27 @@
+package model
+
+// Sides of a merge, naming whose value a merged field kept
+const (
+	MergeTarget = "target"
+	MergeSource = "source"
+)
+
+// MergeRequest represents the body of POST /users/:id/merge, which merges
+// the source user into the target of the path. Prefer is the user whose
+// values win when both have one, the target by default.
+type MergeRequest struct {
+	SourceID string `json:"source_id" binding:"required"`
+	Prefer   string `json:"prefer" binding:"omitempty,oneof=target source"`
+}
+
+// MergeReport describes a merge. Fields tells, for each field both
+// users had a value of, whose value was kept, and Moved counts the
+// records of the source moved to the target, per kind.
+type MergeReport struct {
+	User      *User             `json:"user"`
+	SourceID  string            `json:"source_id"`
+	Fields    map[string]string `json:"fields"`
+	TagsAdded []string          `json:"tags_added"`
+	Moved     map[string]int64  `json:"moved"`
+	DryRun    bool              `json:"dry_run,omitempty"`
+}
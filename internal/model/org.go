@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Organization groups users, such as the employees of a company. A user
+// belongs to at most one organization.
+type Organization struct {
+	ID        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	PublicID  string             `json:"id" bson:"public_id"`
+	Name      string             `json:"name" bson:"name"`
+	NameKey   string             `json:"-" bson:"name_key"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// OrgCount counts the members of an organization, OrgID being empty for
+// the users in none
+type OrgCount struct {
+	OrgID string `json:"org_id" bson:"_id"`
+	Users int64  `json:"users" bson:"users"`
+}
+
+// CreateOrgRequest represents the request body for creating an organization
+type CreateOrgRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// AddMemberRequest represents the request body for adding a user to an
+// organization
+type AddMemberRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// ParseOrgID accepts the public ID of an organization, which has no other
+// ID clients can use
+func ParseOrgID(s string) (string, error) {
+	if id, err := primitive.ObjectIDFromHex(s); err == nil {
+		return id.Hex(), nil
+	}
+	id, ok := parsePublicID(s)
+	if !ok {
+		return "", &ValidationError{Field: "id", Reason: "must be an ObjectID, a UUID or a Snowflake ID"}
+	}
+	return id, nil
+}
@@ -0,0 +1,21 @@
+package model
+
+// PatchableUser is the client-editable view of a user that JSON Patch and
+// merge patch documents are applied to. Email and location are optional
+// so patches can clear them.
+type PatchableUser struct {
+	Name     string           `json:"name" binding:"required"`
+	Email    string           `json:"email,omitempty" binding:"omitempty,email"`
+	Age      int              `json:"age" binding:"required,min=1,max=150"`
+	Location *LocationRequest `json:"location,omitempty" binding:"omitempty"`
+}
+
+// Patchable returns the editable view of the user
+func (u *User) Patchable() PatchableUser {
+	return PatchableUser{
+		Name:     u.Name,
+		Email:    u.Email,
+		Age:      u.Age,
+		Location: u.Location.Request(),
+	}
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// UserStats aggregates every user as of ComputedAt
+type UserStats struct {
+	Users int64    `json:"users" bson:"users"`
+	Age   AgeStats `json:"age" bson:"age"`
+	// InOrgs and WithLocation count the users in an organization and
+	// those with a location
+	InOrgs       int64 `json:"in_orgs" bson:"in_orgs"`
+	WithLocation int64 `json:"with_location" bson:"with_location"`
+	// Tags are the most used tags, the most used first
+	Tags       []TagCount `json:"tags" bson:"tags"`
+	ComputedAt time.Time  `json:"computed_at" bson:"-"`
+}
+
+// AgeStats summarizes the ages of the users
+type AgeStats struct {
+	Min int     `json:"min" bson:"min"`
+	Max int     `json:"max" bson:"max"`
+	Avg float64 `json:"avg" bson:"avg"`
+}
+
+// TagCount counts the users with a tag
+type TagCount struct {
+	Tag   string `json:"tag" bson:"_id"`
+	Users int64  `json:"users" bson:"users"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"slices"
+	"strings"
+)
+
+// Bounds for user tags
+const (
+	maxTagLen   = 32
+	maxUserTags = 20
+)
+
+// TagsRequest represents the request body for adding tags to a user
+type TagsRequest struct {
+	Tags []string `json:"tags" binding:"required,min=1,max=20"`
+}
+
+// NormalizeTag lowercases and trims a tag, then checks it only holds
+// letters, digits, hyphens, underscores and colons. Errors are reported
+// against field.
+func NormalizeTag(field, tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || len(tag) > maxTagLen {
+		return "", &ValidationError{Field: field, Reason: "must be between 1 and 32 characters"}
+	}
+	for _, r := range tag {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == ':') {
+			return "", &ValidationError{Field: field, Reason: tag + " may only contain letters, digits, '-', '_' and ':'"}
+		}
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes every tag and drops duplicates, keeping the
+// first occurrence
+func NormalizeTags(tags []string) ([]string, error) {
+	if len(tags) > maxUserTags {
+		return nil, &ValidationError{Field: "tags", Reason: "must contain at most 20 tags"}
+	}
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := NormalizeTag("tags", tag)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out, nil
+}
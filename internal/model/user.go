@@ -0,0 +1,198 @@
+// Package model defines the domain types shared by every layer.
+package model
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// User represents a user document in MongoDB. Only the public ID is
+// exposed to clients; the ObjectID stays internal.
+type User struct {
+	ID       primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	PublicID string             `json:"id" bson:"public_id"`
+	Username string             `json:"username" bson:"username"`
+	Name     string             `json:"name" bson:"name"`
+	NameKey  string             `json:"-" bson:"name_key"`
+	Email    string             `json:"email,omitempty" bson:"email,omitempty"`
+	// EmailRisk is why the email verification API distrusts Email, when it
+	// does and risky addresses are flagged rather than rejected
+	EmailRisk string    `json:"email_risk,omitempty" bson:"email_risk,omitempty"`
+	Age       int       `json:"age" bson:"age"`
+	Location  *GeoPoint `json:"location,omitempty" bson:"location,omitempty"`
+	Tags      []string  `json:"tags,omitempty" bson:"tags,omitempty"`
+	// OrgID is the public ID of the organization of the user, if any
+	OrgID     string    `json:"org_id,omitempty" bson:"org_id,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	// Version increases with every write, for optimistic concurrency
+	Version int64 `json:"version" bson:"version"`
+	// ErasedAt is when the personal data of the user was erased
+	ErasedAt *time.Time `json:"erased_at,omitempty" bson:"erased_at,omitempty"`
+	// SchemaVersion is the shape of the document the user was stored as,
+	// zero before versioning; older documents are upgraded when read
+	SchemaVersion int `json:"-" bson:"schema_version,omitempty"`
+	// AttachmentsCount is derived from the attachments of the user, kept
+	// up to date by atomic increments and reconciled periodically
+	AttachmentsCount int64 `json:"attachments_count,omitempty" bson:"attachments_count,omitempty"`
+}
+
+// CreateUserRequest represents the request body for creating a user
+type CreateUserRequest struct {
+	Name     string           `json:"name" binding:"required"`
+	Email    string           `json:"email" binding:"required,email"`
+	Age      int              `json:"age" binding:"required,min=1,max=150"`
+	Location *LocationRequest `json:"location" binding:"omitempty"`
+}
+
+// UpdateUserRequest represents the request body for updating a user
+type UpdateUserRequest struct {
+	Name     string           `json:"name"`
+	Email    string           `json:"email" binding:"omitempty,email"`
+	Age      int              `json:"age" binding:"omitempty,min=1,max=150"`
+	Location *LocationRequest `json:"location" binding:"omitempty"`
+}
+
+// ReplaceUserRequest represents the full body of PUT /users/:id. When
+// Version is set the replace only succeeds if the stored user is still at
+// that version.
+type ReplaceUserRequest struct {
+	Name     string           `json:"name" binding:"required"`
+	Email    string           `json:"email" binding:"required,email"`
+	Age      int              `json:"age" binding:"required,min=1,max=150"`
+	Location *LocationRequest `json:"location" binding:"omitempty"`
+	Version  *int64           `json:"version" binding:"omitempty,min=0"`
+}
+
+// BatchGetRequest represents the request body for fetching many users
+type BatchGetRequest struct {
+	IDs []string `json:"ids" binding:"required,min=1"`
+}
+
+// BatchGetResult holds the users found by a batch get and the requested
+// IDs that matched no user
+type BatchGetResult struct {
+	Users   []User   `json:"users"`
+	Missing []string `json:"missing"`
+}
+
+// UserUpdate is a partial update of a user; nil fields are left unchanged
+// and fields listed in Unset are removed from the document. AddTags and
+// RemoveTags edit the tag set and cannot be combined.
+type UserUpdate struct {
+	Name       *string
+	NameKey    *string
+	Email      *string
+	EmailRisk  *string
+	Age        *int
+	Location   *GeoPoint
+	OrgID      *string
+	Unset      []string
+	AddTags    []string
+	RemoveTags []string
+	UpdatedAt  time.Time
+}
+
+// Applied returns a copy of u with update applied, as the store would
+// persist it
+func (u User) Applied(update UserUpdate) *User {
+	if update.Name != nil {
+		u.Name = *update.Name
+	}
+	if update.NameKey != nil {
+		u.NameKey = *update.NameKey
+	}
+	if update.Email != nil {
+		u.Email = *update.Email
+	}
+	if update.EmailRisk != nil {
+		u.EmailRisk = *update.EmailRisk
+	}
+	if update.Age != nil {
+		u.Age = *update.Age
+	}
+	if update.Location != nil {
+		u.Location = update.Location
+	}
+	if update.OrgID != nil {
+		u.OrgID = *update.OrgID
+	}
+	for _, tag := range update.AddTags {
+		if !slices.Contains(u.Tags, tag) {
+			u.Tags = append(slices.Clip(u.Tags), tag)
+		}
+	}
+	if len(update.RemoveTags) > 0 {
+		u.Tags = slices.DeleteFunc(slices.Clone(u.Tags), func(tag string) bool {
+			return slices.Contains(update.RemoveTags, tag)
+		})
+	}
+	for _, field := range update.Unset {
+		switch field {
+		case "email":
+			u.Email, u.EmailRisk = "", ""
+		case "location":
+			u.Location = nil
+		}
+	}
+	u.UpdatedAt = update.UpdatedAt
+	u.Version++
+	return &u
+}
+
+// UserFilter narrows and orders the users returned by a list
+type UserFilter struct {
+	// Filters maps field names, such as "tag", to the value they must equal
+	Filters map[string]string
+	// OrgID restricts the list to the members of an organization
+	OrgID string
+	// Sort is the field to sort by, prefixed with "-" for descending order
+	Sort string
+	// Limit caps the number of users returned, zero for all of them, after
+	// skipping the first Offset
+	Limit  int
+	Offset int
+	// Fields limits the users to these JSON fields, the default fields of
+	// lists when empty; the ID is always returned
+	Fields []string
+	// CreatedBefore restricts the list to the users created before it;
+	// zero is open
+	CreatedBefore time.Time
+}
+
+// Count modes of a user query
+const (
+	CountExact     = "exact"
+	CountEstimated = "estimated"
+	CountNone      = "none"
+)
+
+// UserQuery represents the body of POST /users/query, a user list too
+// complex for query parameters. Count is exact, counting the matching
+// users, estimated, reading the collection size from its metadata, which
+// is only possible without filters, or none.
+type UserQuery struct {
+	Filters map[string]string `json:"filters"`
+	Sort    string            `json:"sort"`
+	Limit   int               `json:"limit" binding:"omitempty,min=1,max=100"`
+	Offset  int               `json:"offset" binding:"omitempty,min=0"`
+	Fields  []string          `json:"fields"`
+	Count   string            `json:"count" binding:"omitempty,oneof=exact estimated none"`
+}
+
+// UserQueryResult is a page of users matching a query and, unless the
+// count was none, their total
+type UserQueryResult struct {
+	Users []User
+	Total int64
+	Count string
+}
+
+// Suggestion is a lightweight user match returned for typeahead queries
+type Suggestion struct {
+	ID       string `json:"id" bson:"public_id"`
+	Name     string `json:"name" bson:"name"`
+	Username string `json:"username" bson:"username"`
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxUsernameLen bounds the length of generated usernames
+const maxUsernameLen = 32
+
+// Slugify derives a lowercase, URL-safe username from a display name:
+// accents are stripped and runs of other characters become single hyphens
+func Slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range norm.NFKD.String(name) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.Is(unicode.Mn, r):
+			// Combining mark left over from decomposing an accented letter
+		default:
+			hyphen = true
+		}
+		if b.Len() >= maxUsernameLen {
+			break
+		}
+	}
+	slug := strings.TrimRight(b.String(), "-")
+	if slug == "" {
+		return "user"
+	}
+	return slug
+}
+
+// UsernameCandidate returns the username to try on the given attempt:
+// the base itself first, then base-2, base-3 and so on
+func UsernameCandidate(base string, attempt int) string {
+	if attempt <= 1 {
+		return base
+	}
+	return base + "-" + strconv.Itoa(attempt)
+}
+
+// FoldName normalizes a name for prefix matching: accents are stripped and
+// letters lowercased, so "Jo" matches "josé"
+func FoldName(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(name) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// validate checks struct tags with the same "binding" tag name Gin uses,
+// so request types validate identically inside and outside handlers
+var validate = func() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	return v
+}()
+
+// Validate checks v against its binding tags
+func Validate(v any) error {
+	if err := validate.Struct(v); err != nil {
+		return fmt.Errorf("%w: %w", ErrValidation, err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Package perf guards hot code paths against performance regressions.
+// Their benchmarks are run several times and the allocations of the runs
+// compared with a baseline stored in the Go benchmark format, so
+// benchstat can compare a run with it too. Latencies depend on the
+// machine, so their p95 is only compared when asked for, with a run of
+// the same machine.
+package perf
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	update  = flag.Bool("perf.update", false, "rewrite the performance baselines with this run")
+	out     = flag.String("perf.out", "", "also write this run to the file, for benchstat")
+	base    = flag.String("perf.base", "", "compare the p95 latencies with this run of the same machine, written with -perf.out")
+	latency = flag.Float64("perf.latency", 0.5, "tolerated p95 latency regression from -perf.base, 0.5 being 50%")
+	allocs  = flag.Float64("perf.allocs", 0.1, "tolerated allocation regression, 0.1 being 10%")
+)
+
+// samples is the number of runs of each benchmark, and sampleTime the
+// duration of a run unless -benchtime sets it
+const (
+	samples    = 10
+	sampleTime = 100 * time.Millisecond
+)
+
+// Benchmark is a named benchmark of a hot path
+type Benchmark struct {
+	// Name is the name of the benchmark without its Benchmark prefix
+	Name string
+	F    func(b *testing.B)
+}
+
+// stats are the samples of one benchmark
+type stats struct {
+	iterations                       []int
+	nsPerOp, bytesPerOp, allocsPerOp []float64
+}
+
+// p95 returns the 95th percentile of the latencies
+func (s stats) p95() float64 {
+	if len(s.nsPerOp) == 0 {
+		return 0
+	}
+	sorted := slices.Sorted(slices.Values(s.nsPerOp))
+	return sorted[int(math.Ceil(0.95*float64(len(sorted))))-1]
+}
+
+// allocs returns the median of the allocations, which barely vary
+func (s stats) allocs() float64 {
+	if len(s.allocsPerOp) == 0 {
+		return 0
+	}
+	sorted := slices.Sorted(slices.Values(s.allocsPerOp))
+	return sorted[len(sorted)/2]
+}
+
+// Check runs each benchmark and fails t when its allocations regress
+// beyond the tolerance of -perf.allocs from the baseline of t, in
+// testdata/perf/<test>.txt. With -perf.base, it also fails when its p95
+// latency regresses beyond -perf.latency from that run, the latencies of
+// the baseline coming from another machine. With -perf.update the
+// baseline is rewritten instead. The benchmarks are slow, so the checks
+// only run when asked for with -run=Perf.
+func Check(t *testing.T, benchmarks ...Benchmark) {
+	t.Helper()
+	if !asked() {
+		t.Skip("performance checks only run with -run=Perf")
+	}
+	if !flagSet("test.benchtime") {
+		flag.Set("test.benchtime", sampleTime.String())
+	}
+	path := filepath.Join("testdata", "perf", t.Name()+".txt")
+	baseline, err := read(path)
+	if err != nil && !*update {
+		t.Fatalf("read the baseline: %v; write it with -perf.update", err)
+	}
+	var latencies map[string]stats
+	if *base != "" && !*update {
+		if latencies, err = read(*base); err != nil {
+			t.Fatalf("read the run to compare latencies with: %v", err)
+		}
+	}
+
+	current := map[string]stats{}
+	for _, bench := range benchmarks {
+		var s stats
+		for range samples {
+			r := testing.Benchmark(bench.F)
+			if r.N == 0 {
+				t.Fatalf("%s: the benchmark failed", bench.Name)
+			}
+			s.iterations = append(s.iterations, r.N)
+			s.nsPerOp = append(s.nsPerOp, float64(r.T.Nanoseconds())/float64(r.N))
+			s.bytesPerOp = append(s.bytesPerOp, float64(r.MemBytes)/float64(r.N))
+			s.allocsPerOp = append(s.allocsPerOp, float64(r.MemAllocs)/float64(r.N))
+		}
+		current[bench.Name] = s
+		if *update {
+			continue
+		}
+		base, ok := baseline[bench.Name]
+		if !ok {
+			t.Errorf("%s: no baseline; write it with -perf.update", bench.Name)
+			continue
+		}
+		t.Logf("%s: p95 %.0f ns/op, %.0f allocs/op (baseline %.0f)", bench.Name, s.p95(), s.allocs(), base.allocs())
+		if latencies != nil {
+			before, ok := latencies[bench.Name]
+			switch {
+			case !ok:
+				t.Errorf("%s: not in the run of -perf.base", bench.Name)
+			case s.p95() > before.p95()*(1+*latency):
+				t.Errorf("%s: p95 latency regressed to %.0f ns/op from %.0f, beyond %.0f%%",
+					bench.Name, s.p95(), before.p95(), *latency*100)
+			}
+		}
+		if s.allocs() > base.allocs()*(1+*allocs) && s.allocs()-base.allocs() >= 1 {
+			t.Errorf("%s: allocations regressed to %.0f allocs/op from %.0f, beyond %.0f%%",
+				bench.Name, s.allocs(), base.allocs(), *allocs*100)
+		}
+	}
+
+	names := make([]string, len(benchmarks))
+	for i, bench := range benchmarks {
+		names[i] = bench.Name
+	}
+	if *update {
+		if err := write(path, names, current); err != nil {
+			t.Fatalf("write the baseline: %v", err)
+		}
+		t.Logf("wrote the baseline to %s", path)
+	}
+	if *out != "" {
+		if err := write(*out, names, current); err != nil {
+			t.Fatalf("write the run: %v", err)
+		}
+		t.Logf("compare the run with: benchstat %s %s", path, *out)
+	}
+}
+
+// asked reports whether the performance checks were asked for, by a -run
+// pattern naming them
+func asked() bool {
+	run := flag.Lookup("test.run")
+	return run != nil && strings.Contains(run.Value.String(), "Perf")
+}
+
+// flagSet reports whether the named flag was set on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// read parses the samples of a file in the Go benchmark format
+func read(path string) (map[string]stats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	all := map[string]stats{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		// The name may end with the GOMAXPROCS of the run
+		name := strings.TrimPrefix(fields[0], "Benchmark")
+		if i := strings.LastIndexByte(name, '-'); i > 0 {
+			name = name[:i]
+		}
+		s := all[name]
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a number of iterations", path, fields[1])
+		}
+		s.iterations = append(s.iterations, n)
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %q is not a value", path, fields[i])
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				s.nsPerOp = append(s.nsPerOp, v)
+			case "B/op":
+				s.bytesPerOp = append(s.bytesPerOp, v)
+			case "allocs/op":
+				s.allocsPerOp = append(s.allocsPerOp, v)
+			}
+		}
+		all[name] = s
+	}
+	return all, scanner.Err()
+}
+
+// write writes the samples of the benchmarks in the Go benchmark format,
+// with the configuration lines benchstat groups the runs by
+func write(path string, names []string, all map[string]stats) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// As go test does, the GOMAXPROCS of the run suffixes the names
+	// unless it is 1
+	suffix := ""
+	if procs := runtime.GOMAXPROCS(0); procs > 1 {
+		suffix = "-" + strconv.Itoa(procs)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "goos: %s\ngoarch: %s\n", runtime.GOOS, runtime.GOARCH)
+	for _, name := range names {
+		s := all[name]
+		for i := range s.nsPerOp {
+			fmt.Fprintf(&b, "Benchmark%s%s\t%d\t%.1f ns/op\t%.0f B/op\t%.0f allocs/op\n",
+				name, suffix, s.iterations[i], s.nsPerOp[i], s.bytesPerOp[i], s.allocsPerOp[i])
+		}
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
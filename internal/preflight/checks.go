@@ -0,0 +1,51 @@
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"datadog-golang-example/internal/telemetry"
+)
+
+// Env checks that every named environment variable is set
+func Env(names []string) Check {
+	return Check{Name: "env", Run: func(context.Context) error {
+		var missing []string
+		for _, name := range names {
+			if os.Getenv(name) == "" {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+		}
+		return nil
+	}}
+}
+
+// TraceAgent checks that the trace agent answers on its /info endpoint
+func TraceAgent(url string) Check {
+	return Check{Name: "trace_agent", Run: func(ctx context.Context) error {
+		return telemetry.ProbeAgent(ctx, url)
+	}}
+}
+
+// DogStatsD checks that the DogStatsD socket exists, or that the UDP
+// address resolves. UDP is connectionless, so a running agent cannot be
+// confirmed from here.
+func DogStatsD(addr string) Check {
+	return Check{Name: "dogstatsd", Run: func(ctx context.Context) error {
+		if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+			return telemetry.SocketExists(path)
+		}
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		_, err = net.DefaultResolver.LookupHost(ctx, host)
+		return err
+	}}
+}
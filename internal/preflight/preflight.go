@@ -0,0 +1,94 @@
+// Package preflight runs the startup checks that tell whether the
+// application's dependencies are usable before it starts serving.
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Check is a named startup check
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Name     string
+	Required bool
+	Duration time.Duration
+	Err      error
+}
+
+// Status returns "ok", "fail" for a failed required check, or "warn"
+func (r Result) Status() string {
+	switch {
+	case r.Err == nil:
+		return "ok"
+	case r.Required:
+		return "fail"
+	default:
+		return "warn"
+	}
+}
+
+// Suite runs checks; failures of the required ones abort startup, the
+// others are reported and the application runs degraded
+type Suite struct {
+	checks   []Check
+	required map[string]bool
+	timeout  time.Duration
+}
+
+// NewSuite creates a suite where the checks named in required are fatal
+// and each check runs under timeout
+func NewSuite(required []string, timeout time.Duration) *Suite {
+	s := &Suite{required: make(map[string]bool, len(required)), timeout: timeout}
+	for _, name := range required {
+		s.required[name] = true
+	}
+	return s
+}
+
+// Add registers a check
+func (s *Suite) Add(c Check) {
+	s.checks = append(s.checks, c)
+}
+
+// Run runs every check, logs the report and returns an error naming the
+// required checks that failed
+func (s *Suite) Run(ctx context.Context) ([]Result, error) {
+	results := make([]Result, 0, len(s.checks))
+	var failed []string
+	for _, c := range s.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		start := time.Now()
+		err := c.Run(checkCtx)
+		cancel()
+
+		r := Result{Name: c.Name, Required: s.required[c.Name], Duration: time.Since(start), Err: err}
+		results = append(results, r)
+		if r.Status() == "fail" {
+			failed = append(failed, c.Name)
+		}
+		log.Print(r)
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("preflight checks failed: %s", strings.Join(failed, ", "))
+	}
+	return results, nil
+}
+
+// String formats the result as a key=value log line
+func (r Result) String() string {
+	line := fmt.Sprintf("Preflight check=%s status=%s required=%t duration=%s",
+		r.Name, r.Status(), r.Required, r.Duration.Round(time.Millisecond))
+	if r.Err != nil {
+		line += fmt.Sprintf(" error=%q", r.Err.Error())
+	}
+	return line
+}
@@ -0,0 +1,157 @@
+// Package profile builds the profile of a user by fanning out, in
+// parallel, to the user endpoint of this service and to an external
+// enrichment service, then merging their answers. Each call is a traced
+// HTTP request, so a single profile yields a trace spanning several
+// services.
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"golang.org/x/sync/errgroup"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/model"
+)
+
+// Enrichment is what the external service knows of a user
+type Enrichment struct {
+	Company string `json:"company"`
+	Title   string `json:"title"`
+	// Score rates the engagement of the user, from 0 to 100
+	Score int `json:"score"`
+}
+
+// Source reports how one call of the fan-out went
+type Source struct {
+	Name string `json:"name"`
+	// Status is the HTTP status answered, zero when the call failed
+	// without an answer
+	Status     int    `json:"status,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Profile merges a user with its enrichment. The user is passed through
+// as the user endpoint answered it.
+type Profile struct {
+	User json.RawMessage `json:"user"`
+	// Enrichment is nil when the enrichment service failed, the profile
+	// then being degraded
+	Enrichment *Enrichment `json:"enrichment"`
+	Degraded   bool        `json:"degraded"`
+	Sources    []Source    `json:"sources"`
+}
+
+// The sources of a profile, also the resource names of their spans
+const (
+	sourceUser       = "user"
+	sourceEnrichment = "enrichment"
+)
+
+// credentialHeaders are forwarded to the calls, so the user endpoint
+// authorizes them as it would the caller
+var credentialHeaders = []string{"X-API-Key", "Authorization"}
+
+// Fetcher builds profiles
+type Fetcher struct {
+	cfg    config.ProfilesConfig
+	client *http.Client
+}
+
+// NewFetcher creates a Fetcher calling the services through client, which
+// must be traced so the calls show up as child spans
+func NewFetcher(cfg config.ProfilesConfig, client *http.Client) *Fetcher {
+	return &Fetcher{cfg: cfg, client: client}
+}
+
+// Fetch builds the profile of the user with the ID, calling the services
+// with the credentials in header. The user is required: model.ErrNotFound
+// is returned for an unknown one and model.ErrUnavailable when the user
+// endpoint fails, cancelling the enrichment. A failed enrichment only
+// degrades the profile.
+func (f *Fetcher) Fetch(ctx context.Context, id string, header http.Header) (*Profile, error) {
+	var (
+		profile    Profile
+		user       Source
+		enrichment Source
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		user, err = f.fetch(gctx, sourceUser, f.cfg.UsersURL+url.PathEscape(id), header, &profile.User)
+		return err
+	})
+	g.Go(func() error {
+		var e Enrichment
+		enrichment, _ = f.fetch(gctx, sourceEnrichment, f.cfg.EnrichmentURL+url.PathEscape(id), header, &e)
+		if enrichment.Error == "" {
+			profile.Enrichment = &e
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	profile.Degraded = profile.Enrichment == nil
+	profile.Sources = []Source{user, enrichment}
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		span.SetTag("profile.degraded", profile.Degraded)
+	}
+	return &profile, nil
+}
+
+// fetch calls one source of the profile and decodes its answer into v,
+// reporting the call in the returned Source even when it fails
+func (f *Fetcher) fetch(ctx context.Context, name, target string, header http.Header, v any) (source Source, err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "profile.fetch", tracer.ResourceName(name))
+	start := time.Now()
+	source = Source{Name: name}
+	defer func() {
+		source.DurationMS = time.Since(start).Milliseconds()
+		if err != nil {
+			source.Error = err.Error()
+		}
+		span.SetTag("profile.source.status", source.Status)
+		span.Finish(tracer.WithError(err))
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, f.cfg.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return source, err
+	}
+	for _, h := range credentialHeaders {
+		if value := header.Get(h); value != "" {
+			req.Header.Set(h, value)
+		}
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return source, fmt.Errorf("fetch %s: %v: %w", name, err, model.ErrUnavailable)
+	}
+	defer resp.Body.Close()
+	source.Status = resp.StatusCode
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return source, fmt.Errorf("fetch %s: %w", name, model.ErrNotFound)
+	case resp.StatusCode != http.StatusOK:
+		return source, fmt.Errorf("fetch %s: unexpected status %d: %w", name, resp.StatusCode, model.ErrUnavailable)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return source, fmt.Errorf("fetch %s: %v: %w", name, err, model.ErrUnavailable)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return source, fmt.Errorf("decode %s: %v: %w", name, err, model.ErrUnavailable)
+	}
+	return source, nil
+}
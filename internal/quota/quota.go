@@ -0,0 +1,90 @@
+// Package quota tracks monthly request quotas per API key in Redis.
+package quota
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"datadog-golang-example/internal/config"
+)
+
+// Usage is the state of a key's quota for the current month
+type Usage struct {
+	Limit     int64     `json:"limit"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// Exhausted reports whether the quota has been used up
+func (u Usage) Exhausted() bool {
+	return u.Used > u.Limit
+}
+
+// Tracker counts requests per API key and calendar month (UTC)
+type Tracker struct {
+	rdb redis.UniversalClient
+	cfg config.QuotaConfig
+	now func() time.Time
+}
+
+// NewTracker creates a Tracker storing its counters in rdb
+func NewTracker(rdb redis.UniversalClient, cfg config.QuotaConfig) *Tracker {
+	return &Tracker{rdb: rdb, cfg: cfg, now: time.Now}
+}
+
+// Consume counts one request for keyName and returns the resulting usage.
+// Requests rejected for exceeding the quota are not counted against it.
+func (t *Tracker) Consume(ctx context.Context, keyName string) (Usage, error) {
+	key, resets := t.counterKey(keyName)
+
+	pipe := t.rdb.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	// Keep the counter a day past the reset so late reads still see it
+	pipe.ExpireAt(ctx, key, resets.Add(24*time.Hour))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Usage{}, err
+	}
+
+	u := t.usage(keyName, incr.Val(), resets)
+	if u.Exhausted() {
+		if err := t.rdb.Decr(ctx, key).Err(); err != nil {
+			return u, err
+		}
+	}
+	return u, nil
+}
+
+// Usage returns the current usage of keyName without consuming quota
+func (t *Tracker) Usage(ctx context.Context, keyName string) (Usage, error) {
+	key, resets := t.counterKey(keyName)
+	used, err := t.rdb.Get(ctx, key).Int64()
+	if err != nil && err != redis.Nil {
+		return Usage{}, err
+	}
+	return t.usage(keyName, used, resets), nil
+}
+
+// usage builds the Usage of keyName for a counter value
+func (t *Tracker) usage(keyName string, used int64, resets time.Time) Usage {
+	limit := t.cfg.Monthly
+	if override, ok := t.cfg.Overrides[keyName]; ok {
+		limit = override
+	}
+	return Usage{
+		Limit:     limit,
+		Used:      used,
+		Remaining: max(limit-used, 0),
+		ResetsAt:  resets,
+	}
+}
+
+// counterKey returns the Redis key for the current month's counter and
+// the instant that month ends
+func (t *Tracker) counterKey(keyName string) (string, time.Time) {
+	now := t.now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return "quota:" + keyName + ":" + month.Format("2006-01"), month.AddDate(0, 1, 0)
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"maps"
+	"sync"
+
+	"datadog-golang-example/internal/config"
+)
+
+// Policy holds the limits of each route group; it is safe for concurrent
+// use and can be reconfigured at runtime. Buckets pick up a new limit on
+// their next request.
+type Policy struct {
+	mu     sync.RWMutex
+	groups map[string]config.TierLimits
+}
+
+// NewPolicy creates a Policy with the configured limits
+func NewPolicy(cfg config.RateLimitConfig) *Policy {
+	return &Policy{groups: maps.Clone(cfg.Groups)}
+}
+
+// Limits returns the limits of a route group
+func (p *Policy) Limits(group string) config.TierLimits {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.groups[group]
+}
+
+// Groups returns a copy of the limits of every route group
+func (p *Policy) Groups() map[string]config.TierLimits {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return maps.Clone(p.groups)
+}
+
+// Set replaces the limits of a route group
+func (p *Policy) Set(group string, limits config.TierLimits) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.groups[group] = limits
+}
@@ -0,0 +1,83 @@
+// Package ratelimit implements in-memory token-bucket rate limiting keyed
+// by client.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"datadog-golang-example/internal/config"
+)
+
+// idleTTL is how long an unused bucket is kept before it is evicted
+const idleTTL = 10 * time.Minute
+
+// Decision is the outcome of a rate limit check
+type Decision struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter holds one token bucket per key
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	limit    config.Limit
+	lastSeen time.Time
+}
+
+// New creates an empty Limiter
+func New() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+// Allow consumes a token from the bucket for key, creating it with limit
+// on first use. A bucket is recreated if its limit has changed.
+func (l *Limiter) Allow(key string, limit config.Limit) Decision {
+	now := time.Now()
+
+	l.mu.Lock()
+	b, ok := l.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(limit.RPS), limit.Burst), limit: limit}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+	if now.Sub(l.lastSweep) > time.Minute {
+		l.sweep(now)
+	}
+	l.mu.Unlock()
+
+	d := Decision{Limit: limit.Burst}
+	r := b.limiter.ReserveN(now, 1)
+	switch {
+	case !r.OK():
+		// Burst of zero: nothing is ever allowed
+	case r.DelayFrom(now) == 0:
+		d.Allowed = true
+	default:
+		d.RetryAfter = r.DelayFrom(now)
+		r.CancelAt(now)
+	}
+	d.Remaining = max(int(b.limiter.TokensAt(now)), 0)
+	return d
+}
+
+// sweep evicts buckets that have not been used for idleTTL
+func (l *Limiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		if now.Sub(b.lastSeen) > idleTTL {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,28 @@
+// Package readonly holds the read-only mode of the API, switched at
+// runtime: while it is on, the routes that write are refused and the
+// reads stay served.
+package readonly
+
+import "sync/atomic"
+
+// Mode is whether the API is read-only; it is safe for concurrent use
+type Mode struct {
+	on atomic.Bool
+}
+
+// New creates a Mode, read-only when on
+func New(on bool) *Mode {
+	m := &Mode{}
+	m.on.Store(on)
+	return m
+}
+
+// Enabled reports whether the API is read-only
+func (m *Mode) Enabled() bool {
+	return m.on.Load()
+}
+
+// Set switches the mode and returns the previous one
+func (m *Mode) Set(on bool) bool {
+	return m.on.Swap(on)
+}
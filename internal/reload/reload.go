@@ -0,0 +1,194 @@
+// Package reload applies the runtime settings file while the application
+// is serving. Only settings that are safe to change live are read from it:
+// the log level, the trace sample rate, rate limits, feature flags and the
+// read-only mode.
+package reload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/features"
+	"datadog-golang-example/internal/logging"
+	"datadog-golang-example/internal/ratelimit"
+	"datadog-golang-example/internal/readonly"
+	"datadog-golang-example/internal/telemetry"
+)
+
+// Settings is the content of the settings file. Omitted settings keep
+// their current value.
+type Settings struct {
+	LogLevel        *string  `json:"log_level,omitempty"`
+	TraceSampleRate *float64 `json:"trace_sample_rate,omitempty"`
+	// RateLimits maps a route group to a spec such as
+	// "anonymous=5:10,api_key=50:100"
+	RateLimits map[string]string `json:"rate_limits,omitempty"`
+	Features   map[string]bool   `json:"features,omitempty"`
+	ReadOnly   *bool             `json:"read_only,omitempty"`
+}
+
+// Targets are the components the settings are applied to
+type Targets struct {
+	Tracer     *telemetry.Tracer
+	RateLimits *ratelimit.Policy
+	Features   *features.Flags
+	ReadOnly   *readonly.Mode
+}
+
+// change is one applied setting, as recorded in the audit log
+type change struct {
+	setting  string
+	old, new any
+	apply    func()
+}
+
+// Reloader polls the settings file and applies what changed
+type Reloader struct {
+	cfg     config.ReloadConfig
+	targets Targets
+	done    chan struct{}
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+}
+
+// New creates a Reloader; the file is first read by Start
+func New(cfg config.ReloadConfig, targets Targets) *Reloader {
+	return &Reloader{cfg: cfg, targets: targets, done: make(chan struct{})}
+}
+
+// Start applies the settings file, then polls it every Interval. A
+// missing or invalid file is logged and does not prevent startup.
+func (r *Reloader) Start(ctx context.Context) error {
+	r.reload(ctx)
+	go r.poll()
+	return nil
+}
+
+// Stop ends the polling
+func (r *Reloader) Stop(context.Context) error {
+	close(r.done)
+	return nil
+}
+
+func (r *Reloader) poll() {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.reload(context.Background())
+		}
+	}
+}
+
+// reload applies the file if it changed since the last attempt. Each
+// attempt is traced, with the applied settings tagged on the span.
+func (r *Reloader) reload(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.cfg.File)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		slog.Debug("Settings file not found", "file", r.cfg.File)
+		return
+	case err != nil:
+		slog.Error("Failed to read settings file", "file", r.cfg.File, "error", err)
+		return
+	case info.ModTime().Equal(r.modTime) && info.Size() == r.size:
+		return
+	}
+	r.modTime, r.size = info.ModTime(), info.Size()
+
+	span, _ := tracer.StartSpanFromContext(ctx, "config.reload", tracer.ResourceName(r.cfg.File))
+	changes, err := r.load()
+	if err != nil {
+		slog.Error("Rejected settings file", "file", r.cfg.File, "error", err)
+		span.Finish(tracer.WithError(err))
+		return
+	}
+
+	names := make([]string, 0, len(changes))
+	for _, c := range changes {
+		c.apply()
+		names = append(names, c.setting)
+		logging.Audit("Applied setting", "source", r.cfg.File, "setting", c.setting, "old", c.old, "new", c.new)
+	}
+	span.SetTag("config.changes", len(changes))
+	span.SetTag("config.settings", names)
+	span.Finish()
+}
+
+// load reads and validates the whole file and returns the settings that
+// differ from the current ones. Nothing is applied if any setting is
+// invalid.
+func (r *Reloader) load() ([]change, error) {
+	data, err := os.ReadFile(r.cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	var s Settings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	var changes []change
+	if s.LogLevel != nil {
+		level, err := logging.ParseLevel(*s.LogLevel)
+		if err != nil {
+			return nil, fmt.Errorf("log_level: %w", err)
+		}
+		if old := logging.Level(); level != old {
+			changes = append(changes, change{"log_level", old.String(), level.String(), func() { logging.SetLevel(level) }})
+		}
+	}
+	if s.TraceSampleRate != nil {
+		rate := *s.TraceSampleRate
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("trace_sample_rate: %v is not between 0 and 1", rate)
+		}
+		if old := r.targets.Tracer.SampleRate(); rate != old {
+			changes = append(changes, change{"trace_sample_rate", old, rate, func() { r.targets.Tracer.SetSampleRate(rate) }})
+		}
+	}
+	current := r.targets.RateLimits.Groups()
+	for group, spec := range s.RateLimits {
+		old, ok := current[group]
+		if !ok {
+			return nil, fmt.Errorf("rate_limits: unknown route group %q", group)
+		}
+		limits, err := config.ParseTierLimits(spec, old)
+		if err != nil {
+			return nil, fmt.Errorf("rate_limits.%s: %w", group, err)
+		}
+		if limits != old {
+			changes = append(changes, change{"rate_limits." + group, old, limits, func() { r.targets.RateLimits.Set(group, limits) }})
+		}
+	}
+	if s.Features != nil {
+		if old := r.targets.Features.All(); !maps.Equal(old, s.Features) {
+			changes = append(changes, change{"features", old, s.Features, func() { r.targets.Features.Set(s.Features) }})
+		}
+	}
+	if s.ReadOnly != nil {
+		on := *s.ReadOnly
+		if old := r.targets.ReadOnly.Enabled(); on != old {
+			changes = append(changes, change{"read_only", old, on, func() { r.targets.ReadOnly.Set(on) }})
+		}
+	}
+	return changes, nil
+}
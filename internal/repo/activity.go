@@ -0,0 +1,142 @@
+package repo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"datadog-golang-example/internal/model"
+)
+
+// ActivityLog is the read model of the activity feed
+type ActivityLog interface {
+	Record(ctx context.Context, activity *model.Activity) error
+	Feed(ctx context.Context, filter model.ActivityFilter) (*model.ActivityFeed, error)
+	// Reassign moves the activity of a user to another and returns how
+	// many mutations were moved
+	Reassign(ctx context.Context, from, to string) (int64, error)
+	// Changes sums up the events of each user since the given time
+	Changes(ctx context.Context, since time.Time) ([]model.UserChange, error)
+}
+
+// MongoActivityLog is an ActivityLog backed by a MongoDB collection
+type MongoActivityLog struct {
+	coll *mongo.Collection
+}
+
+// NewMongoActivityLog creates an activity log for the given collection
+func NewMongoActivityLog(coll *mongo.Collection) *MongoActivityLog {
+	return &MongoActivityLog{coll: coll}
+}
+
+// Record inserts activity. Recording an activity again, as every instance
+// consuming the same event does, is not an error.
+func (l *MongoActivityLog) Record(ctx context.Context, activity *model.Activity) error {
+	_, err := l.coll.InsertOne(ctx, activity)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return mapError("record activity", err)
+}
+
+// Feed returns a page of the activities matching filter, newest first,
+// with the count of each type. Both come from one aggregation over the
+// index matching the filter.
+func (l *MongoActivityLog) Feed(ctx context.Context, filter model.ActivityFilter) (*model.ActivityFeed, error) {
+	q := newQuery()
+	hint := "at_-1"
+	switch {
+	case filter.UserID != "":
+		q.eq(fieldUserID, str(filter.UserID))
+		hint = "user_id_1_at_-1"
+	case filter.Type != "":
+		hint = "type_1_at_-1"
+	}
+	if filter.Type != "" {
+		if strings.Contains(filter.Type, ".") {
+			q.eq(fieldType, str(filter.Type))
+		} else {
+			q.prefix(fieldType, filter.Type+".")
+		}
+	}
+	q.between(fieldAt, filter.Since, filter.Before)
+
+	page := bson.A{
+		bson.D{{Key: "$sort", Value: bson.D{{Key: fieldAt.path, Value: -1}, {Key: fieldID.path, Value: -1}}}},
+		bson.D{{Key: "$skip", Value: filter.Offset}},
+	}
+	if filter.Limit > 0 {
+		page = append(page, bson.D{{Key: "$limit", Value: filter.Limit}})
+	}
+	tagIndexHint(ctx, hint)
+	cursor, err := l.coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: q.filter()}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "items", Value: page},
+			{Key: "counts", Value: bson.A{
+				bson.D{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$type"}, {Key: "n", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+			}},
+		}}},
+	}, options.Aggregate().SetHint(hint))
+	if err != nil {
+		return nil, mapError("aggregate activity", err)
+	}
+	defer cursor.Close(ctx)
+
+	var facets []struct {
+		Items  []model.Activity `bson:"items"`
+		Counts []struct {
+			Type string `bson:"_id"`
+			N    int    `bson:"n"`
+		} `bson:"counts"`
+	}
+	if err := cursor.All(ctx, &facets); err != nil {
+		return nil, mapError("decode activity", err)
+	}
+	feed := &model.ActivityFeed{Items: []model.Activity{}, Counts: map[string]int{}}
+	if len(facets) == 1 {
+		if facets[0].Items != nil {
+			feed.Items = facets[0].Items
+		}
+		for _, c := range facets[0].Counts {
+			feed.Counts[c.Type] = c.N
+		}
+	}
+	return feed, nil
+}
+
+// Changes implements ActivityLog. The events are grouped by user in the
+// order of the feed index, oldest first.
+func (l *MongoActivityLog) Changes(ctx context.Context, since time.Time) ([]model.UserChange, error) {
+	const hint = "at_-1"
+	tagIndexHint(ctx, hint)
+	q := newQuery().prefix(fieldType, "user.").between(fieldAt, since, time.Time{})
+	cursor, err := l.coll.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: q.filter()}},
+		{{Key: "$sort", Value: bson.D{{Key: fieldAt.path, Value: 1}, {Key: fieldID.path, Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$" + fieldUserID.path},
+			{Key: "first", Value: bson.D{{Key: "$first", Value: "$" + fieldType.path}}},
+			{Key: "last", Value: bson.D{{Key: "$last", Value: "$" + fieldType.path}}},
+		}}},
+	}, options.Aggregate().SetHint(hint))
+	if err != nil {
+		return nil, mapError("aggregate user changes", err)
+	}
+	defer cursor.Close(ctx)
+
+	var changes []model.UserChange
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, mapError("decode user changes", err)
+	}
+	return changes, nil
+}
+
+// Reassign implements ActivityLog
+func (r *MongoActivityLog) Reassign(ctx context.Context, from, to string) (int64, error) {
+	return reassign(ctx, r.coll, "activity", from, to)
+}
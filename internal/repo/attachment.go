@@ -0,0 +1,131 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"datadog-golang-example/internal/model"
+)
+
+// errAttachmentNotFound is returned when no attachment matches the query
+var errAttachmentNotFound = fmt.Errorf("attachment %w", model.ErrNotFound)
+
+// maxAttachments bounds the attachments listed for a user
+const maxAttachments = 100
+
+// AttachmentRepository stores the metadata of user attachments
+type AttachmentRepository interface {
+	Create(ctx context.Context, a *model.Attachment) error
+	// List returns the attachments of a user, newest first
+	List(ctx context.Context, userID string) ([]model.Attachment, error)
+	Get(ctx context.Context, userID, id string) (*model.Attachment, error)
+	// DeleteByUser deletes the attachments of a user and returns how many
+	// there were
+	DeleteByUser(ctx context.Context, userID string) (int64, error)
+	// Keys returns the object keys of the attachments of a user
+	Keys(ctx context.Context, userID string) ([]string, error)
+	// Reassign moves the attachments of a user to another and returns
+	// how many were moved; their objects keep their keys
+	Reassign(ctx context.Context, from, to string) (int64, error)
+}
+
+// MongoAttachmentRepository is an AttachmentRepository backed by a
+// MongoDB collection
+type MongoAttachmentRepository struct {
+	coll *mongo.Collection
+}
+
+// NewMongoAttachmentRepository creates a repository for the given
+// collection
+func NewMongoAttachmentRepository(coll *mongo.Collection) *MongoAttachmentRepository {
+	return &MongoAttachmentRepository{coll: coll}
+}
+
+// Create inserts a new attachment
+func (r *MongoAttachmentRepository) Create(ctx context.Context, a *model.Attachment) error {
+	if _, err := r.coll.InsertOne(ctx, a); err != nil {
+		return mapError("insert attachment", err)
+	}
+	return nil
+}
+
+// List implements AttachmentRepository
+func (r *MongoAttachmentRepository) List(ctx context.Context, userID string) ([]model.Attachment, error) {
+	return retryRead(ctx, func() ([]model.Attachment, error) {
+		opts := options.Find().
+			SetSort(bson.D{{Key: fieldCreatedAt.path, Value: -1}}).
+			SetLimit(maxAttachments)
+		cursor, err := r.coll.Find(ctx, newQuery().eq(fieldUserID, str(userID)).filter(), opts)
+		if err != nil {
+			return nil, mapError("find attachments", err)
+		}
+		attachments := []model.Attachment{}
+		if err := cursor.All(ctx, &attachments); err != nil {
+			return nil, mapError("decode attachments", err)
+		}
+		return attachments, nil
+	})
+}
+
+// Get returns the attachment of a user with the given ID, or
+// model.ErrNotFound
+func (r *MongoAttachmentRepository) Get(ctx context.Context, userID, id string) (*model.Attachment, error) {
+	return retryRead(ctx, func() (*model.Attachment, error) {
+		var a model.Attachment
+		err := r.coll.FindOne(ctx, newQuery().eq(fieldID, str(id)).eq(fieldUserID, str(userID)).filter()).Decode(&a)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errAttachmentNotFound
+		}
+		if err != nil {
+			return nil, mapError("find attachment", err)
+		}
+		return &a, nil
+	})
+}
+
+// DeleteByUser implements AttachmentRepository
+func (r *MongoAttachmentRepository) DeleteByUser(ctx context.Context, userID string) (int64, error) {
+	result, err := r.coll.DeleteMany(ctx, newQuery().eq(fieldUserID, str(userID)).filter())
+	if err != nil {
+		return 0, mapError("delete attachments", err)
+	}
+	return result.DeletedCount, nil
+}
+
+// Keys implements AttachmentRepository
+func (r *MongoAttachmentRepository) Keys(ctx context.Context, userID string) ([]string, error) {
+	values, err := r.coll.Distinct(ctx, "key", newQuery().eq(fieldUserID, str(userID)).filter())
+	if err != nil {
+		return nil, mapError("find attachment keys", err)
+	}
+	keys := make([]string, 0, len(values))
+	for _, v := range values {
+		if key, ok := v.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Reassign implements AttachmentRepository
+func (r *MongoAttachmentRepository) Reassign(ctx context.Context, from, to string) (int64, error) {
+	return reassign(ctx, r.coll, "attachments", from, to)
+}
+
+// reassign moves the documents of coll whose user_id is from to the user
+// to, and returns how many it moved
+func reassign(ctx context.Context, coll *mongo.Collection, what, from, to string) (int64, error) {
+	result, err := coll.UpdateMany(ctx,
+		newQuery().eq(fieldUserID, str(from)).filter(),
+		bson.M{"$set": bson.M{fieldUserID.path: to}},
+	)
+	if err != nil {
+		return 0, mapError("reassign "+what, err)
+	}
+	return result.ModifiedCount, nil
+}
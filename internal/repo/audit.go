@@ -0,0 +1,91 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"datadog-golang-example/internal/model"
+)
+
+// errAuditSeqTaken is returned when another instance appended an entry
+// with the same sequence number first
+var errAuditSeqTaken = fmt.Errorf("audit entry sequence number taken: %w", model.ErrConflict)
+
+// AuditLog stores the entries of the audit trail by sequence number
+type AuditLog interface {
+	// Append inserts entry, or returns a model.ErrConflict when its
+	// sequence number is taken
+	Append(ctx context.Context, entry *model.AuditEntry) error
+	// Last returns the entry with the highest sequence number, nil when
+	// the trail is empty
+	Last(ctx context.Context) (*model.AuditEntry, error)
+	// All yields every entry in sequence order; an error ends it
+	All(ctx context.Context) iter.Seq2[model.AuditEntry, error]
+}
+
+// MongoAuditLog is an AuditLog backed by a MongoDB collection, whose _id
+// is the sequence number
+type MongoAuditLog struct {
+	coll *mongo.Collection
+}
+
+// NewMongoAuditLog creates an audit log for the given collection
+func NewMongoAuditLog(coll *mongo.Collection) *MongoAuditLog {
+	return &MongoAuditLog{coll: coll}
+}
+
+// Append implements AuditLog
+func (l *MongoAuditLog) Append(ctx context.Context, entry *model.AuditEntry) error {
+	_, err := l.coll.InsertOne(ctx, entry)
+	if mongo.IsDuplicateKeyError(err) {
+		return errAuditSeqTaken
+	}
+	return mapError("append audit entry", err)
+}
+
+// Last implements AuditLog
+func (l *MongoAuditLog) Last(ctx context.Context) (*model.AuditEntry, error) {
+	return retryRead(ctx, func() (*model.AuditEntry, error) {
+		var entry model.AuditEntry
+		err := l.coll.FindOne(ctx, bson.D{}, options.FindOne().SetSort(bson.D{{Key: fieldID.path, Value: -1}})).Decode(&entry)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, mapError("find last audit entry", err)
+		}
+		return &entry, nil
+	})
+}
+
+// All implements AuditLog
+func (l *MongoAuditLog) All(ctx context.Context) iter.Seq2[model.AuditEntry, error] {
+	return func(yield func(model.AuditEntry, error) bool) {
+		cursor, err := l.coll.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: fieldID.path, Value: 1}}))
+		if err != nil {
+			yield(model.AuditEntry{}, mapError("find audit entries", err))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var entry model.AuditEntry
+			if err := cursor.Decode(&entry); err != nil {
+				yield(model.AuditEntry{}, mapError("decode audit entry", err))
+				return
+			}
+			if !yield(entry, nil) {
+				return
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			yield(model.AuditEntry{}, mapError("read audit entries", err))
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Counter is a derived field of the user documents counting the
+// documents of another collection, by their user_id
+type Counter struct {
+	Name   string
+	field  field
+	source string
+}
+
+// Counters of the user documents
+var (
+	AttachmentsCounter = Counter{Name: "attachments_count", field: fieldAttachmentsCount, source: "attachments"}
+	Counters           = []Counter{AttachmentsCounter}
+)
+
+// CounterDrift is the outcome of reconciling a counter. Drifted users had
+// a wrong count, off by Delta in total, and Fixed of them were corrected;
+// the others were written to concurrently and are left for the next run.
+// Orphans are counted records whose user does not exist.
+type CounterDrift struct {
+	Counter string
+	Checked int
+	Drifted int
+	Fixed   int
+	Delta   int64
+	Orphans int
+}
+
+// UserCounters maintains the counters of the user documents of db
+type UserCounters struct {
+	db    *mongo.Database
+	users *mongo.Collection
+}
+
+// NewUserCounters creates the counters of the users of db
+func NewUserCounters(db *mongo.Database) *UserCounters {
+	return &UserCounters{db: db, users: db.Collection("users")}
+}
+
+// Add atomically adds delta to the counter of a user. Like any write, it
+// moves the version and the update time of the user, so a concurrent
+// replacement cannot overwrite the count it read and a revalidating
+// client sees the change.
+func (c *UserCounters) Add(ctx context.Context, counter Counter, userID string, delta int64) error {
+	result, err := c.users.UpdateOne(ctx,
+		newQuery().eq(fieldPublicID, str(userID)).filter(),
+		bson.M{
+			"$inc": bson.M{counter.field.path: delta, fieldVersion.path: 1},
+			"$set": bson.M{fieldUpdatedAt.path: time.Now()},
+		},
+	)
+	if err != nil {
+		return mapError("increment "+counter.Name, err)
+	}
+	if result.MatchedCount == 0 {
+		return errUserNotFound
+	}
+	return nil
+}
+
+// Reconcile recounts the records of counter and sets the count of every
+// user whose stored count differs. A count is only set if it has not
+// changed since it was read, so concurrent increments are never lost.
+func (c *UserCounters) Reconcile(ctx context.Context, counter Counter) (*CounterDrift, error) {
+	actual, err := c.recount(ctx, counter)
+	if err != nil {
+		return nil, err
+	}
+	drift := &CounterDrift{Counter: counter.Name}
+
+	// Users with a count, then users with records but no count
+	cursor, err := c.users.Find(ctx,
+		newQuery().gt(counter.field, num(0)).filter(),
+		options.Find().SetProjection(bson.M{fieldPublicID.path: 1, counter.field.path: 1}),
+	)
+	if err != nil {
+		return nil, mapError("find counted users", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return drift, mapError("decode user", err)
+		}
+		id, _ := doc[fieldPublicID.path].(string)
+		if err := c.fix(ctx, counter, drift, id, toInt64(doc[counter.field.path]), actual[id]); err != nil {
+			return drift, err
+		}
+		delete(actual, id)
+	}
+	if err := cursor.Err(); err != nil {
+		return drift, mapError("find counted users", err)
+	}
+
+	uncounted, err := c.existing(ctx, actual)
+	if err != nil {
+		return drift, err
+	}
+	drift.Orphans = len(actual) - len(uncounted)
+	for _, id := range uncounted {
+		if err := c.fix(ctx, counter, drift, id, 0, actual[id]); err != nil {
+			return drift, err
+		}
+	}
+	return drift, nil
+}
+
+// existing returns the IDs of counts whose user exists
+func (c *UserCounters) existing(ctx context.Context, counts map[string]int64) ([]string, error) {
+	if len(counts) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	values, err := c.users.Distinct(ctx, fieldPublicID.path, newQuery().in(fieldPublicID, strs(ids)).filter())
+	if err != nil {
+		return nil, mapError("find users", err)
+	}
+	found := make([]string, 0, len(values))
+	for _, v := range values {
+		if id, ok := v.(string); ok {
+			found = append(found, id)
+		}
+	}
+	return found, nil
+}
+
+// recount returns the number of records of counter of each user
+func (c *UserCounters) recount(ctx context.Context, counter Counter) (map[string]int64, error) {
+	return recount(ctx, c.db, counter)
+}
+
+// recount returns the number of records of counter in db of each user
+func recount(ctx context.Context, db *mongo.Database, counter Counter) (map[string]int64, error) {
+	cursor, err := db.Collection(counter.source).Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$" + fieldUserID.path, "n": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, mapError("count "+counter.source, err)
+	}
+	var groups []struct {
+		UserID string `bson:"_id"`
+		N      int64  `bson:"n"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, mapError("count "+counter.source, err)
+	}
+	counts := make(map[string]int64, len(groups))
+	for _, g := range groups {
+		counts[g.UserID] = g.N
+	}
+	return counts, nil
+}
+
+// fix sets the count of a user from stored to want, if they differ and
+// the stored count is still current, and records the outcome in drift
+func (c *UserCounters) fix(ctx context.Context, counter Counter, drift *CounterDrift, userID string, stored, want int64) error {
+	if !drift.check(stored, want) {
+		return nil
+	}
+	q := newQuery().eq(fieldPublicID, str(userID))
+	if stored == 0 {
+		q.in(counter.field, []value{num(0), null()})
+	} else {
+		q.eq(counter.field, num(stored))
+	}
+	result, err := c.users.UpdateOne(ctx, q.filter(),
+		bson.M{"$set": bson.M{counter.field.path: want, fieldUpdatedAt.path: time.Now()}, "$inc": bson.M{fieldVersion.path: 1}},
+	)
+	if err != nil {
+		return mapError("fix "+counter.Name, err)
+	}
+	drift.Fixed += int(result.MatchedCount)
+	return nil
+}
+
+// check records a count stored for a user which should be want, and
+// reports whether it drifted
+func (d *CounterDrift) check(stored, want int64) bool {
+	d.Checked++
+	if stored == want {
+		return false
+	}
+	d.Drifted++
+	d.Delta += abs(want - stored)
+	return true
+}
+
+// MySQLUserCounters maintains the counters of the users of a MySQL store,
+// whose counted records are in MongoDB
+type MySQLUserCounters struct {
+	db      *sql.DB
+	records *mongo.Database
+}
+
+// NewMySQLUserCounters creates the counters of the users of db, counting
+// the records of records
+func NewMySQLUserCounters(db *sql.DB, records *mongo.Database) *MySQLUserCounters {
+	return &MySQLUserCounters{db: db, records: records}
+}
+
+// Add atomically adds delta to the counter of a user, moving its version
+// and its update time as UserCounters.Add does
+func (c *MySQLUserCounters) Add(ctx context.Context, counter Counter, userID string, delta int64) error {
+	column := counter.field.path
+	result, err := c.db.ExecContext(ctx,
+		"UPDATE users SET "+column+" = "+column+" + ?, version = version + 1, updated_at = ? WHERE public_id = ?",
+		delta, time.Now().UTC(), userID)
+	if err != nil {
+		return mapMySQLError("increment "+counter.Name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return mapMySQLError("increment "+counter.Name, err)
+	}
+	if n == 0 {
+		return errUserNotFound
+	}
+	return nil
+}
+
+// Reconcile recounts the records of counter and sets the count of every
+// user whose stored count differs, as UserCounters.Reconcile does
+func (c *MySQLUserCounters) Reconcile(ctx context.Context, counter Counter) (*CounterDrift, error) {
+	actual, err := recount(ctx, c.records, counter)
+	if err != nil {
+		return nil, err
+	}
+	drift := &CounterDrift{Counter: counter.Name}
+
+	// Users with a count, then users with records but no count. The
+	// counts are read before being fixed, so the fixes do not wait for the
+	// connection of the rows in a small pool.
+	column := counter.field.path
+	rows, err := c.db.QueryContext(ctx, "SELECT public_id, "+column+" FROM users WHERE "+column+" > 0")
+	if err != nil {
+		return nil, mapMySQLError("find counted users", err)
+	}
+	stored := map[string]int64{}
+	for rows.Next() {
+		var id string
+		var n int64
+		if err := rows.Scan(&id, &n); err != nil {
+			rows.Close()
+			return nil, mapMySQLError("find counted users", err)
+		}
+		stored[id] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, mapMySQLError("find counted users", err)
+	}
+	for id, n := range stored {
+		if err := c.fix(ctx, counter, drift, id, n, actual[id]); err != nil {
+			return drift, err
+		}
+		delete(actual, id)
+	}
+
+	uncounted, err := c.existing(ctx, actual)
+	if err != nil {
+		return drift, err
+	}
+	drift.Orphans = len(actual) - len(uncounted)
+	for _, id := range uncounted {
+		if err := c.fix(ctx, counter, drift, id, 0, actual[id]); err != nil {
+			return drift, err
+		}
+	}
+	return drift, nil
+}
+
+// existing returns the IDs of counts whose user exists
+func (c *MySQLUserCounters) existing(ctx context.Context, counts map[string]int64) ([]string, error) {
+	if len(counts) == 0 {
+		return nil, nil
+	}
+	ids := make([]any, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	rows, err := c.db.QueryContext(ctx, "SELECT public_id FROM users WHERE public_id IN ("+placeholders(len(ids))+")", ids...)
+	if err != nil {
+		return nil, mapMySQLError("find users", err)
+	}
+	defer rows.Close()
+	found := make([]string, 0, len(ids))
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, mapMySQLError("find users", err)
+		}
+		found = append(found, id)
+	}
+	return found, mapMySQLError("find users", rows.Err())
+}
+
+// fix sets the count of a user from stored to want, if they differ and
+// the stored count is still current, and records the outcome in drift
+func (c *MySQLUserCounters) fix(ctx context.Context, counter Counter, drift *CounterDrift, userID string, stored, want int64) error {
+	if !drift.check(stored, want) {
+		return nil
+	}
+	column := counter.field.path
+	result, err := c.db.ExecContext(ctx,
+		"UPDATE users SET "+column+" = ?, version = version + 1, updated_at = ? WHERE public_id = ? AND "+column+" = ?",
+		want, time.Now().UTC(), userID, stored)
+	if err != nil {
+		return mapMySQLError("fix "+counter.Name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return mapMySQLError("fix "+counter.Name, err)
+	}
+	drift.Fixed += int(n)
+	return nil
+}
+
+// toInt64 converts a number decoded from BSON
+func toInt64(v any) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+// abs returns the absolute value of n
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
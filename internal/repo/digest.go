@@ -0,0 +1,128 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"datadog-golang-example/internal/model"
+)
+
+// SignupStats aggregates the users created over a period
+type SignupStats struct {
+	users *mongo.Collection
+}
+
+// NewSignupStats creates the signup statistics of the users of db
+func NewSignupStats(db *mongo.Database) *SignupStats {
+	return &SignupStats{users: db.Collection("users")}
+}
+
+// ByOrg counts the users created from since until before in each
+// organization, with its name, the largest first
+func (s *SignupStats) ByOrg(ctx context.Context, since, before time.Time) ([]model.OrgSignups, error) {
+	return retryRead(ctx, func() ([]model.OrgSignups, error) {
+		cursor, err := s.users.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: newQuery().between(fieldCreatedAt, since, before).filter()}},
+			{{Key: "$group", Value: bson.M{"_id": bson.M{"$ifNull": bson.A{"$" + fieldOrgID.path, ""}}, "users": bson.M{"$sum": 1}}}},
+			{{Key: "$lookup", Value: bson.M{
+				"from":         "organizations",
+				"localField":   "_id",
+				"foreignField": fieldPublicID.path,
+				"as":           "org",
+			}}},
+			{{Key: "$project", Value: bson.M{
+				"_id":    0,
+				"org_id": "$_id",
+				"users":  1,
+				"name":   bson.M{"$ifNull": bson.A{bson.M{"$first": "$org.name"}, ""}},
+			}}},
+			{{Key: "$sort", Value: bson.D{{Key: "users", Value: -1}, {Key: "org_id", Value: 1}}}},
+		})
+		if err != nil {
+			return nil, mapError("aggregate signups", err)
+		}
+		signups := []model.OrgSignups{}
+		if err := cursor.All(ctx, &signups); err != nil {
+			return nil, mapError("aggregate signups", err)
+		}
+		return signups, nil
+	})
+}
+
+// MySQLSignupStats aggregates the users of a MySQL store created over a
+// period, their organizations being in MongoDB
+type MySQLSignupStats struct {
+	db   *sql.DB
+	orgs *mongo.Collection
+}
+
+// NewMySQLSignupStats creates the signup statistics of the users of db,
+// whose organizations are in orgs
+func NewMySQLSignupStats(db *sql.DB, orgs *mongo.Collection) *MySQLSignupStats {
+	return &MySQLSignupStats{db: db, orgs: orgs}
+}
+
+// ByOrg counts the users created from since until before in each
+// organization, with its name, the largest first
+func (s *MySQLSignupStats) ByOrg(ctx context.Context, since, before time.Time) ([]model.OrgSignups, error) {
+	w := new(mysqlWhere)
+	if !since.IsZero() {
+		w.add("created_at >= ?", since.UTC())
+	}
+	if !before.IsZero() {
+		w.add("created_at < ?", before.UTC())
+	}
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT COALESCE(org_id, ''), COUNT(*) AS users FROM users"+w.String()+" GROUP BY org_id ORDER BY users DESC, org_id",
+		w.args...)
+	if err != nil {
+		return nil, mapMySQLError("aggregate signups", err)
+	}
+	defer rows.Close()
+	signups := []model.OrgSignups{}
+	var orgIDs []string
+	for rows.Next() {
+		var signup model.OrgSignups
+		if err := rows.Scan(&signup.OrgID, &signup.Users); err != nil {
+			return nil, mapMySQLError("aggregate signups", err)
+		}
+		signups = append(signups, signup)
+		if signup.OrgID != "" {
+			orgIDs = append(orgIDs, signup.OrgID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapMySQLError("aggregate signups", err)
+	}
+	if len(orgIDs) == 0 {
+		return signups, nil
+	}
+
+	// The organizations are named from MongoDB, as the pipeline of
+	// SignupStats looks them up
+	orgs, err := retryRead(ctx, func() ([]model.Organization, error) {
+		cursor, err := s.orgs.Find(ctx, newQuery().in(fieldPublicID, strs(orgIDs)).filter(),
+			options.Find().SetProjection(bson.M{fieldPublicID.path: 1, "name": 1}))
+		if err != nil {
+			return nil, mapError("find organizations", err)
+		}
+		var orgs []model.Organization
+		return orgs, mapError("find organizations", cursor.All(ctx, &orgs))
+	})
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(orgs))
+	for _, org := range orgs {
+		names[org.PublicID] = org.Name
+	}
+	for i := range signups {
+		signups[i].Name = names[signups[i].OrgID]
+	}
+	return signups, nil
+}
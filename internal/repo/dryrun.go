@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"datadog-golang-example/internal/model"
+)
+
+// DryRunUserRepository wraps a UserRepository for dry runs: reads go to
+// the wrapped repository, while writes only run the checks the real write
+// would fail on (missing user, stale version, unique fields) and report
+// the outcome without changing anything.
+type DryRunUserRepository struct {
+	UserRepository
+}
+
+// NewDryRunUserRepository creates a dry-run view of r
+func NewDryRunUserRepository(r UserRepository) *DryRunUserRepository {
+	return &DryRunUserRepository{UserRepository: r}
+}
+
+// EnsureIndexes does nothing
+func (r *DryRunUserRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+// Create checks the unique fields of user and assigns its ObjectID
+func (r *DryRunUserRepository) Create(ctx context.Context, user *model.User) error {
+	if err := r.checkUnique(ctx, user); err != nil {
+		return err
+	}
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	return nil
+}
+
+// Update returns the user as it would be after update
+func (r *DryRunUserRepository) Update(ctx context.Context, ref model.UserRef, update model.UserUpdate) (*model.User, error) {
+	user, err := r.Get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err := updatable(user); err != nil {
+		return nil, err
+	}
+	updated := user.Applied(update)
+	if update.Email != nil {
+		if err := r.checkEmail(ctx, updated); err != nil {
+			return nil, err
+		}
+	}
+	return updated, nil
+}
+
+// BulkUpdate reports the updates as applied, except those that would
+// violate a unique field
+func (r *DryRunUserRepository) BulkUpdate(ctx context.Context, updates []model.BulkUpdate) (*model.BulkUpdateResult, error) {
+	result := &model.BulkUpdateResult{Errors: []model.BulkItemError{}}
+	for _, u := range updates {
+		_, err := r.Update(ctx, u.Ref, u.Update)
+		if errors.Is(err, model.ErrConflict) {
+			result.Errors = append(result.Errors, model.BulkItemError{Index: u.Index, ID: u.Ref.String(), Err: err})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Matched++
+		result.Modified++
+	}
+	return result, nil
+}
+
+// Replace runs the version and unique field checks of a replace
+func (r *DryRunUserRepository) Replace(ctx context.Context, user *model.User, version int64, upsert bool) (bool, error) {
+	existing, err := r.Get(ctx, model.UserRef{PublicID: user.PublicID})
+	switch {
+	case errors.Is(err, model.ErrNotFound) && upsert:
+		return true, r.checkUnique(ctx, user)
+	case err != nil:
+		return false, err
+	case !upsert && existing.Version != version:
+		return false, errUserNotFound
+	}
+	return false, r.checkEmail(ctx, user)
+}
+
+// Delete checks that the user exists
+func (r *DryRunUserRepository) Delete(ctx context.Context, ref model.UserRef) error {
+	_, err := r.Get(ctx, ref)
+	return err
+}
+
+// checkUnique reports a ConflictError when another user holds the public
+// ID, username or email of user
+func (r *DryRunUserRepository) checkUnique(ctx context.Context, user *model.User) error {
+	if _, err := r.Get(ctx, model.UserRef{PublicID: user.PublicID}); !errors.Is(err, model.ErrNotFound) {
+		if err == nil {
+			return &model.ConflictError{Field: "public_id"}
+		}
+		return err
+	}
+	if _, err := r.GetByUsername(ctx, user.Username); !errors.Is(err, model.ErrNotFound) {
+		if err == nil {
+			return &model.ConflictError{Field: "username"}
+		}
+		return err
+	}
+	return r.checkEmail(ctx, user)
+}
+
+// checkEmail reports a ConflictError when a different user holds the
+// email of user
+func (r *DryRunUserRepository) checkEmail(ctx context.Context, user *model.User) error {
+	if user.Email == "" {
+		return nil
+	}
+	other, err := r.GetByEmail(ctx, user.Email)
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		return nil
+	case err != nil:
+		return err
+	case other.PublicID != user.PublicID:
+		return &model.ConflictError{Field: "email"}
+	}
+	return nil
+}
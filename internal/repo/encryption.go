@@ -0,0 +1,165 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"datadog-golang-example/internal/fieldcrypt"
+	"datadog-golang-example/internal/model"
+)
+
+// storedUser is a user document as stored, with the blind index of its
+// email when it is encrypted
+type storedUser struct {
+	model.User `bson:",inline"`
+	EmailHash  string `bson:"email_hash,omitempty"`
+}
+
+// sealed returns the user as it is stored, with its email encrypted
+func (r *MongoUserRepository) sealed(user *model.User) *storedUser {
+	if r.opts.Encryption == nil {
+		return &storedUser{User: *user}
+	}
+	stored := storedUser{User: *user, EmailHash: r.opts.Encryption.BlindIndex(user.Email)}
+	stored.Email = r.opts.Encryption.Encrypt(user.Email)
+	return &stored
+}
+
+// open decrypts the email of a user read from the collection
+func (r *MongoUserRepository) open(user *model.User) error {
+	if r.opts.Encryption == nil {
+		return nil
+	}
+	email, err := r.opts.Encryption.Decrypt(user.Email)
+	if err != nil {
+		return fmt.Errorf("decrypt email of user %s: %w", user.PublicID, err)
+	}
+	user.Email = email
+	return nil
+}
+
+// openAll decrypts the email of a page of users
+func (r *MongoUserRepository) openAll(users []model.User) error {
+	for i := range users {
+		if err := r.open(&users[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sealedEmail returns the stored form of a new email
+func (r *MongoUserRepository) sealedEmail(email string) string {
+	if r.opts.Encryption == nil {
+		return email
+	}
+	return r.opts.Encryption.Encrypt(email)
+}
+
+// emailHash returns the blind index of a new email, empty when emails are
+// stored in clear
+func (r *MongoUserRepository) emailHash(email string) string {
+	if r.opts.Encryption == nil {
+		return ""
+	}
+	return r.opts.Encryption.BlindIndex(email)
+}
+
+// whereEmail adds to q a condition matching email in any form it may be
+// stored in
+func (r *MongoUserRepository) whereEmail(q *query, email string) *query {
+	if r.opts.Encryption == nil {
+		return q.eq(fieldEmail, str(email))
+	}
+	return q.in(fieldEmail, strs(r.opts.Encryption.Candidates(email)))
+}
+
+// RotateEmails re-encrypts with the current key every email of coll that
+// is in clear or under an older key, or lacks its blind index, and returns
+// how many it rewrote. Until it completes, queries match emails under
+// every key of keys.
+func RotateEmails(ctx context.Context, coll *mongo.Collection, keys *fieldcrypt.Keyring) (int, error) {
+	cursor, err := coll.Find(ctx, bson.M{"email": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, mapError("find users", err)
+	}
+	defer cursor.Close(ctx)
+
+	rotated := 0
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID        any    `bson:"_id"`
+			Email     string `bson:"email"`
+			EmailHash string `bson:"email_hash"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return rotated, mapError("decode user", err)
+		}
+		if !keys.Stale(doc.Email) && doc.EmailHash != "" {
+			continue
+		}
+		email, err := keys.Decrypt(doc.Email)
+		if err != nil {
+			return rotated, err
+		}
+		// Matching the old value skips users updated since they were read
+		_, err = coll.UpdateOne(ctx,
+			bson.M{"_id": doc.ID, "email": doc.Email},
+			bson.M{"$set": bson.M{"email": keys.Encrypt(email), "email_hash": keys.BlindIndex(email)}},
+		)
+		if err != nil {
+			return rotated, mapError("rotate email", err)
+		}
+		rotated++
+	}
+	return rotated, mapError("find users", cursor.Err())
+}
+
+// RotateMySQLEmails is RotateEmails for the users table of a MySQL store
+func RotateMySQLEmails(ctx context.Context, db *sql.DB, keys *fieldcrypt.Keyring) (int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, email, email_hash FROM users WHERE email IS NOT NULL")
+	if err != nil {
+		return 0, mapMySQLError("find users", err)
+	}
+	// Read first, so the rewrites do not wait for the connection of the
+	// rows in a small pool
+	type stored struct {
+		id, email string
+	}
+	var stale []stored
+	for rows.Next() {
+		var row stored
+		var hash sql.NullString
+		if err := rows.Scan(&row.id, &row.email, &hash); err != nil {
+			rows.Close()
+			return 0, mapMySQLError("find users", err)
+		}
+		if keys.Stale(row.email) || !hash.Valid {
+			stale = append(stale, row)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, mapMySQLError("find users", err)
+	}
+
+	rotated := 0
+	for _, row := range stale {
+		email, err := keys.Decrypt(row.email)
+		if err != nil {
+			return rotated, err
+		}
+		// Matching the old value skips users updated since they were read
+		_, err = db.ExecContext(ctx, "UPDATE users SET email = ?, email_hash = ? WHERE id = ? AND email = ?",
+			keys.Encrypt(email), nullString(keys.BlindIndex(email)), row.id, row.email)
+		if err != nil {
+			return rotated, mapMySQLError("rotate email", err)
+		}
+		rotated++
+	}
+	return rotated, nil
+}
@@ -0,0 +1,30 @@
+package repo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"datadog-golang-example/internal/model"
+)
+
+// ErasureLog keeps the record of every erasure
+type ErasureLog interface {
+	Record(ctx context.Context, erasure *model.Erasure) error
+}
+
+// MongoErasureLog is an ErasureLog backed by a MongoDB collection
+type MongoErasureLog struct {
+	coll *mongo.Collection
+}
+
+// NewMongoErasureLog creates an erasure log for the given collection
+func NewMongoErasureLog(coll *mongo.Collection) *MongoErasureLog {
+	return &MongoErasureLog{coll: coll}
+}
+
+// Record inserts erasure
+func (l *MongoErasureLog) Record(ctx context.Context, erasure *model.Erasure) error {
+	_, err := l.coll.InsertOne(ctx, erasure)
+	return mapError("record erasure", err)
+}
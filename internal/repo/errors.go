@@ -0,0 +1,66 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+
+	"datadog-golang-example/internal/model"
+)
+
+// errUserNotFound is returned when no user matches the query
+var errUserNotFound = fmt.Errorf("user %w", model.ErrNotFound)
+
+// ErrUserErased is returned when updating an erased user, which would
+// write personal data back onto it
+var ErrUserErased = fmt.Errorf("erased users cannot be updated: %w", model.ErrConflict)
+
+// updatable returns ErrUserErased when user is erased
+func updatable(user *model.User) error {
+	if user.ErasedAt != nil {
+		return ErrUserErased
+	}
+	return nil
+}
+
+// duplicateIndex extracts the index name from an E11000 error message
+var duplicateIndex = regexp.MustCompile(`index: (\S+)`)
+
+// duplicateField returns the field whose unique index was violated
+func duplicateField(err error) string {
+	m := duplicateIndex.FindStringSubmatch(err.Error())
+	if m == nil {
+		return "document"
+	}
+	if field, ok := uniqueIndexFields[m[1]]; ok {
+		return field
+	}
+	return m[1]
+}
+
+// mapError translates a MongoDB driver error into a domain error while
+// keeping the original error in the chain
+func mapError(op string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return errUserNotFound
+	case errors.Is(err, context.Canceled):
+		// Cancelled by the caller, not a failure of the database
+		return fmt.Errorf("%s: %w", op, err)
+	case isFailover(err):
+		return fmt.Errorf("%s: %w: %w", op, model.ErrElection, err)
+	case mongo.IsDuplicateKeyError(err):
+		return fmt.Errorf("%s: %w: %w", op, &model.ConflictError{Field: duplicateField(err)}, err)
+	case mongo.IsTimeout(err), mongo.IsNetworkError(err),
+		errors.Is(err, context.DeadlineExceeded), errors.As(err, new(topology.ServerSelectionError)):
+		return fmt.Errorf("%s: %w: %w", op, model.ErrUnavailable, err)
+	default:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+}
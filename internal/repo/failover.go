@@ -0,0 +1,127 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/description"
+
+	"datadog-golang-example/internal/model"
+)
+
+// failoverCodes are the server errors of a primary that stepped down or
+// of a member that is no longer primary
+var failoverCodes = []int{
+	91,    // ShutdownInProgress
+	189,   // PrimarySteppedDown
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// isFailover reports whether err comes from a replica set changing its
+// primary
+func isFailover(err error) bool {
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	for _, code := range failoverCodes {
+		if serverErr.HasErrorCode(code) {
+			return true
+		}
+	}
+	return serverErr.HasErrorMessage("not primary") || serverErr.HasErrorMessage("not master")
+}
+
+// Reads failing during an election are retried, by which time the driver
+// has usually found the new primary
+const (
+	failoverReadAttempts = 3
+	failoverBackoff      = 250 * time.Millisecond
+)
+
+// retryRead runs read again while it fails with model.ErrElection, with a
+// growing backoff. Each retry is an event of the span in ctx.
+func retryRead[T any](ctx context.Context, read func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		v, err := read()
+		if !errors.Is(err, model.ErrElection) || attempt == failoverReadAttempts {
+			return v, err
+		}
+		if span, ok := tracer.SpanFromContext(ctx); ok {
+			span.AddEvent("mongodb.failover_retry", tracer.WithSpanEventAttributes(map[string]any{"attempt": attempt}))
+		}
+		select {
+		case <-ctx.Done():
+			return v, err
+		case <-time.After(time.Duration(attempt) * failoverBackoff):
+		}
+	}
+}
+
+// FailoverMonitor follows the primary of a replica set through the
+// topology events of a client. It counts mongodb.primary.lost when the set
+// loses its primary and mongodb.failover when another member takes over,
+// timing the election in mongodb.failover.duration.
+type FailoverMonitor struct {
+	tags    []string
+	metrics statsd.ClientInterface
+
+	mu      sync.Mutex
+	primary string
+	lostAt  time.Time
+}
+
+// NewFailoverMonitor creates the monitor of a client; name tells the
+// clients of one process apart
+func NewFailoverMonitor(name string, metrics statsd.ClientInterface) *FailoverMonitor {
+	return &FailoverMonitor{tags: []string{"client:" + name}, metrics: metrics}
+}
+
+// Monitor returns the server monitor to set on the client
+func (m *FailoverMonitor) Monitor() *event.ServerMonitor {
+	return &event.ServerMonitor{TopologyDescriptionChanged: m.changed}
+}
+
+// changed compares the primary of the new topology with the last one
+func (m *FailoverMonitor) changed(e *event.TopologyDescriptionChangedEvent) {
+	if e.NewDescription.Kind != description.ReplicaSetWithPrimary && e.NewDescription.Kind != description.ReplicaSetNoPrimary {
+		return
+	}
+	primary := ""
+	for _, s := range e.NewDescription.Servers {
+		if s.Kind == description.RSPrimary {
+			primary = s.Addr.String()
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case primary == m.primary:
+		return
+	case primary == "":
+		log.Printf("WARNING: MongoDB replica set lost its primary %s", m.primary)
+		m.lostAt = time.Now()
+		_ = m.metrics.Incr("mongodb.primary.lost", m.tags, 1)
+	case m.primary != "" || !m.lostAt.IsZero():
+		log.Printf("MongoDB primary moved to %s", primary)
+		if m.lostAt.IsZero() {
+			m.lostAt = time.Now()
+		}
+		_ = m.metrics.Incr("mongodb.failover", m.tags, 1)
+		_ = m.metrics.Timing("mongodb.failover.duration", time.Since(m.lostAt), m.tags, 1)
+		m.lostAt = time.Time{}
+	}
+	m.primary = primary
+}
@@ -0,0 +1,207 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"datadog-golang-example/internal/model"
+)
+
+// Operations of the user writes told to the hooks running after them
+const (
+	OpCreate  = "create"
+	OpUpdate  = "update"
+	OpReplace = "replace"
+	OpDelete  = "delete"
+)
+
+// UserHooks run around the writes of a HookedUserRepository, so what every
+// write of a user needs, such as its timestamps, version and schema
+// version, is done in one place. Before hooks run in registration order
+// and may change what is written; an error fails the write. After hooks
+// run once a write succeeded, except in dry runs. Hooks are registered
+// while wiring, before the repository is used.
+type UserHooks struct {
+	beforeCreate  []func(ctx context.Context, user *model.User) error
+	beforeUpdate  []func(ctx context.Context, update *model.UserUpdate) error
+	beforeReplace []func(ctx context.Context, user *model.User, version int64) error
+	afterWrite    []func(ctx context.Context, op string, ref model.UserRef)
+}
+
+// NewUserHooks returns the hooks stamping the users written with their
+// timestamps, version and schema version
+func NewUserHooks() *UserHooks {
+	h := &UserHooks{}
+	h.BeforeCreate(stampCreate)
+	h.BeforeUpdate(stampUpdate)
+	h.BeforeReplace(stampReplace)
+	return h
+}
+
+// BeforeCreate registers hook to run before a user is inserted
+func (h *UserHooks) BeforeCreate(hook func(ctx context.Context, user *model.User) error) {
+	h.beforeCreate = append(h.beforeCreate, hook)
+}
+
+// BeforeUpdate registers hook to run before a partial update, alone or in
+// a bulk update, is applied
+func (h *UserHooks) BeforeUpdate(hook func(ctx context.Context, update *model.UserUpdate) error) {
+	h.beforeUpdate = append(h.beforeUpdate, hook)
+}
+
+// BeforeReplace registers hook to run before a user replaces the one
+// stored at version, zero for a user upserted
+func (h *UserHooks) BeforeReplace(hook func(ctx context.Context, user *model.User, version int64) error) {
+	h.beforeReplace = append(h.beforeReplace, hook)
+}
+
+// AfterWrite registers hook to run after a write of op, one of the Op
+// constants, to the referenced user. The write has already succeeded, so
+// hooks have no error to return.
+func (h *UserHooks) AfterWrite(hook func(ctx context.Context, op string, ref model.UserRef)) {
+	h.afterWrite = append(h.afterWrite, hook)
+}
+
+// stampCreate gives a new user its first version and the current schema
+// version, updated when created. A creation time already set is kept.
+func stampCreate(_ context.Context, user *model.User) error {
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	user.UpdatedAt = user.CreatedAt
+	user.Version = 1
+	user.SchemaVersion = userSchemaVersion
+	return nil
+}
+
+// stampUpdate updates a user now; the update increments its version
+func stampUpdate(_ context.Context, update *model.UserUpdate) error {
+	update.UpdatedAt = time.Now()
+	return nil
+}
+
+// stampReplace gives a replacing user the version after the one it
+// replaces and the current schema version, updated now. Upserted users
+// are created now too.
+func stampReplace(_ context.Context, user *model.User, version int64) error {
+	now := time.Now()
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	user.Version = version + 1
+	user.SchemaVersion = userSchemaVersion
+	return nil
+}
+
+// HookedUserRepository wraps a UserRepository to run hooks around its
+// writes; reads go to the wrapped repository
+type HookedUserRepository struct {
+	UserRepository
+	hooks *UserHooks
+}
+
+// NewHookedUserRepository creates a view of r running hooks
+func NewHookedUserRepository(r UserRepository, hooks *UserHooks) *HookedUserRepository {
+	return &HookedUserRepository{UserRepository: r, hooks: hooks}
+}
+
+// Create implements UserRepository
+func (r *HookedUserRepository) Create(ctx context.Context, user *model.User) error {
+	for _, hook := range r.hooks.beforeCreate {
+		if err := hook(ctx, user); err != nil {
+			return err
+		}
+	}
+	if err := r.UserRepository.Create(ctx, user); err != nil {
+		return err
+	}
+	r.after(ctx, OpCreate, model.UserRef{PublicID: user.PublicID})
+	return nil
+}
+
+// Update implements UserRepository
+func (r *HookedUserRepository) Update(ctx context.Context, ref model.UserRef, update model.UserUpdate) (*model.User, error) {
+	if err := r.beforeUpdate(ctx, &update); err != nil {
+		return nil, err
+	}
+	user, err := r.UserRepository.Update(ctx, ref, update)
+	if err != nil {
+		return nil, err
+	}
+	r.after(ctx, OpUpdate, model.UserRef{PublicID: user.PublicID})
+	return user, nil
+}
+
+// BulkUpdate implements UserRepository. The after hooks run for the
+// updates that were not reported as failed.
+func (r *HookedUserRepository) BulkUpdate(ctx context.Context, updates []model.BulkUpdate) (*model.BulkUpdateResult, error) {
+	updates = append([]model.BulkUpdate(nil), updates...)
+	for i := range updates {
+		if err := r.beforeUpdate(ctx, &updates[i].Update); err != nil {
+			return nil, err
+		}
+	}
+	result, err := r.UserRepository.BulkUpdate(ctx, updates)
+	if err != nil {
+		return nil, err
+	}
+	failed := make(map[int]bool, len(result.Errors))
+	for _, e := range result.Errors {
+		failed[e.Index] = true
+	}
+	for _, u := range updates {
+		if !failed[u.Index] {
+			r.after(ctx, OpUpdate, u.Ref)
+		}
+	}
+	return result, nil
+}
+
+// Replace implements UserRepository
+func (r *HookedUserRepository) Replace(ctx context.Context, user *model.User, version int64, upsert bool) (bool, error) {
+	for _, hook := range r.hooks.beforeReplace {
+		if err := hook(ctx, user, version); err != nil {
+			return false, err
+		}
+	}
+	created, err := r.UserRepository.Replace(ctx, user, version, upsert)
+	if err != nil {
+		return false, err
+	}
+	op := OpReplace
+	if created {
+		op = OpCreate
+	}
+	r.after(ctx, op, model.UserRef{PublicID: user.PublicID})
+	return created, nil
+}
+
+// Delete implements UserRepository
+func (r *HookedUserRepository) Delete(ctx context.Context, ref model.UserRef) error {
+	if err := r.UserRepository.Delete(ctx, ref); err != nil {
+		return err
+	}
+	r.after(ctx, OpDelete, ref)
+	return nil
+}
+
+// beforeUpdate runs the before hooks of a partial update
+func (r *HookedUserRepository) beforeUpdate(ctx context.Context, update *model.UserUpdate) error {
+	for _, hook := range r.hooks.beforeUpdate {
+		if err := hook(ctx, update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// after runs the after hooks of a write, unless in a dry run
+func (r *HookedUserRepository) after(ctx context.Context, op string, ref model.UserRef) {
+	if model.IsDryRun(ctx) {
+		return
+	}
+	for _, hook := range r.hooks.afterWrite {
+		hook(ctx, op, ref)
+	}
+}
@@ -0,0 +1,116 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"datadog-golang-example/internal/model"
+)
+
+// Import upserts the users by email in one unordered bulk write. A user
+// whose email is taken is updated: its name and age are replaced and its
+// tags added to. Otherwise it is inserted with its public ID, username and
+// creation time. Writes that fail, such as a taken username, are reported
+// per row; the others are still applied. The public IDs of the updated
+// users are read back by email, as the bulk write does not return them.
+func (r *MongoUserRepository) Import(ctx context.Context, users []model.ImportedUser) (*model.ImportResult, error) {
+	models := make([]mongo.WriteModel, len(users))
+	for i, u := range users {
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(r.whereEmail(newQuery(), u.User.Email).filter()).
+			SetUpdate(r.importDoc(&u.User)).
+			SetUpsert(true)
+	}
+
+	result := &model.ImportResult{Rows: len(users), Errors: []model.ImportRowError{}}
+	res, err := r.coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	var bulkErr mongo.BulkWriteException
+	if err != nil && !(errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil) {
+		return nil, mapError("import users", err)
+	}
+
+	written := make([]bool, len(users))
+	for i := range written {
+		written[i] = true
+	}
+	for _, we := range bulkErr.WriteErrors {
+		u := users[we.Index]
+		written[we.Index] = false
+		result.Errors = append(result.Errors, model.ImportRowError{
+			Row:   u.Row,
+			Email: u.User.Email,
+			Err:   mapError("import user", we),
+		})
+	}
+	result.Created, result.Updated = res.UpsertedCount, res.MatchedCount
+	var matched []*query
+	for i, u := range users {
+		if _, created := res.UpsertedIDs[int64(i)]; created {
+			result.CreatedIDs = append(result.CreatedIDs, u.User.PublicID)
+		} else if written[i] {
+			matched = append(matched, r.whereEmail(newQuery(), u.User.Email))
+		}
+	}
+	if result.Updated > 0 && len(matched) > 0 {
+		if result.UpdatedIDs, err = r.importedIDs(ctx, newQuery().or(matched...)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// importedIDs returns the public IDs of the users matching q
+func (r *MongoUserRepository) importedIDs(ctx context.Context, q *query) ([]string, error) {
+	cursor, err := r.coll.Find(ctx, q.filter(), options.Find().SetProjection(bson.M{fieldPublicID.path: 1}))
+	if err != nil {
+		return nil, mapError("find imported users", err)
+	}
+	defer cursor.Close(ctx)
+	var ids []string
+	for cursor.Next(ctx) {
+		var doc struct {
+			PublicID string `bson:"public_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return ids, mapError("decode user", err)
+		}
+		ids = append(ids, doc.PublicID)
+	}
+	if err := cursor.Err(); err != nil {
+		return ids, mapError("find imported users", err)
+	}
+	return ids, nil
+}
+
+// importDoc builds the upsert document of an imported user
+func (r *MongoUserRepository) importDoc(user *model.User) bson.M {
+	set := bson.M{
+		"name":       user.Name,
+		"name_key":   user.NameKey,
+		"email":      r.sealedEmail(user.Email),
+		"age":        user.Age,
+		"updated_at": user.UpdatedAt,
+	}
+	if hash := r.emailHash(user.Email); hash != "" {
+		set["email_hash"] = hash
+	}
+	doc := bson.M{
+		"$set": set,
+		"$setOnInsert": bson.M{
+			"_id":            user.ID,
+			"public_id":      user.PublicID,
+			"username":       user.Username,
+			"created_at":     user.CreatedAt,
+			"schema_version": userSchemaVersion,
+		},
+		"$inc": bson.M{"version": 1},
+	}
+	if len(user.Tags) > 0 {
+		doc["$addToSet"] = bson.M{"tags": bson.M{"$each": user.Tags}}
+	}
+	return doc
+}
@@ -0,0 +1,254 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IndexSpec describes an index the application relies on
+type IndexSpec struct {
+	Collection string
+	Keys       bson.D
+	Unique     bool
+	Sparse     bool
+}
+
+// Name is the default name MongoDB gives the index
+func (s IndexSpec) Name() string {
+	return indexName(s.Keys)
+}
+
+// String identifies the index in logs, as collection.name
+func (s IndexSpec) String() string {
+	return s.Collection + "." + s.Name()
+}
+
+// model returns the index to create
+func (s IndexSpec) model() mongo.IndexModel {
+	opts := options.Index()
+	if s.Unique {
+		opts.SetUnique(true)
+	}
+	if s.Sparse {
+		opts.SetSparse(true)
+	}
+	return mongo.IndexModel{Keys: s.Keys, Options: opts}
+}
+
+// Indexes is the registry of every index the application requires
+var Indexes = []IndexSpec{
+	// Sparse so documents created before public IDs existed don't collide
+	{Collection: "users", Keys: bson.D{{Key: "public_id", Value: 1}}, Unique: true, Sparse: true},
+	{Collection: "users", Keys: bson.D{{Key: "username", Value: 1}}, Unique: true, Sparse: true},
+	// Email is optional, hence sparse
+	{Collection: "users", Keys: bson.D{{Key: "email", Value: 1}}, Unique: true, Sparse: true},
+	// An email encrypts differently under each key; its blind index keeps
+	// it unique while keys are rotated
+	{Collection: "users", Keys: bson.D{{Key: "email_hash", Value: 1}}, Unique: true, Sparse: true},
+	// Serves anchored prefix queries for suggestions
+	{Collection: "users", Keys: bson.D{{Key: "name_key", Value: 1}}},
+	// Multikey, one entry per tag, for filtering lists by tag
+	{Collection: "users", Keys: bson.D{{Key: "tags", Value: 1}}},
+	// Required by $geoNear; users without a location are skipped
+	{Collection: "users", Keys: bson.D{{Key: "location", Value: "2dsphere"}}},
+	// Serve the member lists of an organization in username or name order
+	{Collection: "users", Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "username", Value: 1}}},
+	{Collection: "users", Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "name_key", Value: 1}}},
+	// The users created over a period, for the digest
+	{Collection: "users", Keys: bson.D{{Key: "created_at", Value: 1}}},
+
+	{Collection: "organizations", Keys: bson.D{{Key: "public_id", Value: 1}}, Unique: true},
+	// Organization names are unique regardless of case and accents
+	{Collection: "organizations", Keys: bson.D{{Key: "name_key", Value: 1}}, Unique: true},
+
+	// The activity feed, newest first, whole or by type or user
+	{Collection: "activity", Keys: bson.D{{Key: "at", Value: -1}}},
+	{Collection: "activity", Keys: bson.D{{Key: "type", Value: 1}, {Key: "at", Value: -1}}},
+	{Collection: "activity", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "at", Value: -1}}},
+
+	// The attachments of a user, newest first
+	{Collection: "attachments", Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+}
+
+// indexesOf returns the specs of the registry for collection
+func indexesOf(collection string) []IndexSpec {
+	var specs []IndexSpec
+	for _, s := range Indexes {
+		if s.Collection == collection {
+			specs = append(specs, s)
+		}
+	}
+	return specs
+}
+
+// indexName returns the default name MongoDB gives an index on keys
+func indexName(keys bson.D) string {
+	parts := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		parts = append(parts, k.Key, fmt.Sprint(k.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+// IndexConflict is an existing index that differs from the one required
+// under the same name or on the same keys
+type IndexConflict struct {
+	Want   IndexSpec
+	Have   string
+	Reason string
+}
+
+// IndexDrift is the difference between the registry and the database
+type IndexDrift struct {
+	Missing     []IndexSpec
+	Conflicting []IndexConflict
+	// Unexpected are collection.name of indexes the registry lacks
+	Unexpected []string
+}
+
+// Empty reports whether the database matches the registry
+func (d IndexDrift) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Conflicting) == 0 && len(d.Unexpected) == 0
+}
+
+// IndexManager creates the indexes of a registry and detects the drift of
+// a database from it
+type IndexManager struct {
+	db      *mongo.Database
+	specs   []IndexSpec
+	metrics statsd.ClientInterface
+}
+
+// NewIndexManager creates a manager of specs in db
+func NewIndexManager(db *mongo.Database, specs []IndexSpec, metrics statsd.ClientInterface) *IndexManager {
+	return &IndexManager{db: db, specs: specs, metrics: metrics}
+}
+
+// Drift compares the indexes of every collection of the registry with it
+func (m *IndexManager) Drift(ctx context.Context) (IndexDrift, error) {
+	var drift IndexDrift
+	var collections []string
+	for _, s := range m.specs {
+		if !slices.Contains(collections, s.Collection) {
+			collections = append(collections, s.Collection)
+		}
+	}
+	for _, coll := range collections {
+		have, err := m.db.Collection(coll).Indexes().ListSpecifications(ctx)
+		if err != nil {
+			return IndexDrift{}, mapError("list indexes", err)
+		}
+		d, err := compareIndexes(coll, m.specs, have)
+		if err != nil {
+			return IndexDrift{}, err
+		}
+		drift.Missing = append(drift.Missing, d.Missing...)
+		drift.Conflicting = append(drift.Conflicting, d.Conflicting...)
+		drift.Unexpected = append(drift.Unexpected, d.Unexpected...)
+	}
+	return drift, nil
+}
+
+// Sync creates the missing indexes and reports the remaining drift, which
+// is returned. Conflicting and unexpected indexes are left alone: dropping
+// them is a decision for an operator.
+func (m *IndexManager) Sync(ctx context.Context) (IndexDrift, error) {
+	drift, err := m.Drift(ctx)
+	if err != nil {
+		return IndexDrift{}, err
+	}
+	for _, s := range drift.Missing {
+		if _, err := m.db.Collection(s.Collection).Indexes().CreateOne(ctx, s.model()); err != nil {
+			return drift, mapError("create index "+s.String(), err)
+		}
+		log.Printf("Created index %s", s)
+	}
+	drift.Missing = nil
+	m.Report(drift)
+	return drift, nil
+}
+
+// Report logs drift and sends one mongodb.index.drift gauge per kind
+func (m *IndexManager) Report(drift IndexDrift) {
+	for _, s := range drift.Missing {
+		log.Printf("WARNING: Index drift: %s is missing", s)
+	}
+	for _, c := range drift.Conflicting {
+		log.Printf("WARNING: Index drift: %s conflicts with %s: %s", c.Have, c.Want, c.Reason)
+	}
+	for _, name := range drift.Unexpected {
+		log.Printf("WARNING: Index drift: %s is not in the registry", name)
+	}
+	if m.metrics == nil {
+		return
+	}
+	m.metrics.Gauge("mongodb.index.drift", float64(len(drift.Missing)), []string{"kind:missing"}, 1)
+	m.metrics.Gauge("mongodb.index.drift", float64(len(drift.Conflicting)), []string{"kind:conflicting"}, 1)
+	m.metrics.Gauge("mongodb.index.drift", float64(len(drift.Unexpected)), []string{"kind:unexpected"}, 1)
+}
+
+// compareIndexes compares the indexes have of coll with the specs of coll
+func compareIndexes(coll string, specs []IndexSpec, have []*mongo.IndexSpecification) (IndexDrift, error) {
+	var drift IndexDrift
+	byName := make(map[string]*mongo.IndexSpecification, len(have))
+	byKeys := make(map[string]string, len(have))
+	for _, h := range have {
+		var keys bson.D
+		if err := bson.Unmarshal(h.KeysDocument, &keys); err != nil {
+			return IndexDrift{}, fmt.Errorf("decode keys of index %s.%s: %w", coll, h.Name, err)
+		}
+		byName[h.Name] = h
+		byKeys[indexName(keys)] = h.Name
+	}
+
+	known := map[string]bool{"_id_": true}
+	for _, s := range specs {
+		if s.Collection != coll {
+			continue
+		}
+		name := s.Name()
+		known[name] = true
+		h, ok := byName[name]
+		if !ok {
+			if other, ok := byKeys[name]; ok {
+				known[other] = true
+				drift.Conflicting = append(drift.Conflicting, IndexConflict{Want: s, Have: coll + "." + other, Reason: "same keys under another name"})
+				continue
+			}
+			drift.Missing = append(drift.Missing, s)
+			continue
+		}
+		var reasons []string
+		if byKeys[name] != name {
+			reasons = append(reasons, "different keys")
+		}
+		if isSet(h.Unique) != s.Unique {
+			reasons = append(reasons, fmt.Sprintf("unique is %t", isSet(h.Unique)))
+		}
+		if isSet(h.Sparse) != s.Sparse {
+			reasons = append(reasons, fmt.Sprintf("sparse is %t", isSet(h.Sparse)))
+		}
+		if len(reasons) > 0 {
+			drift.Conflicting = append(drift.Conflicting, IndexConflict{Want: s, Have: coll + "." + name, Reason: strings.Join(reasons, ", ")})
+		}
+	}
+	for _, h := range have {
+		if !known[h.Name] {
+			drift.Unexpected = append(drift.Unexpected, coll+"."+h.Name)
+		}
+	}
+	return drift, nil
+}
+
+// isSet reports whether an optional index option is true
+func isSet(b *bool) bool {
+	return b != nil && *b
+}
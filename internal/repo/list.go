@@ -0,0 +1,239 @@
+package repo
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"datadog-golang-example/internal/model"
+)
+
+// listField is a field lists can be sorted or filtered by
+type listField struct {
+	field field
+	index string // index serving the field
+	// orgIndex serves the field within an organization, when one does
+	orgIndex string
+}
+
+// listFields are the fields backed by an index of Indexes, so sorting
+// or filtering by them never scans the collection
+var listFields = map[string]listField{
+	"username": {field: fieldUsername, index: "username_1", orgIndex: "org_id_1_username_1"},
+	"email":    {field: fieldEmail, index: "email_1"},
+	"name":     {field: fieldNameKey, index: "name_key_1", orgIndex: "org_id_1_name_key_1"},
+	"tag":      {field: fieldTags, index: "tags_1"},
+}
+
+// projectedFields maps the JSON fields of a user to their paths
+var projectedFields = map[string]string{
+	"id":         "public_id",
+	"username":   "username",
+	"name":       "name",
+	"email":      "email",
+	"email_risk": "email_risk",
+	"age":        "age",
+	"location":   "location",
+	"tags":       "tags",
+	"org_id":     "org_id",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"version":    "version",
+	"erased_at":  "erased_at",
+
+	"attachments_count": "attachments_count",
+}
+
+// projection returns the projection of the JSON fields, nil for all of
+// them
+func projection(fields []string) (bson.D, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	// The schema version keeps current documents from being upgraded
+	proj := bson.D{{Key: "public_id", Value: 1}, {Key: "schema_version", Value: 1}}
+	for i, name := range fields {
+		path, ok := projectedFields[name]
+		if !ok {
+			allowed := make([]string, 0, len(projectedFields))
+			for name := range projectedFields {
+				allowed = append(allowed, name)
+			}
+			slices.Sort(allowed)
+			return nil, &model.ValidationError{Field: "fields", Reason: name + " is not a field, fields are " + fieldList(allowed)}
+		}
+		// The ID is already projected and repeating a path is an error
+		if name != "id" && !slices.Contains(fields[:i], name) {
+			proj = append(proj, bson.E{Key: path, Value: 1})
+		}
+	}
+	return proj, nil
+}
+
+// listProjection returns the projection of the fields lists read when
+// they ask for none, logging and ignoring the fields of a user that do
+// not exist; nil reads whole documents
+func listProjection(fields []string) bson.D {
+	var known []string
+	for _, name := range fields {
+		if _, ok := projectedFields[name]; !ok {
+			log.Printf("WARNING: Ignoring list field %q, users have no such field", name)
+			continue
+		}
+		known = append(known, name)
+	}
+	proj, _ := projection(known)
+	return proj
+}
+
+// indexedFields keeps the fields of allowed that are backed by an index,
+// logging the others
+func indexedFields(kind string, allowed []string) []string {
+	var fields []string
+	for _, field := range allowed {
+		if _, ok := listFields[field]; !ok {
+			log.Printf("WARNING: Ignoring %s field %q, no index backs it", kind, field)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// listQuery validates filter against the allowed fields and returns the
+// query, its options and the index it relies on
+func (r *MongoUserRepository) listQuery(filter model.UserFilter) (bson.D, *options.FindOptions, string, error) {
+	q := newQuery()
+	opts := options.Find()
+	var hint string
+
+	// The members of an organization are read from its compound indexes,
+	// in the order of the sort when one serves it
+	if filter.OrgID != "" {
+		q.eq(fieldOrgID, str(filter.OrgID))
+		hint = listFields["username"].orgIndex
+		if name := strings.TrimPrefix(filter.Sort, "-"); listFields[name].orgIndex != "" {
+			hint = listFields[name].orgIndex
+		}
+	}
+
+	// Sorted for a deterministic hint when several filters are given
+	names := make([]string, 0, len(filter.Filters))
+	for name := range filter.Filters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !slices.Contains(r.filterFields, name) {
+			return nil, nil, "", &model.ValidationError{Field: name, Reason: "cannot be filtered on, allowed filters are " + fieldList(r.filterFields)}
+		}
+		field := listFields[name]
+		if name == "email" {
+			r.whereEmail(q, filter.Filters[name])
+		} else {
+			q.eq(field.field, str(filter.Filters[name]))
+		}
+		if hint == "" {
+			hint = field.index
+		}
+	}
+
+	if !filter.CreatedBefore.IsZero() {
+		q.between(fieldCreatedAt, time.Time{}, filter.CreatedBefore)
+		if hint == "" {
+			hint = "created_at_1"
+		}
+	}
+
+	if filter.Sort != "" {
+		name, desc := strings.CutPrefix(filter.Sort, "-")
+		if !slices.Contains(r.sortFields, name) {
+			return nil, nil, "", &model.ValidationError{Field: "sort", Reason: "must be one of " + fieldList(r.sortFields)}
+		}
+		field := listFields[name]
+		order := 1
+		if desc {
+			order = -1
+		}
+		opts.SetSort(bson.D{{Key: field.field.path, Value: order}})
+		if hint == "" {
+			hint = field.index
+		}
+	}
+
+	// Pages must not overlap, so ties are broken by _id
+	if filter.Limit > 0 {
+		sort, _ := opts.Sort.(bson.D)
+		opts.SetSort(append(sort, bson.E{Key: fieldID.path, Value: 1}))
+		opts.SetLimit(int64(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		opts.SetSkip(int64(filter.Offset))
+	}
+	proj, err := projection(filter.Fields)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if proj == nil {
+		proj = r.listProjection
+	}
+	if proj != nil {
+		opts.SetProjection(proj)
+	}
+	if r.opts.BatchSize > 0 {
+		opts.SetBatchSize(r.opts.BatchSize)
+	}
+
+	// Forcing a sparse index on a sort alone would skip the users lacking
+	// the field, so only filtered lists are hinted
+	if !q.empty() {
+		opts.SetHint(hint)
+	}
+	return q.filter(), opts, hint, nil
+}
+
+// fieldList formats allowed fields for error messages
+func fieldList(fields []string) string {
+	if len(fields) == 0 {
+		return "none"
+	}
+	return strings.Join(fields, ", ")
+}
+
+// tagIndexHint records on the span of ctx which index a list relies on,
+// "none" for an unfiltered and unsorted list
+func tagIndexHint(ctx context.Context, hint string) {
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		if hint == "" {
+			hint = "none"
+		}
+		span.SetTag("mongodb.index_hint", hint)
+	}
+}
+
+// decodeStats counts the documents a list decodes and their BSON size,
+// which projections and batch sizes are tuned against
+type decodeStats struct {
+	documents int
+	bytes     int
+}
+
+// add counts the document raw
+func (s *decodeStats) add(raw bson.Raw) {
+	s.documents++
+	s.bytes += len(raw)
+}
+
+// tag records the counts on the span of ctx
+func (s *decodeStats) tag(ctx context.Context) {
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		span.SetTag("mongodb.documents_returned", s.documents)
+		span.SetTag("mongodb.bytes_decoded", s.bytes)
+	}
+}
@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/model"
+)
+
+// defaultListRepository is a repository with the default list settings
+func defaultListRepository() *MongoUserRepository {
+	cfg := config.Load().Mongo
+	return NewMongoUserRepository(nil, MongoOptions{
+		SortFields:   cfg.SortFields,
+		FilterFields: cfg.FilterFields,
+		ListFields:   cfg.ListFields,
+		BatchSize:    cfg.ListBatchSize,
+	})
+}
+
+// TestListQueryProjects guards the list against reading whole documents:
+// every list, whatever its filters, is limited to the fields of a user
+func TestListQueryProjects(t *testing.T) {
+	r := defaultListRepository()
+	filters := []model.UserFilter{
+		{},
+		{Filters: map[string]string{"tag": "beta"}, Sort: "-name", Limit: 11},
+		{OrgID: "0192a8e2-0000-7000-8000-000000000000", Offset: 20},
+	}
+	for _, filter := range filters {
+		_, opts, _, err := r.listQuery(filter)
+		if err != nil {
+			t.Fatalf("listQuery(%+v): %v", filter, err)
+		}
+		proj, ok := opts.Projection.(bson.D)
+		if !ok || len(proj) == 0 {
+			t.Fatalf("listQuery(%+v) reads whole documents, want the default list fields", filter)
+		}
+		paths := make([]string, len(proj))
+		for i, e := range proj {
+			paths[i] = e.Key
+		}
+		for _, want := range []string{"public_id", "schema_version", "username", "created_at"} {
+			if !slices.Contains(paths, want) {
+				t.Errorf("listQuery(%+v) projects %v, missing %q", filter, paths, want)
+			}
+		}
+		if slices.Contains(paths, "name_key") {
+			t.Errorf("listQuery(%+v) projects the internal name_key", filter)
+		}
+		if opts.BatchSize == nil || *opts.BatchSize != r.opts.BatchSize {
+			t.Errorf("listQuery(%+v) batch size %v, want %d", filter, opts.BatchSize, r.opts.BatchSize)
+		}
+	}
+}
+
+// TestListQueryAskedFields checks that the fields a list asks for replace
+// the default ones
+func TestListQueryAskedFields(t *testing.T) {
+	_, opts, _, err := defaultListRepository().listQuery(model.UserFilter{Fields: []string{"name"}})
+	if err != nil {
+		t.Fatalf("listQuery: %v", err)
+	}
+	want := bson.D{{Key: "public_id", Value: 1}, {Key: "schema_version", Value: 1}, {Key: "name", Value: 1}}
+	if got := opts.Projection.(bson.D); !slices.Equal(got, want) {
+		t.Fatalf("projection %v, want %v", got, want)
+	}
+}
+
+// TestListQueryHintsIndex guards the filtered lists against collection
+// scans: each is hinted at an index the repository creates
+func TestListQueryHintsIndex(t *testing.T) {
+	r := defaultListRepository()
+	indexes := map[string]bool{}
+	for _, s := range indexesOf("users") {
+		indexes[s.Name()] = true
+	}
+	for _, name := range r.filterFields {
+		for _, orgID := range []string{"", "0192a8e2-0000-7000-8000-000000000000"} {
+			filter := model.UserFilter{Filters: map[string]string{name: "x"}, OrgID: orgID}
+			_, opts, hint, err := r.listQuery(filter)
+			if err != nil {
+				t.Fatalf("listQuery(%+v): %v", filter, err)
+			}
+			if hint == "" || opts.Hint != hint || !indexes[hint] {
+				t.Errorf("listQuery(%+v) hints %v, want an index of the users", filter, opts.Hint)
+			}
+		}
+	}
+}
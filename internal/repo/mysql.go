@@ -0,0 +1,1000 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"log"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"datadog-golang-example/internal/fieldcrypt"
+	"datadog-golang-example/internal/model"
+)
+
+// MySQLOptions tunes the MySQL repository
+type MySQLOptions struct {
+	// SortFields and FilterFields are the fields lists may be sorted and
+	// filtered by, as for MongoOptions
+	SortFields   []string
+	FilterFields []string
+	// Encryption encrypts emails at rest when set
+	Encryption *fieldcrypt.Keyring
+	// ListFields are the JSON fields read by lists that ask for none, all
+	// of them when empty
+	ListFields []string
+}
+
+// MySQLUserRepository is a UserRepository backed by a MySQL table. Its
+// queries are plain SQL, so once the database/sql driver is traced with
+// Database Monitoring propagation their trace context is visible to DBM.
+type MySQLUserRepository struct {
+	db           *sql.DB
+	opts         MySQLOptions
+	sortFields   []string
+	filterFields []string
+	listColumns  []string
+}
+
+// NewMySQLUserRepository creates a repository storing users in the users
+// table of db
+func NewMySQLUserRepository(db *sql.DB, opts MySQLOptions) *MySQLUserRepository {
+	return &MySQLUserRepository{
+		db:           db,
+		opts:         opts,
+		sortFields:   indexedFields("sort", opts.SortFields),
+		filterFields: indexedFields("filter", opts.FilterFields),
+		listColumns:  mysqlListColumns(opts.ListFields),
+	}
+}
+
+// mysqlSchema creates the users table. The indexes are named after their
+// MongoDB counterparts, so conflicts name the same fields. The binary
+// collation compares like MongoDB does, case-sensitively.
+const mysqlSchema = `CREATE TABLE IF NOT EXISTS users (
+	id CHAR(24) NOT NULL,
+	public_id VARCHAR(36) NOT NULL,
+	username VARCHAR(255) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	name_key VARCHAR(255) NOT NULL,
+	email VARCHAR(512) NULL,
+	email_risk VARCHAR(64) NULL,
+	email_hash VARCHAR(64) NULL,
+	age INT NOT NULL,
+	lat DOUBLE NULL,
+	lng DOUBLE NULL,
+	tags JSON NOT NULL,
+	org_id VARCHAR(36) NULL,
+	created_at DATETIME(6) NOT NULL,
+	updated_at DATETIME(6) NOT NULL,
+	version BIGINT NOT NULL DEFAULT 0,
+	erased_at DATETIME(6) NULL,
+	attachments_count BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (id),
+	UNIQUE KEY public_id_1 (public_id),
+	UNIQUE KEY username_1 (username),
+	UNIQUE KEY email_1 (email),
+	UNIQUE KEY email_hash_1 (email_hash),
+	KEY name_key_1 (name_key),
+	KEY org_id_1_username_1 (org_id, username),
+	KEY org_id_1_name_key_1 (org_id, name_key),
+	KEY created_at_1 (created_at),
+	KEY tags_1 ((CAST(tags AS CHAR(32) ARRAY)))
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin`
+
+// mysqlIndexes are the indexes of mysqlSchema
+var mysqlIndexes = []string{
+	"PRIMARY", "public_id_1", "username_1", "email_1", "email_hash_1", "name_key_1",
+	"org_id_1_username_1", "org_id_1_name_key_1", "created_at_1", "tags_1",
+}
+
+// mysqlColumns are the columns of the users table, in the order rows are
+// written
+var mysqlColumns = []string{
+	"id", "public_id", "username", "name", "name_key", "email", "email_risk", "email_hash", "age", "lat", "lng",
+	"tags", "org_id", "created_at", "updated_at", "version", "erased_at", "attachments_count",
+}
+
+// mysqlFieldColumns maps the JSON fields of a user to their columns
+var mysqlFieldColumns = map[string][]string{
+	"id":         {"public_id"},
+	"username":   {"username"},
+	"name":       {"name"},
+	"email":      {"email"},
+	"email_risk": {"email_risk"},
+	"age":        {"age"},
+	"location":   {"lat", "lng"},
+	"tags":       {"tags"},
+	"org_id":     {"org_id"},
+	"created_at": {"created_at"},
+	"updated_at": {"updated_at"},
+	"version":    {"version"},
+	"erased_at":  {"erased_at"},
+
+	"attachments_count": {"attachments_count"},
+}
+
+// mysqlListFields maps the fields of listFields to the columns of the
+// users table
+var mysqlListFields = map[string]string{
+	"username": "username",
+	"email":    "email",
+	"name":     "name_key",
+	"tag":      "tags",
+}
+
+// columnsOf returns the columns of the JSON fields, nil for all of them.
+// The internal and public IDs are always read.
+func columnsOf(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if _, err := projection(fields); err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{"id": true, "public_id": true}
+	for _, name := range fields {
+		for _, column := range mysqlFieldColumns[name] {
+			wanted[column] = true
+		}
+	}
+	var columns []string
+	for _, column := range mysqlColumns {
+		if wanted[column] {
+			columns = append(columns, column)
+		}
+	}
+	return columns, nil
+}
+
+// mysqlListColumns returns the columns lists read when they ask for no
+// fields, ignoring the fields a user does not have like listProjection;
+// nil reads whole rows
+func mysqlListColumns(fields []string) []string {
+	var known []string
+	for _, name := range fields {
+		if _, ok := mysqlFieldColumns[name]; !ok {
+			log.Printf("WARNING: Ignoring list field %q, users have no such field", name)
+			continue
+		}
+		known = append(known, name)
+	}
+	columns, _ := columnsOf(known)
+	return columns
+}
+
+// mysqlWhere accumulates the conditions of a query and their arguments
+type mysqlWhere struct {
+	conds []string
+	args  []any
+}
+
+// add appends a condition with its arguments
+func (w *mysqlWhere) add(cond string, args ...any) *mysqlWhere {
+	w.conds = append(w.conds, cond)
+	w.args = append(w.args, args...)
+	return w
+}
+
+// String returns the WHERE clause, empty without conditions
+func (w *mysqlWhere) String() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+// placeholders returns n comma separated placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// selectUsers returns the SELECT of the columns, all of them when nil
+func selectUsers(columns []string) string {
+	if columns == nil {
+		columns = mysqlColumns
+	}
+	return "SELECT " + strings.Join(columns, ", ") + " FROM users"
+}
+
+// refWhere returns the condition matching the referenced user
+func refWhere(ref model.UserRef) *mysqlWhere {
+	if ref.PublicID != "" {
+		return new(mysqlWhere).add("public_id = ?", ref.PublicID)
+	}
+	return new(mysqlWhere).add("id = ?", ref.ObjectID.Hex())
+}
+
+// mysqlRow is a row of the users table as scanned
+type mysqlRow struct {
+	id, publicID, username, name, nameKey string
+	email, emailRisk, emailHash, orgID    sql.NullString
+	age                                   int
+	lat, lng                              sql.NullFloat64
+	tags                                  []byte
+	createdAt, updatedAt                  time.Time
+	version                               int64
+	erasedAt                              sql.NullTime
+	attachments                           int64
+}
+
+// dest returns the scan destinations of the columns, all of them when nil
+func (row *mysqlRow) dest(columns []string) []any {
+	if columns == nil {
+		columns = mysqlColumns
+	}
+	dest := make([]any, len(columns))
+	for i, column := range columns {
+		switch column {
+		case "id":
+			dest[i] = &row.id
+		case "public_id":
+			dest[i] = &row.publicID
+		case "username":
+			dest[i] = &row.username
+		case "name":
+			dest[i] = &row.name
+		case "name_key":
+			dest[i] = &row.nameKey
+		case "email":
+			dest[i] = &row.email
+		case "email_risk":
+			dest[i] = &row.emailRisk
+		case "email_hash":
+			dest[i] = &row.emailHash
+		case "age":
+			dest[i] = &row.age
+		case "lat":
+			dest[i] = &row.lat
+		case "lng":
+			dest[i] = &row.lng
+		case "tags":
+			dest[i] = &row.tags
+		case "org_id":
+			dest[i] = &row.orgID
+		case "created_at":
+			dest[i] = &row.createdAt
+		case "updated_at":
+			dest[i] = &row.updatedAt
+		case "version":
+			dest[i] = &row.version
+		case "erased_at":
+			dest[i] = &row.erasedAt
+		case "attachments_count":
+			dest[i] = &row.attachments
+		}
+	}
+	return dest
+}
+
+// user converts the row into a user, its email still sealed
+func (row *mysqlRow) user() (model.User, error) {
+	id, err := primitive.ObjectIDFromHex(row.id)
+	if err != nil {
+		return model.User{}, fmt.Errorf("decode user %s: %w", row.publicID, err)
+	}
+	user := model.User{
+		ID:               id,
+		PublicID:         row.publicID,
+		Username:         row.username,
+		Name:             row.name,
+		NameKey:          row.nameKey,
+		Email:            row.email.String,
+		EmailRisk:        row.emailRisk.String,
+		Age:              row.age,
+		OrgID:            row.orgID.String,
+		CreatedAt:        row.createdAt,
+		UpdatedAt:        row.updatedAt,
+		Version:          row.version,
+		AttachmentsCount: row.attachments,
+		SchemaVersion:    userSchemaVersion,
+	}
+	if row.lat.Valid && row.lng.Valid {
+		user.Location = model.NewGeoPoint(row.lat.Float64, row.lng.Float64)
+	}
+	if len(row.tags) > 0 {
+		if err := json.Unmarshal(row.tags, &user.Tags); err != nil {
+			return model.User{}, fmt.Errorf("decode tags of user %s: %w", row.publicID, err)
+		}
+		if len(user.Tags) == 0 {
+			user.Tags = nil
+		}
+	}
+	if row.erasedAt.Valid {
+		user.ErasedAt = &row.erasedAt.Time
+	}
+	return user, nil
+}
+
+// values returns the column values of the stored form of user, in the
+// order of mysqlColumns
+func (r *MySQLUserRepository) values(user *model.User) ([]any, error) {
+	tags := user.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("encode tags of user %s: %w", user.PublicID, err)
+	}
+	var lat, lng sql.NullFloat64
+	if location := user.Location.Request(); location != nil {
+		lat = sql.NullFloat64{Float64: location.Lat, Valid: true}
+		lng = sql.NullFloat64{Float64: location.Lng, Valid: true}
+	}
+	var erasedAt sql.NullTime
+	if user.ErasedAt != nil {
+		erasedAt = sql.NullTime{Time: user.ErasedAt.UTC(), Valid: true}
+	}
+	return []any{
+		user.ID.Hex(), user.PublicID, user.Username, user.Name, user.NameKey,
+		nullString(r.sealedEmail(user.Email)), nullString(user.EmailRisk), nullString(r.emailHash(user.Email)), user.Age, lat, lng,
+		encoded, nullString(user.OrgID), user.CreatedAt.UTC(), user.UpdatedAt.UTC(), user.Version,
+		erasedAt, user.AttachmentsCount,
+	}, nil
+}
+
+// nullString stores the empty string as NULL, as MongoDB omits the empty
+// fields
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// sealedEmail returns the stored form of email, encrypted when keys are
+// configured
+func (r *MySQLUserRepository) sealedEmail(email string) string {
+	if r.opts.Encryption == nil || email == "" {
+		return email
+	}
+	return r.opts.Encryption.Encrypt(email)
+}
+
+// emailHash returns the blind index of email, NULL when emails are stored
+// in clear
+func (r *MySQLUserRepository) emailHash(email string) string {
+	if r.opts.Encryption == nil {
+		return ""
+	}
+	return r.opts.Encryption.BlindIndex(email)
+}
+
+// open decrypts the email of a user read from the table
+func (r *MySQLUserRepository) open(user *model.User) error {
+	if r.opts.Encryption == nil || user.Email == "" {
+		return nil
+	}
+	email, err := r.opts.Encryption.Decrypt(user.Email)
+	if err != nil {
+		return fmt.Errorf("decrypt email of user %s: %w", user.PublicID, err)
+	}
+	user.Email = email
+	return nil
+}
+
+// whereEmail adds to w a condition matching email in any form it may be
+// stored in
+func (r *MySQLUserRepository) whereEmail(w *mysqlWhere, email string) *mysqlWhere {
+	if r.opts.Encryption == nil {
+		return w.add("email = ?", email)
+	}
+	candidates := r.opts.Encryption.Candidates(email)
+	args := make([]any, len(candidates))
+	for i, c := range candidates {
+		args[i] = c
+	}
+	return w.add("email IN ("+placeholders(len(args))+")", args...)
+}
+
+// EnsureIndexes creates the users table with its indexes
+func (r *MySQLUserRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, mysqlSchema)
+	return mapMySQLError("create users table", err)
+}
+
+// CheckIndexes returns an error naming the indexes of EnsureIndexes that
+// are missing from the table
+func (r *MySQLUserRepository) CheckIndexes(ctx context.Context) error {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT DISTINCT index_name FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = 'users'")
+	if err != nil {
+		return mapMySQLError("list indexes", err)
+	}
+	defer rows.Close()
+	have := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return mapMySQLError("list indexes", err)
+		}
+		have[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return mapMySQLError("list indexes", err)
+	}
+	var missing []string
+	for _, name := range mysqlIndexes {
+		if !have[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing indexes: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// queryer runs the queries of a repository on the pool or in a
+// transaction
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// insert inserts the row of user
+func (r *MySQLUserRepository) insert(ctx context.Context, q queryer, user *model.User) error {
+	values, err := r.values(user)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx,
+		"INSERT INTO users ("+strings.Join(mysqlColumns, ", ")+") VALUES ("+placeholders(len(mysqlColumns))+")", values...)
+	return mapMySQLError("insert user", err)
+}
+
+// write rewrites every column of the row of user but its IDs, which
+// never change
+func (r *MySQLUserRepository) write(ctx context.Context, q queryer, user *model.User) error {
+	values, err := r.values(user)
+	if err != nil {
+		return err
+	}
+	set := make([]string, 0, len(mysqlColumns)-2)
+	for _, column := range mysqlColumns[2:] {
+		set = append(set, column+" = ?")
+	}
+	_, err = q.ExecContext(ctx, "UPDATE users SET "+strings.Join(set, ", ")+" WHERE public_id = ?",
+		append(values[2:], user.PublicID)...)
+	return mapMySQLError("update user", err)
+}
+
+// query returns the users of a query reading the columns
+func (r *MySQLUserRepository) query(ctx context.Context, q queryer, query string, args []any, columns []string) ([]model.User, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, mapMySQLError("find users", err)
+	}
+	defer rows.Close()
+	users := []model.User{}
+	for rows.Next() {
+		user, err := r.scan(rows, columns)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, mapMySQLError("find users", rows.Err())
+}
+
+// scan reads the current row of rows, its email opened
+func (r *MySQLUserRepository) scan(rows *sql.Rows, columns []string, extra ...any) (model.User, error) {
+	var row mysqlRow
+	if err := rows.Scan(append(row.dest(columns), extra...)...); err != nil {
+		return model.User{}, mapMySQLError("decode user", err)
+	}
+	user, err := row.user()
+	if err != nil {
+		return model.User{}, err
+	}
+	return user, r.open(&user)
+}
+
+// getOne returns the only user of a query, or model.ErrNotFound
+func (r *MySQLUserRepository) getOne(ctx context.Context, q queryer, where *mysqlWhere, suffix string) (*model.User, error) {
+	users, err := r.query(ctx, q, selectUsers(nil)+where.String()+" LIMIT 1"+suffix, where.args, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, errUserNotFound
+	}
+	return &users[0], nil
+}
+
+// inTx runs fn in a transaction, committed unless fn fails
+func (r *MySQLUserRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return mapMySQLError("begin transaction", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return mapMySQLError("commit transaction", tx.Commit())
+}
+
+// Create inserts a new user, stamped by the UserHooks of NewUserHooks
+func (r *MySQLUserRepository) Create(ctx context.Context, user *model.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	return r.insert(ctx, r.db, user)
+}
+
+// listWhere validates the filters of filter against the allowed fields
+// and returns the conditions they are
+func (r *MySQLUserRepository) listWhere(filter model.UserFilter) (*mysqlWhere, error) {
+	w := new(mysqlWhere)
+	if filter.OrgID != "" {
+		w.add("org_id = ?", filter.OrgID)
+	}
+	names := make([]string, 0, len(filter.Filters))
+	for name := range filter.Filters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !slices.Contains(r.filterFields, name) {
+			return nil, &model.ValidationError{Field: name, Reason: "cannot be filtered on, allowed filters are " + fieldList(r.filterFields)}
+		}
+		switch value := filter.Filters[name]; name {
+		case "email":
+			r.whereEmail(w, value)
+		case "tag":
+			// Served by the multi-valued index on tags
+			w.add("? MEMBER OF (tags)", value)
+		default:
+			w.add(mysqlListFields[name]+" = ?", value)
+		}
+	}
+	if !filter.CreatedBefore.IsZero() {
+		w.add("created_at < ?", filter.CreatedBefore.UTC())
+	}
+	return w, nil
+}
+
+// listQuery validates filter and returns its query, arguments and the
+// columns it reads
+func (r *MySQLUserRepository) listQuery(filter model.UserFilter) (string, []any, []string, error) {
+	w, err := r.listWhere(filter)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	columns, err := columnsOf(filter.Fields)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if columns == nil {
+		columns = r.listColumns
+	}
+
+	var order []string
+	if filter.Sort != "" {
+		name, desc := strings.CutPrefix(filter.Sort, "-")
+		if !slices.Contains(r.sortFields, name) {
+			return "", nil, nil, &model.ValidationError{Field: "sort", Reason: "must be one of " + fieldList(r.sortFields)}
+		}
+		column := mysqlListFields[name]
+		if desc {
+			column += " DESC"
+		}
+		order = append(order, column)
+	}
+	// Pages must not overlap, so ties are broken by id
+	if filter.Limit > 0 {
+		order = append(order, "id")
+	}
+
+	query, args := selectUsers(columns)+w.String(), w.args
+	if len(order) > 0 {
+		query += " ORDER BY " + strings.Join(order, ", ")
+	}
+	switch {
+	case filter.Limit > 0:
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, filter.Limit, filter.Offset)
+	case filter.Offset > 0:
+		// MySQL has no OFFSET without LIMIT, the largest one is unbounded
+		query += " LIMIT 18446744073709551615 OFFSET ?"
+		args = append(args, filter.Offset)
+	}
+	return query, args, columns, nil
+}
+
+// List returns the users matching filter, in its order. Filtering or
+// sorting by a field that is not allowed is a model.ErrValidation.
+func (r *MySQLUserRepository) List(ctx context.Context, filter model.UserFilter) ([]model.User, error) {
+	query, args, columns, err := r.listQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	return r.query(ctx, r.db, query, args, columns)
+}
+
+// Stream yields the users matching filter, in its order, as rows are
+// read, so a list of any size is read in bounded memory. An error ends
+// the sequence.
+func (r *MySQLUserRepository) Stream(ctx context.Context, filter model.UserFilter) iter.Seq2[model.User, error] {
+	return func(yield func(model.User, error) bool) {
+		query, args, columns, err := r.listQuery(filter)
+		if err != nil {
+			yield(model.User{}, err)
+			return
+		}
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			yield(model.User{}, mapMySQLError("find users", err))
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			user, err := r.scan(rows, columns)
+			if err != nil {
+				yield(model.User{}, err)
+				return
+			}
+			if !yield(user, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(model.User{}, mapMySQLError("stream users", err))
+		}
+	}
+}
+
+// Count returns the number of users matching filter, ignoring its sort,
+// page and fields. Without exact, the count is the row estimate of the
+// table statistics, which is fast but approximate and cannot be filtered.
+func (r *MySQLUserRepository) Count(ctx context.Context, filter model.UserFilter, exact bool) (int64, error) {
+	var n int64
+	if !exact {
+		if len(filter.Filters) > 0 || filter.OrgID != "" {
+			return 0, &model.ValidationError{Field: "count", Reason: "cannot be estimated for filtered queries"}
+		}
+		err := r.db.QueryRowContext(ctx,
+			"SELECT COALESCE(table_rows, 0) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'users'").Scan(&n)
+		return n, mapMySQLError("estimate users", err)
+	}
+	w, err := r.listWhere(model.UserFilter{Filters: filter.Filters, OrgID: filter.OrgID})
+	if err != nil {
+		return 0, err
+	}
+	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+w.String(), w.args...).Scan(&n)
+	return n, mapMySQLError("count users", err)
+}
+
+// CountByOrg returns the number of users of each organization, the
+// largest first, the users in none counted under an empty ID
+func (r *MySQLUserRepository) CountByOrg(ctx context.Context) ([]model.OrgCount, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT COALESCE(org_id, ''), COUNT(*) AS users FROM users GROUP BY org_id ORDER BY users DESC, org_id")
+	if err != nil {
+		return nil, mapMySQLError("count users by org", err)
+	}
+	defer rows.Close()
+	counts := []model.OrgCount{}
+	for rows.Next() {
+		var c model.OrgCount
+		if err := rows.Scan(&c.OrgID, &c.Users); err != nil {
+			return nil, mapMySQLError("count users by org", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, mapMySQLError("count users by org", rows.Err())
+}
+
+// Get returns the referenced user, or model.ErrNotFound
+func (r *MySQLUserRepository) Get(ctx context.Context, ref model.UserRef) (*model.User, error) {
+	return r.getOne(ctx, r.db, refWhere(ref), "")
+}
+
+// GetMany returns the users matching any of refs in a single query.
+// References that match nothing are simply absent from the result.
+func (r *MySQLUserRepository) GetMany(ctx context.Context, refs []model.UserRef) ([]model.User, error) {
+	var ids, publicIDs []any
+	for _, ref := range refs {
+		if ref.PublicID != "" {
+			publicIDs = append(publicIDs, ref.PublicID)
+		} else {
+			ids = append(ids, ref.ObjectID.Hex())
+		}
+	}
+	var or []string
+	if len(ids) > 0 {
+		or = append(or, "id IN ("+placeholders(len(ids))+")")
+	}
+	if len(publicIDs) > 0 {
+		or = append(or, "public_id IN ("+placeholders(len(publicIDs))+")")
+	}
+	if len(or) == 0 {
+		return []model.User{}, nil
+	}
+	return r.query(ctx, r.db, selectUsers(nil)+" WHERE "+strings.Join(or, " OR "), append(ids, publicIDs...), nil)
+}
+
+// GetByUsername returns the user with the given username, or model.ErrNotFound
+func (r *MySQLUserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	return r.getOne(ctx, r.db, new(mysqlWhere).add("username = ?", username), "")
+}
+
+// GetByEmail returns the user with the given email, or model.ErrNotFound
+func (r *MySQLUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.getOne(ctx, r.db, r.whereEmail(new(mysqlWhere), email), "")
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Suggest returns up to limit users whose name starts with prefix, read
+// from the name_key index. The prefix must already be folded with
+// model.FoldName.
+func (r *MySQLUserRepository) Suggest(ctx context.Context, prefix string, limit int) ([]model.Suggestion, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT public_id, name, username FROM users WHERE name_key LIKE ? ORDER BY name_key LIMIT ?",
+		likeEscaper.Replace(prefix)+"%", limit)
+	if err != nil {
+		return nil, mapMySQLError("suggest users", err)
+	}
+	defer rows.Close()
+	suggestions := []model.Suggestion{}
+	for rows.Next() {
+		var s model.Suggestion
+		if err := rows.Scan(&s.ID, &s.Name, &s.Username); err != nil {
+			return nil, mapMySQLError("decode suggestions", err)
+		}
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, mapMySQLError("suggest users", rows.Err())
+}
+
+// Nearby returns up to limit users within radiusMeters of point, closest
+// first, with their distance. The distance of every located user is
+// computed, as the coordinates have no spatial index.
+func (r *MySQLUserRepository) Nearby(ctx context.Context, point *model.GeoPoint, radiusMeters float64, limit int) ([]model.NearbyUser, error) {
+	location := point.Request()
+	if location == nil {
+		return nil, &model.ValidationError{Field: "location", Reason: "must be a point"}
+	}
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+strings.Join(mysqlColumns, ", ")+
+			", ST_Distance_Sphere(POINT(lng, lat), POINT(?, ?)) AS distance_m FROM users"+
+			" WHERE lat IS NOT NULL AND lng IS NOT NULL HAVING distance_m <= ? ORDER BY distance_m LIMIT ?",
+		location.Lng, location.Lat, radiusMeters, limit)
+	if err != nil {
+		return nil, mapMySQLError("find nearby users", err)
+	}
+	defer rows.Close()
+	users := []model.NearbyUser{}
+	for rows.Next() {
+		var distance float64
+		user, err := r.scan(rows, nil, &distance)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, model.NearbyUser{User: user, DistanceMeters: distance})
+	}
+	return users, mapMySQLError("find nearby users", rows.Err())
+}
+
+// SlowQuery runs a query that sleeps server-side for sleep but is capped
+// at maxTime by the MAX_EXECUTION_TIME hint. SLEEP reports the
+// interruption by returning 1 rather than failing, which is turned into
+// a timeout. It exists for the error tracking demo routes only.
+func (r *MySQLUserRepository) SlowQuery(ctx context.Context, sleep, maxTime time.Duration) error {
+	var interrupted int
+	err := r.db.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT /*+ MAX_EXECUTION_TIME(%d) */ SLEEP(?)", maxTime.Milliseconds()), sleep.Seconds()).Scan(&interrupted)
+	if err != nil {
+		return mapMySQLError("slow query", err)
+	}
+	if interrupted == 1 {
+		return fmt.Errorf("slow query: %w: interrupted after %s", model.ErrUnavailable, maxTime)
+	}
+	return nil
+}
+
+// Update applies a partial update and returns the updated user, or
+// model.ErrNotFound when no user matches and ErrUserErased when the user
+// is erased. The row is locked while the update is applied to it.
+func (r *MySQLUserRepository) Update(ctx context.Context, ref model.UserRef, update model.UserUpdate) (*model.User, error) {
+	var updated *model.User
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		user, err := r.getOne(ctx, tx, refWhere(ref), " FOR UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := updatable(user); err != nil {
+			return err
+		}
+		updated = user.Applied(update)
+		return r.write(ctx, tx, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Replace replaces the whole row of user, stamped by the UserHooks of
+// NewUserHooks. Without upsert, only a stored row at the given version is
+// replaced and model.ErrNotFound is returned otherwise. With upsert, a
+// missing row is inserted and created reports it.
+func (r *MySQLUserRepository) Replace(ctx context.Context, user *model.User, version int64, upsert bool) (bool, error) {
+	created := false
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		var stored int64
+		err := tx.QueryRowContext(ctx, "SELECT version FROM users WHERE public_id = ? FOR UPDATE", user.PublicID).Scan(&stored)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if !upsert {
+				return errUserNotFound
+			}
+			created = true
+			if user.ID.IsZero() {
+				user.ID = primitive.NewObjectID()
+			}
+			return r.insert(ctx, tx, user)
+		case err != nil:
+			return mapMySQLError("find user", err)
+		case !upsert && stored != version:
+			return errUserNotFound
+		}
+		return r.write(ctx, tx, user)
+	})
+	return created, err
+}
+
+// BulkUpdate applies many partial updates, each in its own transaction
+// so a failing one, such as a duplicate email, leaves the others applied.
+// Failures are reported per item by index; users that match nothing are
+// skipped, as in a bulk write.
+func (r *MySQLUserRepository) BulkUpdate(ctx context.Context, updates []model.BulkUpdate) (*model.BulkUpdateResult, error) {
+	result := &model.BulkUpdateResult{Errors: []model.BulkItemError{}}
+	for _, u := range updates {
+		_, err := r.Update(ctx, u.Ref, u.Update)
+		switch {
+		case errors.Is(err, model.ErrNotFound):
+			continue
+		case errors.Is(err, model.ErrConflict):
+			result.Matched++
+			result.Errors = append(result.Errors, model.BulkItemError{Index: u.Index, ID: u.Ref.String(), Err: err})
+		case err != nil:
+			return nil, fmt.Errorf("bulk update users: %w", err)
+		default:
+			result.Matched++
+			result.Modified++
+		}
+	}
+	return result, nil
+}
+
+// Import upserts imported users by email, each in its own transaction.
+// Stored users have their name, age and email replaced and the imported
+// tags added; duplicate usernames are reported per row.
+func (r *MySQLUserRepository) Import(ctx context.Context, users []model.ImportedUser) (*model.ImportResult, error) {
+	result := &model.ImportResult{Rows: len(users), Errors: []model.ImportRowError{}}
+	for _, u := range users {
+		id, created, err := r.importUser(ctx, &u.User)
+		switch {
+		case errors.Is(err, model.ErrConflict):
+			result.Errors = append(result.Errors, model.ImportRowError{Row: u.Row, Email: u.User.Email, Err: err})
+		case err != nil:
+			return nil, fmt.Errorf("import users: %w", err)
+		case created:
+			result.Created++
+			result.CreatedIDs = append(result.CreatedIDs, id)
+		default:
+			result.Updated++
+			result.UpdatedIDs = append(result.UpdatedIDs, id)
+		}
+	}
+	return result, nil
+}
+
+// importUser upserts one imported user and returns the public ID of the
+// user written and whether it was inserted
+func (r *MySQLUserRepository) importUser(ctx context.Context, user *model.User) (string, bool, error) {
+	id, created := user.PublicID, false
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		stored, err := r.getOne(ctx, tx, r.whereEmail(new(mysqlWhere), user.Email), " FOR UPDATE")
+		if errors.Is(err, model.ErrNotFound) {
+			id, created = user.PublicID, true
+			inserted := *user
+			if inserted.ID.IsZero() {
+				inserted.ID = primitive.NewObjectID()
+			}
+			inserted.Version = 1
+			return r.insert(ctx, tx, &inserted)
+		}
+		if err != nil {
+			return err
+		}
+		id, created = stored.PublicID, false
+		stored.Name, stored.NameKey, stored.Email, stored.Age = user.Name, user.NameKey, user.Email, user.Age
+		for _, tag := range user.Tags {
+			if !slices.Contains(stored.Tags, tag) {
+				stored.Tags = append(stored.Tags, tag)
+			}
+		}
+		stored.UpdatedAt = user.UpdatedAt
+		stored.Version++
+		return r.write(ctx, tx, stored)
+	})
+	return id, created, err
+}
+
+// Delete removes the referenced user, or returns model.ErrNotFound
+func (r *MySQLUserRepository) Delete(ctx context.Context, ref model.UserRef) error {
+	w := refWhere(ref)
+	result, err := r.db.ExecContext(ctx, "DELETE FROM users"+w.String(), w.args...)
+	if err != nil {
+		return mapMySQLError("delete user", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return errUserNotFound
+	}
+	return nil
+}
+
+// MySQL errors the repository maps to domain errors
+const (
+	mysqlDuplicateEntry     = 1062
+	mysqlLockWaitTimeout    = 1205
+	mysqlDeadlock           = 1213
+	mysqlMaxExecutionTime   = 3024
+	mysqlServerGoneAway     = 2006
+	mysqlLostConnection     = 2013
+	mysqlTooManyConnections = 1040
+)
+
+// duplicateKey extracts the index name from a duplicate entry message,
+// which MySQL 8 prefixes with the table name
+var duplicateKey = regexp.MustCompile(`for key '(?:[^.']+\.)?([^']+)'`)
+
+// mysqlDuplicateField returns the field whose unique index was violated
+func mysqlDuplicateField(message string) string {
+	m := duplicateKey.FindStringSubmatch(message)
+	if m == nil {
+		return "document"
+	}
+	if field, ok := uniqueIndexFields[m[1]]; ok {
+		return field
+	}
+	return m[1]
+}
+
+// mapMySQLError translates a MySQL driver error into a domain error while
+// keeping the original error in the chain, as mapError does for MongoDB
+func mapMySQLError(op string, err error) error {
+	var mysqlErr *mysql.MySQLError
+	isMySQL := errors.As(err, &mysqlErr)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return errUserNotFound
+	case errors.Is(err, context.Canceled):
+		// Cancelled by the caller, not a failure of the database
+		return fmt.Errorf("%s: %w", op, err)
+	case isMySQL && mysqlErr.Number == mysqlDuplicateEntry:
+		return fmt.Errorf("%s: %w: %w", op, &model.ConflictError{Field: mysqlDuplicateField(mysqlErr.Message)}, err)
+	case isMySQL && slices.Contains([]uint16{mysqlLockWaitTimeout, mysqlDeadlock, mysqlMaxExecutionTime,
+		mysqlServerGoneAway, mysqlLostConnection, mysqlTooManyConnections}, mysqlErr.Number),
+		errors.Is(err, context.DeadlineExceeded), errors.Is(err, driver.ErrBadConn), errors.Is(err, mysql.ErrInvalidConn),
+		errors.As(err, new(net.Error)):
+		return fmt.Errorf("%s: %w: %w", op, model.ErrUnavailable, err)
+	default:
+		return fmt.Errorf("%s: %w", op, err)
+	}
+}
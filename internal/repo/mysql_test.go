@@ -0,0 +1,128 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/model"
+)
+
+// defaultMySQLRepository is a MySQL repository with the default list
+// settings
+func defaultMySQLRepository() *MySQLUserRepository {
+	cfg := config.Load().Mongo
+	return NewMySQLUserRepository(nil, MySQLOptions{
+		SortFields:   cfg.SortFields,
+		FilterFields: cfg.FilterFields,
+		ListFields:   cfg.ListFields,
+	})
+}
+
+// TestMySQLFieldColumns checks that every field a MongoDB list can project
+// is a column of the users table
+func TestMySQLFieldColumns(t *testing.T) {
+	for name := range projectedFields {
+		columns, ok := mysqlFieldColumns[name]
+		if !ok {
+			t.Errorf("field %s has no column", name)
+		}
+		for _, column := range columns {
+			if !slices.Contains(mysqlColumns, column) {
+				t.Errorf("field %s maps to %s, which is not a column", name, column)
+			}
+		}
+	}
+	for name := range listFields {
+		if !slices.Contains(mysqlColumns, mysqlListFields[name]) {
+			t.Errorf("list field %s has no column", name)
+		}
+	}
+}
+
+// TestMySQLCounterColumns checks that every counter of the user documents
+// is a column of the users table, which MySQLUserCounters writes
+func TestMySQLCounterColumns(t *testing.T) {
+	for _, counter := range Counters {
+		if !slices.Contains(mysqlColumns, counter.field.path) {
+			t.Errorf("counter %s has no column", counter.Name)
+		}
+	}
+}
+
+// TestMySQLListQuery checks the SQL of lists: their filters, order and
+// page, and the columns they read
+func TestMySQLListQuery(t *testing.T) {
+	r := defaultMySQLRepository()
+	query, args, columns, err := r.listQuery(model.UserFilter{
+		Filters: map[string]string{"tag": "beta", "username": "ana"},
+		OrgID:   "0192a8e2-0000-7000-8000-000000000000",
+		Sort:    "-name",
+		Limit:   10,
+		Offset:  20,
+		Fields:  []string{"name", "location"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SELECT id, public_id, name, lat, lng FROM users WHERE org_id = ? AND ? MEMBER OF (tags) AND username = ?" +
+		" ORDER BY name_key DESC, id LIMIT ? OFFSET ?"
+	if query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+	if got := fmt.Sprint(args); got != "[0192a8e2-0000-7000-8000-000000000000 beta ana 10 20]" {
+		t.Errorf("args = %s", got)
+	}
+	if !slices.Equal(columns, []string{"id", "public_id", "name", "lat", "lng"}) {
+		t.Errorf("columns = %v", columns)
+	}
+
+	// Offsets alone still need a limit
+	query, _, _, err = r.listQuery(model.UserFilter{Offset: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(query, " FROM users LIMIT 18446744073709551615 OFFSET ?") {
+		t.Errorf("query = %q, want an unbounded limit", query)
+	}
+	if strings.Contains(query, "name_key") {
+		t.Errorf("query = %q reads the internal name_key", query)
+	}
+}
+
+// TestMySQLListQueryRejects checks that lists are validated as with
+// MongoDB
+func TestMySQLListQueryRejects(t *testing.T) {
+	r := defaultMySQLRepository()
+	for _, filter := range []model.UserFilter{
+		{Filters: map[string]string{"age": "30"}},
+		{Sort: "age"},
+		{Fields: []string{"password"}},
+	} {
+		if _, _, _, err := r.listQuery(filter); !errors.Is(err, model.ErrValidation) {
+			t.Errorf("listQuery(%+v) = %v, want a validation error", filter, err)
+		}
+	}
+}
+
+// TestMapMySQLError checks that duplicate entries are conflicts naming
+// the field of their index
+func TestMapMySQLError(t *testing.T) {
+	err := mapMySQLError("insert user", &mysql.MySQLError{
+		Number:  mysqlDuplicateEntry,
+		Message: "Duplicate entry 'ana@example.com' for key 'users.email_1'",
+	})
+	var conflict *model.ConflictError
+	if !errors.As(err, &conflict) || conflict.Field != "email" {
+		t.Errorf("duplicate email mapped to %v, want a conflict on email", err)
+	}
+	err = mapMySQLError("update user", &mysql.MySQLError{Number: mysqlDeadlock, Message: "Deadlock found"})
+	if !errors.Is(err, model.ErrUnavailable) {
+		t.Errorf("deadlock mapped to %v, want unavailable", err)
+	}
+}
@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"datadog-golang-example/internal/model"
+)
+
+// errOrgNotFound is returned when no organization matches the query
+var errOrgNotFound = fmt.Errorf("organization %w", model.ErrNotFound)
+
+// OrgRepository stores and retrieves organizations
+type OrgRepository interface {
+	Create(ctx context.Context, org *model.Organization) error
+	Get(ctx context.Context, publicID string) (*model.Organization, error)
+}
+
+// MongoOrgRepository is an OrgRepository backed by a MongoDB collection
+type MongoOrgRepository struct {
+	coll *mongo.Collection
+}
+
+// NewMongoOrgRepository creates a repository for the given collection
+func NewMongoOrgRepository(coll *mongo.Collection) *MongoOrgRepository {
+	return &MongoOrgRepository{coll: coll}
+}
+
+// Create inserts a new organization
+func (r *MongoOrgRepository) Create(ctx context.Context, org *model.Organization) error {
+	result, err := r.coll.InsertOne(ctx, org)
+	if err != nil {
+		return mapError("insert organization", err)
+	}
+	org.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Get returns the organization with the given public ID, or
+// model.ErrNotFound
+func (r *MongoOrgRepository) Get(ctx context.Context, publicID string) (*model.Organization, error) {
+	var org model.Organization
+	err := r.coll.FindOne(ctx, newQuery().eq(fieldPublicID, str(publicID)).filter()).Decode(&org)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errOrgNotFound
+	}
+	if err != nil {
+		return nil, mapError("find organization", err)
+	}
+	return &org, nil
+}
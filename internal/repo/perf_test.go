@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/perf"
+)
+
+// benchFilter is a typical page of a list: filtered, sorted and bounded
+var benchFilter = model.UserFilter{
+	Filters: map[string]string{"tag": "beta"},
+	OrgID:   "0192a8e2-0000-7000-8000-000000000000",
+	Sort:    "-name",
+	Limit:   21,
+	Offset:  20,
+}
+
+func BenchmarkListQuery(b *testing.B) {
+	r := defaultListRepository()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, _, err := r.listQuery(benchFilter); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMySQLListQuery(b *testing.B) {
+	r := defaultMySQLRepository()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, _, _, err := r.listQuery(benchFilter); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUpdateDoc(b *testing.B) {
+	r := NewMongoUserRepository(nil, MongoOptions{})
+	name, email, age := "Alice Martin", "alice@example.com", 31
+	nameKey := model.FoldName(name)
+	update := model.UserUpdate{Name: &name, NameKey: &nameKey, Email: &email, Age: &age, AddTags: []string{"beta"}}
+	b.ReportAllocs()
+	for b.Loop() {
+		r.updateDoc(update)
+	}
+}
+
+// BenchmarkDecodeUsers decodes a page of 20 user documents, as a list
+// reads them from its cursor
+func BenchmarkDecodeUsers(b *testing.B) {
+	created := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	docs := make([]any, 20)
+	for i := range docs {
+		docs[i] = bson.M{
+			"public_id":      fmt.Sprintf("0192a8e2-0000-7000-8000-%012d", i),
+			"username":       fmt.Sprintf("user-%d", i),
+			"name":           fmt.Sprintf("User %d", i),
+			"name_key":       fmt.Sprintf("user %d", i),
+			"email":          fmt.Sprintf("user%d@example.com", i),
+			"age":            20 + i,
+			"tags":           bson.A{"beta", "demo"},
+			"created_at":     created,
+			"updated_at":     created,
+			"version":        1,
+			"schema_version": userSchemaVersion,
+		}
+	}
+	ctx := context.Background()
+	b.ReportAllocs()
+	for b.Loop() {
+		cursor, err := mongo.NewCursorFromDocuments(docs, nil, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		users, err := decodeUsers[model.User](ctx, cursor)
+		if err != nil || len(users) != len(docs) {
+			b.Fatalf("decoded %d users: %v", len(users), err)
+		}
+	}
+}
+
+// TestPerfQueries fails when building and decoding the queries of the
+// repositories gets slower or allocates more than their baseline
+func TestPerfQueries(t *testing.T) {
+	perf.Check(t,
+		perf.Benchmark{Name: "ListQuery", F: BenchmarkListQuery},
+		perf.Benchmark{Name: "MySQLListQuery", F: BenchmarkMySQLListQuery},
+		perf.Benchmark{Name: "UpdateDoc", F: BenchmarkUpdateDoc},
+		perf.Benchmark{Name: "DecodeUsers", F: BenchmarkDecodeUsers},
+	)
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// field is a document field queries can match on. Only the fields declared
+// here exist, so no path is ever taken from input.
+type field struct {
+	path string
+}
+
+// Fields that queries match on
+var (
+	fieldID               = field{"_id"}
+	fieldPublicID         = field{"public_id"}
+	fieldUsername         = field{"username"}
+	fieldEmail            = field{"email"}
+	fieldNameKey          = field{"name_key"}
+	fieldTags             = field{"tags"}
+	fieldVersion          = field{"version"}
+	fieldOrgID            = field{"org_id"}
+	fieldType             = field{"type"}
+	fieldUserID           = field{"user_id"}
+	fieldAt               = field{"at"}
+	fieldCreatedAt        = field{"created_at"}
+	fieldUpdatedAt        = field{"updated_at"}
+	fieldAttachmentsCount = field{"attachments_count"}
+	fieldErasedAt         = field{"erased_at"}
+)
+
+// value is an operand of a query condition
+type value struct {
+	v any
+}
+
+// str, oid, num and null are the operand types queries support
+func str(s string) value              { return value{s} }
+func oid(id primitive.ObjectID) value { return value{id} }
+func num(n int64) value               { return value{n} }
+func null() value                     { return value{nil} }
+
+// strs converts ss to operands
+func strs(ss []string) []value {
+	values := make([]value, len(ss))
+	for i, s := range ss {
+		values[i] = str(s)
+	}
+	return values
+}
+
+// query builds a filter from typed conditions on declared fields. Every
+// operand sits under an operator of the builder, so input can only be
+// compared against, never be read as an operator or a field path.
+type query struct {
+	conds bson.D
+}
+
+// newQuery returns a query matching every document
+func newQuery() *query {
+	return &query{conds: bson.D{}}
+}
+
+// eq matches documents whose f equals v, or contains it for arrays
+func (q *query) eq(f field, v value) *query {
+	return q.add(f, "$eq", v.v)
+}
+
+// in matches documents whose f equals any of vs
+func (q *query) in(f field, vs []value) *query {
+	operands := make(bson.A, len(vs))
+	for i, v := range vs {
+		operands[i] = v.v
+	}
+	return q.add(f, "$in", operands)
+}
+
+// gt matches documents whose f is greater than v
+func (q *query) gt(f field, v value) *query {
+	return q.add(f, "$gt", v.v)
+}
+
+// prefix matches documents whose f starts with s, taken literally
+func (q *query) prefix(f field, s string) *query {
+	return q.add(f, "$regex", "^"+regexp.QuoteMeta(s))
+}
+
+// between matches documents whose f is at or after since and before
+// before; a zero bound is left open
+func (q *query) between(f field, since, before time.Time) *query {
+	bounds := bson.D{}
+	if !since.IsZero() {
+		bounds = append(bounds, bson.E{Key: "$gte", Value: since})
+	}
+	if !before.IsZero() {
+		bounds = append(bounds, bson.E{Key: "$lt", Value: before})
+	}
+	if len(bounds) > 0 {
+		q.conds = append(q.conds, bson.E{Key: f.path, Value: bounds})
+	}
+	return q
+}
+
+// or matches documents matching any of qs
+func (q *query) or(qs ...*query) *query {
+	alternatives := make(bson.A, len(qs))
+	for i, alt := range qs {
+		alternatives[i] = alt.filter()
+	}
+	q.conds = append(q.conds, bson.E{Key: "$or", Value: alternatives})
+	return q
+}
+
+// add appends the condition {f: {op: operand}}
+func (q *query) add(f field, op string, operand any) *query {
+	q.conds = append(q.conds, bson.E{Key: f.path, Value: bson.D{{Key: op, Value: operand}}})
+	return q
+}
+
+// empty reports whether the query has no condition
+func (q *query) empty() bool {
+	return len(q.conds) == 0
+}
+
+// filter returns the query as a MongoDB filter
+func (q *query) filter() bson.D {
+	return q.conds
+}
@@ -0,0 +1,155 @@
+package repo
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"datadog-golang-example/internal/model"
+)
+
+// roundTrip encodes a filter or update document as the driver would send
+// it and decodes it back, so the tests inspect what the server receives
+func roundTrip(t *testing.T, doc any) bson.D {
+	t.Helper()
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal document: %v", err)
+	}
+	var decoded bson.D
+	if err := bson.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("unmarshal document: %v", err)
+	}
+	return decoded
+}
+
+// operand returns the single operator and operand of a condition
+func operand(t *testing.T, cond bson.E) (string, any) {
+	t.Helper()
+	doc, ok := cond.Value.(bson.D)
+	if !ok || len(doc) != 1 {
+		t.Fatalf("condition on %q is %#v, want one operator", cond.Key, cond.Value)
+	}
+	return doc[0].Key, doc[0].Value
+}
+
+func FuzzListQuery(f *testing.F) {
+	f.Add("username", "alice", "-name")
+	f.Add("username", "$ne", "")
+	f.Add("tag", `{"$gt": ""}`, "username")
+	f.Add("email", "a@example.com", "")
+	f.Add("$where", "sleep(1000)", "")
+	f.Add("email[$ne]", "x", "")
+	f.Add("tag", "x", "$natural")
+	f.Add("name", "^.*", "-$where")
+
+	r := NewMongoUserRepository(nil, MongoOptions{
+		SortFields:   []string{"username", "name"},
+		FilterFields: []string{"tag", "username", "email", "name"},
+	})
+	f.Fuzz(func(t *testing.T, name, v, sort string) {
+		filter, opts, _, err := r.listQuery(model.UserFilter{Filters: map[string]string{name: v}, Sort: sort})
+		if err != nil {
+			var invalid *model.ValidationError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("listQuery(%q=%q, sort %q) failed with %v, want a validation error", name, v, sort, err)
+			}
+			return
+		}
+
+		got := roundTrip(t, filter)
+		if len(got) != 1 {
+			t.Fatalf("filter %v has %d conditions, want 1", got, len(got))
+		}
+		if want := listFields[name].field.path; got[0].Key != want {
+			t.Fatalf("filter on %q, want %q", got[0].Key, want)
+		}
+		op, arg := operand(t, got[0])
+		if op != "$eq" || arg != v {
+			t.Fatalf("condition is {%s: %#v}, want {$eq: %q}", op, arg, v)
+		}
+
+		if sort == "" {
+			return
+		}
+		keys := opts.Sort.(bson.D)
+		path := listFields[strings.TrimPrefix(sort, "-")].field.path
+		if len(keys) != 1 || keys[0].Key != path || !slices.Contains([]any{1, -1}, keys[0].Value) {
+			t.Fatalf("sort %q gives %v", sort, keys)
+		}
+	})
+}
+
+func FuzzQueryOperands(f *testing.F) {
+	f.Add("alice")
+	f.Add("$gt")
+	f.Add(".*")
+	f.Add("a|b(")
+	f.Add("\x00$where")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) {
+			t.Skip("BSON strings are UTF-8, the server rejects others")
+		}
+		got := roundTrip(t, newQuery().
+			eq(fieldUsername, str(s)).
+			in(fieldPublicID, strs([]string{s, "$" + s})).
+			prefix(fieldNameKey, s).
+			filter())
+
+		want := []string{"username", "public_id", "name_key"}
+		if len(got) != len(want) {
+			t.Fatalf("filter %v, want conditions on %v", got, want)
+		}
+		for i, cond := range got {
+			if cond.Key != want[i] {
+				t.Fatalf("condition %d on %q, want %q", i, cond.Key, want[i])
+			}
+		}
+
+		if op, arg := operand(t, got[0]); op != "$eq" || arg != s {
+			t.Fatalf("eq gives {%s: %#v}", op, arg)
+		}
+		if op, arg := operand(t, got[1]); op != "$in" || !slices.Equal(arg.(bson.A), bson.A{s, "$" + s}) {
+			t.Fatalf("in gives {%s: %#v}", op, arg)
+		}
+
+		// The pattern only matches values starting with s itself
+		op, arg := operand(t, got[2])
+		pattern, err := regexp.Compile(arg.(string))
+		if op != "$regex" || err != nil {
+			t.Fatalf("prefix gives {%s: %#v}: %v", op, arg, err)
+		}
+		if !pattern.MatchString(s + "suffix") {
+			t.Fatalf("pattern %q does not match its own prefix %q", pattern, s)
+		}
+		other := "#" + s
+		if pattern.MatchString(other) != strings.HasPrefix(other, s) {
+			t.Fatalf("pattern %q matches %q, which does not start with %q", pattern, other, s)
+		}
+	})
+}
+
+// TestUpdatableFilter checks that the updates only match users who are
+// not erased, whichever way they are referenced
+func TestUpdatableFilter(t *testing.T) {
+	for _, ref := range []model.UserRef{
+		{PublicID: "0192a8e2-7b3c-7def-8000-0123456789ab"},
+		{ObjectID: primitive.NewObjectID()},
+	} {
+		filter := roundTrip(t, updatableFilter(ref))
+		i := slices.IndexFunc(filter, func(cond bson.E) bool { return cond.Key == "erased_at" })
+		if i < 0 || len(filter) != 2 {
+			t.Fatalf("filter of %s is %v, want the ref and erased_at", ref, filter)
+		}
+		if op, v := operand(t, filter[i]); op != "$eq" || v != nil {
+			t.Errorf("filter of %s matches erased_at %s %v, want $eq null", ref, op, v)
+		}
+	}
+}
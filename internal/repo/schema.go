@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"datadog-golang-example/internal/model"
+)
+
+// userSchemaVersion is the shape of the user documents written by this
+// version. Documents without a schema_version predate versioning and
+// are at version 1.
+const userSchemaVersion = 2
+
+// userConverters upgrade stored user documents, each from the version
+// before its key to its key, so older documents decode into the current
+// model.User. A converter must leave a document it does not apply to
+// untouched, such as one limited by a projection. To change the shape of
+// the documents, bump userSchemaVersion and register the converter.
+var userConverters = map[int]func(doc bson.M) error{
+	2: backfillNameKey,
+}
+
+// backfillNameKey sets the folded name of the documents written before
+// suggestions, as the backfill_name_keys migration does for all of them
+func backfillNameKey(doc bson.M) error {
+	if _, ok := doc["name_key"]; ok {
+		return nil
+	}
+	if name, ok := doc["name"].(string); ok {
+		doc["name_key"] = model.FoldName(name)
+	}
+	return nil
+}
+
+// schemaVersion returns the schema version of a stored user document
+func schemaVersion(raw bson.Raw) int {
+	v, err := raw.LookupErr("schema_version")
+	if err != nil {
+		return 1
+	}
+	n, ok := v.AsInt64OK()
+	if !ok {
+		return 1
+	}
+	return int(n)
+}
+
+// upgradeUser returns the stored user document raw converted to the
+// current schema. The schema_version it holds is kept, so the user
+// decoded from it reports the version it was stored at.
+func upgradeUser(raw bson.Raw) (bson.Raw, error) {
+	version := schemaVersion(raw)
+	if version >= userSchemaVersion {
+		return raw, nil
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	for v := version + 1; v <= userSchemaVersion; v++ {
+		if convert, ok := userConverters[v]; ok {
+			if err := convert(doc); err != nil {
+				return nil, fmt.Errorf("upgrade user to schema %d: %w", v, err)
+			}
+		}
+	}
+	return bson.Marshal(doc)
+}
+
+// decodeUser decodes a stored user document, upgraded to the current
+// schema, into v, a model.User or a type inlining one
+func decodeUser(raw bson.Raw, v any) error {
+	upgraded, err := upgradeUser(raw)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(upgraded, v)
+}
+
+// findUser decodes the user document found by result into user
+func findUser(result *mongo.SingleResult, user *model.User) error {
+	raw, err := result.Raw()
+	if err != nil {
+		return err
+	}
+	return decodeUser(raw, user)
+}
+
+// decodeUsers decodes every user document of cursor, as cursor.All does,
+// and tags the span of ctx with how many documents and bytes it decoded
+func decodeUsers[T any](ctx context.Context, cursor *mongo.Cursor) ([]T, error) {
+	var stats decodeStats
+	defer stats.tag(ctx)
+	users := []T{}
+	for cursor.Next(ctx) {
+		stats.add(cursor.Current)
+		var user T
+		if err := decodeUser(cursor.Current, &user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, cursor.Err()
+}
+
+// stale reports whether user was read from a document of an older
+// schema, which is rewritten by the next save
+func stale(user *model.User) bool {
+	return user.SchemaVersion < userSchemaVersion
+}
+
+// rewrite stores user, read from a document of an older schema, at the
+// current one. The user was already saved, so a failure, or a concurrent
+// write winning, only leaves the document to be upgraded again. It does
+// not go through the hooks, which would move its version.
+func (r *MongoUserRepository) rewrite(ctx context.Context, user *model.User) {
+	if !stale(user) {
+		return
+	}
+	user.SchemaVersion = userSchemaVersion
+	if _, err := r.Replace(ctx, user, user.Version, false); err != nil {
+		log.Printf("Failed to rewrite user %s at schema %d: %v", user.PublicID, userSchemaVersion, err)
+	}
+}
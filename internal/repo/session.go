@@ -0,0 +1,64 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CausalSessions starts the causally consistent sessions in which a
+// request reads its own writes, even from a secondary
+type CausalSessions struct {
+	client *mongo.Client
+}
+
+// NewCausalSessions creates the sessions of client
+func NewCausalSessions(client *mongo.Client) *CausalSessions {
+	return &CausalSessions{client: client}
+}
+
+// Start returns ctx carrying a new causally consistent session, which
+// every operation run with it joins, and the function ending the session.
+// The session must not be used concurrently.
+func (s *CausalSessions) Start(ctx context.Context) (context.Context, func(), error) {
+	sess, err := s.client.StartSession(options.Session().SetCausalConsistency(true))
+	if err != nil {
+		return ctx, func() {}, mapError("start session", err)
+	}
+	return mongo.NewSessionContext(ctx, sess), func() { sess.EndSession(context.WithoutCancel(ctx)) }, nil
+}
+
+// Topology describes the deployment a client is connected to
+type Topology struct {
+	SetName string   `bson:"setName"`
+	Primary string   `bson:"primary"`
+	Hosts   []string `bson:"hosts"`
+	// Msg is isdbgrid for a mongos
+	Msg string `bson:"msg"`
+}
+
+// String summarizes the topology for logs
+func (t Topology) String() string {
+	switch {
+	case t.SetName != "":
+		return fmt.Sprintf("replica set %s, primary %s, members %s", t.SetName, t.Primary, strings.Join(t.Hosts, ", "))
+	case t.Msg == "isdbgrid":
+		return "sharded cluster"
+	default:
+		return "standalone server"
+	}
+}
+
+// DescribeTopology asks the server the client selects what it is part of
+func DescribeTopology(ctx context.Context, client *mongo.Client) (Topology, error) {
+	var t Topology
+	err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&t)
+	if err != nil {
+		return Topology{}, mapError("describe topology", err)
+	}
+	return t, nil
+}
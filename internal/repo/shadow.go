@@ -0,0 +1,231 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+
+	"datadog-golang-example/internal/model"
+)
+
+// TaskSubmitter runs work in the background
+type TaskSubmitter interface {
+	Submit(ctx context.Context, name string, task func(ctx context.Context) error) error
+}
+
+// ShadowUserRepository dual-writes to a primary and a shadow repository,
+// as done when migrating between backends. The primary alone answers
+// requests; every write is replayed on the shadow in the background and
+// single-user reads are repeated there, and the results are compared to
+// emit divergence metrics.
+type ShadowUserRepository struct {
+	UserRepository
+	shadow  UserRepository
+	tasks   TaskSubmitter
+	metrics statsd.ClientInterface
+}
+
+// NewShadowUserRepository creates a repository serving from primary and
+// mirroring to shadow through tasks
+func NewShadowUserRepository(primary, shadow UserRepository, tasks TaskSubmitter, metrics statsd.ClientInterface) *ShadowUserRepository {
+	return &ShadowUserRepository{UserRepository: primary, shadow: shadow, tasks: tasks, metrics: metrics}
+}
+
+// EnsureIndexes creates the indexes of both repositories
+func (r *ShadowUserRepository) EnsureIndexes(ctx context.Context) error {
+	if err := r.UserRepository.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+	return r.shadow.EnsureIndexes(ctx)
+}
+
+// Create inserts into the primary, then mirrors the insert
+func (r *ShadowUserRepository) Create(ctx context.Context, user *model.User) error {
+	if err := r.UserRepository.Create(ctx, user); err != nil {
+		return err
+	}
+	mirrored := *user
+	r.mirror(ctx, "create", func(ctx context.Context) error {
+		return r.shadow.Create(ctx, &mirrored)
+	})
+	return nil
+}
+
+// Get reads from the primary and compares with the shadow
+func (r *ShadowUserRepository) Get(ctx context.Context, ref model.UserRef) (*model.User, error) {
+	user, err := r.UserRepository.Get(ctx, ref)
+	r.compareRead(ctx, "get", user, err, func(ctx context.Context) (*model.User, error) {
+		return r.shadow.Get(ctx, ref)
+	})
+	return user, err
+}
+
+// GetByUsername reads from the primary and compares with the shadow
+func (r *ShadowUserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	user, err := r.UserRepository.GetByUsername(ctx, username)
+	r.compareRead(ctx, "get_by_username", user, err, func(ctx context.Context) (*model.User, error) {
+		return r.shadow.GetByUsername(ctx, username)
+	})
+	return user, err
+}
+
+// Update updates the primary, then mirrors the update and compares the
+// resulting documents
+func (r *ShadowUserRepository) Update(ctx context.Context, ref model.UserRef, update model.UserUpdate) (*model.User, error) {
+	user, err := r.UserRepository.Update(ctx, ref, update)
+	if err != nil {
+		return nil, err
+	}
+	r.mirror(ctx, "update", func(ctx context.Context) error {
+		shadowed, err := r.shadow.Update(ctx, ref, update)
+		r.compare("update", user, shadowed, err)
+		return err
+	})
+	return user, nil
+}
+
+// BulkUpdate updates the primary, then mirrors the updates and compares
+// the counts
+func (r *ShadowUserRepository) BulkUpdate(ctx context.Context, updates []model.BulkUpdate) (*model.BulkUpdateResult, error) {
+	result, err := r.UserRepository.BulkUpdate(ctx, updates)
+	if err != nil {
+		return nil, err
+	}
+	r.mirror(ctx, "bulk_update", func(ctx context.Context) error {
+		shadowed, err := r.shadow.BulkUpdate(ctx, updates)
+		if err != nil {
+			return err
+		}
+		if shadowed.Modified != result.Modified || len(shadowed.Errors) != len(result.Errors) {
+			r.diverged("bulk_update", "counts")
+		} else {
+			r.matched("bulk_update")
+		}
+		return nil
+	})
+	return result, nil
+}
+
+// Replace replaces in the primary, then mirrors the replace
+func (r *ShadowUserRepository) Replace(ctx context.Context, user *model.User, version int64, upsert bool) (bool, error) {
+	created, err := r.UserRepository.Replace(ctx, user, version, upsert)
+	if err != nil {
+		return false, err
+	}
+	mirrored := *user
+	r.mirror(ctx, "replace", func(ctx context.Context) error {
+		// The primary already checked the version; the shadow may lag
+		_, err := r.shadow.Replace(ctx, &mirrored, version, true)
+		return err
+	})
+	return created, nil
+}
+
+// Delete deletes from the primary, then mirrors the delete
+func (r *ShadowUserRepository) Delete(ctx context.Context, ref model.UserRef) error {
+	if err := r.UserRepository.Delete(ctx, ref); err != nil {
+		return err
+	}
+	r.mirror(ctx, "delete", func(ctx context.Context) error {
+		err := r.shadow.Delete(ctx, ref)
+		if errors.Is(err, model.ErrNotFound) {
+			r.diverged("delete", "missing")
+			return nil
+		}
+		return err
+	})
+	return nil
+}
+
+// mirror runs a shadow write in the background, counting its outcome. A
+// failing shadow never fails the request.
+func (r *ShadowUserRepository) mirror(ctx context.Context, op string, write func(ctx context.Context) error) {
+	tags := []string{"op:" + op}
+	err := r.tasks.Submit(ctx, "shadow."+op, func(ctx context.Context) error {
+		err := write(ctx)
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+		}
+		r.metrics.Incr("shadow.writes", append(tags, "outcome:"+outcome), 1)
+		return err
+	})
+	if err != nil {
+		log.Printf("Skipping shadow %s: %v", op, err)
+		r.metrics.Incr("shadow.writes", append(tags, "outcome:skipped"), 1)
+	}
+}
+
+// compareRead repeats a read on the shadow in the background and compares
+// it with the primary's answer
+func (r *ShadowUserRepository) compareRead(ctx context.Context, op string, primary *model.User, primaryErr error, read func(ctx context.Context) (*model.User, error)) {
+	if primaryErr != nil && !errors.Is(primaryErr, model.ErrNotFound) {
+		return
+	}
+	_ = r.tasks.Submit(ctx, "shadow."+op, func(ctx context.Context) error {
+		shadowed, err := read(ctx)
+		if errors.Is(primaryErr, model.ErrNotFound) {
+			if errors.Is(err, model.ErrNotFound) {
+				r.matched(op)
+			} else {
+				r.diverged(op, "exists")
+			}
+			return nil
+		}
+		r.compare(op, primary, shadowed, err)
+		return nil
+	})
+}
+
+// compare reports whether the shadow document matches the primary one
+func (r *ShadowUserRepository) compare(op string, primary, shadowed *model.User, shadowErr error) {
+	switch {
+	case errors.Is(shadowErr, model.ErrNotFound):
+		r.diverged(op, "missing")
+		return
+	case shadowErr != nil:
+		return
+	}
+	fields := divergentFields(primary, shadowed)
+	if len(fields) == 0 {
+		r.matched(op)
+		return
+	}
+	for _, field := range fields {
+		r.diverged(op, field)
+	}
+}
+
+func (r *ShadowUserRepository) matched(op string) {
+	r.metrics.Incr("shadow.comparisons", []string{"op:" + op, "result:match"}, 1)
+}
+
+func (r *ShadowUserRepository) diverged(op, field string) {
+	r.metrics.Incr("shadow.comparisons", []string{"op:" + op, "result:divergent", "field:" + field}, 1)
+}
+
+// divergentFields lists the fields that differ between two versions of a
+// user. Timestamps are compared at the millisecond precision stores keep.
+func divergentFields(a, b *model.User) []string {
+	var fields []string
+	add := func(field string, differs bool) {
+		if differs {
+			fields = append(fields, field)
+		}
+	}
+	add("public_id", a.PublicID != b.PublicID)
+	add("username", a.Username != b.Username)
+	add("name", a.Name != b.Name)
+	add("email", a.Email != b.Email)
+	add("age", a.Age != b.Age)
+	add("location", (a.Location == nil) != (b.Location == nil) ||
+		a.Location != nil && b.Location != nil && !slices.Equal(a.Location.Coordinates, b.Location.Coordinates))
+	add("tags", !slices.Equal(a.Tags, b.Tags))
+	add("version", a.Version != b.Version)
+	add("updated_at", !a.UpdatedAt.Truncate(time.Millisecond).Equal(b.UpdatedAt.Truncate(time.Millisecond)))
+	return fields
+}
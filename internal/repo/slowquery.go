@@ -0,0 +1,244 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// explainable are the commands MongoDB can explain
+var explainable = map[string]bool{
+	"find":          true,
+	"aggregate":     true,
+	"count":         true,
+	"distinct":      true,
+	"update":        true,
+	"delete":        true,
+	"findAndModify": true,
+}
+
+// explainTimeout bounds the explain of a slow command
+const explainTimeout = 5 * time.Second
+
+// SlowQueryMonitor watches the commands of a client and explains those
+// slower than a threshold in the background. The plan summary is logged,
+// tagged on the span that issued the command and on a mongodb.explain
+// child span, and a mongodb.slow_queries metric is incremented.
+type SlowQueryMonitor struct {
+	threshold time.Duration
+	metrics   statsd.ClientInterface
+	next      *event.CommandMonitor
+
+	mu       sync.Mutex
+	client   *mongo.Client
+	inflight map[commandKey]startedCommand
+}
+
+// commandKey identifies a command on the wire
+type commandKey struct {
+	conn    string
+	request int64
+}
+
+// startedCommand is what is kept of a command until it finishes
+type startedCommand struct {
+	db      string
+	command bson.Raw
+	span    *tracer.Span
+}
+
+// NewSlowQueryMonitor creates a monitor for commands slower than
+// threshold. Events are passed on to next, such as the tracing monitor.
+func NewSlowQueryMonitor(threshold time.Duration, metrics statsd.ClientInterface, next *event.CommandMonitor) *SlowQueryMonitor {
+	return &SlowQueryMonitor{
+		threshold: threshold,
+		metrics:   metrics,
+		next:      next,
+		inflight:  make(map[commandKey]startedCommand),
+	}
+}
+
+// SetClient sets the client slow commands are explained with, which is
+// only known once the monitor is installed
+func (m *SlowQueryMonitor) SetClient(client *mongo.Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.client = client
+}
+
+// Monitor returns the driver monitor to install on the client
+func (m *SlowQueryMonitor) Monitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, evt *event.CommandStartedEvent) {
+			if explainable[evt.CommandName] {
+				span, _ := tracer.SpanFromContext(ctx)
+				m.mu.Lock()
+				m.inflight[commandKey{evt.ConnectionID, evt.RequestID}] = startedCommand{
+					db:      evt.DatabaseName,
+					command: append(bson.Raw(nil), evt.Command...),
+					span:    span,
+				}
+				m.mu.Unlock()
+			}
+			if m.next != nil && m.next.Started != nil {
+				m.next.Started(ctx, evt)
+			}
+		},
+		Succeeded: func(ctx context.Context, evt *event.CommandSucceededEvent) {
+			m.finished(evt.CommandFinishedEvent)
+			if m.next != nil && m.next.Succeeded != nil {
+				m.next.Succeeded(ctx, evt)
+			}
+		},
+		Failed: func(ctx context.Context, evt *event.CommandFailedEvent) {
+			m.finished(evt.CommandFinishedEvent)
+			if m.next != nil && m.next.Failed != nil {
+				m.next.Failed(ctx, evt)
+			}
+		},
+	}
+}
+
+// finished forgets a command and explains it when it was slow
+func (m *SlowQueryMonitor) finished(evt event.CommandFinishedEvent) {
+	key := commandKey{evt.ConnectionID, evt.RequestID}
+	m.mu.Lock()
+	cmd, ok := m.inflight[key]
+	delete(m.inflight, key)
+	client := m.client
+	m.mu.Unlock()
+	if !ok || evt.Duration < m.threshold {
+		return
+	}
+
+	coll, _ := cmd.command.Lookup(evt.CommandName).StringValueOK()
+	_ = m.metrics.Incr("mongodb.slow_queries", []string{"command:" + evt.CommandName, "collection:" + coll}, 1)
+	cmd.span.SetTag("mongodb.slow_query", true)
+	if client == nil {
+		return
+	}
+	go m.explain(client, cmd, evt.CommandName, coll, evt.Duration)
+}
+
+// explain runs the query planner on a slow command and reports its plan
+func (m *SlowQueryMonitor) explain(client *mongo.Client, cmd startedCommand, name, coll string, took time.Duration) {
+	opts := []tracer.StartSpanOption{tracer.ResourceName(name + " " + coll)}
+	if cmd.span != nil {
+		opts = append(opts, tracer.ChildOf(cmd.span.Context()))
+	}
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "mongodb.explain", opts...)
+	ctx, cancel := context.WithTimeout(ctx, explainTimeout)
+	defer cancel()
+
+	var result bson.Raw
+	err := client.Database(cmd.db).RunCommand(ctx, bson.D{
+		{Key: "explain", Value: explainCommand(cmd.command)},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}).Decode(&result)
+	if err != nil {
+		span.Finish(tracer.WithError(err))
+		log.Printf("Slow %s on %s.%s took %s, explain failed: %v", name, cmd.db, coll, took.Round(time.Millisecond), err)
+		return
+	}
+
+	summary := planSummary(result)
+	span.SetTag("mongodb.plan_summary", summary)
+	span.Finish()
+	// Only sticks when the issuing span is still open
+	cmd.span.SetTag("mongodb.plan_summary", summary)
+	log.Printf("Slow %s on %s.%s took %s, plan: %s", name, cmd.db, coll, took.Round(time.Millisecond), summary)
+}
+
+// explainCommand strips the session and routing fields the driver adds to
+// a command, which explain rejects
+func explainCommand(command bson.Raw) bson.D {
+	elems, _ := command.Elements()
+	cmd := make(bson.D, 0, len(elems))
+	for _, e := range elems {
+		switch key := e.Key(); {
+		case strings.HasPrefix(key, "$"), key == "lsid", key == "txnNumber", key == "readConcern", key == "writeConcern":
+		default:
+			cmd = append(cmd, bson.E{Key: key, Value: e.Value()})
+		}
+	}
+	return cmd
+}
+
+// planSummary condenses the winning plan of an explain result into the
+// form of MongoDB's slow query log, such as "IXSCAN { username: 1 }"
+func planSummary(result bson.Raw) string {
+	planner, ok := result.Lookup("queryPlanner").DocumentOK()
+	if !ok {
+		// Aggregations nest the planner of their first stage
+		planner, ok = result.Lookup("stages", "0", "$cursor", "queryPlanner").DocumentOK()
+		if !ok {
+			return "unknown"
+		}
+	}
+	plan, ok := planner.Lookup("winningPlan").DocumentOK()
+	if !ok {
+		return "unknown"
+	}
+	// The slot based engine wraps the classic plan
+	if inner, ok := plan.Lookup("queryPlan").DocumentOK(); ok {
+		plan = inner
+	}
+
+	var scans []string
+	collectScans(plan, &scans)
+	if len(scans) == 0 {
+		stage, _ := plan.Lookup("stage").StringValueOK()
+		return stage
+	}
+	return strings.Join(scans, ", ")
+}
+
+// collectScans appends the leaf stages of plan, with the key pattern of
+// index scans
+func collectScans(plan bson.Raw, scans *[]string) {
+	stage, _ := plan.Lookup("stage").StringValueOK()
+	switch stage {
+	case "COLLSCAN", "EOF":
+		*scans = append(*scans, stage)
+		return
+	case "IXSCAN", "COUNT_SCAN", "DISTINCT_SCAN", "GEO_NEAR_2DSPHERE":
+		keys, _ := plan.Lookup("keyPattern").DocumentOK()
+		*scans = append(*scans, stage+" "+formatKeys(keys))
+		return
+	}
+	if input, ok := plan.Lookup("inputStage").DocumentOK(); ok {
+		collectScans(input, scans)
+	}
+	if inputs, ok := plan.Lookup("inputStages").ArrayOK(); ok {
+		values, _ := inputs.Values()
+		for _, v := range values {
+			if doc, ok := v.DocumentOK(); ok {
+				collectScans(doc, scans)
+			}
+		}
+	}
+}
+
+// formatKeys formats an index key pattern as MongoDB logs it
+func formatKeys(keys bson.Raw) string {
+	elems, _ := keys.Elements()
+	parts := make([]string, 0, len(elems))
+	for _, e := range elems {
+		v := e.Value()
+		if n, ok := v.AsInt64OK(); ok {
+			parts = append(parts, fmt.Sprintf("%s: %d", e.Key(), n))
+		} else if str, ok := v.StringValueOK(); ok {
+			parts = append(parts, fmt.Sprintf("%s: %q", e.Key(), str))
+		}
+	}
+	return "{ " + strings.Join(parts, ", ") + " }"
+}
@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"datadog-golang-example/internal/model"
+)
+
+// topTags is the number of tags the user statistics count
+const topTags = 10
+
+// UserStatistics aggregates the statistics of every user
+type UserStatistics struct {
+	users *mongo.Collection
+}
+
+// NewUserStatistics creates the statistics of the users of db
+func NewUserStatistics(db *mongo.Database) *UserStatistics {
+	return &UserStatistics{users: db.Collection("users")}
+}
+
+// Compute scans every user once, summing them up and counting their most
+// used tags in the two facets of one aggregation
+func (s *UserStatistics) Compute(ctx context.Context) (*model.UserStats, error) {
+	return retryRead(ctx, func() (*model.UserStats, error) {
+		cursor, err := s.users.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$facet", Value: bson.M{
+				"totals": bson.A{
+					bson.M{"$group": bson.M{
+						"_id":           nil,
+						"users":         bson.M{"$sum": 1},
+						"min":           bson.M{"$min": "$age"},
+						"max":           bson.M{"$max": "$age"},
+						"avg":           bson.M{"$avg": "$age"},
+						"in_orgs":       bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$" + fieldOrgID.path, nil}}, 1, 0}}},
+						"with_location": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$location", nil}}, 1, 0}}},
+					}},
+				},
+				"tags": bson.A{
+					bson.M{"$unwind": "$" + fieldTags.path},
+					bson.M{"$group": bson.M{"_id": "$" + fieldTags.path, "users": bson.M{"$sum": 1}}},
+					bson.M{"$sort": bson.D{{Key: "users", Value: -1}, {Key: "_id", Value: 1}}},
+					bson.M{"$limit": topTags},
+				},
+			}}},
+		})
+		if err != nil {
+			return nil, mapError("aggregate user stats", err)
+		}
+		var facets []struct {
+			Totals []struct {
+				Users        int64   `bson:"users"`
+				Min          int     `bson:"min"`
+				Max          int     `bson:"max"`
+				Avg          float64 `bson:"avg"`
+				InOrgs       int64   `bson:"in_orgs"`
+				WithLocation int64   `bson:"with_location"`
+			} `bson:"totals"`
+			Tags []model.TagCount `bson:"tags"`
+		}
+		if err := cursor.All(ctx, &facets); err != nil {
+			return nil, mapError("aggregate user stats", err)
+		}
+		stats := &model.UserStats{Tags: []model.TagCount{}}
+		if len(facets) == 0 {
+			return stats, nil
+		}
+		if len(facets[0].Totals) > 0 {
+			t := facets[0].Totals[0]
+			stats.Users, stats.InOrgs, stats.WithLocation = t.Users, t.InOrgs, t.WithLocation
+			stats.Age = model.AgeStats{Min: t.Min, Max: t.Max, Avg: t.Avg}
+		}
+		stats.Tags = append(stats.Tags, facets[0].Tags...)
+		return stats, nil
+	})
+}
+
+// MySQLUserStatistics aggregates the statistics of the users of a MySQL
+// store
+type MySQLUserStatistics struct {
+	db *sql.DB
+}
+
+// NewMySQLUserStatistics creates the statistics of the users of db
+func NewMySQLUserStatistics(db *sql.DB) *MySQLUserStatistics {
+	return &MySQLUserStatistics{db: db}
+}
+
+// Compute sums up every user, then counts their most used tags
+func (s *MySQLUserStatistics) Compute(ctx context.Context) (*model.UserStats, error) {
+	stats := &model.UserStats{Tags: []model.TagCount{}}
+	var minAge, maxAge sql.NullInt64
+	var avgAge sql.NullFloat64
+	err := s.db.QueryRowContext(ctx,
+		"SELECT COUNT(*), MIN(age), MAX(age), AVG(age), COUNT(org_id), COUNT(lat) FROM users",
+	).Scan(&stats.Users, &minAge, &maxAge, &avgAge, &stats.InOrgs, &stats.WithLocation)
+	if err != nil {
+		return nil, mapMySQLError("aggregate user stats", err)
+	}
+	stats.Age = model.AgeStats{Min: int(minAge.Int64), Max: int(maxAge.Int64), Avg: avgAge.Float64}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT t.tag, COUNT(*) AS users
+FROM users, JSON_TABLE(users.tags, '$[*]' COLUMNS (tag VARCHAR(32) PATH '$')) AS t
+GROUP BY t.tag ORDER BY users DESC, t.tag LIMIT ?`, topTags)
+	if err != nil {
+		return nil, mapMySQLError("aggregate user tags", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag model.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Users); err != nil {
+			return nil, mapMySQLError("aggregate user tags", err)
+		}
+		stats.Tags = append(stats.Tags, tag)
+	}
+	return stats, mapMySQLError("aggregate user tags", rows.Err())
+}
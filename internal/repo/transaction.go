@@ -0,0 +1,57 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+
+	"datadog-golang-example/internal/model"
+)
+
+// errNoTransactions is returned when the deployment cannot run
+// transactions
+var errNoTransactions = fmt.Errorf("transactions need a replica set or a sharded cluster: %w", model.ErrUnavailable)
+
+// illegalOperation is the code of the error a standalone server answers
+// transactions with
+const illegalOperation = 20
+
+// Transactions runs functions in MongoDB transactions
+type Transactions struct {
+	client *mongo.Client
+}
+
+// NewTransactions creates the transactions of client
+func NewTransactions(client *mongo.Client) *Transactions {
+	return &Transactions{client: client}
+}
+
+// Run runs fn in a transaction, committed when fn returns nil and aborted
+// otherwise. Every operation run with the context fn receives joins the
+// transaction, which the driver retries as a whole on transient errors,
+// so fn must not have other side effects. The error of fn is returned
+// as it is.
+func (t *Transactions) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	sess, err := t.client.StartSession()
+	if err != nil {
+		return mapError("start session", err)
+	}
+	defer sess.EndSession(context.WithoutCancel(ctx))
+
+	opts := options.Transaction().
+		SetReadConcern(readconcern.Snapshot()).
+		SetWriteConcern(writeconcern.Majority())
+	_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	}, opts)
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(illegalOperation) {
+		return errNoTransactions
+	}
+	return err
+}
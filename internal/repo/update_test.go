@@ -0,0 +1,112 @@
+package repo
+
+import (
+	"slices"
+	"testing"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"datadog-golang-example/internal/fieldcrypt"
+	"datadog-golang-example/internal/model"
+)
+
+// updateFields are the operators updateDoc may write, with the fields
+// each may touch
+var updateFields = map[string][]string{
+	"$set":      {"updated_at", "name", "name_key", "email", "email_risk", "email_hash", "age", "location", "org_id"},
+	"$inc":      {"version"},
+	"$addToSet": {"tags"},
+	"$pull":     {"tags"},
+	"$unset":    {"email", "email_risk", "email_hash", "location"},
+}
+
+func FuzzUpdateDoc(f *testing.F) {
+	f.Add("Alice", "alice@example.com", 30, "beta", false, true)
+	f.Add("$set", "$where", 0, "$each", true, false)
+	f.Add(`{"$gt": ""}`, "a@b.c", -1, "", false, false)
+	f.Add("name.first", "email.$", 151, "tags.$[]", true, true)
+
+	r := NewMongoUserRepository(nil, MongoOptions{})
+	f.Fuzz(func(t *testing.T, name, email string, age int, tag string, addTag, unset bool) {
+		if !utf8.ValidString(name + email + tag) {
+			t.Skip("BSON strings are UTF-8, the server rejects others")
+		}
+		nameKey := model.FoldName(name)
+		update := model.UserUpdate{Name: &name, NameKey: &nameKey, Email: &email, Age: &age}
+		if addTag {
+			update.AddTags = []string{tag}
+		} else {
+			update.RemoveTags = []string{tag}
+		}
+		if unset {
+			update.Unset = []string{"email", "location"}
+		}
+
+		for _, op := range roundTrip(t, r.updateDoc(update)) {
+			allowed, ok := updateFields[op.Key]
+			if !ok {
+				t.Fatalf("update has the operator %q", op.Key)
+			}
+			for _, field := range op.Value.(bson.D) {
+				if !slices.Contains(allowed, field.Key) {
+					t.Fatalf("%s touches %q, want one of %v", op.Key, field.Key, allowed)
+				}
+				checkUpdateValue(t, op.Key, field, name, email, tag)
+			}
+		}
+	})
+}
+
+// checkUpdateValue fails unless the value an update operator writes to a
+// field is the input itself, or the tag list of a tag operator
+func checkUpdateValue(t *testing.T, op string, field bson.E, name, email, tag string) {
+	t.Helper()
+	switch {
+	case field.Key == "tags":
+		list, ok := field.Value.(bson.D)
+		want := map[string]string{"$addToSet": "$each", "$pull": "$in"}[op]
+		if !ok || len(list) != 1 || list[0].Key != want || !slices.Equal(list[0].Value.(bson.A), bson.A{tag}) {
+			t.Fatalf("%s of tags is %#v, want {%s: [%q]}", op, field.Value, want, tag)
+		}
+	case op == "$set" && field.Key == "name" && field.Value != name,
+		op == "$set" && field.Key == "email" && field.Value != email:
+		t.Fatalf("$set of %s is %#v", field.Key, field.Value)
+	}
+}
+
+// TestEmailHashAcrossRotation checks that an email stored under the old
+// and the new key of a rotation gets the same blind index, so the unique
+// index on it still refuses the duplicate
+func TestEmailHashAcrossRotation(t *testing.T) {
+	const (
+		oldKey   = "2024-01:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+		newKey   = "2024-06:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+		indexKey = "AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI="
+	)
+	var repos []*MongoUserRepository
+	for _, spec := range []string{oldKey, newKey + "," + oldKey} {
+		keys, err := fieldcrypt.ParseKeys(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keys.SetIndexKey(indexKey); err != nil {
+			t.Fatal(err)
+		}
+		repos = append(repos, NewMongoUserRepository(nil, MongoOptions{Encryption: keys}))
+	}
+
+	user := &model.User{Email: "ana@example.com"}
+	before, after := repos[0].sealed(user), repos[1].sealed(user)
+	if before.Email == after.Email {
+		t.Fatalf("the email is stored as %q under both keys", before.Email)
+	}
+	if before.EmailHash == "" || before.EmailHash != after.EmailHash {
+		t.Fatalf("blind indexes %q and %q, want equal ones", before.EmailHash, after.EmailHash)
+	}
+	email := "ana@example.com"
+	set := repos[1].updateDoc(model.UserUpdate{Email: &email})["$set"].(bson.M)
+	if set["email_hash"] != before.EmailHash {
+		t.Errorf("an update sets email_hash %v, want %q", set["email_hash"], before.EmailHash)
+	}
+}
@@ -0,0 +1,592 @@
+// Package repo implements persistence for the domain model.
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"datadog-golang-example/internal/fieldcrypt"
+	"datadog-golang-example/internal/model"
+)
+
+// UserRepository stores and retrieves users
+type UserRepository interface {
+	EnsureIndexes(ctx context.Context) error
+	Create(ctx context.Context, user *model.User) error
+	List(ctx context.Context, filter model.UserFilter) ([]model.User, error)
+	Stream(ctx context.Context, filter model.UserFilter) iter.Seq2[model.User, error]
+	Count(ctx context.Context, filter model.UserFilter, exact bool) (int64, error)
+	Get(ctx context.Context, ref model.UserRef) (*model.User, error)
+	GetMany(ctx context.Context, refs []model.UserRef) ([]model.User, error)
+	GetByUsername(ctx context.Context, username string) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	Suggest(ctx context.Context, prefix string, limit int) ([]model.Suggestion, error)
+	Nearby(ctx context.Context, point *model.GeoPoint, radiusMeters float64, limit int) ([]model.NearbyUser, error)
+	Update(ctx context.Context, ref model.UserRef, update model.UserUpdate) (*model.User, error)
+	BulkUpdate(ctx context.Context, updates []model.BulkUpdate) (*model.BulkUpdateResult, error)
+	Replace(ctx context.Context, user *model.User, version int64, upsert bool) (created bool, err error)
+	Delete(ctx context.Context, ref model.UserRef) error
+}
+
+// UserStore is a UserRepository along with what the application uses of
+// the store itself: imports, the index check of the preflight, the slow
+// query of the debug routes and the member counts of inspection
+type UserStore interface {
+	UserRepository
+	Import(ctx context.Context, users []model.ImportedUser) (*model.ImportResult, error)
+	SlowQuery(ctx context.Context, sleep, maxTime time.Duration) error
+	CheckIndexes(ctx context.Context) error
+	CountByOrg(ctx context.Context) ([]model.OrgCount, error)
+}
+
+// MongoOptions tunes the MongoDB repository
+type MongoOptions struct {
+	// AtlasSearchIndex is the Atlas Search index used for suggestions;
+	// when empty a prefix query on the name_key index is used instead
+	AtlasSearchIndex string
+	// SortFields and FilterFields are the fields lists may be sorted and
+	// filtered by. Fields without an index are ignored.
+	SortFields   []string
+	FilterFields []string
+	// Encryption encrypts emails at rest when set
+	Encryption *fieldcrypt.Keyring
+	// ListFields are the JSON fields read by lists that ask for none, all
+	// of them when empty
+	ListFields []string
+	// BatchSize is the number of users a list cursor asks for at a time;
+	// zero uses the server default for pages and streamBatchSize for
+	// streams
+	BatchSize int32
+}
+
+// MongoUserRepository is a UserRepository backed by a MongoDB collection
+type MongoUserRepository struct {
+	coll           *mongo.Collection
+	opts           MongoOptions
+	sortFields     []string
+	filterFields   []string
+	listProjection bson.D
+}
+
+// NewMongoUserRepository creates a repository for the given collection
+func NewMongoUserRepository(coll *mongo.Collection, opts MongoOptions) *MongoUserRepository {
+	return &MongoUserRepository{
+		coll:           coll,
+		opts:           opts,
+		sortFields:     indexedFields("sort", opts.SortFields),
+		filterFields:   indexedFields("filter", opts.FilterFields),
+		listProjection: listProjection(opts.ListFields),
+	}
+}
+
+// uniqueIndexFields maps each unique index name to the field it guards
+var uniqueIndexFields = map[string]string{
+	"public_id_1":  "public_id",
+	"username_1":   "username",
+	"email_1":      "email",
+	"email_hash_1": "email",
+	// Only unique on organizations
+	"name_key_1": "name",
+}
+
+// EnsureIndexes creates the indexes the repository relies on
+func (r *MongoUserRepository) EnsureIndexes(ctx context.Context) error {
+	specs := indexesOf(r.coll.Name())
+	models := make([]mongo.IndexModel, 0, len(specs))
+	for _, s := range specs {
+		models = append(models, s.model())
+	}
+	_, err := r.coll.Indexes().CreateMany(ctx, models)
+	return mapError("create indexes", err)
+}
+
+// CheckIndexes returns an error naming the indexes of EnsureIndexes that
+// are missing from the collection
+func (r *MongoUserRepository) CheckIndexes(ctx context.Context) error {
+	specs, err := r.coll.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return mapError("list indexes", err)
+	}
+	have := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		have[spec.Name] = true
+	}
+	var missing []string
+	for _, s := range indexesOf(r.coll.Name()) {
+		if !have[s.Name()] {
+			missing = append(missing, s.Name())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing indexes: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// refFilter returns the query matching the referenced user
+func refFilter(ref model.UserRef) bson.D {
+	if ref.PublicID != "" {
+		return newQuery().eq(fieldPublicID, str(ref.PublicID)).filter()
+	}
+	return newQuery().eq(fieldID, oid(ref.ObjectID)).filter()
+}
+
+// updatableFilter matches the user ref addresses unless it is erased
+func updatableFilter(ref model.UserRef) bson.D {
+	return append(refFilter(ref), newQuery().eq(fieldErasedAt, null()).filter()...)
+}
+
+// Create inserts a new user, stamped by the UserHooks of NewUserHooks
+func (r *MongoUserRepository) Create(ctx context.Context, user *model.User) error {
+	result, err := r.coll.InsertOne(ctx, r.sealed(user))
+	if err != nil {
+		return mapError("insert user", err)
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// List returns the users matching filter, in its order. Filtering or
+// sorting by a field that is not allowed is a model.ErrValidation.
+func (r *MongoUserRepository) List(ctx context.Context, filter model.UserFilter) ([]model.User, error) {
+	return retryRead(ctx, func() ([]model.User, error) {
+		query, opts, hint, err := r.listQuery(filter)
+		if err != nil {
+			return nil, err
+		}
+		tagIndexHint(ctx, hint)
+		cursor, err := r.coll.Find(ctx, query, opts)
+		if err != nil {
+			return nil, mapError("find users", err)
+		}
+		defer cursor.Close(ctx)
+
+		users, err := decodeUsers[model.User](ctx, cursor)
+		if err != nil {
+			return nil, mapError("decode users", err)
+		}
+		return users, r.openAll(users)
+	})
+}
+
+// streamBatchSize is the number of users a stream holds in memory, the
+// size of the batches it asks the cursor for unless BatchSize is set
+const streamBatchSize = 100
+
+// Stream yields the users matching filter, in its order, as the cursor
+// returns them, so a list of any size is read in bounded memory. An error
+// ends the sequence. The documents and bytes decoded are tagged on the span
+// of ctx once it ends.
+func (r *MongoUserRepository) Stream(ctx context.Context, filter model.UserFilter) iter.Seq2[model.User, error] {
+	return func(yield func(model.User, error) bool) {
+		query, opts, hint, err := r.listQuery(filter)
+		if err != nil {
+			yield(model.User{}, err)
+			return
+		}
+		tagIndexHint(ctx, hint)
+		if r.opts.BatchSize == 0 {
+			opts.SetBatchSize(streamBatchSize)
+		}
+		cursor, err := r.coll.Find(ctx, query, opts)
+		if err != nil {
+			yield(model.User{}, mapError("find users", err))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		var stats decodeStats
+		defer stats.tag(ctx)
+		for cursor.Next(ctx) {
+			stats.add(cursor.Current)
+			var user model.User
+			if err := decodeUser(cursor.Current, &user); err != nil {
+				yield(model.User{}, mapError("decode user", err))
+				return
+			}
+			if err := r.open(&user); err != nil {
+				yield(model.User{}, err)
+				return
+			}
+			if !yield(user, nil) {
+				return
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			yield(model.User{}, mapError("stream users", err))
+		}
+	}
+}
+
+// Count returns the number of users matching filter, ignoring its sort,
+// page and fields. Without exact, the count is read from the collection
+// metadata, which is fast but may be off after an unclean shutdown and
+// cannot be filtered.
+func (r *MongoUserRepository) Count(ctx context.Context, filter model.UserFilter, exact bool) (int64, error) {
+	return retryRead(ctx, func() (int64, error) {
+		if !exact {
+			if len(filter.Filters) > 0 || filter.OrgID != "" {
+				return 0, &model.ValidationError{Field: "count", Reason: "cannot be estimated for filtered queries"}
+			}
+			n, err := r.coll.EstimatedDocumentCount(ctx)
+			return n, mapError("estimate users", err)
+		}
+
+		query, _, hint, err := r.listQuery(model.UserFilter{Filters: filter.Filters, OrgID: filter.OrgID})
+		if err != nil {
+			return 0, err
+		}
+		opts := options.Count()
+		if len(query) > 0 {
+			opts.SetHint(hint)
+		}
+		n, err := r.coll.CountDocuments(ctx, query, opts)
+		return n, mapError("count users", err)
+	})
+}
+
+// CountByOrg returns the number of users of each organization, the
+// largest first, the users in none counted under an empty ID
+func (r *MongoUserRepository) CountByOrg(ctx context.Context) ([]model.OrgCount, error) {
+	return retryRead(ctx, func() ([]model.OrgCount, error) {
+		cursor, err := r.coll.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$group", Value: bson.M{"_id": "$" + fieldOrgID.path, "users": bson.M{"$sum": 1}}}},
+			{{Key: "$sort", Value: bson.D{{Key: "users", Value: -1}, {Key: "_id", Value: 1}}}},
+		})
+		if err != nil {
+			return nil, mapError("count users by org", err)
+		}
+		defer cursor.Close(ctx)
+
+		counts := []model.OrgCount{}
+		if err := cursor.All(ctx, &counts); err != nil {
+			return nil, mapError("count users by org", err)
+		}
+		return counts, nil
+	})
+}
+
+// Get returns the referenced user, or model.ErrNotFound
+func (r *MongoUserRepository) Get(ctx context.Context, ref model.UserRef) (*model.User, error) {
+	return retryRead(ctx, func() (*model.User, error) {
+		var user model.User
+		if err := findUser(r.coll.FindOne(ctx, refFilter(ref)), &user); err != nil {
+			return nil, mapError("find user", err)
+		}
+		return &user, r.open(&user)
+	})
+}
+
+// GetMany returns the users matching any of refs in a single query.
+// References that match nothing are simply absent from the result.
+func (r *MongoUserRepository) GetMany(ctx context.Context, refs []model.UserRef) ([]model.User, error) {
+	return retryRead(ctx, func() ([]model.User, error) {
+		var objectIDs, publicIDs []value
+		for _, ref := range refs {
+			if ref.PublicID != "" {
+				publicIDs = append(publicIDs, str(ref.PublicID))
+			} else {
+				objectIDs = append(objectIDs, oid(ref.ObjectID))
+			}
+		}
+
+		var or []*query
+		if len(objectIDs) > 0 {
+			or = append(or, newQuery().in(fieldID, objectIDs))
+		}
+		if len(publicIDs) > 0 {
+			or = append(or, newQuery().in(fieldPublicID, publicIDs))
+		}
+		if len(or) == 0 {
+			return []model.User{}, nil
+		}
+
+		cursor, err := r.coll.Find(ctx, newQuery().or(or...).filter())
+		if err != nil {
+			return nil, mapError("find users", err)
+		}
+		defer cursor.Close(ctx)
+
+		users, err := decodeUsers[model.User](ctx, cursor)
+		if err != nil {
+			return nil, mapError("decode users", err)
+		}
+		return users, r.openAll(users)
+	})
+}
+
+// GetByUsername returns the user with the given username, or model.ErrNotFound
+func (r *MongoUserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	return retryRead(ctx, func() (*model.User, error) {
+		var user model.User
+		if err := findUser(r.coll.FindOne(ctx, newQuery().eq(fieldUsername, str(username)).filter()), &user); err != nil {
+			return nil, mapError("find user", err)
+		}
+		return &user, r.open(&user)
+	})
+}
+
+// GetByEmail returns the user with the given email, or model.ErrNotFound
+func (r *MongoUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return retryRead(ctx, func() (*model.User, error) {
+		var user model.User
+		if err := findUser(r.coll.FindOne(ctx, r.whereEmail(newQuery(), email).filter()), &user); err != nil {
+			return nil, mapError("find user", err)
+		}
+		return &user, r.open(&user)
+	})
+}
+
+// suggestionProjection limits suggestion queries to the returned fields
+var suggestionProjection = bson.M{"_id": 0, "public_id": 1, "name": 1, "username": 1}
+
+// Suggest returns up to limit users whose name starts with prefix. The
+// prefix must already be folded with model.FoldName.
+func (r *MongoUserRepository) Suggest(ctx context.Context, prefix string, limit int) ([]model.Suggestion, error) {
+	return retryRead(ctx, func() ([]model.Suggestion, error) {
+		var (
+			cursor *mongo.Cursor
+			err    error
+		)
+		if r.opts.AtlasSearchIndex != "" {
+			cursor, err = r.coll.Aggregate(ctx, mongo.Pipeline{
+				{{Key: "$search", Value: bson.M{
+					"index":        r.opts.AtlasSearchIndex,
+					"autocomplete": bson.M{"query": prefix, "path": "name"},
+				}}},
+				{{Key: "$limit", Value: limit}},
+				{{Key: "$project", Value: suggestionProjection}},
+			})
+		} else {
+			cursor, err = r.coll.Find(ctx,
+				newQuery().prefix(fieldNameKey, prefix).filter(),
+				options.Find().
+					SetProjection(suggestionProjection).
+					SetSort(bson.D{{Key: "name_key", Value: 1}}).
+					SetLimit(int64(limit)),
+			)
+		}
+		if err != nil {
+			return nil, mapError("suggest users", err)
+		}
+		defer cursor.Close(ctx)
+
+		suggestions := []model.Suggestion{}
+		if err = cursor.All(ctx, &suggestions); err != nil {
+			return nil, mapError("decode suggestions", err)
+		}
+		return suggestions, nil
+	})
+}
+
+// Nearby returns up to limit users within radiusMeters of point, closest
+// first, with their distance. $geoNear is the aggregation form of
+// $nearSphere and is used because it can report the distance.
+func (r *MongoUserRepository) Nearby(ctx context.Context, point *model.GeoPoint, radiusMeters float64, limit int) ([]model.NearbyUser, error) {
+	return retryRead(ctx, func() ([]model.NearbyUser, error) {
+		cursor, err := r.coll.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$geoNear", Value: bson.M{
+				"near":          point,
+				"distanceField": "distance_m",
+				"maxDistance":   radiusMeters,
+				"spherical":     true,
+			}}},
+			{{Key: "$limit", Value: limit}},
+		})
+		if err != nil {
+			return nil, mapError("find nearby users", err)
+		}
+		defer cursor.Close(ctx)
+
+		users, err := decodeUsers[model.NearbyUser](ctx, cursor)
+		if err != nil {
+			return nil, mapError("decode nearby users", err)
+		}
+		for i := range users {
+			if err := r.open(&users[i].User); err != nil {
+				return nil, err
+			}
+		}
+		return users, nil
+	})
+}
+
+// SlowQuery runs a query that sleeps server-side for sleep but is capped
+// at maxTime, producing a genuine MaxTimeMSExpired timeout. It exists for
+// the error tracking demo routes only.
+func (r *MongoUserRepository) SlowQuery(ctx context.Context, sleep, maxTime time.Duration) error {
+	cursor, err := r.coll.Find(ctx,
+		bson.M{"$where": fmt.Sprintf("sleep(%d) || true", sleep.Milliseconds())},
+		options.Find().SetMaxTime(maxTime).SetLimit(1),
+	)
+	if err != nil {
+		return mapError("slow query", err)
+	}
+	defer cursor.Close(ctx)
+	cursor.Next(ctx)
+	return mapError("slow query", cursor.Err())
+}
+
+// Update applies a partial update and returns the updated user, or
+// model.ErrNotFound when no user matches and ErrUserErased when the user
+// is erased
+func (r *MongoUserRepository) Update(ctx context.Context, ref model.UserRef, update model.UserUpdate) (*model.User, error) {
+	result, err := r.coll.UpdateOne(ctx, updatableFilter(ref), r.updateDoc(update))
+	if err != nil {
+		return nil, mapError("update user", err)
+	}
+	if result.MatchedCount == 0 {
+		// Tell an erased user from a missing one
+		user, err := r.Get(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		if err := updatable(user); err != nil {
+			return nil, err
+		}
+		return nil, errUserNotFound
+	}
+
+	// Fetch and return updated user
+	user, err := r.Get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	r.rewrite(ctx, user)
+	return user, nil
+}
+
+// Replace replaces the whole document of user, stamped by the UserHooks
+// of NewUserHooks. Without upsert, only a stored document at the given
+// version is replaced and model.ErrNotFound is returned otherwise. With
+// upsert, a missing document is inserted and created reports it.
+func (r *MongoUserRepository) Replace(ctx context.Context, user *model.User, version int64, upsert bool) (bool, error) {
+	q := newQuery().eq(fieldPublicID, str(user.PublicID))
+	if !upsert {
+		if version != 0 {
+			q.eq(fieldVersion, num(version))
+		} else {
+			// Documents written before versioning have no version field
+			q.in(fieldVersion, []value{num(0), null()})
+		}
+	}
+
+	result, err := r.coll.ReplaceOne(ctx, q.filter(), r.sealed(user), options.Replace().SetUpsert(upsert))
+	if err != nil {
+		return false, mapError("replace user", err)
+	}
+	if result.UpsertedCount == 1 {
+		return true, nil
+	}
+	if result.MatchedCount == 0 {
+		return false, errUserNotFound
+	}
+	return false, nil
+}
+
+// BulkUpdate applies many partial updates in one unordered bulk write.
+// Writes that fail, such as duplicate emails, are reported per item by
+// index; the others are still applied. Erased users are not matched.
+func (r *MongoUserRepository) BulkUpdate(ctx context.Context, updates []model.BulkUpdate) (*model.BulkUpdateResult, error) {
+	models := make([]mongo.WriteModel, len(updates))
+	for i, u := range updates {
+		models[i] = mongo.NewUpdateOneModel().SetFilter(updatableFilter(u.Ref)).SetUpdate(r.updateDoc(u.Update))
+	}
+
+	result := &model.BulkUpdateResult{Errors: []model.BulkItemError{}}
+	res, err := r.coll.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if res != nil {
+		result.Matched, result.Modified = res.MatchedCount, res.ModifiedCount
+	}
+	var bulkErr mongo.BulkWriteException
+	if errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil {
+		for _, we := range bulkErr.WriteErrors {
+			u := updates[we.Index]
+			result.Errors = append(result.Errors, model.BulkItemError{
+				Index: u.Index,
+				ID:    u.Ref.String(),
+				Err:   mapError("update user", we),
+			})
+		}
+		return result, nil
+	}
+	if err != nil {
+		return nil, mapError("bulk update users", err)
+	}
+	return result, nil
+}
+
+// updateDoc builds the $set/$unset update document of a partial update
+func (r *MongoUserRepository) updateDoc(update model.UserUpdate) bson.M {
+	set := bson.M{"updated_at": update.UpdatedAt}
+	unset := bson.M{}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.NameKey != nil {
+		set["name_key"] = *update.NameKey
+	}
+	if update.Email != nil {
+		set["email"] = r.sealedEmail(*update.Email)
+		if hash := r.emailHash(*update.Email); hash != "" {
+			set["email_hash"] = hash
+		}
+	}
+	// A verified address carries no risk, which is left unset as on create
+	switch {
+	case update.EmailRisk == nil:
+	case *update.EmailRisk == "":
+		unset["email_risk"] = ""
+	default:
+		set["email_risk"] = *update.EmailRisk
+	}
+	if update.Age != nil {
+		set["age"] = *update.Age
+	}
+	if update.Location != nil {
+		set["location"] = update.Location
+	}
+	if update.OrgID != nil {
+		set["org_id"] = *update.OrgID
+	}
+
+	doc := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(update.AddTags) > 0 {
+		doc["$addToSet"] = bson.M{"tags": bson.M{"$each": update.AddTags}}
+	}
+	if len(update.RemoveTags) > 0 {
+		doc["$pull"] = bson.M{"tags": bson.M{"$in": update.RemoveTags}}
+	}
+	for _, field := range update.Unset {
+		unset[field] = ""
+		if field == "email" {
+			unset["email_risk"] = ""
+			unset["email_hash"] = ""
+		}
+	}
+	if len(unset) > 0 {
+		doc["$unset"] = unset
+	}
+	return doc
+}
+
+// Delete removes the referenced user, or returns model.ErrNotFound
+func (r *MongoUserRepository) Delete(ctx context.Context, ref model.UserRef) error {
+	result, err := r.coll.DeleteOne(ctx, refFilter(ref))
+	if err != nil {
+		return mapError("delete user", err)
+	}
+	if result.DeletedCount == 0 {
+		return errUserNotFound
+	}
+	return nil
+}
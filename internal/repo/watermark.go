@@ -0,0 +1,58 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Watermarks keep the time each collection was last written to
+type Watermarks interface {
+	Touch(ctx context.Context, collection string, at time.Time) error
+	LastModified(ctx context.Context, collection string) (time.Time, error)
+}
+
+// MongoWatermarks stores watermarks as one document per collection, so
+// every instance sees the writes of the others
+type MongoWatermarks struct {
+	coll *mongo.Collection
+}
+
+// NewMongoWatermarks creates watermarks stored in coll
+func NewMongoWatermarks(coll *mongo.Collection) *MongoWatermarks {
+	return &MongoWatermarks{coll: coll}
+}
+
+// watermark is the stored document of a collection
+type watermark struct {
+	LastModified time.Time `bson:"last_modified"`
+}
+
+// Touch moves the watermark of collection to at, unless it is already
+// later, as when concurrent writes finish out of order
+func (w *MongoWatermarks) Touch(ctx context.Context, collection string, at time.Time) error {
+	_, err := w.coll.UpdateOne(ctx,
+		newQuery().eq(fieldID, str(collection)).filter(),
+		bson.M{"$max": bson.M{"last_modified": at}},
+		options.Update().SetUpsert(true),
+	)
+	return mapError("touch watermark", err)
+}
+
+// LastModified returns the watermark of collection, zero when it was
+// never written to
+func (w *MongoWatermarks) LastModified(ctx context.Context, collection string) (time.Time, error) {
+	var doc watermark
+	err := w.coll.FindOne(ctx, newQuery().eq(fieldID, str(collection)).filter()).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, mapError("find watermark", err)
+	}
+	return doc.LastModified, nil
+}
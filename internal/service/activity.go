@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// ActivityService maintains the activity feed from the event bus and
+// serves it
+type ActivityService struct {
+	log repo.ActivityLog
+}
+
+// NewActivityService creates an ActivityService backed by the given log
+func NewActivityService(log repo.ActivityLog) *ActivityService {
+	return &ActivityService{log: log}
+}
+
+// HandleEvent records e in the feed
+func (s *ActivityService) HandleEvent(ctx context.Context, e events.Event) error {
+	resource, _, _ := strings.Cut(e.Type, ".")
+	return s.log.Record(ctx, &model.Activity{
+		ID:       e.ID,
+		Type:     e.Type,
+		Resource: resource,
+		UserID:   e.UserID,
+		At:       e.At,
+	})
+}
+
+// Feed returns the page of the activity feed matching filter
+func (s *ActivityService) Feed(ctx context.Context, filter model.ActivityFilter) (*model.ActivityFeed, error) {
+	if !filter.Since.IsZero() && !filter.Before.IsZero() && !filter.Since.Before(filter.Before) {
+		return nil, &model.ValidationError{Field: "since", Reason: "must be earlier than before"}
+	}
+
+	span, ctx := tracer.StartSpanFromContext(ctx, "activity.feed")
+	feed, err := s.log.Feed(ctx, filter)
+	if err == nil {
+		total := 0
+		for _, n := range feed.Counts {
+			total += n
+		}
+		span.SetTag("activity.items", len(feed.Items))
+		span.SetTag("activity.total", total)
+	}
+	span.Finish(tracer.WithError(err))
+	return feed, err
+}
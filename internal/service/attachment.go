@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"log"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+	"datadog-golang-example/internal/storage"
+)
+
+// AttachmentService implements the user attachment use cases. The bytes
+// live in the object store, which clients reach through presigned URLs;
+// the metadata lives in attachments.
+type AttachmentService struct {
+	attachments repo.AttachmentRepository
+	store       storage.Store
+	users       *UserService
+	counters    CounterStore
+}
+
+// NewAttachmentService creates an AttachmentService and registers the
+// removal of the attachments of deleted and erased users with users. The
+// attachments count of the users is maintained in counters.
+func NewAttachmentService(attachments repo.AttachmentRepository, store storage.Store, users *UserService, counters CounterStore) *AttachmentService {
+	s := &AttachmentService{attachments: attachments, store: store, users: users, counters: counters}
+	users.OnRemove(s.RemoveUser)
+	return s
+}
+
+// Create records a new attachment of the referenced user and returns it
+// with the URL to upload its bytes to
+func (s *AttachmentService) Create(ctx context.Context, ref model.UserRef, req model.CreateAttachmentRequest) (*model.Attachment, *storage.Presigned, error) {
+	user, err := s.users.Get(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, err := s.users.ids.NewID()
+	if err != nil {
+		return nil, nil, err
+	}
+	a := &model.Attachment{
+		ID:          id,
+		UserID:      user.PublicID,
+		Name:        req.Name,
+		ContentType: req.ContentType,
+		Key:         model.AttachmentKey(user.PublicID, id),
+		CreatedAt:   time.Now(),
+	}
+	upload, err := s.store.PresignUpload(ctx, a.Key, a.ContentType)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !model.IsDryRun(ctx) {
+		if err := s.attachments.Create(ctx, a); err != nil {
+			return nil, nil, err
+		}
+		// The attachment exists, so a lost increment is only drift
+		if err := s.counters.Add(ctx, repo.AttachmentsCounter, user.PublicID, 1); err != nil {
+			log.Printf("Failed to count attachment %s of user %s: %v", a.ID, user.PublicID, err)
+		} else {
+			s.users.touch(ctx)
+		}
+	}
+	return a, upload, nil
+}
+
+// List returns the attachments of the referenced user, newest first
+func (s *AttachmentService) List(ctx context.Context, ref model.UserRef) ([]model.Attachment, error) {
+	user, err := s.users.Get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return s.attachments.List(ctx, user.PublicID)
+}
+
+// Download returns an attachment of the referenced user with the URL to
+// download its bytes from
+func (s *AttachmentService) Download(ctx context.Context, ref model.UserRef, id string) (*model.Attachment, *storage.Presigned, error) {
+	user, err := s.users.Get(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := s.attachments.Get(ctx, user.PublicID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	download, err := s.store.PresignDownload(ctx, a.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, download, nil
+}
+
+// RemoveUser deletes the objects and then the metadata of the
+// attachments of a user, so a failed removal can be run again. The
+// objects of attachments moved from merged users keep the prefix of the
+// user they were uploaded for, which is deleted too.
+func (s *AttachmentService) RemoveUser(ctx context.Context, userID string) error {
+	span, ctx := tracer.StartSpanFromContext(ctx, "attachments.remove", tracer.Tag("user.id", userID))
+	keys, err := s.attachments.Keys(ctx, userID)
+	objects := 0
+	if err == nil {
+		prefixes := []string{model.AttachmentPrefix(userID)}
+		for _, key := range keys {
+			if prefix := path.Dir(key) + "/"; !slices.Contains(prefixes, prefix) {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		for _, prefix := range prefixes {
+			var n int
+			n, err = s.store.DeletePrefix(ctx, prefix)
+			objects += n
+			if err != nil {
+				break
+			}
+		}
+	}
+	span.SetTag("attachments.objects", objects)
+	if err == nil {
+		var records int64
+		records, err = s.attachments.DeleteByUser(ctx, userID)
+		span.SetTag("attachments.records", records)
+	}
+	span.Finish(tracer.WithError(err))
+	return err
+}
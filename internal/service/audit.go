@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// maxAuditAttempts bounds the appends of an entry racing other instances
+// for the next sequence number
+const maxAuditAttempts = 5
+
+// AuditTrail keeps the audit entries in a chain signed with HMAC-SHA256:
+// each entry is signed with the hash of the one before it, so an entry
+// changed, inserted or removed after the fact is detected by Verify
+type AuditTrail struct {
+	entries repo.AuditLog
+	key     []byte
+
+	mu sync.Mutex
+	// last is the entry the next one follows, nil for an empty trail;
+	// loaded tells whether it is known
+	last   *model.AuditEntry
+	loaded bool
+}
+
+// NewAuditTrail creates an AuditTrail storing entries in log, signed with
+// key
+func NewAuditTrail(log repo.AuditLog, key []byte) *AuditTrail {
+	return &AuditTrail{entries: log, key: key}
+}
+
+// Append signs an entry after the last one and stores it. When another
+// instance appended first, the trail is read again and the entry signed
+// after the new last one.
+func (t *AuditTrail) Append(ctx context.Context, msg string, attrs map[string]string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(attrs) == 0 {
+		attrs = nil
+	}
+	for attempt := 1; ; attempt++ {
+		if !t.loaded {
+			last, err := t.entries.Last(ctx)
+			if err != nil {
+				return err
+			}
+			t.last, t.loaded = last, true
+		}
+
+		entry := &model.AuditEntry{Seq: 1, At: time.Now().UTC().Truncate(time.Millisecond), Message: msg, Attrs: attrs}
+		if t.last != nil {
+			entry.Seq, entry.PrevHash = t.last.Seq+1, t.last.Hash
+		}
+		entry.Hash = t.sign(entry)
+		err := t.entries.Append(ctx, entry)
+		if errors.Is(err, model.ErrConflict) && attempt < maxAuditAttempts {
+			t.loaded = false
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		t.last = entry
+		return nil
+	}
+}
+
+// sign returns the hex HMAC-SHA256 of the content of entry and the hash
+// it follows. Times are signed at the millisecond precision they are
+// stored at.
+func (t *AuditTrail) sign(entry *model.AuditEntry) string {
+	// Marshalling maps sorts their keys, so the payload is canonical
+	payload, _ := json.Marshal(struct {
+		Seq     int64             `json:"seq"`
+		At      string            `json:"at"`
+		Message string            `json:"message"`
+		Attrs   map[string]string `json:"attrs"`
+		Prev    string            `json:"prev"`
+	}{entry.Seq, entry.At.UTC().Format(time.RFC3339Nano), entry.Message, entry.Attrs, entry.PrevHash})
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify walks the trail from its first entry and reports whether every
+// entry follows the one before it and matches its signature, or the first
+// entry that does not. It is traced as an audit.verify span.
+func (t *AuditTrail) Verify(ctx context.Context) (report *model.AuditReport, err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "audit.verify")
+	defer func() {
+		if report != nil {
+			span.SetTag("audit.entries", report.Entries)
+			span.SetTag("audit.intact", report.Intact)
+		}
+		span.Finish(tracer.WithError(err))
+	}()
+
+	report = &model.AuditReport{Intact: true}
+	var prev *model.AuditEntry
+	for entry, err := range t.entries.All(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		report.Entries++
+		if !report.Intact {
+			continue
+		}
+		if reason := t.check(prev, &entry); reason != "" {
+			report.Intact, report.BrokenAt, report.Reason = false, entry.Seq, reason
+			continue
+		}
+		prev = &entry
+	}
+	if report.Intact && prev != nil {
+		report.LastHash = prev.Hash
+	}
+	return report, nil
+}
+
+// check returns why entry does not follow prev, nil for the first entry,
+// or does not match its signature, or "" when it is valid
+func (t *AuditTrail) check(prev, entry *model.AuditEntry) string {
+	seq, prevHash := int64(1), ""
+	if prev != nil {
+		seq, prevHash = prev.Seq+1, prev.Hash
+	}
+	switch {
+	case entry.Seq != seq:
+		return fmt.Sprintf("entry %d is missing", seq)
+	case entry.PrevHash != prevHash:
+		return "does not follow the hash of the entry before it"
+	case !hmac.Equal([]byte(t.sign(entry)), []byte(entry.Hash)):
+		return "does not match its signature"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// errBulkUserNotFound is reported for bulk items addressing no user
+var errBulkUserNotFound = fmt.Errorf("user %w", model.ErrNotFound)
+
+// BulkUpdate applies the update of every item it can and reports the
+// others as per-item errors instead of failing the whole request
+func (s *UserService) BulkUpdate(ctx context.Context, req model.BulkUpdateRequest) (result *model.BulkUpdateResult, err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "users.bulk_update", tracer.Tag("bulk.size", len(req.Items)))
+	defer func() {
+		if result != nil {
+			span.SetTag("bulk.matched", result.Matched)
+			span.SetTag("bulk.modified", result.Modified)
+			span.SetTag("bulk.failed", len(result.Errors))
+			span.SetTag("bulk.outcome", bulkOutcome(result, len(req.Items)))
+		}
+		span.Finish(tracer.WithError(err))
+	}()
+
+	var itemErrs []model.BulkItemError
+	updates := make([]model.BulkUpdate, 0, len(req.Items))
+	refs := make([]model.UserRef, 0, len(req.Items))
+	for i, item := range req.Items {
+		ref, err := model.ParseUserRef(item.ID)
+		if err != nil {
+			itemErrs = append(itemErrs, model.BulkItemError{
+				Index: i,
+				ID:    item.ID,
+				Err:   &model.ValidationError{Field: "id", Reason: "must be an ObjectID, a UUID or a Snowflake ID"},
+			})
+			continue
+		}
+		update := userUpdate(item.UpdateUserRequest)
+		if err := s.checkUpdatedEmail(ctx, &update); err != nil {
+			itemErrs = append(itemErrs, model.BulkItemError{Index: i, ID: item.ID, Err: err})
+			continue
+		}
+		updates = append(updates, model.BulkUpdate{Index: i, Ref: ref, Update: update})
+		refs = append(refs, ref)
+	}
+
+	// A bulk write only reports aggregate match counts, so items that
+	// address no user, or an erased one the store leaves unmatched, are
+	// found upfront
+	existing, err := s.repo.GetMany(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+	erased := make(map[string]bool)
+	for _, u := range existing {
+		if u.ErasedAt != nil {
+			erased[u.ID.Hex()] = true
+			erased[u.PublicID] = true
+		}
+	}
+	existing = slices.DeleteFunc(existing, func(u model.User) bool { return u.ErasedAt != nil })
+	found := make(map[string]bool, 2*len(existing))
+	for _, u := range existing {
+		found[u.ID.Hex()] = true
+		found[u.PublicID] = true
+	}
+	updates = slices.DeleteFunc(updates, func(u model.BulkUpdate) bool {
+		if found[u.Ref.String()] {
+			return false
+		}
+		err := errBulkUserNotFound
+		if erased[u.Ref.String()] {
+			err = repo.ErrUserErased
+		}
+		itemErrs = append(itemErrs, model.BulkItemError{Index: u.Index, ID: u.Ref.String(), Err: err})
+		return true
+	})
+
+	result = &model.BulkUpdateResult{Errors: []model.BulkItemError{}}
+	if len(updates) > 0 {
+		if result, err = s.store(ctx).BulkUpdate(ctx, updates); err != nil {
+			return nil, err
+		}
+	}
+	result.Errors = append(result.Errors, itemErrs...)
+	slices.SortFunc(result.Errors, func(a, b model.BulkItemError) int { return cmp.Compare(a.Index, b.Index) })
+
+	if result.Modified > 0 {
+		ids := make([]string, len(existing))
+		for i, u := range existing {
+			ids[i] = u.PublicID
+		}
+		s.afterWrite(ctx, events.UserUpdated, ids...)
+	}
+	return result, nil
+}
+
+// bulkOutcome classifies a bulk update for span tags
+func bulkOutcome(result *model.BulkUpdateResult, size int) string {
+	switch len(result.Errors) {
+	case 0:
+		return "success"
+	case size:
+		return "failed"
+	default:
+		return "partial"
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/repo"
+)
+
+// CounterStore maintains the derived counters of the users
+type CounterStore interface {
+	Add(ctx context.Context, counter repo.Counter, userID string, delta int64) error
+	Reconcile(ctx context.Context, counter repo.Counter) (*repo.CounterDrift, error)
+}
+
+// CounterReconciler fixes the drift of the derived counters of the
+// users, which increments lost to failures leave behind, and reports it
+// as counters.* gauges tagged by counter
+type CounterReconciler struct {
+	counters CounterStore
+	marks    repo.Watermarks
+	metrics  statsd.ClientInterface
+	interval time.Duration
+}
+
+// NewCounterReconciler creates a reconciler running every interval. The
+// fixes move the users watermark of marks unless it is nil.
+func NewCounterReconciler(counters CounterStore, marks repo.Watermarks, metrics statsd.ClientInterface, interval time.Duration) *CounterReconciler {
+	return &CounterReconciler{counters: counters, marks: marks, metrics: metrics, interval: interval}
+}
+
+// Reconcile reconciles every counter once, each in a counters.reconcile
+// span, and returns their drift
+func (r *CounterReconciler) Reconcile(ctx context.Context) ([]*repo.CounterDrift, error) {
+	var drifts []*repo.CounterDrift
+	for _, counter := range repo.Counters {
+		span, spanCtx := tracer.StartSpanFromContext(ctx, "counters.reconcile", tracer.ResourceName(counter.Name))
+		drift, err := r.counters.Reconcile(spanCtx, counter)
+		if drift != nil {
+			span.SetTag("counters.drifted", drift.Drifted)
+			span.SetTag("counters.fixed", drift.Fixed)
+			r.report(drift)
+			if drift.Fixed > 0 {
+				touchUsers(spanCtx, r.marks)
+			}
+			drifts = append(drifts, drift)
+		}
+		span.Finish(tracer.WithError(err))
+		if err != nil {
+			return drifts, err
+		}
+	}
+	return drifts, nil
+}
+
+// report sends the gauges of drift
+func (r *CounterReconciler) report(drift *repo.CounterDrift) {
+	tags := []string{"counter:" + drift.Counter}
+	r.metrics.Gauge("counters.checked", float64(drift.Checked), tags, 1)
+	r.metrics.Gauge("counters.drifted", float64(drift.Drifted), tags, 1)
+	r.metrics.Gauge("counters.fixed", float64(drift.Fixed), tags, 1)
+	r.metrics.Gauge("counters.delta", float64(drift.Delta), tags, 1)
+	r.metrics.Gauge("counters.orphans", float64(drift.Orphans), tags, 1)
+	if drift.Drifted > 0 {
+		log.Printf("Counter %s drifted for %d user(s), off by %d, fixed %d", drift.Counter, drift.Drifted, drift.Delta, drift.Fixed)
+	}
+}
+
+// Run reconciles the counters every interval until ctx is cancelled
+func (r *CounterReconciler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := r.Reconcile(ctx); err != nil {
+				log.Printf("Counter reconciliation failed: %v", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
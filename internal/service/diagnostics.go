@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"runtime/debug"
+	"time"
+
+	"datadog-golang-example/internal/features"
+	"datadog-golang-example/internal/migrate"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// TracerState reports whether the tracer runs and what it keeps
+type TracerState interface {
+	Started() bool
+	SampleRate() float64
+	RemoteSampleRate() (float64, bool)
+}
+
+// AgentState reports why the Datadog agent is unreachable, if it is
+type AgentState interface {
+	Err() error
+}
+
+// IndexDrifter compares the indexes of the database with the registry
+type IndexDrifter interface {
+	Drift(ctx context.Context) (repo.IndexDrift, error)
+}
+
+// MigrationLister lists the data migrations and when they ran
+type MigrationLister interface {
+	Status(ctx context.Context) ([]migrate.Status, error)
+}
+
+// buildModules are the modules whose versions the diagnostics report
+var buildModules = []string{
+	"github.com/DataDog/dd-trace-go/v2",
+	"github.com/gin-gonic/gin",
+	"go.mongodb.org/mongo-driver",
+}
+
+// DiagnosticsService gathers how the running instance is wired: the
+// settings it started with and the state of the tracer and of MongoDB
+type DiagnosticsService struct {
+	base       model.Diagnostics
+	flags      *features.Flags
+	tracer     TracerState
+	agent      AgentState
+	topology   func(ctx context.Context) (repo.Topology, error)
+	indexes    IndexDrifter
+	migrations MigrationLister
+}
+
+// NewDiagnosticsService creates a DiagnosticsService completing base, the
+// settings of the instance, with the build it runs and the state of its
+// parts. The instance is taken to start now.
+func NewDiagnosticsService(base model.Diagnostics, flags *features.Flags, tracer TracerState, agent AgentState,
+	topology func(ctx context.Context) (repo.Topology, error), indexes IndexDrifter, migrations MigrationLister) *DiagnosticsService {
+	base.StartedAt = time.Now().UTC().Truncate(time.Second)
+	base.Build = buildVersions()
+	return &DiagnosticsService{
+		base:       base,
+		flags:      flags,
+		tracer:     tracer,
+		agent:      agent,
+		topology:   topology,
+		indexes:    indexes,
+		migrations: migrations,
+	}
+}
+
+// Diagnose reads the current state of every part. MongoDB failures are
+// reported in their part, so the diagnostics of a broken environment are
+// still served.
+func (s *DiagnosticsService) Diagnose(ctx context.Context) *model.Diagnostics {
+	d := s.base
+	d.Build = maps.Clone(s.base.Build)
+	d.Features = maps.Clone(s.base.Features)
+	d.FeatureFlags = s.flags.All()
+
+	d.Tracer.Started = s.tracer.Started()
+	d.Tracer.SampleRate = s.tracer.SampleRate()
+	if rate, ok := s.tracer.RemoteSampleRate(); ok {
+		d.Tracer.RemoteSampleRate = &rate
+	}
+	if err := s.agent.Err(); err != nil {
+		d.Tracer.AgentError = err.Error()
+	}
+
+	if t, err := s.topology(ctx); err != nil {
+		d.MongoDB.Error = err.Error()
+	} else {
+		d.MongoDB.Deployment = t.String()
+		d.MongoDB.ReplicaSet, d.MongoDB.Primary, d.MongoDB.Hosts = t.SetName, t.Primary, t.Hosts
+	}
+	d.Indexes = s.indexStatus(ctx)
+	d.Migrations = s.migrationStatus(ctx)
+	return &d
+}
+
+// indexStatus compares the indexes of the database with the registry
+func (s *DiagnosticsService) indexStatus(ctx context.Context) model.IndexStatus {
+	drift, err := s.indexes.Drift(ctx)
+	if err != nil {
+		return model.IndexStatus{Error: err.Error()}
+	}
+	status := model.IndexStatus{InSync: drift.Empty(), Unexpected: drift.Unexpected}
+	for _, spec := range drift.Missing {
+		status.Missing = append(status.Missing, spec.String())
+	}
+	for _, c := range drift.Conflicting {
+		status.Conflicting = append(status.Conflicting, fmt.Sprintf("%s conflicts with %s: %s", c.Have, c.Want, c.Reason))
+	}
+	return status
+}
+
+// migrationStatus finds the latest migration applied and the pending ones
+func (s *DiagnosticsService) migrationStatus(ctx context.Context) model.MigrationStatus {
+	statuses, err := s.migrations.Status(ctx)
+	if err != nil {
+		return model.MigrationStatus{Error: err.Error()}
+	}
+	var status model.MigrationStatus
+	for _, m := range statuses {
+		switch {
+		case m.AppliedAt == nil:
+			status.Pending = append(status.Pending, fmt.Sprintf("%d %s", m.Version, m.Name))
+		case status.Last == nil || m.Version > status.Last.Version:
+			status.Last = &model.AppliedMigration{Version: m.Version, Name: m.Name, AppliedAt: *m.AppliedAt}
+		}
+	}
+	return status
+}
+
+// buildVersions returns the Go version and the versions of buildModules
+// the binary was built with
+func buildVersions() map[string]string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return map[string]string{}
+	}
+	versions := map[string]string{"go": info.GoVersion}
+	for _, dep := range info.Deps {
+		for _, path := range buildModules {
+			if dep.Path == path {
+				versions[path] = dep.Version
+			}
+		}
+	}
+	return versions
+}
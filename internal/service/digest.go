@@ -0,0 +1,105 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"text/template"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/mail"
+	"datadog-golang-example/internal/model"
+)
+
+// SignupSource aggregates the users created over a period
+type SignupSource interface {
+	ByOrg(ctx context.Context, since, before time.Time) ([]model.OrgSignups, error)
+}
+
+// digestTemplate renders the body of the digest email
+var digestTemplate = template.Must(template.New("digest").Parse(`New users from {{.Since.Format "2006-01-02"}} to {{.Until.Format "2006-01-02"}}: {{.Total}}
+{{range .Orgs}}
+  {{if .Name}}{{.Name}}{{else if .OrgID}}{{.OrgID}}{{else}}No organization{{end}}: {{.Users}}
+{{- else}}
+No users signed up.
+{{- end}}
+`))
+
+// DigestService emails the recipients of the digest a summary of the
+// users created in each organization over the last period
+type DigestService struct {
+	signups SignupSource
+	mailer  mail.Mailer
+	cfg     config.DigestConfig
+}
+
+// NewDigestService creates a DigestService sending the digests of cfg
+// through mailer
+func NewDigestService(signups SignupSource, mailer mail.Mailer, cfg config.DigestConfig) *DigestService {
+	return &DigestService{signups: signups, mailer: mailer, cfg: cfg}
+}
+
+// Send aggregates the period ending at until, renders the digest and
+// sends it to every recipient. It is traced as a digest.run span whose
+// digest.aggregate, digest.render and digest.send children show where
+// the time goes; a failed send does not stop the others.
+func (s *DigestService) Send(ctx context.Context, until time.Time) (digest *model.Digest, err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "digest.run", tracer.ResourceName("signups"))
+	defer func() { span.Finish(tracer.WithError(err)) }()
+
+	digest = &model.Digest{Since: until.Add(-s.cfg.Period), Until: until}
+	step, stepCtx := tracer.StartSpanFromContext(ctx, "digest.aggregate")
+	digest.Orgs, err = s.signups.ByOrg(stepCtx, digest.Since, digest.Until)
+	step.Finish(tracer.WithError(err))
+	if err != nil {
+		return nil, err
+	}
+	for _, org := range digest.Orgs {
+		digest.Total += org.Users
+	}
+	span.SetTag("digest.orgs", len(digest.Orgs))
+	span.SetTag("digest.users", digest.Total)
+
+	step, _ = tracer.StartSpanFromContext(ctx, "digest.render")
+	var body bytes.Buffer
+	err = digestTemplate.Execute(&body, digest)
+	step.Finish(tracer.WithError(err))
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, to := range s.cfg.Recipients {
+		// The recipient is not tagged to keep email addresses out of traces
+		step, stepCtx := tracer.StartSpanFromContext(ctx, "digest.send")
+		sendErr := s.mailer.Send(stepCtx, mail.Message{
+			To:      to,
+			Subject: "Go API Demo: new users digest",
+			Body:    body.String(),
+		})
+		step.Finish(tracer.WithError(sendErr))
+		errs = append(errs, sendErr)
+	}
+	span.SetTag("digest.recipients", len(s.cfg.Recipients))
+	return digest, errors.Join(errs...)
+}
+
+// Run sends a digest every period until ctx is cancelled
+func (s *DigestService) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.cfg.Period)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if _, err := s.Send(ctx, now); err != nil {
+				log.Printf("Digest failed: %v", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
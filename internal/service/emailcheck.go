@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/emailcheck"
+	"datadog-golang-example/internal/model"
+)
+
+// EmailVerifier tells whether an address is risky
+type EmailVerifier interface {
+	Verify(ctx context.Context, email string) (emailcheck.Verdict, error)
+	// Mode is "reject" to refuse risky addresses or "flag" to mark them
+	Mode() string
+	// Forget drops what is kept about email
+	Forget(ctx context.Context, email string) error
+}
+
+// checkEmail verifies a new address. In reject mode a risky address is a
+// validation error; otherwise the reason is returned so the user can be
+// flagged. An unavailable verifier never blocks a signup.
+func (s *UserService) checkEmail(ctx context.Context, email string) (string, error) {
+	if s.emails == nil {
+		return "", nil
+	}
+	verdict, err := s.emails.Verify(ctx, email)
+	if err != nil {
+		log.Printf("Skipping email verification: %v", err)
+		if span, ok := tracer.SpanFromContext(ctx); ok {
+			span.SetTag("emailcheck.skipped", true)
+		}
+		return "", nil
+	}
+	if !verdict.Risky() {
+		return "", nil
+	}
+
+	reason := verdict.Status
+	if verdict.Reason != "" {
+		reason = verdict.Reason
+	}
+	if s.emails.Mode() == "reject" {
+		return "", &model.ValidationError{Field: "email", Reason: "is not accepted: " + reason}
+	}
+	return reason, nil
+}
+
+// checkUpdatedEmail verifies the address update sets, if any, and records
+// its risk on update so the flag of the former address does not stay
+func (s *UserService) checkUpdatedEmail(ctx context.Context, update *model.UserUpdate) error {
+	if update.Email == nil {
+		return nil
+	}
+	risk, err := s.checkEmail(ctx, *update.Email)
+	if err != nil {
+		return err
+	}
+	update.EmailRisk = &risk
+	return nil
+}
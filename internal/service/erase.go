@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/logging"
+	"datadog-golang-example/internal/model"
+)
+
+// errAlreadyErased is returned when erasing a user a second time
+var errAlreadyErased = fmt.Errorf("user already erased: %w", model.ErrConflict)
+
+// erasedFields are the personal fields removed by Erase
+var erasedFields = []string{"name", "username", "email", "age", "location", "tags"}
+
+// Erase anonymizes the personal data of a user: the name and username are
+// replaced by a random token and the other personal fields are removed.
+// The cached email verdict goes with them, and the erasure is recorded
+// with a digest of the erased values. actor is who requested it.
+func (s *UserService) Erase(ctx context.Context, ref model.UserRef, actor string) (*model.ErasureReceipt, error) {
+	existing, err := s.repo.Get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if existing.ErasedAt != nil {
+		return nil, errAlreadyErased
+	}
+
+	salt := make([]byte, 16)
+	token := make([]byte, 6)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	name := "erased-" + hex.EncodeToString(token)
+	erased := *existing
+	erased.Name = name
+	erased.NameKey = model.FoldName(name)
+	erased.Username = name
+	erased.Email = ""
+	erased.EmailRisk = ""
+	erased.Age = 0
+	erased.Location = nil
+	erased.Tags = nil
+	erased.ErasedAt = &now
+	if _, err := s.store(ctx).Replace(ctx, &erased, existing.Version, false); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return nil, errVersionMismatch
+		}
+		return nil, err
+	}
+
+	receipt := &model.ErasureReceipt{
+		Erasure: model.Erasure{
+			ID:       primitive.NewObjectID(),
+			UserID:   existing.PublicID,
+			Actor:    actor,
+			Fields:   erasedFields,
+			ErasedAt: now,
+			Digest:   erasureDigest(salt, existing),
+		},
+		Salt: base64.StdEncoding.EncodeToString(salt),
+	}
+	if model.IsDryRun(ctx) {
+		return receipt, nil
+	}
+	if err := s.erasures.Record(ctx, &receipt.Erasure); err != nil {
+		return nil, err
+	}
+	if s.emails != nil && existing.Email != "" {
+		if err := s.emails.Forget(ctx, existing.Email); err != nil {
+			log.Printf("Failed to forget email verdict of erased user %s: %v", existing.PublicID, err)
+		}
+	}
+	logging.Audit("Erased user", "actor", actor, "user", existing.PublicID, "erasure", receipt.ID.Hex())
+	s.afterWrite(ctx, events.UserErased, existing.PublicID)
+	s.afterRemove(ctx, existing.PublicID)
+	return receipt, nil
+}
+
+// erasureDigest is the HMAC-SHA256, keyed with salt, of the erased values
+// of user, one per line in the order of erasedFields
+func erasureDigest(salt []byte, user *model.User) string {
+	mac := hmac.New(sha256.New, salt)
+	var location string
+	if user.Location != nil {
+		location = fmt.Sprint(user.Location.Coordinates)
+	}
+	for _, v := range []string{user.Name, user.Username, user.Email, strconv.Itoa(user.Age), location, fmt.Sprint(user.Tags)} {
+		mac.Write([]byte(v + "\n"))
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
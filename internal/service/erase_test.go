@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// erasedRepository is a patchRepository whose user is erased, which bulk
+// updates can also reach
+type erasedRepository struct {
+	patchRepository
+}
+
+func (r *erasedRepository) GetMany(context.Context, []model.UserRef) ([]model.User, error) {
+	return []model.User{r.user}, nil
+}
+
+func (r *erasedRepository) BulkUpdate(_ context.Context, updates []model.BulkUpdate) (*model.BulkUpdateResult, error) {
+	for _, u := range updates {
+		r.updates = append(r.updates, u.Update)
+	}
+	n := int64(len(updates))
+	return &model.BulkUpdateResult{Matched: n, Modified: n, Errors: []model.BulkItemError{}}, nil
+}
+
+// TestUpdateErased checks that no write path updates an erased user. The
+// writes are dry runs, whose store runs the erasure check of the real
+// ones.
+func TestUpdateErased(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		write func(context.Context, *UserService, model.UserRef) error
+	}{
+		{"update", func(ctx context.Context, s *UserService, ref model.UserRef) error {
+			_, err := s.Update(ctx, ref, model.UpdateUserRequest{Name: "Alice"})
+			return err
+		}},
+		{"merge patch", func(ctx context.Context, s *UserService, ref model.UserRef) error {
+			_, err := s.ApplyMergePatch(ctx, ref, []byte(`{"name": "Alice", "age": 30}`))
+			return err
+		}},
+		{"json patch", func(ctx context.Context, s *UserService, ref model.UserRef) error {
+			_, err := s.ApplyJSONPatch(ctx, ref, []byte(`[{"op": "replace", "path": "/name", "value": "Alice"}, {"op": "replace", "path": "/age", "value": 30}]`))
+			return err
+		}},
+		{"bulk", func(ctx context.Context, s *UserService, ref model.UserRef) error {
+			result, err := s.BulkUpdate(ctx, model.BulkUpdateRequest{Items: []model.BulkUpdateItem{
+				{ID: ref.PublicID, UpdateUserRequest: model.UpdateUserRequest{Name: "Alice"}},
+			}})
+			if err != nil {
+				return err
+			}
+			if len(result.Errors) != 1 {
+				return nil
+			}
+			return result.Errors[0].Err
+		}},
+		{"tags", func(ctx context.Context, s *UserService, ref model.UserRef) error {
+			_, err := s.AddTags(ctx, ref, []string{"beta"})
+			return err
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			erasedAt := time.Now()
+			r := &erasedRepository{patchRepository{user: model.User{
+				PublicID: "0192a8e2-7b3c-7def-8000-0123456789ab",
+				Name:     "erased",
+				NameKey:  model.FoldName("erased"),
+				Version:  4,
+				ErasedAt: &erasedAt,
+			}}}
+			s := NewUserService(r, nil, config.SuggestConfig{}, discardTasks{}, nil, nil, nil, nil, nil)
+			err := tc.write(model.WithDryRun(context.Background()), s, model.UserRef{PublicID: r.user.PublicID})
+			if !errors.Is(err, repo.ErrUserErased) {
+				t.Fatalf("write failed with %v, want %v", err, repo.ErrUserErased)
+			}
+			if len(r.updates) > 0 {
+				t.Errorf("the erased user was updated with %+v", r.updates)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/model"
+)
+
+// publish announces a change of type typ to each of the users on the bus.
+// The write has already succeeded, so failures are only logged.
+func (s *UserService) publish(ctx context.Context, typ string, userIDs []string) {
+	if s.bus == nil {
+		return
+	}
+	for _, id := range userIDs {
+		eventID, err := model.NewPublicID()
+		if err != nil {
+			log.Printf("Skipping %s event of user %s: %v", typ, id, err)
+			continue
+		}
+		e := events.Event{ID: eventID, Type: typ, UserID: id, At: time.Now()}
+		if err := s.bus.Publish(ctx, e); err != nil {
+			log.Printf("Failed to publish %s event of user %s: %v", typ, id, err)
+		}
+	}
+}
+
+// HandleEvent reacts to the user changes published by any instance,
+// dropping the suggestions that may have gone stale
+func (s *UserService) HandleEvent(_ context.Context, _ events.Event) error {
+	s.suggests.purge()
+	return nil
+}
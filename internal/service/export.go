@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/export"
+	"datadog-golang-example/internal/model"
+)
+
+// errExportNotFound is returned for an unknown or expired export job
+var errExportNotFound = fmt.Errorf("export: %w", model.ErrNotFound)
+
+// ExportService runs admin exports of the users as background jobs. Jobs
+// are tracked by the process running them and the last retain finished
+// ones are kept for status polling.
+type ExportService struct {
+	users  *UserService
+	sink   export.Sink
+	retain int
+
+	// ctx is cancelled by Stop to interrupt the running jobs
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	jobs     map[string]*model.ExportJob
+	finished []string
+}
+
+// NewExportService creates an ExportService writing to sink
+func NewExportService(users *UserService, sink export.Sink, retain int) *ExportService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ExportService{
+		users:  users,
+		sink:   sink,
+		retain: max(retain, 1),
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(map[string]*model.ExportJob),
+	}
+}
+
+// Start starts exporting the users selected by req and returns the
+// pending job. The users are counted first, which validates the filters.
+// The job is traced as an export.run span linked to the span in ctx.
+func (s *ExportService) Start(ctx context.Context, req model.ExportRequest) (*model.ExportJob, error) {
+	filter := model.UserFilter{Filters: req.Filters, OrgID: req.OrgID}
+	if filter.Filters == nil {
+		filter.Filters = map[string]string{}
+	}
+	if err := normalizeFilter(filter); err != nil {
+		return nil, err
+	}
+	total, err := s.users.repo.Count(ctx, filter, true)
+	if err != nil {
+		return nil, err
+	}
+	id, err := model.NewPublicID()
+	if err != nil {
+		return nil, err
+	}
+	job := &model.ExportJob{
+		ID:        id,
+		Status:    model.ExportPending,
+		Filters:   req.Filters,
+		OrgID:     req.OrgID,
+		Total:     total,
+		CreatedAt: time.Now(),
+	}
+
+	opts := []tracer.StartSpanOption{tracer.ResourceName("users"), tracer.Tag("export.id", id)}
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		sc := span.Context()
+		opts = append(opts, tracer.WithSpanLinks([]tracer.SpanLink{{
+			TraceID:     sc.TraceIDLower(),
+			TraceIDHigh: sc.TraceIDUpper(),
+			SpanID:      sc.SpanID(),
+			Attributes:  map[string]string{"link.kind": "started_by"},
+		}}))
+	}
+
+	s.mu.Lock()
+	s.jobs[id] = job
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		span, ctx := tracer.StartSpanFromContext(s.ctx, "export.run", opts...)
+		err := s.run(ctx, job, filter)
+		span.SetTag("export.users", s.progress(job))
+		span.Finish(tracer.WithError(err))
+	}()
+	return s.snapshot(job), nil
+}
+
+// run writes the users matching filter to the sink as the file of job
+func (s *ExportService) run(ctx context.Context, job *model.ExportJob, filter model.UserFilter) error {
+	s.update(job, func(j *model.ExportJob) {
+		now := time.Now()
+		j.Status = model.ExportRunning
+		j.StartedAt = &now
+	})
+
+	location, err := s.sink.Write(ctx, "users-"+job.ID+".ndjson", func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for user, err := range s.users.repo.Stream(ctx, filter) {
+			if err != nil {
+				return err
+			}
+			if err := enc.Encode(user); err != nil {
+				return fmt.Errorf("encode user: %w", err)
+			}
+			s.update(job, func(j *model.ExportJob) { j.Exported++ })
+		}
+		return nil
+	})
+
+	s.update(job, func(j *model.ExportJob) {
+		now := time.Now()
+		j.FinishedAt = &now
+		if err != nil {
+			j.Status = model.ExportFailed
+			j.Error = err.Error()
+			return
+		}
+		j.Status = model.ExportSucceeded
+		j.Location = location
+	})
+	if err != nil {
+		log.Printf("Export %s failed: %v", job.ID, err)
+	}
+	s.expire(job.ID)
+	return err
+}
+
+// Get returns the job with the given ID
+func (s *ExportService) Get(ctx context.Context, id string) (*model.ExportJob, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, errExportNotFound
+	}
+	return s.snapshot(job), nil
+}
+
+// Stop interrupts the running jobs, which fail, and waits for them to
+// finish, or for ctx to expire
+func (s *ExportService) Stop(ctx context.Context) error {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update changes job under the lock
+func (s *ExportService) update(job *model.ExportJob, change func(*model.ExportJob)) {
+	s.mu.Lock()
+	change(job)
+	s.mu.Unlock()
+}
+
+// snapshot returns a copy of job, safe to read while it runs
+func (s *ExportService) snapshot(job *model.ExportJob) *model.ExportJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *job
+	return &copied
+}
+
+// progress returns the number of users job exported
+func (s *ExportService) progress(job *model.ExportJob) int64 {
+	return s.snapshot(job).Exported
+}
+
+// expire records id as finished and forgets the oldest finished jobs
+// beyond the retained ones
+func (s *ExportService) expire(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = append(s.finished, id)
+	for len(s.finished) > s.retain {
+		delete(s.jobs, s.finished[0])
+		s.finished = s.finished[1:]
+	}
+}
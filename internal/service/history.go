@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// HistoryService lists the current users along with the changes recorded
+// in the activity log since a past instant, so it reaches back as far as
+// the log is complete. The users are in their current state: the events
+// mark the users that changed, not what they changed.
+type HistoryService struct {
+	users  *UserService
+	log    repo.ActivityLog
+	window time.Duration
+}
+
+// NewHistoryService creates a HistoryService marking the users of users
+// with the events of log, up to window back
+func NewHistoryService(users *UserService, log repo.ActivityLog, window time.Duration) *HistoryService {
+	return &HistoryService{users: users, log: log, window: window}
+}
+
+// UsersChangedSince returns the page of the users matching filter that
+// were created before since and still exist, in their current state,
+// marking those changed since, and the IDs of the users deleted since. It
+// is traced as a users.changed_since span.
+func (s *HistoryService) UsersChangedSince(ctx context.Context, filter model.UserFilter, since time.Time) (history *model.UserHistory, err error) {
+	now := time.Now()
+	switch {
+	case since.After(now):
+		return nil, &model.ValidationError{Field: "changed_since", Reason: "must not be in the future"}
+	case since.Before(now.Add(-s.window)):
+		return nil, &model.ValidationError{Field: "changed_since", Reason: "must be within the last " + s.window.String()}
+	}
+
+	span, ctx := tracer.StartSpanFromContext(ctx, "users.changed_since", tracer.Tag("history.age", now.Sub(since).String()))
+	defer func() {
+		if history != nil {
+			span.SetTag("history.changed", len(history.Changed))
+			span.SetTag("history.removed", len(history.Removed))
+		}
+		span.Finish(tracer.WithError(err))
+	}()
+
+	changes, err := s.log.Changes(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	filter.CreatedBefore = since
+	users, err := s.users.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	history = &model.UserHistory{Since: since, Users: users, Changed: []string{}, Removed: []string{}}
+	changed := make(map[string]bool, len(changes))
+	for _, c := range changes {
+		changed[c.UserID] = true
+		// A user created since did not exist yet, whatever followed
+		if c.Last == events.UserDeleted && c.First != events.UserCreated {
+			history.Removed = append(history.Removed, c.UserID)
+		}
+	}
+	for _, u := range users {
+		if changed[u.PublicID] {
+			history.Changed = append(history.Changed, u.PublicID)
+		}
+	}
+	slices.Sort(history.Removed)
+	return history, nil
+}
@@ -0,0 +1,318 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/model"
+)
+
+// UserImporter upserts imported users by email in bulk
+type UserImporter interface {
+	Import(ctx context.Context, users []model.ImportedUser) (*model.ImportResult, error)
+}
+
+// importColumns are the columns of an imported CSV file, the first three
+// being required. Tags are separated by semicolons; other columns are
+// ignored.
+var importColumns = []string{"name", "email", "age", "tags"}
+
+// ImportService imports users from CSV files downloaded from a URL, such
+// as a Google Sheet published as CSV
+type ImportService struct {
+	users    *UserService
+	importer UserImporter
+	client   *http.Client
+	cfg      config.ImportConfig
+}
+
+// NewImportService creates an ImportService downloading the files of the
+// allowed hosts of cfg through client, which is not followed to others
+func NewImportService(users *UserService, importer UserImporter, client *http.Client, cfg config.ImportConfig) *ImportService {
+	s := &ImportService{users: users, importer: importer, cfg: cfg}
+	redirecting := *client
+	redirecting.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if !s.allowed(req.URL) {
+			return fmt.Errorf("redirected to %s, which is not an allowed import host", req.URL.Hostname())
+		}
+		return nil
+	}
+	s.client = &redirecting
+	return s
+}
+
+// Import downloads the CSV file at sourceURL, validates its rows and
+// upserts the valid ones by email in batches of BatchSize. Invalid rows
+// and failed writes are reported per row instead of failing the import.
+// It is traced as an import.run span whose import.download, import.parse
+// and import.batch children hold the downstream and MongoDB spans.
+func (s *ImportService) Import(ctx context.Context, sourceURL string) (result *model.ImportResult, err error) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "import.run", tracer.ResourceName("users"))
+	defer func() {
+		if result != nil {
+			span.SetTag("import.rows", result.Rows)
+			span.SetTag("import.created", result.Created)
+			span.SetTag("import.updated", result.Updated)
+			span.SetTag("import.failed", len(result.Errors))
+		}
+		span.Finish(tracer.WithError(err))
+	}()
+
+	source, err := s.sourceURL(sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	span.SetTag("import.source_host", source.Hostname())
+
+	step, stepCtx := tracer.StartSpanFromContext(ctx, "import.download")
+	body, err := s.download(stepCtx, source)
+	step.SetTag("import.bytes", len(body))
+	step.Finish(tracer.WithError(err))
+	if err != nil {
+		return nil, err
+	}
+
+	step, _ = tracer.StartSpanFromContext(ctx, "import.parse")
+	users, rowErrs, err := s.parse(body, time.Now())
+	step.Finish(tracer.WithError(err))
+	if err != nil {
+		return nil, err
+	}
+
+	result = &model.ImportResult{Rows: len(users) + len(rowErrs), Errors: rowErrs}
+	defer s.afterImport(ctx, result)
+	for batch := range slices.Chunk(users, max(s.cfg.BatchSize, 1)) {
+		step, stepCtx := tracer.StartSpanFromContext(ctx, "import.batch", tracer.Tag("import.batch_size", len(batch)))
+		res, err := s.importer.Import(stepCtx, batch)
+		step.Finish(tracer.WithError(err))
+		if err != nil {
+			// The batches already written stay imported
+			return nil, err
+		}
+		result.Created += res.Created
+		result.Updated += res.Updated
+		result.Errors = append(result.Errors, res.Errors...)
+		result.CreatedIDs = append(result.CreatedIDs, res.CreatedIDs...)
+		result.UpdatedIDs = append(result.UpdatedIDs, res.UpdatedIDs...)
+	}
+	slices.SortFunc(result.Errors, func(a, b model.ImportRowError) int { return a.Row - b.Row })
+	return result, nil
+}
+
+// afterImport runs the follow-up work of the users an import wrote, even
+// when a later batch failed. The users do not get welcome emails; the
+// events name each user created or updated.
+func (s *ImportService) afterImport(ctx context.Context, result *model.ImportResult) {
+	if result.Created > 0 {
+		s.users.afterWrite(ctx, events.UserCreated, result.CreatedIDs...)
+	}
+	if result.Updated > 0 {
+		s.users.afterWrite(ctx, events.UserUpdated, result.UpdatedIDs...)
+	}
+}
+
+// sourceURL validates the URL of a file to import. The edit links of
+// Google Sheets are turned into their CSV export links.
+func (s *ImportService) sourceURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if raw == "" || err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, &model.ValidationError{Field: "source_url", Reason: "must be an http or https URL"}
+	}
+	if !s.allowed(u) {
+		return nil, &model.ValidationError{Field: "source_url", Reason: "must be on an allowed host: " + strings.Join(s.cfg.AllowedHosts, ", ")}
+	}
+	return sheetsExportURL(u), nil
+}
+
+// allowed reports whether files may be downloaded from the host of u. A
+// host starting with a dot allows its subdomains.
+func (s *ImportService) allowed(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range s.cfg.AllowedHosts {
+		if allowed == "*" || host == allowed || strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// sheetsExportURL returns the CSV export link of a Google Sheets edit
+// link, keeping its sheet, and any other URL as is
+func sheetsExportURL(u *url.URL) *url.URL {
+	doc, ok := strings.CutPrefix(u.Path, "/spreadsheets/d/")
+	if u.Hostname() != "docs.google.com" || !ok {
+		return u
+	}
+	id, rest, _ := strings.Cut(doc, "/")
+	if id == "" || strings.HasPrefix(rest, "export") {
+		return u
+	}
+	query := url.Values{"format": {"csv"}}
+	gid := u.Query().Get("gid")
+	if fragment, ok := strings.CutPrefix(u.Fragment, "gid="); ok && gid == "" {
+		gid = fragment
+	}
+	if gid != "" {
+		query.Set("gid", gid)
+	}
+	return &url.URL{Scheme: "https", Host: u.Host, Path: "/spreadsheets/d/" + id + "/export", RawQuery: query.Encode()}
+}
+
+// download fetches the file at source, up to MaxBytes
+func (s *ImportService) download(ctx context.Context, source *url.URL) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.DownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/csv")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("download import: %w: %w", err, model.ErrUnavailable)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &model.ValidationError{Field: "source_url", Reason: "answered " + resp.Status}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, s.cfg.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("download import: %w: %w", err, model.ErrUnavailable)
+	}
+	if int64(len(body)) > s.cfg.MaxBytes {
+		return nil, &model.ValidationError{Field: "source_url", Reason: "must be at most " + strconv.FormatInt(s.cfg.MaxBytes, 10) + " bytes"}
+	}
+	return body, nil
+}
+
+// parse reads the users of a CSV file with a header row, created at now.
+// Rows that are not valid users are returned as row errors; a file
+// without the required columns or with more than MaxRows rows is an
+// error.
+func (s *ImportService) parse(body []byte, now time.Time) ([]model.ImportedUser, []model.ImportRowError, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, &model.ValidationError{Field: "source_url", Reason: "must be a CSV file with a header row"}
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, dup := columns[name]; slices.Contains(importColumns, name) && !dup {
+			columns[name] = i
+		}
+	}
+	for _, name := range importColumns[:3] {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, &model.ValidationError{Field: "source_url", Reason: "must have a " + name + " column"}
+		}
+	}
+
+	var users []model.ImportedUser
+	var rowErrs []model.ImportRowError
+	seen := map[string]int{}
+	for rows := 0; ; rows++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if rows == s.cfg.MaxRows {
+			return nil, nil, &model.ValidationError{Field: "source_url", Reason: "must have at most " + strconv.Itoa(s.cfg.MaxRows) + " rows"}
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			line := 0
+			if errors.As(err, &parseErr) {
+				line = parseErr.Line
+			}
+			rowErrs = append(rowErrs, model.ImportRowError{Row: line, Err: fmt.Errorf("%w: %w", model.ErrValidation, err)})
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		user, err := s.importedUser(cell, now)
+		if err == nil {
+			email := strings.ToLower(user.Email)
+			if first, ok := seen[email]; ok {
+				err = &model.ValidationError{Field: "email", Reason: "is already imported on row " + strconv.Itoa(first)}
+			} else {
+				seen[email] = line
+			}
+		}
+		if err != nil {
+			rowErrs = append(rowErrs, model.ImportRowError{Row: line, Email: cell("email"), Err: err})
+			continue
+		}
+		users = append(users, model.ImportedUser{Row: line, User: *user})
+	}
+	return users, rowErrs, nil
+}
+
+// importedUser validates the cells of a row as a new user would be, and
+// gives it the public ID, username and timestamps it is inserted with
+func (s *ImportService) importedUser(cell func(name string) string, now time.Time) (*model.User, error) {
+	age, err := strconv.Atoi(cell("age"))
+	if err != nil {
+		return nil, &model.ValidationError{Field: "age", Reason: "must be a number"}
+	}
+	req := model.CreateUserRequest{Name: cell("name"), Email: cell("email"), Age: age}
+	if err := model.Validate(req); err != nil {
+		return nil, err
+	}
+	var tags []string
+	for tag := range strings.SplitSeq(cell("tags"), ";") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if tags, err = model.NormalizeTags(tags); err != nil {
+		return nil, err
+	}
+
+	publicID, err := s.users.ids.NewID()
+	if err != nil {
+		return nil, err
+	}
+	return &model.User{
+		ID:       internalID(publicID),
+		PublicID: publicID,
+		// Imports write in bulk, so usernames that are unique in practice
+		// spare retrying taken ones
+		Username:  model.Slugify(req.Name) + "-" + publicID[len(publicID)-8:],
+		Name:      req.Name,
+		NameKey:   model.FoldName(req.Name),
+		Email:     req.Email,
+		Age:       req.Age,
+		Tags:      tags,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Version:   1,
+	}, nil
+}
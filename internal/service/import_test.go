@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/ids"
+	"datadog-golang-example/internal/model"
+)
+
+// storedImporter updates the imported users whose email it holds, under
+// the public ID it holds them by, and creates the others
+type storedImporter map[string]string
+
+func (s storedImporter) Import(_ context.Context, users []model.ImportedUser) (*model.ImportResult, error) {
+	result := &model.ImportResult{Rows: len(users), Errors: []model.ImportRowError{}}
+	for _, u := range users {
+		if id, ok := s[u.User.Email]; ok {
+			result.Updated++
+			result.UpdatedIDs = append(result.UpdatedIDs, id)
+			continue
+		}
+		result.Created++
+		result.CreatedIDs = append(result.CreatedIDs, u.User.PublicID)
+	}
+	return result, nil
+}
+
+// TestImportPublishesIDs checks that an import publishes an event naming
+// each user it created or updated
+func TestImportPublishesIDs(t *testing.T) {
+	file := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("name,email,age\nAlice,alice@example.com,30\nBob,bob@example.com,40\n"))
+	}))
+	defer file.Close()
+
+	publicIDs, err := ids.New(config.IDConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := &recordingBus{}
+	users := NewUserService(nil, publicIDs, config.SuggestConfig{}, discardTasks{}, nil, nil, nil, nil, bus)
+	stored := storedImporter{"bob@example.com": "0192a8e2-7b3c-7def-8000-0123456789ab"}
+	s := NewImportService(users, stored, file.Client(), config.ImportConfig{
+		AllowedHosts:    []string{"127.0.0.1"},
+		MaxBytes:        1 << 20,
+		MaxRows:         10,
+		BatchSize:       10,
+		DownloadTimeout: time.Second,
+	})
+	result, err := s.Import(context.Background(), file.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		events.UserCreated: result.CreatedIDs,
+		events.UserUpdated: {stored["bob@example.com"]},
+	}
+	got := map[string][]string{}
+	for _, e := range bus.published {
+		got[e.Type] = append(got[e.Type], e.UserID)
+	}
+	for typ, ids := range want {
+		if len(ids) != 1 || !slices.Equal(got[typ], ids) {
+			t.Errorf("published %s of %v, want %v", typ, got[typ], ids)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/model"
+)
+
+// Merge errors
+var (
+	errMergeErased = fmt.Errorf("erased users cannot be merged: %w", model.ErrConflict)
+	errMergeOrgs   = fmt.Errorf("users belong to different organizations: %w", model.ErrConflict)
+)
+
+// Transactor runs functions in database transactions, committed when the
+// function returns nil
+type Transactor interface {
+	Run(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Reassigner moves the records of a user, such as its attachments, to
+// another user and returns how many it moved
+type Reassigner interface {
+	Reassign(ctx context.Context, from, to string) (int64, error)
+}
+
+// MergeService merges duplicate users
+type MergeService struct {
+	users *UserService
+	tx    Transactor
+	// moves are the records merged with the users, by kind
+	moves map[string]Reassigner
+}
+
+// NewMergeService creates a MergeService. moves are the records of the
+// merged users, by kind, such as "activity".
+func NewMergeService(users *UserService, tx Transactor, moves map[string]Reassigner) *MergeService {
+	return &MergeService{users: users, tx: tx, moves: moves}
+}
+
+// Merge merges the source user of req into the referenced target in one
+// transaction: the target takes the values it lacks, and those req
+// prefers, from the source, which is deleted once its records are moved
+// to the target. Users of different organizations are not merged.
+func (s *MergeService) Merge(ctx context.Context, ref model.UserRef, req model.MergeRequest) (*model.MergeReport, error) {
+	sourceRef, err := model.ParseUserRef(req.SourceID)
+	if err != nil {
+		return nil, &model.ValidationError{Field: "source_id", Reason: "must be an ObjectID, a UUID or a Snowflake ID"}
+	}
+
+	span, stepCtx := startStep(ctx, "merge", "load")
+	target, source, err := s.load(stepCtx, ref, sourceRef)
+	span.Finish(tracer.WithError(err))
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := mergeUsers(target, source, req.Prefer, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if model.IsDryRun(ctx) {
+		report.DryRun = true
+		return report, nil
+	}
+
+	span, stepCtx = startStep(ctx, "merge", "persist")
+	span.SetTag("merge.source", source.PublicID)
+	err = s.tx.Run(stepCtx, func(ctx context.Context) error {
+		return s.persist(ctx, report, target.Version)
+	})
+	span.Finish(tracer.WithError(err))
+	if err != nil {
+		return nil, err
+	}
+
+	span, stepCtx = startStep(ctx, "merge", "post_process")
+	s.users.afterWrite(stepCtx, events.UserMerged, target.PublicID)
+	s.users.publish(stepCtx, events.UserDeleted, []string{source.PublicID})
+	span.Finish()
+	return report, nil
+}
+
+// load reads the users of the merge, which must be distinct and not erased
+func (s *MergeService) load(ctx context.Context, ref, sourceRef model.UserRef) (*model.User, *model.User, error) {
+	target, err := s.users.repo.Get(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	source, err := s.users.repo.Get(ctx, sourceRef)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, nil, &model.ValidationError{Field: "source_id", Reason: "is not a user"}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case source.ID == target.ID:
+		return nil, nil, &model.ValidationError{Field: "source_id", Reason: "must be another user"}
+	case target.ErasedAt != nil || source.ErasedAt != nil:
+		return nil, nil, errMergeErased
+	}
+	return target, source, nil
+}
+
+// persist writes the merge of report, whose target was read at version.
+// The source is deleted first, so the target can take its email without
+// a uniqueness conflict.
+func (s *MergeService) persist(ctx context.Context, report *model.MergeReport, version int64) error {
+	sourceRef := model.UserRef{PublicID: report.SourceID}
+	if err := s.users.repo.Delete(ctx, sourceRef); err != nil {
+		return err
+	}
+	// Guard against writes that landed since the merged users were read
+	if _, err := s.users.repo.Replace(ctx, report.User, version, false); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return errVersionMismatch
+		}
+		return err
+	}
+	for kind, moves := range s.moves {
+		n, err := moves.Reassign(ctx, report.SourceID, report.User.PublicID)
+		if err != nil {
+			return err
+		}
+		report.Moved[kind] = n
+	}
+	return nil
+}
+
+// mergeUsers returns the report of merging source into target. The
+// target keeps its identity; each other field takes the value of the
+// preferred user when both have one, and the only value otherwise. Tags
+// are combined and the earliest creation time is kept.
+func mergeUsers(target, source *model.User, prefer string, now time.Time) (*model.MergeReport, error) {
+	if target.OrgID != "" && source.OrgID != "" && target.OrgID != source.OrgID {
+		return nil, errMergeOrgs
+	}
+	if prefer == "" {
+		prefer = model.MergeTarget
+	}
+	merged := *target
+	report := &model.MergeReport{
+		User:      &merged,
+		SourceID:  source.PublicID,
+		Fields:    map[string]string{},
+		TagsAdded: []string{},
+		Moved:     map[string]int64{},
+	}
+
+	// pick reports whose value a field both users have keeps
+	pick := func(field string, targetSet, sourceSet bool) bool {
+		switch {
+		case !sourceSet:
+			return false
+		case !targetSet:
+			return true
+		}
+		report.Fields[field] = prefer
+		return prefer == model.MergeSource
+	}
+	if pick("name", target.Name != "", source.Name != "") {
+		merged.Name, merged.NameKey = source.Name, source.NameKey
+	}
+	if pick("email", target.Email != "", source.Email != "") {
+		merged.Email, merged.EmailRisk = source.Email, source.EmailRisk
+	}
+	if pick("age", target.Age != 0, source.Age != 0) {
+		merged.Age = source.Age
+	}
+	if pick("location", target.Location != nil, source.Location != nil) {
+		merged.Location = source.Location
+	}
+	if merged.OrgID == "" {
+		merged.OrgID = source.OrgID
+	}
+	// The attachments of the source are moved to the target
+	merged.AttachmentsCount += source.AttachmentsCount
+
+	merged.Tags = slices.Clone(target.Tags)
+	for _, tag := range source.Tags {
+		if !slices.Contains(merged.Tags, tag) {
+			merged.Tags = append(merged.Tags, tag)
+			report.TagsAdded = append(report.TagsAdded, tag)
+		}
+	}
+	if _, err := model.NormalizeTags(merged.Tags); err != nil {
+		return nil, &model.ValidationError{Field: "tags", Reason: "the merged users carry more than 20 tags"}
+	}
+
+	if source.CreatedAt.Before(merged.CreatedAt) {
+		merged.CreatedAt = source.CreatedAt
+	}
+	merged.UpdatedAt = now
+	merged.Version = target.Version + 1
+	return report, nil
+}
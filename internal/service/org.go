@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/ids"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// errOtherOrg is returned when adding a user who belongs to another
+// organization
+var errOtherOrg = fmt.Errorf("user belongs to another organization: %w", model.ErrConflict)
+
+// OrgService implements the organization use cases. Memberships are
+// writes to the users, made through users.
+type OrgService struct {
+	orgs  repo.OrgRepository
+	users *UserService
+	ids   ids.Generator
+}
+
+// NewOrgService creates an OrgService backed by the given repository,
+// giving new organizations the IDs of publicIDs
+func NewOrgService(orgs repo.OrgRepository, users *UserService, publicIDs ids.Generator) *OrgService {
+	return &OrgService{orgs: orgs, users: users, ids: publicIDs}
+}
+
+// Create creates a new organization from the request
+func (s *OrgService) Create(ctx context.Context, req model.CreateOrgRequest) (*model.Organization, error) {
+	publicID, err := s.ids.NewID()
+	if err != nil {
+		return nil, err
+	}
+	org := &model.Organization{
+		PublicID:  publicID,
+		Name:      req.Name,
+		NameKey:   model.FoldName(req.Name),
+		CreatedAt: time.Now(),
+	}
+	if model.IsDryRun(ctx) {
+		return org, nil
+	}
+	if err := s.orgs.Create(ctx, org); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// Get returns a single organization
+func (s *OrgService) Get(ctx context.Context, id string) (*model.Organization, error) {
+	return s.orgs.Get(ctx, id)
+}
+
+// AddMember adds the referenced user to the organization and returns the
+// updated user. Adding a member again is not an error, but a user who
+// belongs to another organization must leave it first.
+func (s *OrgService) AddMember(ctx context.Context, id string, ref model.UserRef) (*model.User, error) {
+	org, err := s.orgs.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.users.repo.Get(ctx, ref)
+	switch {
+	case err != nil:
+		return nil, err
+	case user.OrgID == org.PublicID:
+		return user, nil
+	case user.OrgID != "":
+		return nil, errOtherOrg
+	}
+
+	user, err = s.users.store(ctx).Update(ctx, ref, model.UserUpdate{OrgID: &org.PublicID})
+	if err != nil {
+		return nil, err
+	}
+	s.users.afterWrite(ctx, events.UserUpdated, user.PublicID)
+	return user, nil
+}
+
+// Members returns the members of the organization matching filter
+func (s *OrgService) Members(ctx context.Context, id string, filter model.UserFilter) ([]model.User, error) {
+	// Unknown organizations are not found rather than empty
+	if _, err := s.orgs.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	filter.OrgID = id
+	return s.users.List(ctx, filter)
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/model"
+)
+
+// jsonPatchOps lists the RFC 6902 operations accepted for users
+var jsonPatchOps = map[string]bool{"add": true, "replace": true, "remove": true, "test": true}
+
+// patchableFields lists the top-level fields a patch may touch
+var patchableFields = map[string]bool{"name": true, "email": true, "age": true, "location": true}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch document to the user. A
+// failing "test" operation is reported as a conflict.
+func (s *UserService) ApplyJSONPatch(ctx context.Context, ref model.UserRef, patch []byte) (*model.User, error) {
+	ops, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return nil, &model.ValidationError{Reason: "invalid JSON Patch document: " + err.Error()}
+	}
+	for _, op := range ops {
+		if kind := op.Kind(); !jsonPatchOps[kind] {
+			return nil, &model.ValidationError{Field: "op", Reason: fmt.Sprintf("unsupported operation %q", kind)}
+		}
+		path, err := op.Path()
+		if err != nil || !patchableField(path) {
+			return nil, &model.ValidationError{Field: "path", Reason: fmt.Sprintf("cannot patch %q", path)}
+		}
+	}
+
+	user, err := s.repo.Get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := json.Marshal(user.Patchable())
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := ops.Apply(doc)
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return nil, fmt.Errorf("%w: %w", model.ErrConflict, err)
+	}
+	if err != nil {
+		return nil, &model.ValidationError{Reason: err.Error()}
+	}
+	return s.savePatched(ctx, ref, user, patched)
+}
+
+// ApplyMergePatch applies an RFC 7386 JSON Merge Patch document to the
+// user. Members set to null are removed, which clears optional fields
+// such as email or location.
+func (s *UserService) ApplyMergePatch(ctx context.Context, ref model.UserRef, patch []byte) (*model.User, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil {
+		return nil, &model.ValidationError{Reason: "merge patch must be a JSON object"}
+	}
+	for field := range members {
+		if !patchableFields[field] {
+			return nil, &model.ValidationError{Field: field, Reason: "cannot be patched"}
+		}
+	}
+
+	user, err := s.repo.Get(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := json.Marshal(user.Patchable())
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := jsonpatch.MergePatch(doc, patch)
+	if err != nil {
+		return nil, &model.ValidationError{Reason: err.Error()}
+	}
+	return s.savePatched(ctx, ref, user, patched)
+}
+
+// patchableField reports whether a JSON Pointer targets an editable field
+func patchableField(path string) bool {
+	top, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return strings.HasPrefix(path, "/") && patchableFields[top]
+}
+
+// savePatched validates the patched document and persists the fields that
+// changed relative to the stored user
+func (s *UserService) savePatched(ctx context.Context, ref model.UserRef, user *model.User, patched []byte) (*model.User, error) {
+	var after model.PatchableUser
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&after); err != nil {
+		return nil, &model.ValidationError{Reason: "patched document is invalid: " + err.Error()}
+	}
+	if err := model.Validate(after); err != nil {
+		return nil, err
+	}
+
+	before := user.Patchable()
+	update := model.UserUpdate{}
+	if after.Name != before.Name {
+		nameKey := model.FoldName(after.Name)
+		update.Name = &after.Name
+		update.NameKey = &nameKey
+	}
+	switch {
+	case after.Email == "" && before.Email != "":
+		update.Unset = append(update.Unset, "email")
+	case after.Email != before.Email:
+		update.Email = &after.Email
+	}
+	if after.Age != before.Age {
+		update.Age = &after.Age
+	}
+	switch {
+	case after.Location == nil && before.Location != nil:
+		update.Unset = append(update.Unset, "location")
+	case after.Location != nil && (before.Location == nil || *after.Location != *before.Location):
+		update.Location = after.Location.Point()
+	}
+	if err := s.checkUpdatedEmail(ctx, &update); err != nil {
+		return nil, err
+	}
+	patchedUser, err := s.store(ctx).Update(ctx, ref, update)
+	if err != nil {
+		return nil, err
+	}
+	s.afterWrite(ctx, events.UserUpdated, patchedUser.PublicID)
+	return patchedUser, nil
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// patchRepository serves one stored user and records the updates patches
+// reach the repository with
+type patchRepository struct {
+	repo.UserRepository
+	user    model.User
+	updates []model.UserUpdate
+}
+
+func (r *patchRepository) Get(context.Context, model.UserRef) (*model.User, error) {
+	user := r.user
+	return &user, nil
+}
+
+func (r *patchRepository) Update(_ context.Context, _ model.UserRef, update model.UserUpdate) (*model.User, error) {
+	r.updates = append(r.updates, update)
+	return r.user.Applied(update), nil
+}
+
+// discardTasks drops the post-write work
+type discardTasks struct{}
+
+func (discardTasks) Submit(context.Context, string, func(context.Context) error) error { return nil }
+
+// patchService returns a UserService over a patchRepository holding a
+// user with every patchable field set
+func patchService() (*UserService, *patchRepository) {
+	r := &patchRepository{user: model.User{
+		PublicID: "0192a8e2-7b3c-7def-8000-0123456789ab",
+		Name:     "Alice",
+		NameKey:  model.FoldName("Alice"),
+		Email:    "alice@example.com",
+		Age:      30,
+		Location: model.NewGeoPoint(48.85, 2.35),
+		Tags:     []string{"beta"},
+		OrgID:    "0192a8e2-0000-7000-8000-000000000000",
+		Version:  3,
+	}}
+	return NewUserService(r, nil, config.SuggestConfig{}, discardTasks{}, nil, nil, nil, nil, nil), r
+}
+
+// checkPatch fails unless a patch either was rejected as invalid or
+// conflicting, or reached the repository as one update of the patchable
+// fields of a valid user
+func checkPatch(t *testing.T, patch []byte, r *patchRepository, err error) {
+	t.Helper()
+	if err != nil {
+		if !errors.Is(err, model.ErrValidation) && !errors.Is(err, model.ErrConflict) {
+			t.Fatalf("patch %q failed with %v, want a validation error or a conflict", patch, err)
+		}
+		if len(r.updates) > 0 {
+			t.Fatalf("rejected patch %q reached the repository with %+v", patch, r.updates)
+		}
+		return
+	}
+	if len(r.updates) != 1 {
+		t.Fatalf("patch %q reached the repository with %d updates, want 1", patch, len(r.updates))
+	}
+	update := r.updates[0]
+	if update.OrgID != nil || len(update.AddTags) > 0 || len(update.RemoveTags) > 0 {
+		t.Fatalf("patch %q changes a field that cannot be patched: %+v", patch, update)
+	}
+	for _, field := range update.Unset {
+		if field != "email" && field != "location" {
+			t.Fatalf("patch %q unsets %q", patch, field)
+		}
+	}
+	if (update.Name == nil) != (update.NameKey == nil) || update.Name != nil && *update.NameKey != model.FoldName(*update.Name) {
+		t.Fatalf("patch %q sets name %v with name_key %v", patch, update.Name, update.NameKey)
+	}
+	user := r.user.Applied(update)
+	if err := model.Validate(user.Patchable()); err != nil {
+		t.Fatalf("patch %q saves an invalid user %+v: %v", patch, user.Patchable(), err)
+	}
+}
+
+func FuzzApplyMergePatch(f *testing.F) {
+	f.Add([]byte(`{"name": "Bob"}`))
+	f.Add([]byte(`{"email": null, "location": null}`))
+	f.Add([]byte(`{"location": {"lat": 91, "lng": 0}}`))
+	f.Add([]byte(`{"age": 0}`))
+	f.Add([]byte(`{"name": {"$ne": ""}}`))
+	f.Add([]byte(`{"$set": {"org_id": "x"}}`))
+	f.Add([]byte(`{"tags": ["admin"]}`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`null`))
+
+	f.Fuzz(func(t *testing.T, patch []byte) {
+		s, r := patchService()
+		_, err := s.ApplyMergePatch(context.Background(), model.UserRef{PublicID: r.user.PublicID}, patch)
+		checkPatch(t, patch, r, err)
+	})
+}
+
+func FuzzApplyJSONPatch(f *testing.F) {
+	f.Add([]byte(`[{"op": "replace", "path": "/name", "value": "Bob"}]`))
+	f.Add([]byte(`[{"op": "remove", "path": "/email"}]`))
+	f.Add([]byte(`[{"op": "test", "path": "/age", "value": 31}]`))
+	f.Add([]byte(`[{"op": "add", "path": "/location/lat", "value": 10}]`))
+	f.Add([]byte(`[{"op": "copy", "from": "/email", "path": "/name"}]`))
+	f.Add([]byte(`[{"op": "add", "path": "/$where", "value": "1"}]`))
+	f.Add([]byte(`[{"op": "replace", "path": "/tags/0", "value": "admin"}]`))
+	f.Add([]byte(`[{"op": "replace", "path": "name", "value": "Bob"}]`))
+	f.Add([]byte(`{}`))
+
+	f.Fuzz(func(t *testing.T, patch []byte) {
+		s, r := patchService()
+		_, err := s.ApplyJSONPatch(context.Background(), model.UserRef{PublicID: r.user.PublicID}, patch)
+		checkPatch(t, patch, r, err)
+		if slices.ContainsFunc(r.updates, func(u model.UserUpdate) bool { return u.Location != nil && u.Location.Type != "Point" }) {
+			t.Fatalf("patch %q writes a location that is not a point", patch)
+		}
+	})
+}
+
+// TestUpdateEmailRisk checks that a patched address is verified, its
+// verdict replacing the flag of the former one
+func TestUpdateEmailRisk(t *testing.T) {
+	for _, tc := range []struct {
+		email, risk string
+	}{
+		{"alice@example.org", ""},
+		{"alice@risky.example", "disposable"},
+	} {
+		s, r := patchService()
+		s.emails = riskyEmails{mode: "flag"}
+		r.user.EmailRisk = "disposable"
+		user, err := s.Update(context.Background(), model.UserRef{PublicID: r.user.PublicID}, model.UpdateUserRequest{Email: tc.email})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.EmailRisk != tc.risk {
+			t.Errorf("%s: email_risk %q, want %q", tc.email, user.EmailRisk, tc.risk)
+		}
+	}
+
+	s, r := patchService()
+	s.emails = riskyEmails{mode: "reject"}
+	_, err := s.ApplyMergePatch(context.Background(), model.UserRef{PublicID: r.user.PublicID}, []byte(`{"email": "alice@risky.example"}`))
+	if !errors.Is(err, model.ErrValidation) || len(r.updates) > 0 {
+		t.Fatalf("patch failed with %v and reached the repository with %+v, want a validation error", err, r.updates)
+	}
+}
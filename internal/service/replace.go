@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/model"
+)
+
+var (
+	// errVersionMismatch is returned when a replace targets a stale version
+	errVersionMismatch = fmt.Errorf("version mismatch: %w", model.ErrConflict)
+	// errReplaceErased is returned when replacing an erased user, which
+	// would restore personal data to it
+	errReplaceErased = fmt.Errorf("erased users cannot be replaced: %w", model.ErrConflict)
+)
+
+// Replace creates or fully replaces the referenced user and reports
+// whether it was created. Users can only be created under a client-chosen
+// UUID or Snowflake ID, since ObjectIDs are assigned by the server. Erased
+// users cannot be replaced.
+func (s *UserService) Replace(ctx context.Context, ref model.UserRef, req model.ReplaceUserRequest) (*model.User, bool, error) {
+	existing, err := s.repo.Get(ctx, ref)
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		return s.createReplacement(ctx, ref, req)
+	case err != nil:
+		return nil, false, err
+	}
+
+	if existing.ErasedAt != nil {
+		return nil, false, errReplaceErased
+	}
+	if req.Version != nil && *req.Version != existing.Version {
+		return nil, false, errVersionMismatch
+	}
+	// An unchanged address keeps its verdict; a new one is verified
+	risk := existing.EmailRisk
+	if req.Email != existing.Email {
+		if risk, err = s.checkEmail(ctx, req.Email); err != nil {
+			return nil, false, err
+		}
+	}
+	user := &model.User{
+		ID:        existing.ID,
+		PublicID:  existing.PublicID,
+		Username:  existing.Username,
+		Name:      req.Name,
+		NameKey:   model.FoldName(req.Name),
+		Email:     req.Email,
+		EmailRisk: risk,
+		Age:       req.Age,
+		Location:  req.Location.Point(),
+		// Tags and memberships are edited through their own endpoints only
+		Tags:             existing.Tags,
+		OrgID:            existing.OrgID,
+		AttachmentsCount: existing.AttachmentsCount,
+		CreatedAt:        existing.CreatedAt,
+	}
+	// Guard against writes that landed since the read above
+	if _, err := s.store(ctx).Replace(ctx, user, existing.Version, false); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return nil, false, errVersionMismatch
+		}
+		return nil, false, err
+	}
+	s.afterWrite(ctx, events.UserUpdated, user.PublicID)
+	return user, false, nil
+}
+
+// createReplacement upserts a new user under the public ID of ref
+func (s *UserService) createReplacement(ctx context.Context, ref model.UserRef, req model.ReplaceUserRequest) (*model.User, bool, error) {
+	if ref.PublicID == "" {
+		return nil, false, fmt.Errorf("user %w: only UUIDs and Snowflake IDs can be used to create users", model.ErrNotFound)
+	}
+	if req.Version != nil && *req.Version != 0 {
+		return nil, false, errVersionMismatch
+	}
+	risk, err := s.checkEmail(ctx, req.Email)
+	if err != nil {
+		return nil, false, err
+	}
+
+	user := &model.User{
+		ID:        primitive.NewObjectID(),
+		PublicID:  ref.PublicID,
+		Name:      req.Name,
+		NameKey:   model.FoldName(req.Name),
+		Email:     req.Email,
+		EmailRisk: risk,
+		Age:       req.Age,
+		Location:  req.Location.Point(),
+	}
+	created := false
+	err = s.createWithUsername(ctx, user, func(ctx context.Context, u *model.User) error {
+		var err error
+		created, err = s.store(ctx).Replace(ctx, u, 0, true)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	typ := events.UserUpdated
+	if created {
+		typ = events.UserCreated
+	}
+	s.afterWrite(ctx, typ, user.PublicID)
+	if created {
+		s.sendWelcome(ctx, user)
+	}
+	return user, created, nil
+}
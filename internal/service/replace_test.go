@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/emailcheck"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// riskyEmails distrusts the addresses of the risky.example domain
+type riskyEmails struct {
+	mode string
+}
+
+func (v riskyEmails) Verify(_ context.Context, email string) (emailcheck.Verdict, error) {
+	if strings.HasSuffix(email, "@risky.example") {
+		return emailcheck.Verdict{Status: "risky", Reason: "disposable"}, nil
+	}
+	return emailcheck.Verdict{Status: "valid"}, nil
+}
+
+func (v riskyEmails) Mode() string { return v.mode }
+
+func (riskyEmails) Forget(context.Context, string) error { return nil }
+
+// replaceRepository holds at most one user, replaced in place
+type replaceRepository struct {
+	repo.UserRepository
+	user *model.User
+}
+
+func (r *replaceRepository) Get(context.Context, model.UserRef) (*model.User, error) {
+	if r.user == nil {
+		return nil, model.ErrNotFound
+	}
+	user := *r.user
+	return &user, nil
+}
+
+func (r *replaceRepository) Replace(_ context.Context, user *model.User, _ int64, _ bool) (bool, error) {
+	created := r.user == nil
+	stored := *user
+	r.user = &stored
+	return created, nil
+}
+
+// replaceService returns a UserService over a replaceRepository holding
+// user, verifying the addresses in mode
+func replaceService(user *model.User, mode string) (*UserService, *replaceRepository) {
+	r := &replaceRepository{user: user}
+	return NewUserService(r, nil, config.SuggestConfig{}, discardTasks{}, nil, riskyEmails{mode: mode}, nil, nil, nil), r
+}
+
+// flaggedUser is a stored user whose address was flagged
+func flaggedUser() *model.User {
+	return &model.User{
+		PublicID:  "0192a8e2-7b3c-7def-8000-0123456789ab",
+		Name:      "Alice",
+		Email:     "alice@risky.example",
+		EmailRisk: "disposable",
+		Age:       30,
+		Version:   3,
+	}
+}
+
+func TestReplaceEmailRisk(t *testing.T) {
+	ref := model.UserRef{PublicID: "0192a8e2-7b3c-7def-8000-0123456789ab"}
+	for _, tc := range []struct {
+		name, email, risk string
+	}{
+		{"unchanged", "alice@risky.example", "disposable"},
+		{"verified", "alice@example.com", ""},
+		{"flagged", "bob@risky.example", "disposable"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, r := replaceService(flaggedUser(), "flag")
+			if _, _, err := s.Replace(context.Background(), ref, model.ReplaceUserRequest{Name: "Alice", Email: tc.email, Age: 30}); err != nil {
+				t.Fatal(err)
+			}
+			if r.user.EmailRisk != tc.risk {
+				t.Errorf("email_risk %q, want %q", r.user.EmailRisk, tc.risk)
+			}
+		})
+	}
+}
+
+// TestReplaceRejectsEmail checks that a replace, creating or not, refuses
+// a risky address in reject mode
+func TestReplaceRejectsEmail(t *testing.T) {
+	ref := model.UserRef{PublicID: "0192a8e2-7b3c-7def-8000-0123456789ab"}
+	req := model.ReplaceUserRequest{Name: "Bob", Email: "bob@risky.example", Age: 30}
+	for name, user := range map[string]*model.User{"create": nil, "replace": {PublicID: ref.PublicID, Email: "bob@example.com", Version: 1}} {
+		t.Run(name, func(t *testing.T) {
+			s, r := replaceService(user, "reject")
+			_, _, err := s.Replace(context.Background(), ref, req)
+			if !errors.Is(err, model.ErrValidation) {
+				t.Fatalf("replace failed with %v, want a validation error", err)
+			}
+			if r.user != user {
+				t.Errorf("the rejected replace stored %+v", r.user)
+			}
+		})
+	}
+}
+
+func TestReplaceErased(t *testing.T) {
+	erasedAt := time.Now()
+	user := &model.User{PublicID: "0192a8e2-7b3c-7def-8000-0123456789ab", Version: 2, ErasedAt: &erasedAt}
+	s, r := replaceService(user, "flag")
+	_, _, err := s.Replace(context.Background(), model.UserRef{PublicID: user.PublicID}, model.ReplaceUserRequest{Name: "Alice", Email: "alice@example.com", Age: 30})
+	if !errors.Is(err, errReplaceErased) {
+		t.Fatalf("replace failed with %v, want %v", err, errReplaceErased)
+	}
+	if r.user != user {
+		t.Errorf("the erased user was replaced with %+v", r.user)
+	}
+}
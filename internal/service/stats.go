@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"golang.org/x/sync/singleflight"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/model"
+)
+
+// StatsComputer aggregates the statistics of every user
+type StatsComputer interface {
+	Compute(ctx context.Context) (*model.UserStats, error)
+}
+
+// StatsService serves the user statistics from a snapshot, as the
+// aggregation scans every user. The snapshot is recomputed in the
+// background every RefreshInterval, and on demand once it is missing or
+// older than MaxAge, with concurrent requests waiting on one aggregation.
+type StatsService struct {
+	stats    StatsComputer
+	cfg      config.UserStatsConfig
+	snapshot atomic.Pointer[model.UserStats]
+	inflight singleflight.Group
+}
+
+// NewStatsService creates a StatsService aggregating with stats
+func NewStatsService(stats StatsComputer, cfg config.UserStatsConfig) *StatsService {
+	return &StatsService{stats: stats, cfg: cfg}
+}
+
+// Stats returns the current snapshot, computing it when the cache is cold
+func (s *StatsService) Stats(ctx context.Context) (*model.UserStats, error) {
+	if snap := s.snapshot.Load(); snap != nil && time.Since(snap.ComputedAt) <= s.cfg.MaxAge {
+		return snap, nil
+	}
+	return s.refresh(ctx, "on_demand")
+}
+
+// refresh computes a new snapshot, or waits for the one being computed.
+// The aggregation runs in a users.stats span of the first caller, but not
+// under its cancellation: the callers joining it must not fail because
+// that one went away. A caller cancelled while waiting stops waiting.
+func (s *StatsService) refresh(ctx context.Context, trigger string) (*model.UserStats, error) {
+	ch := s.inflight.DoChan("stats", func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.cfg.Timeout)
+		defer cancel()
+		span, ctx := tracer.StartSpanFromContext(ctx, "users.stats", tracer.Tag("stats.trigger", trigger))
+		stats, err := s.stats.Compute(ctx)
+		if err == nil {
+			stats.ComputedAt = time.Now().UTC()
+			s.snapshot.Store(stats)
+			span.SetTag("stats.users", stats.Users)
+		}
+		span.Finish(tracer.WithError(err))
+		return stats, err
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*model.UserStats), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Run computes the snapshot right away, then every RefreshInterval until
+// ctx is cancelled. A failed refresh keeps serving the last snapshot
+// until it is older than MaxAge.
+func (s *StatsService) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.refresh(ctx, "schedule"); err != nil && ctx.Err() == nil {
+			log.Printf("User stats refresh failed: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package service
+
+import (
+	"context"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/baggage"
+)
+
+// startStep starts the child span of one step of a use case, such as
+// "persist" in "create", so flame graphs show where a write spends its time
+func startStep(ctx context.Context, useCase, step string) (*tracer.Span, context.Context) {
+	span, ctx := tracer.StartSpanFromContext(ctx, "users."+step, tracer.ResourceName(useCase))
+	baggage.Tag(span)
+	return span, ctx
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"datadog-golang-example/internal/model"
+)
+
+// maxSuggestCacheEntries bounds the memory used by the suggestion cache
+const maxSuggestCacheEntries = 1024
+
+// suggestCache is a small TTL cache of suggestion results keyed by prefix.
+// Typeahead traffic repeats the same short prefixes, so even a short TTL
+// absorbs most of the load.
+type suggestCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]suggestEntry
+}
+
+type suggestEntry struct {
+	suggestions []model.Suggestion
+	expires     time.Time
+}
+
+func newSuggestCache(ttl time.Duration) *suggestCache {
+	return &suggestCache{ttl: ttl, entries: make(map[string]suggestEntry)}
+}
+
+// get returns the cached suggestions for prefix if they have not expired
+func (c *suggestCache) get(prefix string) ([]model.Suggestion, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[prefix]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.suggestions, true
+}
+
+// put caches suggestions for prefix, evicting expired entries (or
+// everything) when the cache is full
+func (c *suggestCache) put(prefix string, suggestions []model.Suggestion) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxSuggestCacheEntries {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxSuggestCacheEntries {
+			c.entries = make(map[string]suggestEntry)
+		}
+	}
+	c.entries[prefix] = suggestEntry{suggestions: suggestions, expires: now.Add(c.ttl)}
+}
+
+// purge drops every cached suggestion
+func (c *suggestCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]suggestEntry)
+}
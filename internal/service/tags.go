@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/model"
+)
+
+// AddTags adds the normalized tags to the user; tags it already carries
+// are left as they are
+func (s *UserService) AddTags(ctx context.Context, ref model.UserRef, tags []string) (*model.User, error) {
+	tags, err := model.NormalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateTags(ctx, ref, model.UserUpdate{AddTags: tags})
+}
+
+// RemoveTag removes a tag from the user. Removing a tag the user does not
+// carry is not an error.
+func (s *UserService) RemoveTag(ctx context.Context, ref model.UserRef, tag string) (*model.User, error) {
+	tag, err := model.NormalizeTag("tag", tag)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateTags(ctx, ref, model.UserUpdate{RemoveTags: []string{tag}})
+}
+
+// updateTags applies a tag update and queues the follow-up work
+func (s *UserService) updateTags(ctx context.Context, ref model.UserRef, update model.UserUpdate) (*model.User, error) {
+	user, err := s.store(ctx).Update(ctx, ref, update)
+	if err != nil {
+		return nil, err
+	}
+	s.afterWrite(ctx, events.UserUpdated, user.PublicID)
+	return user, nil
+}
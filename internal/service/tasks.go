@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"datadog-golang-example/internal/mail"
+	"datadog-golang-example/internal/model"
+)
+
+// TaskSubmitter runs non-critical work in the background
+type TaskSubmitter interface {
+	Submit(ctx context.Context, name string, task func(ctx context.Context) error) error
+}
+
+// afterWrite moves the users watermark, publishes a change of type typ to
+// each of userIDs and queues the follow-up work of a user write; dry runs
+// have none. The write has already succeeded, so failures are logged
+// rather than returned.
+func (s *UserService) afterWrite(ctx context.Context, typ string, userIDs ...string) {
+	if model.IsDryRun(ctx) {
+		return
+	}
+	s.touch(ctx)
+	s.publish(ctx, typ, userIDs)
+	err := s.tasks.Submit(ctx, "suggest.invalidate", func(context.Context) error {
+		s.suggests.purge()
+		return nil
+	})
+	if err != nil {
+		log.Printf("Skipping suggestion cache invalidation: %v", err)
+	}
+}
+
+// sendWelcome queues the welcome email of a new user that has an address,
+// unless the user was only created in a dry run
+func (s *UserService) sendWelcome(ctx context.Context, user *model.User) {
+	if user.Email == "" || model.IsDryRun(ctx) {
+		return
+	}
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Welcome to Go API Demo",
+		Body:    fmt.Sprintf("Hi %s,\n\nYour account %s is ready.\n", user.Name, user.Username),
+	}
+	err := s.tasks.Submit(ctx, "mail.welcome", func(ctx context.Context) error {
+		return s.mailer.Send(ctx, msg)
+	})
+	if err != nil {
+		log.Printf("Skipping welcome email for user %s: %v", user.PublicID, err)
+	}
+}
+
+// OnRemove registers cleanup to run in the background when a user is
+// deleted or erased, to remove the data kept about it elsewhere, such as
+// attachments. cleanup receives the public ID of the user.
+func (s *UserService) OnRemove(cleanup func(ctx context.Context, userID string) error) {
+	s.removals = append(s.removals, cleanup)
+}
+
+// afterRemove queues the cleanups registered with OnRemove for a deleted
+// or erased user, unless in a dry run
+func (s *UserService) afterRemove(ctx context.Context, userID string) {
+	if model.IsDryRun(ctx) {
+		return
+	}
+	for _, cleanup := range s.removals {
+		err := s.tasks.Submit(ctx, "user.cleanup", func(ctx context.Context) error {
+			return cleanup(ctx, userID)
+		})
+		if err != nil {
+			log.Printf("Skipping cleanup of user %s: %v", userID, err)
+		}
+	}
+}
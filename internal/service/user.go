@@ -0,0 +1,366 @@
+// Package service implements the business logic on top of the repositories.
+package service
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"strings"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/ids"
+	"datadog-golang-example/internal/mail"
+	"datadog-golang-example/internal/model"
+	"datadog-golang-example/internal/repo"
+)
+
+// UserService implements the user use cases
+type UserService struct {
+	hooks    *repo.UserHooks
+	repo     repo.UserRepository
+	dryRun   repo.UserRepository
+	ids      ids.Generator
+	suggest  config.SuggestConfig
+	suggests *suggestCache
+	tasks    TaskSubmitter
+	mailer   mail.Mailer
+	emails   EmailVerifier
+	erasures repo.ErasureLog
+	marks    repo.Watermarks
+	bus      events.Bus
+	// removals clean up after deleted and erased users
+	removals []func(ctx context.Context, userID string) error
+}
+
+// NewUserService creates a UserService backed by the given repository,
+// giving new users the IDs of publicIDs. Post-write work, including emails
+// sent through mailer, is handed to tasks. New addresses are checked with emails unless it is nil, and
+// erasures are recorded in erasures. Writes move the users watermark of
+// marks and are published on bus, unless they are nil. Writes, dry runs
+// included, run the hooks of Hooks.
+func NewUserService(r repo.UserRepository, publicIDs ids.Generator, suggest config.SuggestConfig, tasks TaskSubmitter, mailer mail.Mailer, emails EmailVerifier, erasures repo.ErasureLog, marks repo.Watermarks, bus events.Bus) *UserService {
+	hooks := repo.NewUserHooks()
+	return &UserService{
+		hooks:    hooks,
+		repo:     repo.NewHookedUserRepository(r, hooks),
+		dryRun:   repo.NewHookedUserRepository(repo.NewDryRunUserRepository(r), hooks),
+		ids:      publicIDs,
+		suggest:  suggest,
+		suggests: newSuggestCache(suggest.CacheTTL),
+		tasks:    tasks,
+		mailer:   mailer,
+		emails:   emails,
+		erasures: erasures,
+		marks:    marks,
+		bus:      bus,
+	}
+}
+
+// Hooks returns the hooks run around the writes of the users, to register
+// more while wiring
+func (s *UserService) Hooks() *repo.UserHooks {
+	return s.hooks
+}
+
+// store returns the repository for ctx, which only simulates writes
+// during a dry run
+func (s *UserService) store(ctx context.Context) repo.UserRepository {
+	if model.IsDryRun(ctx) {
+		return s.dryRun
+	}
+	return s.repo
+}
+
+// Create creates a new user from the request
+func (s *UserService) Create(ctx context.Context, req model.CreateUserRequest) (*model.User, error) {
+	span, stepCtx := startStep(ctx, "create", "email_check")
+	risk, err := s.checkEmail(stepCtx, req.Email)
+	span.Finish(tracer.WithError(err))
+	if err != nil {
+		return nil, err
+	}
+	publicID, err := s.ids.NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{
+		ID:        internalID(publicID),
+		PublicID:  publicID,
+		Name:      req.Name,
+		NameKey:   model.FoldName(req.Name),
+		Email:     req.Email,
+		EmailRisk: risk,
+		Age:       req.Age,
+		Location:  req.Location.Point(),
+	}
+	span, stepCtx = startStep(ctx, "create", "persist")
+	err = s.createWithUsername(stepCtx, user, s.store(ctx).Create)
+	span.Finish(tracer.WithError(err))
+	if err != nil {
+		return nil, err
+	}
+
+	span, stepCtx = startStep(ctx, "create", "post_process")
+	s.afterWrite(stepCtx, events.UserCreated, user.PublicID)
+	s.sendWelcome(stepCtx, user)
+	span.Finish()
+	return user, nil
+}
+
+// internalID returns the ObjectID of a new user: its public ID when that
+// is an ObjectID, so references to either find it, or a new one
+func internalID(publicID string) primitive.ObjectID {
+	if id, err := primitive.ObjectIDFromHex(publicID); err == nil {
+		return id
+	}
+	return primitive.NewObjectID()
+}
+
+// maxUsernameAttempts bounds how many suffixed usernames are tried
+const maxUsernameAttempts = 5
+
+// createWithUsername inserts the user with insert under a username derived
+// from its name, retrying with a numeric suffix when the username is taken.
+// Each taken username is recorded as an event of the span in ctx.
+func (s *UserService) createWithUsername(ctx context.Context, user *model.User, insert func(context.Context, *model.User) error) error {
+	span, _ := tracer.SpanFromContext(ctx)
+	base := model.Slugify(user.Name)
+	for attempt := 1; ; attempt++ {
+		user.Username = model.UsernameCandidate(base, attempt)
+		if attempt == maxUsernameAttempts {
+			// Popular names: fall back to a suffix that is unique in practice
+			user.Username = base + "-" + user.PublicID[len(user.PublicID)-8:]
+		}
+
+		err := insert(ctx, user)
+		var conflict *model.ConflictError
+		if errors.As(err, &conflict) && conflict.Field == "username" && attempt < maxUsernameAttempts {
+			span.AddEvent("username.taken", tracer.WithSpanEventAttributes(map[string]any{"attempt": attempt}))
+			continue
+		}
+		span.SetTag("username.attempts", attempt)
+		return err
+	}
+}
+
+// List returns the users matching filter
+func (s *UserService) List(ctx context.Context, filter model.UserFilter) ([]model.User, error) {
+	if err := normalizeFilter(filter); err != nil {
+		return nil, err
+	}
+	return s.repo.List(ctx, filter)
+}
+
+// Stream yields the users matching filter without holding them all in
+// memory
+func (s *UserService) Stream(ctx context.Context, filter model.UserFilter) iter.Seq2[model.User, error] {
+	if err := normalizeFilter(filter); err != nil {
+		return func(yield func(model.User, error) bool) { yield(model.User{}, err) }
+	}
+	return s.repo.Stream(ctx, filter)
+}
+
+// normalizeFilter normalizes the filter values in place, as they are stored
+func normalizeFilter(filter model.UserFilter) error {
+	if tag, ok := filter.Filters["tag"]; ok {
+		tag, err := model.NormalizeTag("tag", tag)
+		if err != nil {
+			return err
+		}
+		filter.Filters["tag"] = tag
+	}
+	return nil
+}
+
+// Query returns the users matching filter and, unless count is
+// model.CountNone, their total counted as count asks
+func (s *UserService) Query(ctx context.Context, filter model.UserFilter, count string) (*model.UserQueryResult, error) {
+	if count == "" {
+		count = model.CountExact
+	}
+	// List normalizes the filters the count then relies on
+	users, err := s.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.UserQueryResult{Users: users, Count: count}
+	if count != model.CountNone {
+		if result.Total, err = s.repo.Count(ctx, filter, count == model.CountExact); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Get returns a single user
+func (s *UserService) Get(ctx context.Context, ref model.UserRef) (*model.User, error) {
+	return s.repo.Get(ctx, ref)
+}
+
+// maxBatchGetIDs bounds the number of IDs accepted by BatchGet
+const maxBatchGetIDs = 100
+
+// BatchGet fetches many users in one query and reports which of the
+// requested IDs were not found
+func (s *UserService) BatchGet(ctx context.Context, ids []string) (*model.BatchGetResult, error) {
+	if len(ids) > maxBatchGetIDs {
+		return nil, &model.ValidationError{Field: "ids", Reason: "must contain at most 100 IDs"}
+	}
+
+	refs := make([]model.UserRef, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		ref, err := model.ParseUserRef(id)
+		if err != nil {
+			return nil, &model.ValidationError{Field: "ids", Reason: id + " is not an ObjectID, a UUID or a Snowflake ID"}
+		}
+		if !seen[ref.String()] {
+			seen[ref.String()] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	users, err := s.repo.GetMany(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, 2*len(users))
+	for _, u := range users {
+		found[u.ID.Hex()] = true
+		found[u.PublicID] = true
+	}
+	result := &model.BatchGetResult{Users: users, Missing: []string{}}
+	for _, ref := range refs {
+		if !found[ref.String()] {
+			result.Missing = append(result.Missing, ref.String())
+		}
+	}
+	return result, nil
+}
+
+// GetByUsername returns the user with the given username
+func (s *UserService) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	return s.repo.GetByUsername(ctx, strings.ToLower(username))
+}
+
+// maxSuggestPrefixLen bounds the prefix accepted for suggestions
+const maxSuggestPrefixLen = 64
+
+// Suggest returns the users whose name starts with q for typeahead UIs.
+// Results are cached briefly and the query runs under a strict timeout so
+// a slow database never stalls keystrokes.
+func (s *UserService) Suggest(ctx context.Context, q string) ([]model.Suggestion, error) {
+	prefix := model.FoldName(q)
+	if prefix == "" {
+		return nil, &model.ValidationError{Field: "q", Reason: "is required"}
+	}
+	if len(prefix) > maxSuggestPrefixLen {
+		return nil, &model.ValidationError{Field: "q", Reason: "is too long"}
+	}
+
+	if suggestions, ok := s.suggests.get(prefix); ok {
+		return suggestions, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.suggest.Timeout)
+	defer cancel()
+
+	suggestions, err := s.repo.Suggest(ctx, prefix, s.suggest.Limit)
+	if err != nil {
+		return nil, err
+	}
+	s.suggests.put(prefix, suggestions)
+	return suggestions, nil
+}
+
+// Bounds for proximity queries
+const (
+	defaultNearbyRadius = 5000.0
+	maxNearbyRadius     = 50000.0
+	nearbyLimit         = 50
+)
+
+// Nearby returns the users within radiusMeters of the given coordinates.
+// A zero radius uses the default.
+func (s *UserService) Nearby(ctx context.Context, lat, lng, radiusMeters float64) ([]model.NearbyUser, error) {
+	switch {
+	case lat < -90 || lat > 90:
+		return nil, &model.ValidationError{Field: "lat", Reason: "must be between -90 and 90"}
+	case lng < -180 || lng > 180:
+		return nil, &model.ValidationError{Field: "lng", Reason: "must be between -180 and 180"}
+	case radiusMeters < 0 || radiusMeters > maxNearbyRadius:
+		return nil, &model.ValidationError{Field: "radius", Reason: "must be between 0 and 50000 meters"}
+	case radiusMeters == 0:
+		radiusMeters = defaultNearbyRadius
+	}
+	return s.repo.Nearby(ctx, model.NewGeoPoint(lat, lng), radiusMeters, nearbyLimit)
+}
+
+// Update applies the non-empty fields of the request to the user
+func (s *UserService) Update(ctx context.Context, ref model.UserRef, req model.UpdateUserRequest) (*model.User, error) {
+	update := userUpdate(req)
+	span, stepCtx := startStep(ctx, "update", "email_check")
+	err := s.checkUpdatedEmail(stepCtx, &update)
+	span.Finish(tracer.WithError(err))
+	if err != nil {
+		return nil, err
+	}
+
+	span, stepCtx = startStep(ctx, "update", "persist")
+	user, err := s.store(ctx).Update(stepCtx, ref, update)
+	span.Finish(tracer.WithError(err))
+	if err != nil {
+		return nil, err
+	}
+
+	span, stepCtx = startStep(ctx, "update", "post_process")
+	s.afterWrite(stepCtx, events.UserUpdated, user.PublicID)
+	span.Finish()
+	return user, nil
+}
+
+// userUpdate converts the non-empty fields of req into a partial update
+func userUpdate(req model.UpdateUserRequest) model.UserUpdate {
+	var update model.UserUpdate
+	if req.Name != "" {
+		nameKey := model.FoldName(req.Name)
+		update.Name = &req.Name
+		update.NameKey = &nameKey
+	}
+	if req.Email != "" {
+		update.Email = &req.Email
+	}
+	if req.Age > 0 {
+		update.Age = &req.Age
+	}
+	if req.Location != nil {
+		update.Location = req.Location.Point()
+	}
+	return update
+}
+
+// Delete removes a user. Its event and the cleanups registered with
+// OnRemove need its public ID, so a user referenced by ObjectID is read
+// first.
+func (s *UserService) Delete(ctx context.Context, ref model.UserRef) error {
+	userID := ref.PublicID
+	if userID == "" {
+		user, err := s.repo.Get(ctx, ref)
+		if err != nil {
+			return err
+		}
+		userID = user.PublicID
+	}
+	if err := s.store(ctx).Delete(ctx, ref); err != nil {
+		return err
+	}
+	s.afterWrite(ctx, events.UserDeleted, userID)
+	s.afterRemove(ctx, userID)
+	return nil
+}
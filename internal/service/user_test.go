@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/events"
+	"datadog-golang-example/internal/model"
+)
+
+// recordingBus keeps the events published to it
+type recordingBus struct {
+	events.Bus
+	published []events.Event
+}
+
+func (b *recordingBus) Publish(_ context.Context, e events.Event) error {
+	b.published = append(b.published, e)
+	return nil
+}
+
+// deleteRepository is a patchRepository whose user can be deleted
+type deleteRepository struct {
+	patchRepository
+	deleted []model.UserRef
+}
+
+func (r *deleteRepository) Delete(_ context.Context, ref model.UserRef) error {
+	r.deleted = append(r.deleted, ref)
+	return nil
+}
+
+// TestDeletePublishesPublicID checks that the event of a deletion names
+// the user by its public ID, however the user was referenced
+func TestDeletePublishesPublicID(t *testing.T) {
+	user := model.User{ID: primitive.NewObjectID(), PublicID: "0192a8e2-7b3c-7def-8000-0123456789ab", Name: "Alice", Version: 1}
+	for name, ref := range map[string]model.UserRef{
+		"public id": {PublicID: user.PublicID},
+		"object id": {ObjectID: user.ID},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := &deleteRepository{patchRepository: patchRepository{user: user}}
+			bus := &recordingBus{}
+			s := NewUserService(r, nil, config.SuggestConfig{}, discardTasks{}, nil, nil, nil, nil, bus)
+			if err := s.Delete(context.Background(), ref); err != nil {
+				t.Fatal(err)
+			}
+			if len(bus.published) != 1 {
+				t.Fatalf("published %d events, want 1", len(bus.published))
+			}
+			if e := bus.published[0]; e.Type != events.UserDeleted || e.UserID != user.PublicID {
+				t.Errorf("published %s of %s, want %s of %s", e.Type, e.UserID, events.UserDeleted, user.PublicID)
+			}
+		})
+	}
+}
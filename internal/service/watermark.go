@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"datadog-golang-example/internal/repo"
+)
+
+// usersCollection names the watermark of the users
+const usersCollection = "users"
+
+// LastModified returns when any user was last written, zero when unknown
+func (s *UserService) LastModified(ctx context.Context) (time.Time, error) {
+	if s.marks == nil {
+		return time.Time{}, nil
+	}
+	return s.marks.LastModified(ctx, usersCollection)
+}
+
+// touch moves the users watermark to now. It runs before the response is
+// sent, so a client polling right after its own write sees the change.
+func (s *UserService) touch(ctx context.Context) {
+	touchUsers(ctx, s.marks)
+}
+
+// touchUsers moves the users watermark of marks to now, if there are
+// marks
+func touchUsers(ctx context.Context, marks repo.Watermarks) {
+	if marks == nil {
+		return
+	}
+	if err := marks.Touch(ctx, usersCollection, time.Now()); err != nil {
+		log.Printf("Failed to move the users watermark: %v", err)
+	}
+}
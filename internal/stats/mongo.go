@@ -0,0 +1,75 @@
+package stats
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// MongoPool follows the connection pool of a MongoDB client through its
+// pool events
+type MongoPool struct {
+	tags []string
+
+	open       atomic.Int64
+	checkedOut atomic.Int64
+	failures   atomic.Int64
+
+	mu        sync.Mutex
+	waits     int64
+	waitTotal time.Duration
+	waitMax   time.Duration
+}
+
+// NewMongoPool creates the source of a client; name tells the clients of
+// one process apart
+func NewMongoPool(name string) *MongoPool {
+	return &MongoPool{tags: []string{"pool:" + name}}
+}
+
+// Monitor returns the pool monitor to set on the client
+func (p *MongoPool) Monitor() *event.PoolMonitor {
+	return &event.PoolMonitor{Event: p.event}
+}
+
+// event updates the counters from one pool event
+func (p *MongoPool) event(e *event.PoolEvent) {
+	switch e.Type {
+	case event.ConnectionCreated:
+		p.open.Add(1)
+	case event.ConnectionClosed:
+		p.open.Add(-1)
+	case event.GetSucceeded:
+		p.checkedOut.Add(1)
+		p.mu.Lock()
+		p.waits++
+		p.waitTotal += e.Duration
+		p.waitMax = max(p.waitMax, e.Duration)
+		p.mu.Unlock()
+	case event.GetFailed:
+		p.failures.Add(1)
+	case event.ConnectionReturned:
+		p.checkedOut.Add(-1)
+	}
+}
+
+// Report sends the pool size and use, and the checkout waits and
+// failures since the previous report
+func (p *MongoPool) Report(metrics statsd.ClientInterface) {
+	p.mu.Lock()
+	waits, total, longest := p.waits, p.waitTotal, p.waitMax
+	p.waits, p.waitTotal, p.waitMax = 0, 0, 0
+	p.mu.Unlock()
+
+	metrics.Gauge("mongodb.pool.open", float64(p.open.Load()), p.tags, 1)
+	metrics.Gauge("mongodb.pool.checked_out", float64(p.checkedOut.Load()), p.tags, 1)
+	metrics.Count("mongodb.pool.checkouts", waits, p.tags, 1)
+	metrics.Count("mongodb.pool.checkout_failures", p.failures.Swap(0), p.tags, 1)
+	metrics.Gauge("mongodb.pool.wait.max", milliseconds(longest), p.tags, 1)
+	if waits > 0 {
+		metrics.Gauge("mongodb.pool.wait.avg", milliseconds(total)/float64(waits), p.tags, 1)
+	}
+}
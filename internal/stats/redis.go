@@ -0,0 +1,37 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisPool reports the connection pool of a Redis client
+type RedisPool struct {
+	client interface{ PoolStats() *redis.PoolStats }
+	last   redis.PoolStats
+}
+
+// NewRedisPool creates the source of client
+func NewRedisPool(client interface{ PoolStats() *redis.PoolStats }) *RedisPool {
+	return &RedisPool{client: client}
+}
+
+// Report sends the pool size and use, and the waits and timeouts since
+// the previous report
+func (p *RedisPool) Report(metrics statsd.ClientInterface) {
+	s := *p.client.PoolStats()
+	last := p.last
+	p.last = s
+
+	metrics.Gauge("redis.pool.total", float64(s.TotalConns), nil, 1)
+	metrics.Gauge("redis.pool.idle", float64(s.IdleConns), nil, 1)
+	metrics.Gauge("redis.pool.pending", float64(s.PendingRequests), nil, 1)
+	metrics.Count("redis.pool.misses", int64(s.Misses-last.Misses), nil, 1)
+	metrics.Count("redis.pool.timeouts", int64(s.Timeouts-last.Timeouts), nil, 1)
+	if waits := s.WaitCount - last.WaitCount; waits > 0 {
+		wait := time.Duration(s.WaitDurationNs - last.WaitDurationNs)
+		metrics.Gauge("redis.pool.wait.avg", milliseconds(wait)/float64(waits), nil, 1)
+	}
+}
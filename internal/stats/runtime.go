@@ -0,0 +1,35 @@
+package stats
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// Runtime reports the goroutines, the heap and the GC pauses of the
+// process
+type Runtime struct {
+	numGC uint32
+}
+
+// Report sends the goroutine count, the heap size and the collections
+// since the previous report with their longest pause
+func (r *Runtime) Report(metrics statsd.ClientInterface) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	// PauseNs is a ring of the latest 256 pauses, indexed by GC number
+	collections := m.NumGC - r.numGC
+	var longest time.Duration
+	for i := uint32(0); i < min(collections, uint32(len(m.PauseNs))); i++ {
+		pause := time.Duration(m.PauseNs[(m.NumGC-i+255)%256])
+		longest = max(longest, pause)
+	}
+	r.numGC = m.NumGC
+
+	metrics.Gauge("runtime.goroutines", float64(runtime.NumGoroutine()), nil, 1)
+	metrics.Gauge("runtime.heap.alloc", float64(m.HeapAlloc), nil, 1)
+	metrics.Count("runtime.gc.collections", int64(collections), nil, 1)
+	metrics.Gauge("runtime.gc.pause.max", milliseconds(longest), nil, 1)
+}
@@ -0,0 +1,55 @@
+// Package stats periodically reports the saturation of the connection
+// pools and of the Go runtime as DogStatsD gauges, so exhaustion shows in
+// dashboards before requests start failing.
+package stats
+
+import (
+	"context"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+)
+
+// Source reports its current stats, typically the change since its
+// previous report for cumulative counters
+type Source interface {
+	Report(metrics statsd.ClientInterface)
+}
+
+// Reporter reports its sources every interval
+type Reporter struct {
+	interval time.Duration
+	metrics  statsd.ClientInterface
+	sources  []Source
+}
+
+// NewReporter creates a reporter; sources are added with Add
+func NewReporter(interval time.Duration, metrics statsd.ClientInterface) *Reporter {
+	return &Reporter{interval: interval, metrics: metrics}
+}
+
+// Add registers s, before Run is called
+func (r *Reporter) Add(s Source) {
+	r.sources = append(r.sources, s)
+}
+
+// Run reports every source each interval until ctx is cancelled
+func (r *Reporter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, s := range r.sources {
+				s.Report(r.metrics)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// milliseconds converts d for distribution and gauge values
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,128 @@
+// Package storage keeps binary objects, such as user attachments, in an
+// S3-compatible object store. Clients transfer the bytes themselves
+// through presigned URLs, so they never go through the API.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	awstrace "github.com/DataDog/dd-trace-go/contrib/aws/aws-sdk-go-v2/v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"datadog-golang-example/internal/config"
+)
+
+// Presigned is a URL granting one operation on an object until it expires
+type Presigned struct {
+	URL       string    `json:"url"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Store presigns the transfers of objects and deletes them
+type Store interface {
+	// PresignUpload grants uploading key with the given content type
+	PresignUpload(ctx context.Context, key, contentType string) (*Presigned, error)
+	// PresignDownload grants downloading key
+	PresignDownload(ctx context.Context, key string) (*Presigned, error)
+	// DeletePrefix deletes every object whose key starts with prefix and
+	// returns how many were deleted
+	DeletePrefix(ctx context.Context, prefix string) (int, error)
+}
+
+// NewS3Client creates an S3 client from the standard AWS environment,
+// credentials, region and AWS_ENDPOINT_URL, whose calls are traced as
+// aws.request spans. pathStyle addresses buckets in the path, as most
+// S3-compatible stores expect.
+func NewS3Client(ctx context.Context, pathStyle bool) (*s3.Client, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load AWS config: %w", err)
+	}
+	awstrace.AppendMiddleware(&cfg)
+	return s3.NewFromConfig(cfg, func(o *s3.Options) { o.UsePathStyle = pathStyle }), nil
+}
+
+// S3Store is a Store backed by an S3 bucket
+type S3Store struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+	ttl     time.Duration
+}
+
+// NewS3Store creates the store of cfg, whose presigned URLs last
+// cfg.URLTTL
+func NewS3Store(ctx context.Context, cfg config.StorageConfig) (*S3Store, error) {
+	client, err := NewS3Client(ctx, cfg.PathStyle)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Store{client: client, presign: s3.NewPresignClient(client), bucket: cfg.Bucket, ttl: cfg.URLTTL}, nil
+}
+
+// PresignUpload implements Store. The upload must send the same
+// Content-Type header.
+func (s *S3Store) PresignUpload(ctx context.Context, key, contentType string) (*Presigned, error) {
+	req, err := s.presign.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	}, s3.WithPresignExpires(s.ttl))
+	if err != nil {
+		return nil, fmt.Errorf("presign upload: %w", err)
+	}
+	return &Presigned{URL: req.URL, Method: req.Method, ExpiresAt: time.Now().Add(s.ttl)}, nil
+}
+
+// PresignDownload implements Store
+func (s *S3Store) PresignDownload(ctx context.Context, key string) (*Presigned, error) {
+	req, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(s.ttl))
+	if err != nil {
+		return nil, fmt.Errorf("presign download: %w", err)
+	}
+	return &Presigned{URL: req.URL, Method: req.Method, ExpiresAt: time.Now().Add(s.ttl)}, nil
+}
+
+// DeletePrefix implements Store, deleting the objects a page of keys at
+// a time
+func (s *S3Store) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	deleted := 0
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return deleted, fmt.Errorf("list objects: %w", err)
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+		ids := make([]types.ObjectIdentifier, len(page.Contents))
+		for i, obj := range page.Contents {
+			ids[i] = types.ObjectIdentifier{Key: obj.Key}
+		}
+		out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("delete objects: %w", err)
+		}
+		if len(out.Errors) > 0 {
+			return deleted, fmt.Errorf("delete object %s: %s", aws.ToString(out.Errors[0].Key), aws.ToString(out.Errors[0].Message))
+		}
+		deleted += len(ids)
+	}
+	return deleted, nil
+}
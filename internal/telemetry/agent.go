@@ -0,0 +1,123 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+
+	"datadog-golang-example/internal/config"
+)
+
+// ProbeAgent checks that the trace agent at url, over TCP or a Unix
+// socket, answers on /info
+func ProbeAgent(ctx context.Context, url string) error {
+	client, base := agentClient(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/info", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("agent answered %s", resp.Status)
+	}
+	return nil
+}
+
+// AgentMonitor periodically probes the trace agent so an unreachable
+// agent is reported instead of spans being dropped silently
+type AgentMonitor struct {
+	cfg     config.DatadogConfig
+	metrics statsd.ClientInterface
+
+	mu      sync.RWMutex
+	err     error
+	checked bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewAgentMonitor creates a monitor for the agent of cfg
+func NewAgentMonitor(cfg config.DatadogConfig, metrics statsd.ClientInterface) *AgentMonitor {
+	return &AgentMonitor{cfg: cfg, metrics: metrics}
+}
+
+// Start probes the agent once, then keeps probing in the background
+// unless the check interval is zero
+func (m *AgentMonitor) Start(ctx context.Context) error {
+	m.probe(ctx)
+	if m.cfg.AgentCheckInterval <= 0 {
+		return nil
+	}
+
+	ctx, m.cancel = context.WithCancel(context.Background())
+	m.done = make(chan struct{})
+	go func() {
+		defer close(m.done)
+		ticker := time.NewTicker(m.cfg.AgentCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.probe(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops the background probes
+func (m *AgentMonitor) Stop(ctx context.Context) error {
+	if m.cancel == nil {
+		return nil
+	}
+	m.cancel()
+	<-m.done
+	return nil
+}
+
+// Err returns the error of the last probe, nil when the agent answered
+func (m *AgentMonitor) Err() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.err
+}
+
+// probe checks the agent and logs when its reachability changes
+func (m *AgentMonitor) probe(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	err := ProbeAgent(ctx, m.cfg.TraceAgentURL)
+
+	m.mu.Lock()
+	wasReachable := m.checked && m.err == nil
+	first := !m.checked
+	m.err, m.checked = err, true
+	m.mu.Unlock()
+
+	switch {
+	case err != nil && (first || wasReachable):
+		log.Printf("WARNING: Datadog trace agent unreachable at %s, traces are being dropped: %v", m.cfg.TraceAgentURL, err)
+	case err == nil && !first && !wasReachable:
+		log.Printf("Datadog trace agent reachable again at %s", m.cfg.TraceAgentURL)
+	}
+
+	reachable := 0.0
+	if err == nil {
+		reachable = 1
+	}
+	// Only arrives when DogStatsD is up while the trace agent is not, as
+	// with APM disabled on the agent
+	m.metrics.Gauge("datadog.agent.reachable", reachable, nil, 1)
+}
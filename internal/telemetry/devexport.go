@@ -0,0 +1,105 @@
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// DevExporter stands in for the trace agent during development: it is the
+// tracer's HTTP transport and writes every span it receives as one JSON
+// line, so instrumentation can be inspected without an agent
+type DevExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+	// file is closed by Close; nil for stdout
+	file *os.File
+}
+
+// NewDevExporter writes to stdout when dest is "stdout", and appends to
+// the file dest otherwise
+func NewDevExporter(dest string) (*DevExporter, error) {
+	if dest == "stdout" {
+		return &DevExporter{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &DevExporter{w: f, file: f}, nil
+}
+
+// Client returns an HTTP client that delivers every tracer request to e
+func (e *DevExporter) Client() *http.Client {
+	return &http.Client{Transport: e}
+}
+
+// RoundTrip answers the agent endpoints the tracer calls. Trace payloads
+// are exported; telemetry and other posts are accepted and dropped.
+func (e *DevExporter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	switch {
+	case req.URL.Path == "/info":
+		return devResponse(req, http.StatusOK, `{"endpoints":["/v0.4/traces"]}`), nil
+	case strings.HasSuffix(req.URL.Path, "/traces"):
+		if err := e.export(req.Body); err != nil {
+			return devResponse(req, http.StatusBadRequest, err.Error()), nil
+		}
+		return devResponse(req, http.StatusOK, `{}`), nil
+	case req.Method == http.MethodPost:
+		return devResponse(req, http.StatusAccepted, ``), nil
+	default:
+		return devResponse(req, http.StatusNotFound, ``), nil
+	}
+}
+
+// export converts a MessagePack trace payload, a list of traces that are
+// each a list of spans, to one JSON line per span
+func (e *DevExporter) export(body io.Reader) error {
+	var buf bytes.Buffer
+	if _, err := msgp.CopyToJSON(&buf, body); err != nil {
+		return err
+	}
+	// Spans stay raw so 64-bit IDs are copied exactly
+	var traces [][]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &traces); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, spans := range traces {
+		for _, span := range spans {
+			if _, err := e.w.Write(append(span, '\n')); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Close closes the output file
+func (e *DevExporter) Close() error {
+	if e.file == nil {
+		return nil
+	}
+	return e.file.Close()
+}
+
+func devResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
@@ -0,0 +1,24 @@
+package telemetry
+
+import (
+	"github.com/DataDog/datadog-go/v5/statsd"
+
+	"datadog-golang-example/internal/config"
+)
+
+// metricsNamespace prefixes every custom metric emitted by the service
+const metricsNamespace = "go_api_demo."
+
+// NewStatsd creates a DogStatsD client tagged with the unified service
+// tags, sending to cfg.DogStatsDAddr over UDP or a unix:// socket; sending
+// never blocks request handling.
+func NewStatsd(cfg config.DatadogConfig) (*statsd.Client, error) {
+	return statsd.New(cfg.DogStatsDAddr,
+		statsd.WithNamespace(metricsNamespace),
+		statsd.WithTags([]string{
+			"env:" + cfg.Env,
+			"service:" + cfg.Service,
+			"version:" + cfg.Version,
+		}),
+	)
+}
@@ -0,0 +1,271 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/remoteconfig/state"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/config"
+	"datadog-golang-example/internal/logging"
+)
+
+// remoteConfigPath is the endpoint of the agent serving Remote
+// Configuration
+const remoteConfigPath = "/v0.7/config"
+
+// errRemoteConfigUnsupported is returned while the agent does not serve
+// Remote Configuration, being too old or having it disabled
+var errRemoteConfigUnsupported = errors.New("the agent does not serve Remote Configuration")
+
+// remoteSettings are the tracing settings of APM_TRACING, under the names
+// the Datadog UI sets them with. Each is kept as JSON, nil when unset.
+type remoteSettings struct {
+	Enabled       json.RawMessage `json:"tracing_enabled,omitempty"`
+	SamplingRate  json.RawMessage `json:"tracing_sampling_rate,omitempty"`
+	SamplingRules json.RawMessage `json:"tracing_sampling_rules,omitempty"`
+	HeaderTags    json.RawMessage `json:"tracing_header_tags,omitempty"`
+	Tags          json.RawMessage `json:"tracing_tags,omitempty"`
+}
+
+// byName returns the settings by name, leaving out the unset ones
+func (s remoteSettings) byName() map[string]string {
+	m := map[string]string{}
+	for name, v := range map[string]json.RawMessage{
+		"tracing_enabled":        s.Enabled,
+		"tracing_sampling_rate":  s.SamplingRate,
+		"tracing_sampling_rules": s.SamplingRules,
+		"tracing_header_tags":    s.HeaderTags,
+		"tracing_tags":           s.Tags,
+	} {
+		if len(v) > 0 && string(v) != "null" {
+			m[name] = string(v)
+		}
+	}
+	return m
+}
+
+// apmTracingConfig is the content of an APM_TRACING configuration file
+type apmTracingConfig struct {
+	LibConfig remoteSettings `json:"lib_config"`
+}
+
+// rcResponse is the answer of the agent to a poll of the tracer: the TUF
+// roots and signed targets, the files the tracer has not cached yet and
+// the paths of its configurations. It is empty while nothing changed.
+type rcResponse struct {
+	Roots         [][]byte `json:"roots"`
+	Targets       []byte   `json:"targets"`
+	TargetFiles   []rcFile `json:"target_files"`
+	ClientConfigs []string `json:"client_configs"`
+}
+
+type rcFile struct {
+	Path string `json:"path"`
+	Raw  []byte `json:"raw"`
+}
+
+// RemoteConfigWatcher follows the tracing settings set for the service
+// from the Datadog UI through Remote Configuration. It is no client of its
+// own: it sits in the transport of the tracer and reads the answers to the
+// polls the tracer sends, to log and audit each change and to suspend the
+// local sample rate while a remote one replaces it. The answers go through
+// a client repository as the tracer's do, which checks the files against
+// the hashes of the targets, and the targets against their signatures
+// when a TUF root is set. Without Remote Configuration on the agent the
+// local settings stay in effect.
+type RemoteConfigWatcher struct {
+	tracer *Tracer
+	base   http.RoundTripper
+
+	mu       sync.Mutex
+	repo     *state.Repository
+	settings map[string]string
+	// err is the error of the last poll, logged when it changes
+	err     error
+	checked bool
+}
+
+// NewRemoteConfigWatcher creates a watcher of the settings tr applies,
+// reading the polls of tr once it starts. The targets are verified
+// against the TUF root of cfg, if any.
+func NewRemoteConfigWatcher(cfg config.DatadogConfig, tr *Tracer) (*RemoteConfigWatcher, error) {
+	var repo *state.Repository
+	var err error
+	if cfg.RemoteConfigTUFRoot != "" {
+		repo, err = state.NewRepository([]byte(cfg.RemoteConfigTUFRoot))
+	} else {
+		repo, err = state.NewUnverifiedRepository()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("remote config repository: %w", err)
+	}
+	w := &RemoteConfigWatcher{tracer: tr, repo: repo, settings: map[string]string{}}
+	tr.remoteConfig = w
+	return w, nil
+}
+
+// wrap returns the transport of the tracer sending through base, the
+// default transport when nil, and reading the polls of the tracer
+func (w *RemoteConfigWatcher) wrap(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	w.base = base
+	return w
+}
+
+// RoundTrip sends a request of the tracer and reads the answer when it is
+// a poll, leaving the tracer its body to read in turn
+func (w *RemoteConfigWatcher) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := w.base.RoundTrip(req)
+	if !strings.HasSuffix(req.URL.Path, remoteConfigPath) {
+		return resp, err
+	}
+	if err != nil {
+		w.observe(req.Context(), rcResponse{}, err)
+		return nil, err
+	}
+	update, readErr := readUpdate(resp)
+	w.observe(req.Context(), update, readErr)
+	return resp, nil
+}
+
+// readUpdate decodes the answer to a poll, putting its body back
+func readUpdate(resp *http.Response) (rcResponse, error) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return rcResponse{}, errRemoteConfigUnsupported
+	default:
+		return rcResponse{}, fmt.Errorf("agent answered %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return rcResponse{}, fmt.Errorf("read Remote Configuration: %w", err)
+	}
+	var r rcResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return rcResponse{}, fmt.Errorf("decode Remote Configuration: %w", err)
+	}
+	return r, nil
+}
+
+// observe applies what an answer to a poll changed, logging when Remote
+// Configuration becomes unavailable or available again. An update the
+// repository rejects is ignored, the settings staying as they were.
+func (w *RemoteConfigWatcher) observe(ctx context.Context, update rcResponse, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case err != nil && (!w.checked || w.err == nil):
+		log.Printf("WARNING: Datadog Remote Configuration unavailable, tracing keeps its local settings: %v", err)
+	case err == nil && w.checked && w.err != nil:
+		log.Printf("Datadog Remote Configuration available again")
+	}
+	w.err, w.checked = err, true
+	if err != nil || len(update.Targets) == 0 {
+		return
+	}
+
+	files := make(map[string][]byte, len(update.TargetFiles))
+	for _, f := range update.TargetFiles {
+		files[f.Path] = f.Raw
+	}
+	if _, err := w.repo.Update(state.Update{
+		TUFRoots:      update.Roots,
+		TUFTargets:    update.Targets,
+		TargetFiles:   files,
+		ClientConfigs: update.ClientConfigs,
+	}); err != nil {
+		log.Printf("WARNING: Ignoring Remote Configuration update: %v", err)
+		return
+	}
+	settings, err := w.mergeSettings()
+	if err != nil {
+		log.Printf("WARNING: Ignoring Remote Configuration update: %v", err)
+		return
+	}
+	w.apply(ctx, settings)
+}
+
+// mergeSettings merges the APM_TRACING configurations of the repository,
+// in path order, as the tracer applies each of them in turn. No
+// configuration leaves every setting local.
+func (w *RemoteConfigWatcher) mergeSettings() (map[string]string, error) {
+	configs := w.repo.GetConfigs(state.ProductAPMTracing)
+	merged := map[string]string{}
+	for _, path := range slices.Sorted(maps.Keys(configs)) {
+		var c apmTracingConfig
+		if err := json.Unmarshal(configs[path].Config, &c); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		maps.Copy(merged, c.LibConfig.byName())
+	}
+	return merged, nil
+}
+
+// apply logs and audits the settings that changed, and suspends or
+// restores the local sample rate as a remote one is set or removed. Each
+// update is traced, with the changed settings tagged on the span.
+func (w *RemoteConfigWatcher) apply(ctx context.Context, settings map[string]string) {
+	all := slices.Collect(maps.Keys(settings))
+	for name := range w.settings {
+		if _, ok := settings[name]; !ok {
+			all = append(all, name)
+		}
+	}
+	slices.Sort(all)
+	var names []string
+	for _, name := range all {
+		old, new := w.settings[name], settings[name]
+		if old == new {
+			continue
+		}
+		names = append(names, name)
+		logging.Audit("Applied setting", "source", "remote_config", "setting", name, "old", orLocal(old), "new", orLocal(new))
+	}
+	w.settings = settings
+	if len(names) == 0 {
+		return
+	}
+
+	span, _ := tracer.StartSpanFromContext(ctx, "config.remote", tracer.ResourceName(state.ProductAPMTracing))
+	defer span.Finish()
+	if current, err := w.repo.CurrentState(); err == nil {
+		span.SetTag("config.version", current.TargetsVersion)
+	}
+	span.SetTag("config.changes", len(names))
+	span.SetTag("config.settings", names)
+
+	var rate *float64
+	if v, ok := settings["tracing_sampling_rate"]; ok {
+		var r float64
+		if err := json.Unmarshal([]byte(v), &r); err == nil {
+			rate = &r
+		}
+	}
+	w.tracer.setRemoteSampleRate(rate)
+}
+
+// orLocal returns v, or "local" for a setting left to the local
+// configuration
+func orLocal(v string) string {
+	if v == "" {
+		return "local"
+	}
+	return v
+}
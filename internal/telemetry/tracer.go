@@ -0,0 +1,167 @@
+// Package telemetry configures the Datadog tracer and metrics client.
+package telemetry
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/config"
+)
+
+// Tracer starts and stops the Datadog tracer
+type Tracer struct {
+	cfg     config.DatadogConfig
+	agent   *AgentMonitor
+	sampler tracer.RateSampler
+	dev     *DevExporter
+	// remoteConfig reads the polls of the tracer, when watched
+	remoteConfig *RemoteConfigWatcher
+
+	// localRate is the sample rate of the local settings and remoteRate
+	// the one set from the Datadog UI, which suspends it while set
+	mu         sync.Mutex
+	localRate  float64
+	remoteRate *float64
+
+	flushes flushCounter
+	started bool
+}
+
+// NewTracer creates a Tracer for the given service settings. agent must
+// be started first.
+func NewTracer(cfg config.DatadogConfig, agent *AgentMonitor) *Tracer {
+	return &Tracer{cfg: cfg, agent: agent, sampler: tracer.NewRateSampler(1), localRate: 1}
+}
+
+// Start starts the global Datadog tracer. With NoopFallback and no
+// reachable agent the tracer is left unstarted, so spans are no-ops. With
+// DevExport the spans are written locally and the agent is not needed.
+func (t *Tracer) Start(ctx context.Context) error {
+	opts := []tracer.StartOption{
+		tracer.WithAgentURL(t.cfg.TraceAgentURL),
+		tracer.WithService(t.cfg.Service),
+		tracer.WithEnv(t.cfg.Env),
+		tracer.WithServiceVersion(t.cfg.Version),
+		tracer.WithSampler(t.sampler),
+	}
+	switch {
+	case t.cfg.DevExport != "":
+		dev, err := NewDevExporter(t.cfg.DevExport)
+		if err != nil {
+			return err
+		}
+		t.dev = dev
+		opts = append(opts, tracer.WithHTTPClient(tracerClient(dev, &t.flushes)))
+		log.Printf("Exporting spans to %s instead of the agent", t.cfg.DevExport)
+	case t.cfg.NoopFallback && t.agent.Err() != nil:
+		log.Printf("WARNING: Falling back to a no-op tracer, restart once the agent is reachable")
+		return nil
+	default:
+		// The client reaches a Unix socket itself, the tracer only one of
+		// its http URLs
+		client, base := agentClient(t.cfg.TraceAgentURL)
+		transport := client.Transport
+		if t.remoteConfig != nil {
+			transport = t.remoteConfig.wrap(transport)
+		}
+		opts = append(opts, tracer.WithAgentURL(base), tracer.WithHTTPClient(tracerClient(transport, &t.flushes)))
+	}
+	t.started = true
+	return tracer.Start(opts...)
+}
+
+// SampleRate returns the share of traces kept by the local settings
+func (t *Tracer) SampleRate() float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.localRate
+}
+
+// SetSampleRate changes the share of traces kept by the local settings,
+// between 0 and 1. Traces dropped here are never sent to the agent. While
+// a remote sample rate is set the change only takes effect once it is
+// removed.
+func (t *Tracer) SetSampleRate(rate float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.localRate = rate
+	if t.remoteRate == nil {
+		t.sampler.SetRate(rate)
+	}
+}
+
+// RemoteSampleRate returns the sample rate set from the Datadog UI, if
+// any
+func (t *Tracer) RemoteSampleRate() (float64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.remoteRate == nil {
+		return 0, false
+	}
+	return *t.remoteRate, true
+}
+
+// setRemoteSampleRate records the sample rate set from the Datadog UI,
+// nil once removed. The tracer applies it to the traces the local sampler
+// keeps, so the local sampler keeps every trace meanwhile rather than
+// compounding the two rates.
+func (t *Tracer) setRemoteSampleRate(rate *float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remoteRate = rate
+	if rate != nil {
+		t.sampler.SetRate(1)
+	} else {
+		t.sampler.SetRate(t.localRate)
+	}
+}
+
+// Stop flushes pending spans and stops the tracer
+func (t *Tracer) Stop(ctx context.Context) error {
+	if t.started {
+		tracer.Stop()
+	}
+	if t.dev != nil {
+		return t.dev.Close()
+	}
+	return nil
+}
+
+// Flushes returns the counts of the trace payloads sent so far
+func (t *Tracer) Flushes() FlushStats {
+	return t.flushes.stats()
+}
+
+// Started reports whether the tracer runs, false after falling back to
+// no-op spans
+func (t *Tracer) Started() bool {
+	return t.started
+}
+
+// defaultPropagation is the propagation style of the tracer when none is
+// set
+const defaultPropagation = "datadog,tracecontext,baggage"
+
+// PropagationStyles returns the styles the tracer injects and extracts
+// trace headers with, read from the environment as the tracer does
+func PropagationStyles() (inject, extract string) {
+	style := os.Getenv("DD_TRACE_PROPAGATION_STYLE")
+	if style == "" {
+		style = os.Getenv("OTEL_PROPAGATORS")
+	}
+	if style == "" {
+		style = defaultPropagation
+	}
+	inject, extract = os.Getenv("DD_TRACE_PROPAGATION_STYLE_INJECT"), os.Getenv("DD_TRACE_PROPAGATION_STYLE_EXTRACT")
+	if inject == "" {
+		inject = style
+	}
+	if extract == "" {
+		extract = style
+	}
+	return inject, extract
+}
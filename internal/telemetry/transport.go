@@ -0,0 +1,130 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"datadog-golang-example/internal/config"
+)
+
+// socketPath returns the path of a unix:// agent address
+func socketPath(addr string) (string, bool) {
+	return strings.CutPrefix(addr, "unix://")
+}
+
+// CheckTransport validates the agent addresses of cfg and logs the
+// transport chosen for traces and metrics. A socket that does not exist
+// yet only warns, since the agent may create it after the app starts.
+func CheckTransport(cfg config.DatadogConfig) error {
+	for _, t := range []struct{ kind, addr string }{
+		{"traces", cfg.TraceAgentURL},
+		{"metrics", cfg.DogStatsDAddr},
+	} {
+		path, uds := socketPath(t.addr)
+		if !uds {
+			log.Printf("Sending %s to the Datadog agent over the network at %s", t.kind, t.addr)
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("datadog %s socket %q must be an absolute path", t.kind, path)
+		}
+		log.Printf("Sending %s to the Datadog agent over the Unix socket %s", t.kind, path)
+		if err := SocketExists(path); err != nil {
+			log.Printf("WARNING: %v", err)
+		}
+	}
+	return nil
+}
+
+// SocketExists checks that path is a Unix socket
+func SocketExists(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("datadog socket unavailable: %w", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("datadog socket %s is not a socket", path)
+	}
+	return nil
+}
+
+// agentClient returns an HTTP client and base URL reaching the trace
+// agent at url, over its Unix socket when url is unix://
+func agentClient(url string) (*http.Client, string) {
+	path, uds := socketPath(url)
+	if !uds {
+		return http.DefaultClient, strings.TrimSuffix(url, "/")
+	}
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}, "http://localhost"
+}
+
+// tracerTimeout bounds each request of the tracer to the agent, as the
+// tracer's own client does
+const tracerTimeout = 10 * time.Second
+
+// FlushStats counts the trace payloads the tracer sent to the agent. A
+// payload that failed may be retried, and each attempt counts.
+type FlushStats struct {
+	Payloads       int64
+	Traces         int64
+	FailedPayloads int64
+	FailedTraces   int64
+}
+
+// flushCounter is the transport of the tracer, counting the trace
+// payloads going through it by the trace count header the tracer sets
+type flushCounter struct {
+	base http.RoundTripper
+
+	payloads, traces, failedPayloads, failedTraces atomic.Int64
+}
+
+// RoundTrip counts the trace payloads and their outcome
+func (f *flushCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/traces") {
+		return f.base.RoundTrip(req)
+	}
+	traces, _ := strconv.ParseInt(req.Header.Get("X-Datadog-Trace-Count"), 10, 64)
+	resp, err := f.base.RoundTrip(req)
+	if err != nil || resp.StatusCode >= http.StatusBadRequest {
+		f.failedPayloads.Add(1)
+		f.failedTraces.Add(traces)
+	} else {
+		f.payloads.Add(1)
+		f.traces.Add(traces)
+	}
+	return resp, err
+}
+
+// stats returns the counts so far
+func (f *flushCounter) stats() FlushStats {
+	return FlushStats{
+		Payloads:       f.payloads.Load(),
+		Traces:         f.traces.Load(),
+		FailedPayloads: f.failedPayloads.Load(),
+		FailedTraces:   f.failedTraces.Load(),
+	}
+}
+
+// tracerClient returns the HTTP client of the tracer sending through base,
+// the default transport when nil, and counting the payloads in f
+func tracerClient(base http.RoundTripper, f *flushCounter) *http.Client {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	f.base = base
+	return &http.Client{Timeout: tracerTimeout, Transport: f}
+}
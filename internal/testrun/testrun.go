@@ -0,0 +1,36 @@
+// Package testrun runs the test suites under Datadog Test Optimization, so
+// test runs, durations and flaky tests show in Datadog, when
+// DD_CIVISIBILITY_ENABLED is true.
+package testrun
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	_ "unsafe" // for go:linkname
+
+	// Links the test instrumentation of the tracer, which Orchestrion
+	// would otherwise inject
+	_ "github.com/DataDog/dd-trace-go/v2/civisibility"
+)
+
+// instrumentTestingM wraps the tests and benchmarks of m in CI Visibility
+// spans and returns the function closing the test session. The tracer
+// only exposes it to Orchestrion, which links it the same way.
+//
+//go:linkname instrumentTestingM github.com/DataDog/dd-trace-go/v2/internal/civisibility/integrations/gotesting.instrumentTestingM
+func instrumentTestingM(m *testing.M) func(exitCode int)
+
+// Main runs the tests of m and exits with their status. With
+// DD_CIVISIBILITY_ENABLED true, they are reported to Datadog as a test
+// session, through the agent or, with DD_CIVISIBILITY_AGENTLESS_ENABLED
+// and DD_API_KEY, directly.
+func Main(m *testing.M) {
+	if enabled, _ := strconv.ParseBool(os.Getenv("DD_CIVISIBILITY_ENABLED")); !enabled {
+		os.Exit(m.Run())
+	}
+	finish := instrumentTestingM(m)
+	code := m.Run()
+	finish(code)
+	os.Exit(code)
+}
@@ -0,0 +1,180 @@
+// Package worker runs non-critical background tasks, such as webhooks,
+// cache warming and emails, on a bounded pool of goroutines.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/DataDog/dd-trace-go/v2/ddtrace/tracer"
+
+	"datadog-golang-example/internal/config"
+)
+
+// Submission errors
+var (
+	ErrQueueFull = errors.New("worker: queue is full")
+	ErrStopped   = errors.New("worker: pool is stopped")
+)
+
+// job is a queued task with the context needed to trace it
+type job struct {
+	name     string
+	task     func(ctx context.Context) error
+	link     *tracer.SpanLink
+	enqueued time.Time
+}
+
+// Pool executes tasks on a fixed number of workers fed by a bounded
+// queue. A task does not continue the trace that submitted it, since it
+// outlives the request; its span links back to the submitting span.
+type Pool struct {
+	cfg     config.WorkerConfig
+	metrics statsd.ClientInterface
+	jobs    chan job
+	wg      sync.WaitGroup
+
+	// running and done count the tasks being run and run
+	running atomic.Int64
+	done    atomic.Int64
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+// Stats counts the tasks of a pool
+type Stats struct {
+	Queued  int
+	Running int64
+	Done    int64
+}
+
+// NewPool creates a pool; no worker runs until Start is called
+func NewPool(cfg config.WorkerConfig, metrics statsd.ClientInterface) *Pool {
+	return &Pool{
+		cfg:     cfg,
+		metrics: metrics,
+		jobs:    make(chan job, cfg.QueueSize),
+	}
+}
+
+// Start launches the workers
+func (p *Pool) Start(ctx context.Context) error {
+	for i := 0; i < max(p.cfg.Size, 1); i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return nil
+}
+
+// Stop stops accepting tasks and waits for the queued ones to finish, or
+// for ctx to expire
+func (p *Pool) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the tasks queued, being run and run so far
+func (p *Pool) Stats() Stats {
+	return Stats{Queued: len(p.jobs), Running: p.running.Load(), Done: p.done.Load()}
+}
+
+// Submit queues task without blocking. It returns ErrQueueFull when the
+// queue is at capacity, so callers decide whether the work can be dropped.
+func (p *Pool) Submit(ctx context.Context, name string, task func(ctx context.Context) error) error {
+	j := job{name: name, task: task, enqueued: time.Now()}
+	if span, ok := tracer.SpanFromContext(ctx); ok {
+		sc := span.Context()
+		j.link = &tracer.SpanLink{
+			TraceID:     sc.TraceIDLower(),
+			TraceIDHigh: sc.TraceIDUpper(),
+			SpanID:      sc.SpanID(),
+			Attributes:  map[string]string{"link.kind": "submitted_by"},
+		}
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		return ErrStopped
+	}
+	select {
+	case p.jobs <- j:
+		p.metrics.Gauge("worker.queue.depth", float64(len(p.jobs)), nil, 1)
+		return nil
+	default:
+		p.metrics.Incr("worker.tasks", []string{"task:" + name, "outcome:rejected"}, 1)
+		return ErrQueueFull
+	}
+}
+
+// work runs queued jobs until the queue is closed
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for j := range p.jobs {
+		p.metrics.Gauge("worker.queue.depth", float64(len(p.jobs)), nil, 1)
+		p.running.Add(1)
+		p.run(j)
+		p.running.Add(-1)
+		p.done.Add(1)
+	}
+}
+
+// run executes one job in its own trace, linked to the submitting span
+func (p *Pool) run(j job) {
+	tags := []string{"task:" + j.name}
+	p.metrics.Distribution("worker.task.wait", float64(time.Since(j.enqueued).Milliseconds()), tags, 1)
+
+	opts := []tracer.StartSpanOption{tracer.ResourceName(j.name), tracer.SpanType("worker")}
+	if j.link != nil {
+		opts = append(opts, tracer.WithSpanLinks([]tracer.SpanLink{*j.link}))
+	}
+	span, ctx := tracer.StartSpanFromContext(context.Background(), "worker.task", opts...)
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.TaskTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := p.call(ctx, j)
+	span.Finish(tracer.WithError(err))
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		log.Printf("Task %s failed: %v", j.name, err)
+	}
+	p.metrics.Distribution("worker.task.duration", float64(time.Since(start).Milliseconds()), tags, 1)
+	p.metrics.Incr("worker.tasks", append(tags, "outcome:"+outcome), 1)
+}
+
+// call runs the task, turning a panic into an error so one bad task
+// cannot kill a worker
+func (p *Pool) call(ctx context.Context, j job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.task(ctx)
+}